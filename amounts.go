@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// AmountConfig replaces the single log-normal amount distribution with one
+// whose parameters depend on the record's channel and city. The record's
+// standard normal draw is shared, so only the parameters change between
+// cohorts.
+type AmountConfig struct {
+	// Median and Sigma describe the base log-normal distribution.
+	Median float64 `json:"median"`
+	Sigma  float64 `json:"sigma"`
+	// Channels and Cities adjust the base distribution for records of the
+	// given channel or city; both adjustments apply when both match.
+	Channels map[string]AmountAdjustment `json:"channels,omitempty"`
+	Cities   map[string]AmountAdjustment `json:"cities,omitempty"`
+}
+
+// AmountAdjustment scales the median of the amount distribution and
+// optionally widens or narrows it.
+type AmountAdjustment struct {
+	Scale float64 `json:"scale"`
+	// Spread multiplies sigma; zero leaves it unchanged.
+	Spread float64 `json:"spread,omitempty"`
+}
+
+var defaultAmountConfig = AmountConfig{
+	Median: 20,
+	Sigma:  0.35,
+	Channels: map[string]AmountAdjustment{
+		"offline":    {Scale: 2.5, Spread: 1.3},
+		"callcenter": {Scale: 1.5},
+		"mobile":     {Scale: 0.8},
+	},
+	Cities: map[string]AmountAdjustment{
+		"Москва":          {Scale: 1.4, Spread: 1.2},
+		"Санкт-Петербург": {Scale: 1.2},
+		"Алматы":          {Scale: 0.9},
+	},
+}
+
+func (c *AmountConfig) clone() *AmountConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Channels = cloneAdjustments(c.Channels)
+	out.Cities = cloneAdjustments(c.Cities)
+	return &out
+}
+
+func cloneAdjustments(m map[string]AmountAdjustment) map[string]AmountAdjustment {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]AmountAdjustment, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func (c *AmountConfig) validate() error {
+	if c.Median <= 0 {
+		return errors.New("amounts.median must be positive")
+	}
+	if c.Sigma < 0 {
+		return errors.New("amounts.sigma must not be negative")
+	}
+	for name, m := range map[string]map[string]AmountAdjustment{"channels": c.Channels, "cities": c.Cities} {
+		for key, adj := range m {
+			if adj.Scale <= 0 || adj.Spread < 0 {
+				return fmt.Errorf("amounts.%s[%s]: scale must be positive and spread not negative", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// amount maps a standard normal draw to an amount for the given cohort.
+func (c *AmountConfig) amount(normal float64, channel, city string) float64 {
+	median, sigma := c.Median, c.Sigma
+	for _, adj := range []AmountAdjustment{c.Channels[channel], c.Cities[city]} {
+		if adj.Scale == 0 {
+			continue
+		}
+		median *= adj.Scale
+		if adj.Spread != 0 {
+			sigma *= adj.Spread
+		}
+	}
+	return math.Round(median*math.Exp(normal*sigma)*100) / 100
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Anonymizer replaces PII values with deterministic keyed substitutes: the
+// same input and salt always give the same output, so records that shared an
+// identifier before still share one afterwards.
+type Anonymizer struct {
+	salt   []byte
+	mode   string
+	fields map[string]bool
+}
+
+const (
+	anonHash  = "hash"
+	anonToken = "token"
+)
+
+var defaultAnonFields = []string{"firstName", "lastName", "email", "emailCanonical", "phone", "login"}
+
+func NewAnonymizer(salt, mode string, fields []string) (*Anonymizer, error) {
+	if mode != anonHash && mode != anonToken {
+		return nil, fmt.Errorf("unknown mode %q (want %s or %s)", mode, anonHash, anonToken)
+	}
+	a := &Anonymizer{salt: []byte(salt), mode: mode, fields: make(map[string]bool)}
+	for _, f := range fields {
+		a.fields[f] = true
+	}
+	return a, nil
+}
+
+// Value anonymizes a single value. The field name is deliberately not part
+// of the key: an email stored as "email" in one file and "contact" in
+// another must map to the same token.
+func (a *Anonymizer) Value(value string) string {
+	if value == "" {
+		return value
+	}
+	m := hmac.New(sha256.New, a.salt)
+	m.Write([]byte(value))
+	sum := m.Sum(nil)
+	if a.mode == anonHash {
+		return hex.EncodeToString(sum[:16])
+	}
+
+	rng := NewSplitMix64(binary.LittleEndian.Uint64(sum))
+	if local, domain, ok := strings.Cut(value, "@"); ok {
+		return preserveFormat(rng, local) + "@" + domain
+	}
+	if strings.HasPrefix(value, "+") && len(value) > 2 {
+		// Keep the country code so phones stay routable to the same region.
+		return value[:2] + preserveFormat(rng, value[2:])
+	}
+	return preserveFormat(rng, value)
+}
+
+// preserveFormat swaps every letter and digit for a pseudorandom one of the
+// same class (Latin/Cyrillic, case, digit) and keeps everything else, so
+// tokens still pass format validation.
+func preserveFormat(rng *SplitMix64, s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune('0' + rune(rng.NextInt(10)))
+		case r >= 'a' && r <= 'z':
+			b.WriteRune('a' + rune(rng.NextInt(26)))
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune('A' + rune(rng.NextInt(26)))
+		case r >= 'а' && r <= 'я':
+			b.WriteRune('а' + rune(rng.NextInt(32)))
+		case r >= 'А' && r <= 'Я':
+			b.WriteRune('А' + rune(rng.NextInt(32)))
+		case unicode.IsLetter(r):
+			b.WriteRune('x')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Line anonymizes one JSON object, keeping its key order.
+func (a *Anonymizer) Line(line []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("expected a JSON object")
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for first := true; dec.More(); first = false {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		if a.fields[key] {
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				raw, _ = json.Marshal(a.Value(s))
+			}
+		}
+
+		if !first {
+			out.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		out.Write(k)
+		out.WriteByte(':')
+		out.Write(raw)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+func (a *Anonymizer) Stream(in io.Reader, out io.Writer) error {
+	bw := bufio.NewWriterSize(out, 1<<16)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		res, err := a.Line(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		bw.Write(res)
+		bw.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func runAnonymize(args []string) error {
+	fs := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	salt := fs.String("salt", os.Getenv("ANON_SALT"), "secret salt (default $ANON_SALT)")
+	mode := fs.String("mode", anonToken, "\"token\" for format-preserving tokens, \"hash\" for salted hex hashes")
+	fields := fs.String("fields", strings.Join(defaultAnonFields, ","), "comma-separated fields to anonymize")
+	output := fs.String("output", "-", "output file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *salt == "" {
+		return errors.New("a -salt (or $ANON_SALT) is required; without it tokens are reversible by dictionary")
+	}
+
+	anon, err := NewAnonymizer(*salt, *mode, strings.Split(*fields, ","))
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if fs.NArg() == 0 {
+		return anon.Stream(os.Stdin, out)
+	}
+	for _, path := range fs.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = anon.Stream(file, out)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// availabilityFields are the record fields a channel can lack, with how to
+// blank them.
+var availabilityFields = map[string]func(*RawRecord){
+	"firstName":   func(r *RawRecord) { r.FirstName = "" },
+	"lastName":    func(r *RawRecord) { r.LastName = "" },
+	"email":       func(r *RawRecord) { r.Email, r.EmailCanonical = "", "" },
+	"phone":       func(r *RawRecord) { r.Phone = "" },
+	"login":       func(r *RawRecord) { r.Login = "" },
+	"pointOfSale": func(r *RawRecord) { r.PointOfSale = "" },
+	"city":        func(r *RawRecord) { r.City = "" },
+	"device":      func(r *RawRecord) { r.Device = "" },
+}
+
+// defaultFieldAvailability mirrors typical sources: offline purchases have
+// no online identity, online channels no point of sale, and call-center
+// agents often skip the email.
+var defaultFieldAvailability = map[string]map[string]float64{
+	"offline":    {"email": 0, "login": 0},
+	"web":        {"pointOfSale": 0},
+	"mobile":     {"pointOfSale": 0},
+	"callcenter": {"email": 0.6, "login": 0, "pointOfSale": 0},
+}
+
+func cloneAvailability(m map[string]map[string]float64) map[string]map[string]float64 {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]map[string]float64, len(m))
+	for channel, fields := range m {
+		out[channel] = make(map[string]float64, len(fields))
+		for field, rate := range fields {
+			out[channel][field] = rate
+		}
+	}
+	return out
+}
+
+func validateAvailability(m map[string]map[string]float64) error {
+	for channel, fields := range m {
+		for field, rate := range fields {
+			if _, ok := availabilityFields[field]; !ok {
+				return fmt.Errorf("fieldAvailability[%s]: unknown field %q", channel, field)
+			}
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("fieldAvailability[%s][%s] must be in [0, 1]", channel, field)
+			}
+		}
+	}
+	return nil
+}
+
+// applyAvailability blanks the fields the record's channel does not capture.
+func applyAvailability(rec *RawRecord, m map[string]map[string]float64, seed uint64) {
+	fields := m[rec.Channel]
+	if len(fields) == 0 {
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	// Map order is random; draws must not be.
+	sort.Strings(names)
+
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("avail:%d", rec.RecordIndex)), seed))
+	for _, field := range names {
+		if rng.NextFloat() >= fields[field] {
+			availabilityFields[field](rec)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+//go:build beam
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/textio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/runners"
+
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/exec/optimized"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/gcs"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/flink"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/prism"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/spark"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/universal"
+)
+
+// Apache Beam: the dataset as a splittable DoFn, so a pipeline on Flink,
+// Spark or Prism reads records straight from the generator. The
+// restriction is an OffsetRange claimed through an OffsetTracker, as in the
+// source command, so the runner splits and checkpoints reads by record
+// index and a retried bundle re-derives the same records. The SDK is large
+// and replaces main in worker processes, so it is built only with
+// -tags beam; runners that start workers need a -tags beam binary passed
+// as --worker_binary, since the SDK's own cross-compile does not set tags.
+//
+// The portable runners are linked in, with Prism, which runs in process,
+// as the default. Dataflow's runner is not: it registers global flags, such
+// as -update, that collide with the generator's test flags.
+//
+// The beam command writes a text file, which needs a bounded input, so
+// only bounded ranges are read here; unbounded reads stay with the source
+// command.
+
+func init() {
+	register.DoFn3x1[*sdf.LockRTracker, beamSourceSpec, func(uint64, []byte), error](&generatorSourceFn{})
+	register.Emitter2[uint64, []byte]()
+	register.Function2x1(beamRecordLine)
+	beam.RegisterType(reflect.TypeOf(beamSourceSpec{}))
+	beam.RegisterType(reflect.TypeOf(OffsetRange{}))
+	commands["beam"] = command{summary: "run a Beam pipeline reading a range through a splittable source into a JSONL file", run: runBeam}
+
+	// The runner starts workers as the pipeline binary with --worker=true
+	// and no command.
+	if slices.Contains(os.Args[1:], "--worker=true") {
+		platformMain = func() {
+			flag.Parse()
+			beam.Init()
+		}
+	}
+}
+
+// beamSourceSpec is the element the source expands into records: a
+// config, as JSON, and the range to read in up to Splits initial parts.
+type beamSourceSpec struct {
+	Config string
+	Range  OffsetRange
+	Splits int
+}
+
+// generatorSourceFn emits the records of a beamSourceSpec's range as
+// record index and JSON document.
+type generatorSourceFn struct {
+	config string
+	gen    *IdempotentGenerator
+}
+
+func (fn *generatorSourceFn) CreateInitialRestriction(spec beamSourceSpec) OffsetRange {
+	return spec.Range
+}
+
+func (fn *generatorSourceFn) SplitRestriction(spec beamSourceSpec, r OffsetRange) []OffsetRange {
+	return r.Split(spec.Splits)
+}
+
+func (fn *generatorSourceFn) RestrictionSize(_ beamSourceSpec, r OffsetRange) float64 {
+	return r.Size()
+}
+
+func (fn *generatorSourceFn) CreateTracker(r OffsetRange) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(NewOffsetTracker(r))
+}
+
+// generator returns the generator of spec's config, built once per DoFn
+// instance and config.
+func (fn *generatorSourceFn) generator(spec beamSourceSpec) (*IdempotentGenerator, error) {
+	if fn.gen != nil && fn.config == spec.Config {
+		return fn.gen, nil
+	}
+	var cfg GeneratorConfig
+	if err := json.Unmarshal([]byte(spec.Config), &cfg); err != nil {
+		return nil, fmt.Errorf("beam source config: %w", err)
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fn.config, fn.gen = spec.Config, gen
+	return gen, nil
+}
+
+func (fn *generatorSourceFn) ProcessElement(rt *sdf.LockRTracker, spec beamSourceSpec, emit func(uint64, []byte)) error {
+	gen, err := fn.generator(spec)
+	if err != nil {
+		return err
+	}
+	r := rt.GetRestriction().(OffsetRange)
+	if !r.bounded() {
+		return errors.New("beam source: unbounded ranges are read with the source command")
+	}
+	schema := gen.Schema()
+	for idx := r.Start; rt.TryClaim(idx); idx++ {
+		rec := gen.RecordByIndex(idx)
+		emit(idx, schema.AppendJSON(nil, &rec))
+	}
+	return rt.GetError()
+}
+
+// readGeneratorSource returns the records of spec's range as a
+// PCollection<KV<uint64, []byte>> of record index and JSON document.
+func readGeneratorSource(s beam.Scope, spec beamSourceSpec) beam.PCollection {
+	s = s.Scope("generator.Read")
+	return beam.ParDo(s, &generatorSourceFn{}, beam.Create(s, spec))
+}
+
+func beamRecordLine(_ uint64, doc []byte) string {
+	return string(doc)
+}
+
+func runBeam(args []string) error {
+	fs := flag.NewFlagSet("beam", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	splits := fs.Int("splits", 16, "initial splits of the range; runners split further while reading")
+	output := fs.String("output", "output/beam/records.jsonl", "JSONL file the pipeline writes")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: generator beam [flags] [-- beam pipeline options, e.g. --runner=flink --endpoint=localhost:8099 --worker_binary=gen]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *count == 0 || *start+*count < *start {
+		return errors.New("-count must be positive and the range must not overflow")
+	}
+	if err := flag.CommandLine.Parse(fs.Args()); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	if _, err := NewIdempotentGenerator(cfg); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	beam.Init()
+	p, s := beam.NewPipelineWithRoot()
+	records := readGeneratorSource(s, beamSourceSpec{
+		Config: string(data),
+		Range:  OffsetRange{*start, *start + *count},
+		Splits: *splits,
+	})
+	textio.Write(s, *output, beam.ParDo(s, beamRecordLine, records))
+
+	ctx, stop := interruptContext()
+	defer stop()
+	runner := *runners.Runner
+	if runner == "" {
+		runner = "prism"
+	}
+	if _, err := beam.Run(ctx, runner, p); err != nil {
+		return err
+	}
+	logFor("beam").Info("pipeline done", "output", *output, "records", *count, "configHash", configHash(cfg))
+	return nil
+}
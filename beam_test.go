@@ -0,0 +1,71 @@
+//go:build beam
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	register.Function2x1(beamRecordIndex)
+}
+
+func beamRecordIndex(idx uint64, _ []byte) uint64 {
+	return idx
+}
+
+func TestBeamSource(t *testing.T) {
+	cfg := cloneConfig(defaultConfig)
+	data, _ := json.Marshal(cfg)
+	const start, count = 700, 2500
+
+	beam.Init()
+	p, s := beam.NewPipelineWithRoot()
+	records := readGeneratorSource(s, beamSourceSpec{Config: string(data), Range: OffsetRange{start, start + count}, Splits: 4})
+	want := make([]uint64, count)
+	for i := range want {
+		want[i] = start + uint64(i)
+	}
+	passert.EqualsList(s, beam.ParDo(s, beamRecordIndex, records), want)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestBeamCommand(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "records.jsonl")
+	if err := runBeam([]string{"-start", "40", "-count", "300", "-splits", "3", "-output", output, "--", "--runner=prism"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 300 {
+		t.Fatalf("pipeline wrote %d lines, want 300", len(lines))
+	}
+	seen := make(map[uint64]bool)
+	for _, line := range lines {
+		var rec struct {
+			RecordIndex uint64 `json:"recordIndex"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		want := gen.RecordByIndex(rec.RecordIndex)
+		if rec.RecordIndex < 40 || rec.RecordIndex >= 340 || seen[rec.RecordIndex] || !bytes.Equal([]byte(line), gen.Schema().AppendJSON(nil, &want)) {
+			t.Fatalf("line for record %d is out of range, repeated or differs from the generator's", rec.RecordIndex)
+		}
+		seen[rec.RecordIndex] = true
+	}
+}
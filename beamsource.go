@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Pipeline source: the dataset read as a splittable, checkpointable source
+// rather than staged files. OffsetRange is the restriction and
+// OffsetTracker claims record indices from it with the method set of the
+// Beam Go SDK's sdf.RTracker (TryClaim, TrySplit, GetProgress, IsDone,
+// GetRestriction, IsBounded, GetError), so a splittable DoFn in a pipeline
+// module that depends on Beam can delegate to it unchanged; this tree
+// carries no Beam dependency itself. A restriction without an end is
+// unbounded: it reads until stopped and only ever self-checkpoints.
+//
+// The source command is the runner-agnostic form: it writes a restriction
+// to stdout and, every so many records and on interrupt, a checkpoint file
+// holding the residual restriction, from which a restarted reader resumes
+// without duplicates or gaps.
+
+// unboundedEnd is the End of an unbounded restriction.
+const unboundedEnd = math.MaxUint64
+
+// OffsetRange is the restriction [Start, End) of record indices.
+type OffsetRange struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+}
+
+func (r OffsetRange) bounded() bool {
+	return r.End != unboundedEnd
+}
+
+// Size is the number of records in the range; unbounded ranges report
+// math.Inf.
+func (r OffsetRange) Size() float64 {
+	if !r.bounded() {
+		return math.Inf(1)
+	}
+	return float64(r.End - r.Start)
+}
+
+// Split cuts a bounded range into n balanced parts for initial splitting.
+func (r OffsetRange) Split(n int) []OffsetRange {
+	if !r.bounded() || n <= 1 {
+		return []OffsetRange{r}
+	}
+	var out []OffsetRange
+	for _, p := range splitRange(r.Start, r.End-r.Start, n, 1) {
+		if p.Count > 0 {
+			out = append(out, OffsetRange{p.Start, p.Start + p.Count})
+		}
+	}
+	return out
+}
+
+// OffsetTracker claims the indices of an OffsetRange in increasing order.
+type OffsetTracker struct {
+	rest    OffsetRange
+	claimed uint64
+	started bool
+	stopped bool
+	err     error
+}
+
+func NewOffsetTracker(r OffsetRange) *OffsetTracker {
+	return &OffsetTracker{rest: r}
+}
+
+// TryClaim claims index pos, a uint64. It fails, ending the work, once pos
+// reaches the end of the restriction; claiming out of order is an error.
+func (t *OffsetTracker) TryClaim(pos any) bool {
+	idx, ok := pos.(uint64)
+	switch {
+	case t.stopped:
+		return false
+	case !ok:
+		t.err = fmt.Errorf("offset tracker: position %v is %T, not uint64", pos, pos)
+	case idx < t.rest.Start || (t.started && idx <= t.claimed):
+		t.err = fmt.Errorf("offset tracker: claim of %d out of order in %+v after %d", idx, t.rest, t.claimed)
+	case idx >= t.rest.End:
+		t.stopped = true
+		return false
+	default:
+		t.claimed, t.started = idx, true
+		return true
+	}
+	t.stopped = true
+	return false
+}
+
+func (t *OffsetTracker) GetError() error {
+	return t.err
+}
+
+// next is the first index not claimed.
+func (t *OffsetTracker) next() uint64 {
+	if t.started {
+		return t.claimed + 1
+	}
+	return t.rest.Start
+}
+
+// TrySplit keeps fraction of the unclaimed remainder and returns the rest
+// as the residual; fraction 0 is a checkpoint, leaving the primary done
+// after the last claim. Unbounded restrictions only checkpoint.
+func (t *OffsetTracker) TrySplit(fraction float64) (primary, residual any, err error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, nil, fmt.Errorf("offset tracker: split fraction %g outside [0, 1]", fraction)
+	}
+	next := t.next()
+	if t.stopped || next >= t.rest.End {
+		return t.rest, nil, nil
+	}
+	split := next
+	if t.rest.bounded() {
+		split += uint64(fraction * float64(t.rest.End-next))
+	}
+	if split >= t.rest.End {
+		return t.rest, nil, nil
+	}
+	res := OffsetRange{split, t.rest.End}
+	t.rest.End = split
+	return t.rest, res, nil
+}
+
+// GetProgress reports claimed and unclaimed work in records.
+func (t *OffsetTracker) GetProgress() (done, remaining float64) {
+	next := t.next()
+	done = float64(next - t.rest.Start)
+	if !t.rest.bounded() {
+		return done, math.Inf(1)
+	}
+	return done, float64(t.rest.End - min(next, t.rest.End))
+}
+
+// IsDone reports whether every index of the restriction was claimed or the
+// tracker stopped.
+func (t *OffsetTracker) IsDone() bool {
+	return t.stopped || t.next() >= t.rest.End
+}
+
+func (t *OffsetTracker) GetRestriction() any {
+	return t.rest
+}
+
+func (t *OffsetTracker) IsBounded() bool {
+	return t.rest.bounded()
+}
+
+// sourceCheckpoint is the file a source reader resumes from.
+type sourceCheckpoint struct {
+	ConfigHash string      `json:"configHash"`
+	Residual   OffsetRange `json:"residual"`
+}
+
+func writeSourceCheckpoint(path string, c sourceCheckpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	// Replace the file atomically: a crash must leave the old checkpoint
+	// or the new one, never a torn one.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runSource reads a restriction to stdout, checkpointing the residual.
+func runSource(args []string) error {
+	fs := flag.NewFlagSet("source", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index of the restriction")
+	count := fs.Uint64("count", 0, "records in the restriction; 0 reads unbounded")
+	formatName := fs.String("format", "jsonl", "output format: "+strings.Join(sortedKeys(recordFormats), ", "))
+	checkpoint := fs.String("checkpoint", "", "checkpoint file: resume from its residual if it exists, and keep it updated")
+	every := fs.Uint64("checkpoint-every", 10_000, "records between checkpoints")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, ok := recordFormats[*formatName]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *formatName)
+	}
+	if *every == 0 {
+		return errors.New("-checkpoint-every must be positive")
+	}
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	hash := configHash(cfg)
+
+	r := OffsetRange{*start, unboundedEnd}
+	if *count > 0 {
+		if *start+*count < *start {
+			return errors.New("-start plus -count overflows")
+		}
+		r.End = *start + *count
+	}
+	if *checkpoint != "" {
+		data, err := os.ReadFile(*checkpoint)
+		switch {
+		case err == nil:
+			var c sourceCheckpoint
+			if err := json.Unmarshal(data, &c); err != nil {
+				return fmt.Errorf("%s: %w", *checkpoint, err)
+			}
+			if c.ConfigHash != hash {
+				return fmt.Errorf("checkpoint %s was written for config %s, not %s", *checkpoint, c.ConfigHash, hash)
+			}
+			r = c.Residual
+			logFor("source").Info("resuming from checkpoint", "start", r.Start, "end", r.End)
+		case !errors.Is(err, os.ErrNotExist):
+			return err
+		}
+	}
+
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	schema := gen.Schema()
+	ctx, stop := interruptContext()
+	defer stop()
+	w := bufio.NewWriterSize(os.Stdout, 1<<16)
+	if format.header != nil {
+		w.Write(format.header(schema))
+	}
+	// save flushes what was read and records the rest of the restriction.
+	save := func(t *OffsetTracker) error {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if *checkpoint == "" {
+			return nil
+		}
+		residual := OffsetRange{t.next(), t.rest.End}
+		return writeSourceCheckpoint(*checkpoint, sourceCheckpoint{hash, residual})
+	}
+
+	t := NewOffsetTracker(r)
+	var buf []byte
+	for idx := r.Start; t.TryClaim(idx); idx++ {
+		rec := gen.RecordByIndex(idx)
+		buf = format.appendRecord(schema, buf[:0], &rec)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		if done, _ := t.GetProgress(); uint64(done)%*every == 0 {
+			if err := save(t); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			if err := save(t); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+	if err := t.GetError(); err != nil {
+		return err
+	}
+	return save(t)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Benchmark comparison: the hot-path benchmarks live in bench_test.go and
+// run with go test -bench; bench compare reads two of their outputs and
+// fails on regressions above a threshold. Repeated runs of a benchmark
+// (-count) are averaged.
+
+// BenchResult is the measurement of one benchmark.
+type BenchResult struct {
+	Iterations  int     `json:"iterations"`
+	NsPerOp     float64 `json:"nsPerOp"`
+	BytesPerOp  int64   `json:"bytesPerOp"`
+	AllocsPerOp int64   `json:"allocsPerOp"`
+}
+
+// benchReport is the parsed output of one go test -bench run.
+type benchReport struct {
+	GOOS    string                 `json:"goos"`
+	GOARCH  string                 `json:"goarch"`
+	CPUs    int                    `json:"cpus"`
+	Results map[string]BenchResult `json:"results"`
+}
+
+// benchLine matches a result line: the name with its GOMAXPROCS suffix,
+// the iterations and the measurements.
+var benchLine = regexp.MustCompile(`^Benchmark(\S+?)(?:-(\d+))?\s+(\d+)\s+(.*)$`)
+
+// parseBenchOutput reads go test -bench output. Names lose their
+// Benchmark prefix and GOMAXPROCS suffix.
+func parseBenchOutput(r *bufio.Scanner) (benchReport, error) {
+	report := benchReport{Results: map[string]BenchResult{}}
+	runs := map[string]int{}
+	for r.Scan() {
+		line := r.Text()
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			switch k {
+			case "goos":
+				report.GOOS = v
+			case "goarch":
+				report.GOARCH = v
+			}
+			continue
+		}
+		m := benchLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name := m[1]
+		if m[2] != "" {
+			report.CPUs, _ = strconv.Atoi(m[2])
+		}
+		var res BenchResult
+		res.Iterations, _ = strconv.Atoi(m[3])
+		fields := strings.Fields(m[4])
+		for i := 0; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return report, fmt.Errorf("%s: %w", name, err)
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				res.NsPerOp = v
+			case "B/op":
+				res.BytesPerOp = int64(v)
+			case "allocs/op":
+				res.AllocsPerOp = int64(v)
+			}
+		}
+		// Average repeated runs.
+		n := runs[name]
+		runs[name]++
+		prev := report.Results[name]
+		avg := func(a, b float64) float64 { return (a*float64(n) + b) / float64(n+1) }
+		report.Results[name] = BenchResult{
+			Iterations:  prev.Iterations + res.Iterations,
+			NsPerOp:     avg(prev.NsPerOp, res.NsPerOp),
+			BytesPerOp:  int64(avg(float64(prev.BytesPerOp), float64(res.BytesPerOp))),
+			AllocsPerOp: int64(avg(float64(prev.AllocsPerOp), float64(res.AllocsPerOp))),
+		}
+	}
+	return report, r.Err()
+}
+
+// benchRegression is a benchmark slower, or allocating more, than allowed.
+type benchRegression struct {
+	Name     string
+	Metric   string
+	Old, New float64
+}
+
+// compareBench returns the benchmarks of cur whose time or allocations per
+// op grew by more than threshold percent over old. Benchmarks missing from
+// either file are skipped.
+func compareBench(old, cur benchReport, threshold float64) []benchRegression {
+	var out []benchRegression
+	for _, name := range sortedKeys(cur.Results) {
+		o, ok := old.Results[name]
+		if !ok {
+			continue
+		}
+		n := cur.Results[name]
+		for _, m := range []struct {
+			metric   string
+			old, new float64
+		}{{"ns/op", o.NsPerOp, n.NsPerOp}, {"allocs/op", float64(o.AllocsPerOp), float64(n.AllocsPerOp)}} {
+			if m.new > m.old*(1+threshold/100) {
+				out = append(out, benchRegression{name, m.metric, m.old, m.new})
+			}
+		}
+	}
+	return out
+}
+
+func readBenchReport(path string) (benchReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return benchReport{}, err
+	}
+	defer file.Close()
+	r, err := parseBenchOutput(bufio.NewScanner(file))
+	if err != nil {
+		return r, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(r.Results) == 0 {
+		return r, fmt.Errorf("%s: no benchmark results", path)
+	}
+	return r, nil
+}
+
+// runBench diffs two go test -bench outputs.
+func runBench(args []string) error {
+	if len(args) == 0 || args[0] != "compare" {
+		return errors.New("usage: bench compare [-threshold percent] <old.txt> <new.txt>; produce the files with go test -run '^$' -bench . -benchmem")
+	}
+	return runBenchCompare(args[1:])
+}
+
+func runBenchCompare(args []string) error {
+	fs := flag.NewFlagSet("bench compare", flag.ContinueOnError)
+	threshold := fs.Float64("threshold", 10, "allowed growth of ns/op and allocs/op, in percent")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: bench compare [-threshold percent] <old.txt> <new.txt>")
+	}
+	old, err := readBenchReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cur, err := readBenchReport(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if old.GOOS != cur.GOOS || old.GOARCH != cur.GOARCH || old.CPUs != cur.CPUs {
+		fmt.Printf("⚙️  comparing %s/%s with %d CPUs against %s/%s with %d CPUs\n", cur.GOOS, cur.GOARCH, cur.CPUs, old.GOOS, old.GOARCH, old.CPUs)
+	}
+	for _, name := range sortedKeys(cur.Results) {
+		o, ok := old.Results[name]
+		if !ok {
+			fmt.Printf("   %-24s new\n", name)
+			continue
+		}
+		n := cur.Results[name]
+		delta := 0.0
+		if o.NsPerOp > 0 {
+			delta = (n.NsPerOp/o.NsPerOp - 1) * 100
+		}
+		fmt.Printf("   %-24s %12.1f → %12.1f ns/op (%+6.1f%%)  %d → %d allocs/op\n", name, o.NsPerOp, n.NsPerOp, delta, o.AllocsPerOp, n.AllocsPerOp)
+	}
+	regressions := compareBench(old, cur, *threshold)
+	for _, r := range regressions {
+		fmt.Printf("❌ %s: %s %.1f → %.1f\n", r.Name, r.Metric, r.Old, r.New)
+	}
+	if len(regressions) > 0 {
+		return fmt.Errorf("%d regressions above %g%%", len(regressions), *threshold)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// Benchmarks of the hot path: profile and record derivation, distortions,
+// every record encoding and end-to-end writing. Compare two runs with
+// bench compare.
+
+// benchRecords are the pre-built records the encoding benchmarks cycle
+// through.
+const benchRecords = 1024
+
+func BenchmarkProfileBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buildProfile(uint64(i), defaultConfig)
+	}
+}
+
+func BenchmarkRecordBuild(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		opts []Option
+	}{{"uncached", nil}, {"cached", []Option{WithCache(10_000)}}} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			gen := mustNewGenerator(defaultConfig, bm.opts...)
+			for i := 0; i < b.N; i++ {
+				gen.RecordByIndex(uint64(i))
+			}
+		})
+	}
+}
+
+func BenchmarkDistortions(b *testing.B) {
+	profile := buildProfile(1, defaultConfig)
+	b.Run("untraced", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			distortFields(profile, i&7, defaultConfig, uint64(i), nil)
+		}
+	})
+	b.Run("traced", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			distortFields(profile, i&7, defaultConfig, uint64(i), &DistortionTrace{})
+		}
+	})
+}
+
+func BenchmarkEncode(b *testing.B) {
+	gen := mustNewGenerator(defaultConfig)
+	records := make([]RawRecord, benchRecords)
+	for i := range records {
+		records[i] = gen.RecordByIndex(uint64(i))
+	}
+	for _, name := range sortedKeys(recordFormats) {
+		f := recordFormats[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var buf []byte
+			for i := 0; i < b.N; i++ {
+				buf = f.appendRecord(gen.Schema(), buf[:0], &records[i%benchRecords])
+			}
+		})
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	for _, name := range []string{"jsonl", "csv"} {
+		f := recordFormats[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			gen := mustNewGenerator(defaultConfig)
+			if _, _, err := writeRecords(context.Background(), gen, io.Discard, f, 0, uint64(b.N), nil); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
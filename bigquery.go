@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// BigQuery loading: the records as newline-delimited JSON plus a table
+// schema derived from the record schema, loaded by a BigQuery load job.
+// The file is uploaded with the job, or staged in Cloud Storage first with
+// -bucket, which large loads need. JSON rather than Parquet is staged
+// because BigQuery types columns of a Parquet load from the file, which
+// would leave the timestamp fields as strings. The job creates the dataset
+// and table when missing and, when appending, may add columns or relax
+// REQUIRED ones, so a config that outputs more fields loads into the
+// existing table.
+
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+	// Description names the field's sensitivity, if any.
+	Description string `json:"description,omitempty"`
+}
+
+var bigQueryTypes = map[FieldType]string{FieldString: "STRING", FieldInt: "INT64", FieldUint: "INT64", FieldFloat: "FLOAT64"}
+
+// bigQueryTimestampFields hold RFC 3339 UTC times and are typed as such.
+var bigQueryTimestampFields = map[string]bool{"timestamp": true, "sessionStart": true}
+
+// bigQuerySchema is the table schema of s. Fields left out of JSON when
+// empty are NULLABLE.
+func bigQuerySchema(s *Schema) []bigQueryField {
+	fields := make([]bigQueryField, len(s.Fields))
+	for i, f := range s.Fields {
+		t := bigQueryTypes[f.Type]
+		if bigQueryTimestampFields[f.Name] {
+			t = "TIMESTAMP"
+		}
+		mode := "REQUIRED"
+		if f.Optional || f.ptr {
+			mode = "NULLABLE"
+		}
+		fields[i] = bigQueryField{Name: f.Name, Type: t, Mode: mode, Description: sensitivityComment(&f)}
+	}
+	return fields
+}
+
+func runBigQuery(args []string) error {
+	fs := flag.NewFlagSet("bigquery", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	dir := fs.String("dir", "output/bigquery", "directory for the data and schema files")
+	table := fs.String("table", "", "dataset.table or project.dataset.table to load; without it only the files are written")
+	project := fs.String("project", "", "project of a table named without one (default the credentials' project)")
+	bucket := fs.String("bucket", "", "gs://bucket/prefix to stage the data file in before loading, instead of uploading it with the load job")
+	replace := fs.Bool("replace", false, "replace the table's contents instead of appending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	if *table != "" {
+		if _, err := parseBigQueryTable(*table, *project); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	schemaPath := filepath.Join(*dir, "schema.json")
+	data, err := json.MarshalIndent(bigQuerySchema(gen.Schema()), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(schemaPath, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	dataPath := filepath.Join(*dir, fmt.Sprintf("records-%d-%d.jsonl", *start, *count))
+	began := time.Now()
+	file, err := writeRangeFile(ctx, gen, formatJSONL, dataPath, *start, *count, nil)
+	if err != nil {
+		return err
+	}
+	manifest := Manifest{
+		ConfigHash: configHash(cfg),
+		Config:     cfg,
+		Overrides:  config.overrides,
+		Registered: config.named,
+		Format:     formatJSONL.name,
+		Start:      *start,
+		Count:      *count,
+		Files:      []ManifestFile{file},
+		CreatedAt:  gen.now().UTC(),
+	}
+	if err := writeManifest(dataPath+".manifest.json", manifest); err != nil {
+		return err
+	}
+	log := logFor("bigquery")
+	log.Info("wrote records", "output", dataPath, "schema", schemaPath, "duration", time.Since(began).Round(time.Millisecond))
+	if *table == "" {
+		return nil
+	}
+	dest, err := parseBigQueryTable(*table, *project)
+	if err != nil {
+		return err
+	}
+	disposition := bigquery.WriteAppend
+	if *replace {
+		disposition = bigquery.WriteTruncate
+	}
+	if err := loadBigQuery(ctx, dest, bigQueryTableSchema(gen.Schema()), dataPath, *bucket, disposition); err != nil {
+		return err
+	}
+	log.Info("loaded table", "table", *table, "records", *count)
+	return nil
+}
+
+// bigQueryTable names a table; an empty Project is the credentials'.
+type bigQueryTable struct {
+	Project, Dataset, Table string
+}
+
+// parseBigQueryTable parses dataset.table, project.dataset.table or bq's
+// project:dataset.table.
+func parseBigQueryTable(spec, project string) (bigQueryTable, error) {
+	if p, rest, ok := strings.Cut(spec, ":"); ok {
+		project, spec = p, rest
+	}
+	parts := strings.Split(spec, ".")
+	switch {
+	case len(parts) == 3 && project == "":
+		project, parts = parts[0], parts[1:]
+	case len(parts) != 2:
+		return bigQueryTable{}, fmt.Errorf("table %q is not dataset.table or project.dataset.table", spec)
+	}
+	if parts[0] == "" || parts[1] == "" {
+		return bigQueryTable{}, fmt.Errorf("table %q is not dataset.table or project.dataset.table", spec)
+	}
+	return bigQueryTable{Project: project, Dataset: parts[0], Table: parts[1]}, nil
+}
+
+// bigQueryTableSchema is bigQuerySchema(s) for the client library.
+func bigQueryTableSchema(s *Schema) bigquery.Schema {
+	fields := bigQuerySchema(s)
+	schema := make(bigquery.Schema, len(fields))
+	for i, f := range fields {
+		schema[i] = &bigquery.FieldSchema{
+			Name:        f.Name,
+			Type:        bigquery.FieldType(f.Type),
+			Required:    f.Mode == "REQUIRED",
+			Description: f.Description,
+		}
+	}
+	return schema
+}
+
+// loadBigQuery loads the JSONL file at dataPath into dest, staging it as
+// an object under bucket, a gs://bucket/prefix URL, when that is set.
+// Application Default Credentials authenticate both clients; opts are
+// passed to them as well.
+func loadBigQuery(ctx context.Context, dest bigQueryTable, schema bigquery.Schema, dataPath, bucket string, disposition bigquery.TableWriteDisposition, opts ...option.ClientOption) error {
+	project := dest.Project
+	if project == "" {
+		project = bigquery.DetectProjectID
+	}
+	client, err := bigquery.NewClient(ctx, project, opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	dataset := client.DatasetInProject(client.Project(), dest.Dataset)
+	if _, err := dataset.Metadata(ctx); err != nil {
+		var apiErr *googleapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+			return fmt.Errorf("dataset %s: %w", dest.Dataset, err)
+		}
+		if err := dataset.Create(ctx, nil); err != nil {
+			return fmt.Errorf("create dataset %s: %w", dest.Dataset, err)
+		}
+	}
+
+	var source bigquery.LoadSource
+	if bucket != "" {
+		uri, err := stageGCS(ctx, bucket, dataPath, opts...)
+		if err != nil {
+			return err
+		}
+		ref := bigquery.NewGCSReference(uri)
+		ref.SourceFormat, ref.Schema = bigquery.JSON, schema
+		source = ref
+	} else {
+		file, err := os.Open(dataPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		ref := bigquery.NewReaderSource(file)
+		ref.SourceFormat, ref.Schema = bigquery.JSON, schema
+		source = ref
+	}
+	loader := dataset.Table(dest.Table).LoaderFrom(source)
+	loader.WriteDisposition = disposition
+	loader.SchemaUpdateOptions = []string{"ALLOW_FIELD_ADDITION", "ALLOW_FIELD_RELAXATION"}
+	job, err := loader.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("bigquery load: %w", err)
+	}
+	status, err := job.Wait(ctx)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("bigquery load job %s: %w", job.ID(), err)
+	}
+	return nil
+}
+
+// stageGCS uploads the file at name under bucket, a gs://bucket/prefix
+// URL, and returns the object's URL. The object is named after the file,
+// so staging a range again replaces it.
+func stageGCS(ctx context.Context, bucket, name string, opts ...option.ClientOption) (string, error) {
+	rest, ok := strings.CutPrefix(bucket, "gs://")
+	bucketName, prefix, _ := strings.Cut(rest, "/")
+	if !ok || bucketName == "" {
+		return "", fmt.Errorf("-bucket %q is not a gs://bucket/prefix URL", bucket)
+	}
+	object := path.Join(prefix, filepath.Base(name))
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	w := client.Bucket(bucketName).Object(object).NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+	if _, err := io.Copy(w, file); err != nil {
+		w.Close()
+		return "", fmt.Errorf("stage %s: %w", object, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("stage %s: %w", object, err)
+	}
+	return "gs://" + bucketName + "/" + object, nil
+}
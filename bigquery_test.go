@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/option"
+)
+
+func TestParseBigQueryTable(t *testing.T) {
+	for spec, want := range map[string]bigQueryTable{
+		"ds.records":      {Dataset: "ds", Table: "records"},
+		"proj.ds.records": {Project: "proj", Dataset: "ds", Table: "records"},
+		"proj:ds.records": {Project: "proj", Dataset: "ds", Table: "records"},
+	} {
+		if got, err := parseBigQueryTable(spec, ""); err != nil || got != want {
+			t.Errorf("parseBigQueryTable(%q) = %+v, %v, want %+v", spec, got, err, want)
+		}
+	}
+	if got, _ := parseBigQueryTable("ds.records", "proj"); got.Project != "proj" {
+		t.Errorf("-project is not used for a table named without one: %+v", got)
+	}
+	for _, spec := range []string{"records", "a.b.c.d", ".records", "ds.", "proj:a.b.c"} {
+		if _, err := parseBigQueryTable(spec, ""); err == nil {
+			t.Errorf("parseBigQueryTable(%q) succeeded", spec)
+		}
+	}
+}
+
+// fakeGoogleAPI serves the BigQuery and Cloud Storage calls of
+// loadBigQuery, recording the load job and the staged objects.
+type fakeGoogleAPI struct {
+	datasets map[string]bool
+	objects  map[string][]byte
+	job      struct {
+		Configuration struct {
+			Load struct {
+				SourceURIs          []string `json:"sourceUris"`
+				SourceFormat        string   `json:"sourceFormat"`
+				WriteDisposition    string   `json:"writeDisposition"`
+				SchemaUpdateOptions []string `json:"schemaUpdateOptions"`
+				Schema              struct {
+					Fields []bigQueryField `json:"fields"`
+				} `json:"schema"`
+				DestinationTable struct {
+					ProjectID, DatasetID, TableID string
+				} `json:"destinationTable"`
+			} `json:"load"`
+		} `json:"configuration"`
+	}
+	jobMedia []byte
+	jobError string
+}
+
+// readUpload splits a multipart upload into its JSON metadata and media.
+func readUpload(t *testing.T, r *http.Request, metadata any) []byte {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewDecoder(part).Decode(metadata); err != nil {
+		t.Fatal(err)
+	}
+	if part, err = mr.NextPart(); err != nil {
+		t.Fatal(err)
+	}
+	media, _ := io.ReadAll(part)
+	return media
+}
+
+func (f *fakeGoogleAPI) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch p := r.URL.Path; {
+		case r.Method == http.MethodGet && strings.HasPrefix(p, "/projects/proj/datasets/"):
+			if !f.datasets[strings.TrimPrefix(p, "/projects/proj/datasets/")] {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":{"code":404,"message":"Not found: Dataset"}}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && p == "/projects/proj/datasets":
+			var ds struct{ DatasetReference struct{ DatasetID string } }
+			json.NewDecoder(r.Body).Decode(&ds)
+			f.datasets[ds.DatasetReference.DatasetID] = true
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && p == "/upload/storage/v1/b/bucket/o":
+			var obj map[string]any
+			f.objects[r.URL.Query().Get("name")] = readUpload(t, r, &obj)
+			w.Write([]byte(`{"bucket":"bucket"}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(p, "/projects/proj/jobs"):
+			if r.URL.Query().Get("uploadType") != "" {
+				f.jobMedia = readUpload(t, r, &f.job)
+			} else {
+				json.NewDecoder(r.Body).Decode(&f.job)
+			}
+			w.Write([]byte(`{"jobReference":{"projectId":"proj","jobId":"j1"},"status":{"state":"RUNNING"}}`))
+		case r.Method == http.MethodGet && p == "/projects/proj/jobs/j1":
+			if f.jobError != "" {
+				w.Write([]byte(`{"status":{"state":"DONE","errorResult":{"reason":"invalid","message":"` + f.jobError + `"}}}`))
+				return
+			}
+			w.Write([]byte(`{"status":{"state":"DONE"}}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+}
+
+func TestLoadBigQuery(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	data := filepath.Join(t.TempDir(), "records-0-10.jsonl")
+	if _, err := writeRangeFile(context.Background(), gen, formatJSONL, data, 0, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := os.ReadFile(data)
+	schema := bigQueryTableSchema(gen.Schema())
+	dest := bigQueryTable{"proj", "ds", "records"}
+
+	f := &fakeGoogleAPI{datasets: map[string]bool{}, objects: map[string][]byte{}}
+	srv := httptest.NewServer(f.handler(t))
+	defer srv.Close()
+	opts := []option.ClientOption{option.WithEndpoint(srv.URL), option.WithoutAuthentication()}
+
+	// Staged: the dataset is created, the file lands in the bucket and the
+	// job loads it from there.
+	if err := loadBigQuery(context.Background(), dest, schema, data, "gs://bucket/staging", bigquery.WriteAppend, opts...); err != nil {
+		t.Fatal(err)
+	}
+	load := f.job.Configuration.Load
+	if !f.datasets["ds"] || string(f.objects["staging/records-0-10.jsonl"]) != string(want) {
+		t.Errorf("datasets %v, objects %q", f.datasets, slices.Collect(maps.Keys(f.objects)))
+	}
+	if !slices.Equal(load.SourceURIs, []string{"gs://bucket/staging/records-0-10.jsonl"}) || load.SourceFormat != "NEWLINE_DELIMITED_JSON" ||
+		load.WriteDisposition != "WRITE_APPEND" || load.DestinationTable.TableID != "records" || len(load.SchemaUpdateOptions) != 2 {
+		t.Errorf("staged load job is %+v", load)
+	}
+	if got := load.Schema.Fields; len(got) != len(schema) || got[0].Name != schema[0].Name || got[0].Mode != "REQUIRED" {
+		t.Errorf("load job schema is %+v", got)
+	}
+
+	// Direct: the file is uploaded with the job.
+	f.job.Configuration.Load.SourceURIs = nil
+	if err := loadBigQuery(context.Background(), dest, schema, data, "", bigquery.WriteTruncate, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if load := f.job.Configuration.Load; string(f.jobMedia) != string(want) || load.SourceURIs != nil || load.WriteDisposition != "WRITE_TRUNCATE" {
+		t.Errorf("direct load job is %+v with %d bytes of media", load, len(f.jobMedia))
+	}
+
+	f.jobError = "schema mismatch"
+	if err := loadBigQuery(context.Background(), dest, schema, data, "", bigquery.WriteAppend, opts...); err == nil || !strings.Contains(err.Error(), "schema mismatch") {
+		t.Errorf("failed load job returned %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfigBuilder assembles a GeneratorConfig fluently, starting from the
+// defaults:
+//
+//	cfg, err := NewConfig().ProfileSpace(1e12).Bucket(90, 1).Bucket(8, 3).Typo(0.05).Build()
+//
+// Calls never fail on their own; the first error is kept and returned by
+// Build, which also validates the result.
+type ConfigBuilder struct {
+	cfg        GeneratorConfig
+	ownBuckets bool
+	overrides  []ConfigOverride
+	err        error
+}
+
+// NewConfig starts a builder from the default config.
+func NewConfig() *ConfigBuilder {
+	return &ConfigBuilder{cfg: cloneConfig(defaultConfig)}
+}
+
+// NewConfigFrom starts a builder from a named preset.
+func NewConfigFrom(preset string) *ConfigBuilder {
+	cfg, err := presetConfig(preset)
+	return &ConfigBuilder{cfg: cfg, err: err}
+}
+
+func (b *ConfigBuilder) ProfileSpace(n uint64) *ConfigBuilder {
+	b.cfg.ProfileSpaceSize = n
+	return b
+}
+
+func (b *ConfigBuilder) Seed(seed uint64) *ConfigBuilder {
+	b.cfg.Seed = seed
+	return b
+}
+
+// Bucket adds a frequency bucket. The first call replaces the default
+// buckets, so the buckets given are the complete list.
+func (b *ConfigBuilder) Bucket(weight, repeatMultiplier int) *ConfigBuilder {
+	if !b.ownBuckets {
+		b.cfg.Buckets, b.ownBuckets = nil, true
+	}
+	b.cfg.Buckets = append(b.cfg.Buckets, FrequencyBucket{Weight: weight, RepeatMultiplier: repeatMultiplier})
+	return b
+}
+
+func (b *ConfigBuilder) Typo(rate float64) *ConfigBuilder {
+	b.cfg.Distortions.Typo = rate
+	return b
+}
+
+func (b *ConfigBuilder) Transliterate(rate float64) *ConfigBuilder {
+	b.cfg.Distortions.Transliterate = rate
+	return b
+}
+
+func (b *ConfigBuilder) SwapFirstLast(rate float64) *ConfigBuilder {
+	b.cfg.Distortions.SwapFirstLast = rate
+	return b
+}
+
+func (b *ConfigBuilder) DateSpread(start, end time.Time) *ConfigBuilder {
+	b.cfg.DateSpread = DateSpreadConfig{Start: start.UTC(), End: end.UTC()}
+	return b
+}
+
+func (b *ConfigBuilder) Cities(cities ...string) *ConfigBuilder {
+	b.cfg.Pools.Cities = cities
+	return b
+}
+
+func (b *ConfigBuilder) Channels(channels ...string) *ConfigBuilder {
+	b.cfg.Pools.Channels = channels
+	return b
+}
+
+func (b *ConfigBuilder) OutputFields(fields ...string) *ConfigBuilder {
+	b.cfg.OutputFields = fields
+	return b
+}
+
+// configSections enables an optional model with its default settings.
+var configSections = map[string]func(*GeneratorConfig){
+	"emails":            func(c *GeneratorConfig) { c.Emails = defaultEmailConfig.clone() },
+	"emailAliases":      func(c *GeneratorConfig) { c.EmailAliases = defaultEmailAliasConfig.clone() },
+	"logins":            func(c *GeneratorConfig) { c.Logins = defaultLoginConfig.clone() },
+	"cityTimezones":     func(c *GeneratorConfig) { c.CityTimezones = cloneTimezones(defaultCityTimezones) },
+	"geography":         func(c *GeneratorConfig) { c.Geography = defaultGeographyConfig.clone() },
+	"fieldAvailability": func(c *GeneratorConfig) { c.FieldAvailability = cloneAvailability(defaultFieldAvailability) },
+	"amounts":           func(c *GeneratorConfig) { c.Amounts = defaultAmountConfig.clone() },
+	"demographics":      func(c *GeneratorConfig) { c.Demographics = defaultDemographicsConfig.clone() },
+	"lifecycle":         func(c *GeneratorConfig) { c.Lifecycle = defaultLifecycleConfig.clone() },
+	"sessions":          func(c *GeneratorConfig) { c.Sessions = defaultSessionConfig.clone() },
+	"events":            func(c *GeneratorConfig) { c.Events = defaultEventConfig.clone() },
+	"fraud":             func(c *GeneratorConfig) { c.Fraud = defaultFraudConfig.clone() },
+	"relationships":     func(c *GeneratorConfig) { c.Relationships = defaultRelationshipConfig.clone() },
+	"organizations":     func(c *GeneratorConfig) { c.Organizations = defaultOrganizationConfig.clone() },
+	"retention":         func(c *GeneratorConfig) { c.Retention = defaultRetentionConfig.clone() },
+	"erasure":           func(c *GeneratorConfig) { c.Erasure = defaultErasureConfig.clone() },
+}
+
+// Enable turns on optional models, named by their config key (emails,
+// sessions, fraud, ...), with default settings; Set or With can tune them
+// afterwards.
+func (b *ConfigBuilder) Enable(sections ...string) *ConfigBuilder {
+	for _, name := range sections {
+		enable, ok := configSections[name]
+		if !ok {
+			b.fail(fmt.Errorf("unknown config section %q (available: %v)", name, sortedKeys(configSections)))
+			continue
+		}
+		enable(&b.cfg)
+	}
+	return b
+}
+
+// Set sets a value by its dotted config key, as -set does on the command
+// line; it is applied at Build, after the typed setters.
+func (b *ConfigBuilder) Set(key, value string) *ConfigBuilder {
+	b.overrides = append(b.overrides, ConfigOverride{Key: key, Value: value, Source: "builder"})
+	return b
+}
+
+// With applies fn to the config being built, for settings without a typed
+// setter.
+func (b *ConfigBuilder) With(fn func(*GeneratorConfig)) *ConfigBuilder {
+	fn(&b.cfg)
+	return b
+}
+
+func (b *ConfigBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build returns the config, or the first error from the builder calls or
+// from validation.
+func (b *ConfigBuilder) Build() (GeneratorConfig, error) {
+	if b.err != nil {
+		return GeneratorConfig{}, b.err
+	}
+	cfg := cloneConfig(b.cfg)
+	cfg, err := applyOverrides(cfg, b.overrides)
+	if err != nil {
+		return GeneratorConfig{}, err
+	}
+	if err := validateConfig(cfg); err != nil {
+		return GeneratorConfig{}, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Calibration: the observed rate of every distortion, duplicate bucket and
+// variant in a range compared with what the config asks for, with a
+// binomial test flagging deviations too large to be sampling noise. Pairs
+// of distortions are tested for independence too, since every check of a
+// record draws from one RNG stream and a correlation between draws would
+// skew combined distortions without moving any single rate.
+
+// CalibrationCheck is one measured rate against its expected value.
+type CalibrationCheck struct {
+	Name     string  `json:"name"`
+	Expected float64 `json:"expected"`
+	Observed float64 `json:"observed"`
+	Count    uint64  `json:"count"`
+	Trials   uint64  `json:"trials"`
+	// Z is the deviation in standard errors of a binomial with the expected
+	// rate; it is infinite when an expected rate of 0 or 1 is missed.
+	Z           float64 `json:"z"`
+	Significant bool    `json:"significant"`
+}
+
+// Calibration is the report for one range.
+type Calibration struct {
+	Alpha float64 `json:"alpha"`
+	// Threshold is the |Z| above which a check is significant: alpha
+	// two-sided, Bonferroni-corrected for the number of checks.
+	Threshold float64            `json:"threshold"`
+	Checks    []CalibrationCheck `json:"checks"`
+}
+
+// Deviations returns the significant checks.
+func (c Calibration) Deviations() []CalibrationCheck {
+	var out []CalibrationCheck
+	for _, check := range c.Checks {
+		if check.Significant {
+			out = append(out, check)
+		}
+	}
+	return out
+}
+
+// binomialCheck compares count successes in trials with rate p.
+func binomialCheck(name string, p float64, count, trials uint64) CalibrationCheck {
+	c := CalibrationCheck{Name: name, Expected: p, Count: count, Trials: trials}
+	if trials == 0 {
+		return c
+	}
+	c.Observed = float64(count) / float64(trials)
+	diff := float64(count) - p*float64(trials)
+	if sd := math.Sqrt(float64(trials) * p * (1 - p)); sd > 0 {
+		c.Z = diff / sd
+	} else if diff != 0 {
+		c.Z = math.Copysign(math.Inf(1), diff)
+	}
+	return c
+}
+
+// calibrationDistortions are the traced distortion checks with their
+// configured rates.
+var calibrationDistortions = []struct {
+	name  string
+	fired func(*DistortionTrace) bool
+	rate  func(DistortionRates) float64
+}{
+	{"swapFirstLast", func(t *DistortionTrace) bool { return t.SwapFirstLast }, func(r DistortionRates) float64 { return r.SwapFirstLast }},
+	{"transliterate", func(t *DistortionTrace) bool { return t.Transliterate }, func(r DistortionRates) float64 { return r.Transliterate }},
+	{"firstNameTypo", func(t *DistortionTrace) bool { return t.FirstNameTypo }, func(r DistortionRates) float64 { return r.Typo }},
+	{"lastNameTypo", func(t *DistortionTrace) bool { return t.LastNameTypo }, func(r DistortionRates) float64 { return r.Typo }},
+}
+
+// bucketPosition is the position of profileID's bucket in cfg.Buckets.
+// Buckets are told apart by position: two may share a multiplier.
+func bucketPosition(profileID uint64, cfg GeneratorConfig) int {
+	b := classifyBucket(profileID, cfg.Buckets, cfg.Seed)
+	for i := range cfg.Buckets {
+		if cfg.Buckets[i] == b {
+			return i
+		}
+	}
+	return len(cfg.Buckets) - 1
+}
+
+// calibrate measures the range [start, start+count) of gen against its
+// config and tests each rate at significance alpha.
+func calibrate(ctx context.Context, gen *IdempotentGenerator, start, count uint64, alpha float64) (Calibration, error) {
+	cfg := gen.cfg
+	n := len(calibrationDistortions)
+	fired := make([]uint64, n)
+	joint := make([][]uint64, n)
+	for i := range joint {
+		joint[i] = make([]uint64, n)
+	}
+	totalWeight := 0
+	for _, b := range cfg.Buckets {
+		totalWeight += b.Weight
+	}
+	// Bucket shares are tested over distinct profiles: records of one
+	// profile share its bucket, so they are not independent trials.
+	seen := make(map[uint64]struct{})
+	profilesIn := make([]uint64, len(cfg.Buckets))
+	inBucket := make([]uint64, len(cfg.Buckets))
+	firstVariant := make([]uint64, len(cfg.Buckets))
+
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return Calibration{}, err
+			}
+		}
+		var trace DistortionTrace
+		rec := gen.recordByIndex(start+i, &trace)
+		for a, d := range calibrationDistortions {
+			if !d.fired(&trace) {
+				continue
+			}
+			fired[a]++
+			for b := a + 1; b < n; b++ {
+				if calibrationDistortions[b].fired(&trace) {
+					joint[a][b]++
+				}
+			}
+		}
+		b := bucketPosition(rec.ProfileID, cfg)
+		inBucket[b]++
+		if _, ok := seen[rec.ProfileID]; !ok {
+			seen[rec.ProfileID] = struct{}{}
+			profilesIn[b]++
+		}
+		if rec.VariantIndex == 0 {
+			firstVariant[b]++
+		}
+	}
+
+	var checks []CalibrationCheck
+	for a, d := range calibrationDistortions {
+		checks = append(checks, binomialCheck("distortion "+d.name, clamp01(d.rate(cfg.Distortions)), fired[a], count))
+	}
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			p := clamp01(calibrationDistortions[a].rate(cfg.Distortions)) * clamp01(calibrationDistortions[b].rate(cfg.Distortions))
+			name := fmt.Sprintf("independence %s×%s", calibrationDistortions[a].name, calibrationDistortions[b].name)
+			checks = append(checks, binomialCheck(name, p, joint[a][b], count))
+		}
+	}
+	for i, bucket := range cfg.Buckets {
+		name := fmt.Sprintf("bucket %d (weight %d, x%d)", i, bucket.Weight, bucket.RepeatMultiplier)
+		checks = append(checks, binomialCheck(name+" share", float64(bucket.Weight)/float64(totalWeight), profilesIn[i], uint64(len(seen))))
+		if bucket.RepeatMultiplier > 1 {
+			checks = append(checks, binomialCheck(name+" variant 0", 1/float64(bucket.RepeatMultiplier), firstVariant[i], inBucket[i]))
+		}
+	}
+
+	c := Calibration{Alpha: alpha, Threshold: math.Sqrt2 * math.Erfinv(1-alpha/float64(len(checks))), Checks: checks}
+	for i := range c.Checks {
+		c.Checks[i].Significant = math.Abs(c.Checks[i].Z) > c.Threshold
+	}
+	return c, nil
+}
+
+func printCalibration(w io.Writer, c Calibration) {
+	fmt.Fprintf(w, "🎯 Calibration (alpha %g, |z| > %.2f is significant):\n", c.Alpha, c.Threshold)
+	checks := append([]CalibrationCheck(nil), c.Checks...)
+	sort.SliceStable(checks, func(i, j int) bool { return checks[i].Significant && !checks[j].Significant })
+	for _, check := range checks {
+		mark := "✅"
+		if check.Significant {
+			mark = "⚠️ "
+		}
+		fmt.Fprintf(w, "   %s %-48s %7.3f%% vs %7.3f%%  z=%6.2f  (%d/%d)\n", mark, check.Name, check.Observed*100, check.Expected*100, check.Z, check.Count, check.Trials)
+	}
+}
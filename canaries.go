@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// Canary records: every Every-th record has its identity fields replaced
+// by magic values derived from its index — a canary-<index>@canary.invalid
+// email, a +999 phone and a canary-<index> login — so a pipeline can check
+// the far end for every expected canary, in order, with a text search. The
+// rest of the record is unchanged; exclude canaries from match evaluation.
+// Records of erased profiles stay redacted and are not canaries.
+
+// defaultCanaryDomain is reserved (RFC 2606) and never delivers mail.
+const defaultCanaryDomain = "canary.invalid"
+
+type CanaryConfig struct {
+	// Every is the spacing of canaries: record idx is a canary when
+	// idx % Every == Offset % Every.
+	Every  uint64 `json:"every"`
+	Offset uint64 `json:"offset,omitempty"`
+	// Domain of canary emails; defaults to canary.invalid.
+	Domain string `json:"domain,omitempty"`
+}
+
+func (c *CanaryConfig) clone() *CanaryConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	return &out
+}
+
+func (c *CanaryConfig) validate() error {
+	if c.Every == 0 {
+		return errors.New("canaries.every must be positive")
+	}
+	if c.Domain != "" && !emailPattern.MatchString("canary@"+c.Domain) {
+		return fmt.Errorf("canaries.domain %q is not a mail domain", c.Domain)
+	}
+	return nil
+}
+
+func (c *CanaryConfig) domain() string {
+	if c.Domain == "" {
+		return defaultCanaryDomain
+	}
+	return c.Domain
+}
+
+// isCanary reports whether record idx is a canary.
+func (c *CanaryConfig) isCanary(idx uint64) bool {
+	return idx%c.Every == c.Offset%c.Every
+}
+
+// apply replaces the identity fields of rec with its canary values. The
+// phone is +999, a code no country has, followed by the index's last 11
+// digits, which keeps it valid E.164.
+func (c *CanaryConfig) apply(rec *RawRecord) {
+	idx := strconv.FormatUint(rec.RecordIndex, 10)
+	rec.FirstName, rec.LastName = "Canary", "Tracer"
+	rec.Email = "canary-" + idx + "@" + c.domain()
+	rec.Phone = fmt.Sprintf("+999%011d", rec.RecordIndex%100_000_000_000)
+	rec.Login = "canary-" + idx
+	if rec.EmailCanonical != "" {
+		rec.EmailCanonical = rec.Email
+	}
+}
+
+// indices returns the canaries of [start, start+count).
+func (c *CanaryConfig) indices(start, count uint64) []uint64 {
+	var out []uint64
+	first := start + (c.Offset%c.Every+c.Every-start%c.Every)%c.Every
+	for idx := first; idx-start < count && idx >= start; idx += c.Every {
+		out = append(out, idx)
+	}
+	return out
+}
+
+// CanaryCheck is the result of searching an output for its canaries.
+type CanaryCheck struct {
+	Expected   int      `json:"expected"`
+	Found      int      `json:"found"`
+	Missing    []uint64 `json:"missing,omitempty"`
+	Unexpected []uint64 `json:"unexpected,omitempty"`
+	// OutOfOrder are canaries found after a canary of a higher index.
+	OutOfOrder []uint64 `json:"outOfOrder,omitempty"`
+	Duplicated []uint64 `json:"duplicated,omitempty"`
+}
+
+func (c CanaryCheck) ok() bool {
+	return len(c.Missing)+len(c.Unexpected)+len(c.OutOfOrder)+len(c.Duplicated) == 0
+}
+
+// canaryIndices returns the canaries g produces in [start, start+count):
+// the canaries of its config less the records of erased profiles.
+func (g *IdempotentGenerator) canaryIndices(start, count uint64) []uint64 {
+	indices := g.cfg.Canaries.indices(start, count)
+	if g.cfg.Erasure == nil {
+		return indices
+	}
+	return slices.DeleteFunc(indices, func(idx uint64) bool {
+		return g.RecordByIndex(idx).ErasedAt != ""
+	})
+}
+
+// check searches r, in any text format, for the canary emails of c
+// and compares them with the expected canaries.
+func (c *CanaryConfig) check(r io.Reader, expected []uint64) (CanaryCheck, error) {
+	pattern := regexp.MustCompile(`canary-([0-9]+)@` + regexp.QuoteMeta(c.domain()))
+	want := make(map[uint64]bool, len(expected))
+	for _, idx := range expected {
+		want[idx] = true
+	}
+	res := CanaryCheck{Expected: len(expected)}
+	seen := make(map[uint64]bool)
+	var last uint64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		// A record may carry its canary email twice (email and
+		// emailCanonical); count each record once.
+		lineSeen := make(map[uint64]bool)
+		for _, m := range pattern.FindAllSubmatch(scanner.Bytes(), -1) {
+			idx, err := strconv.ParseUint(string(m[1]), 10, 64)
+			if err != nil || lineSeen[idx] {
+				continue
+			}
+			lineSeen[idx] = true
+			switch {
+			case !want[idx]:
+				res.Unexpected = append(res.Unexpected, idx)
+				continue
+			case seen[idx]:
+				res.Duplicated = append(res.Duplicated, idx)
+				continue
+			}
+			seen[idx] = true
+			res.Found++
+			if idx < last {
+				res.OutOfOrder = append(res.OutOfOrder, idx)
+			}
+			last = max(last, idx)
+		}
+	}
+	for _, idx := range expected {
+		if !seen[idx] {
+			res.Missing = append(res.Missing, idx)
+		}
+	}
+	return res, scanner.Err()
+}
+
+// runCanaries lists the canaries of a range, or checks an output for them.
+func runCanaries(args []string) error {
+	mode := "list"
+	if len(args) > 0 && (args[0] == "list" || args[0] == "check") {
+		mode, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("canaries "+mode, flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config with a canaries section")
+	start := fs.Uint64("start", 0, "first record index of the range")
+	count := fs.Uint64("count", 1_000_000, "number of records in the range")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	c := cfg.Canaries
+	if c == nil {
+		return errors.New("the config has no canaries section")
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	expected := gen.canaryIndices(*start, *count)
+
+	if mode == "list" {
+		w := bufio.NewWriter(os.Stdout)
+		for _, idx := range expected {
+			fmt.Fprintf(w, "%d\tcanary-%d@%s\n", idx, idx, c.domain())
+		}
+		return w.Flush()
+	}
+
+	var in io.Reader = os.Stdin
+	if fs.NArg() > 1 {
+		return errors.New("usage: canaries check [flags] [output file]")
+	}
+	if fs.NArg() == 1 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	res, err := c.check(in, expected)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🐤 %d of %d canaries found\n", res.Found, res.Expected)
+	for _, problem := range []struct {
+		name    string
+		indices []uint64
+	}{{"missing", res.Missing}, {"unexpected", res.Unexpected}, {"out of order", res.OutOfOrder}, {"duplicated", res.Duplicated}} {
+		if len(problem.indices) > 0 {
+			fmt.Printf("   ❌ %d %s, first %v\n", len(problem.indices), problem.name, problem.indices[:min(len(problem.indices), 10)])
+		}
+	}
+	if !res.ok() {
+		return errors.New("canary check failed")
+	}
+	return nil
+}
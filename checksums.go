@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// Cross-platform determinism: checksums of canonical ranges — every preset
+// in every record format at the start, middle and far end of the index
+// space — written per platform and compared, so "same config, same bytes"
+// is checked on each OS and architecture instead of assumed. Float math is
+// where platforms drift (arm64 fuses multiply-adds, for one), and amounts,
+// timestamps and weighted picks all go through it.
+
+// checksumStarts are the first indices of the canonical ranges.
+var checksumStarts = []uint64{0, 1 << 32, 1 << 62}
+
+// checksumReport is the result of one platform.
+type checksumReport struct {
+	GOOS             string `json:"goos"`
+	GOARCH           string `json:"goarch"`
+	GoVersion        string `json:"goVersion"`
+	GeneratorVersion int    `json:"generatorVersion"`
+	Records          uint64 `json:"records"`
+	// Checksums maps preset/format/start to the sha256 of the range, and
+	// preset/config to the preset's config hash.
+	Checksums map[string]string `json:"checksums"`
+}
+
+func (r checksumReport) platform() string {
+	return r.GOOS + "/" + r.GOARCH + " " + r.GoVersion
+}
+
+// canonicalChecksums computes the report of this build over count records
+// per range.
+func canonicalChecksums(ctx context.Context, count uint64) (checksumReport, error) {
+	r := checksumReport{
+		GOOS: runtime.GOOS, GOARCH: runtime.GOARCH, GoVersion: runtime.Version(),
+		GeneratorVersion: generatorVersion, Records: count, Checksums: map[string]string{},
+	}
+	for _, preset := range presetNames() {
+		cfg, err := presetConfig(preset)
+		if err != nil {
+			return r, err
+		}
+		r.Checksums[preset+"/config"] = configHash(cfg)
+		gen := mustNewGenerator(cfg)
+		for _, format := range sortedKeys(recordFormats) {
+			for _, start := range checksumStarts {
+				h := sha256.New()
+				if _, _, err := writeRecords(ctx, gen, h, recordFormats[format], start, count, nil); err != nil {
+					return r, err
+				}
+				r.Checksums[preset+"/"+format+"/"+strconv.FormatUint(start, 10)] = hex.EncodeToString(h.Sum(nil))
+			}
+		}
+	}
+	return r, nil
+}
+
+// compareChecksums returns the keys whose checksums differ between a and b,
+// including keys only one of them has.
+func compareChecksums(a, b checksumReport) []string {
+	var differ []string
+	for _, key := range sortedKeys(a.Checksums) {
+		if a.Checksums[key] != b.Checksums[key] {
+			differ = append(differ, key)
+		}
+	}
+	for _, key := range sortedKeys(b.Checksums) {
+		if _, ok := a.Checksums[key]; !ok {
+			differ = append(differ, key)
+		}
+	}
+	return differ
+}
+
+func readChecksumReport(path string) (checksumReport, error) {
+	var r checksumReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// runChecksums writes this platform's checksums, or with "compare" checks
+// that reports from several platforms agree.
+func runChecksums(args []string) error {
+	if len(args) > 0 && args[0] == "compare" {
+		return runChecksumsCompare(args[1:])
+	}
+	fs := flag.NewFlagSet("checksums", flag.ContinueOnError)
+	count := fs.Uint64("count", 1000, "records per canonical range")
+	output := fs.String("output", "-", "report file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	r, err := canonicalChecksums(ctx, *count)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+	logFor("checksums").Info("wrote checksums", "output", *output, "platform", r.platform(), "ranges", len(r.Checksums))
+	return nil
+}
+
+func runChecksumsCompare(paths []string) error {
+	if len(paths) < 2 {
+		return errors.New("usage: checksums compare <report.json> <report.json>...")
+	}
+	base, err := readChecksumReport(paths[0])
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, path := range paths[1:] {
+		r, err := readChecksumReport(path)
+		if err != nil {
+			return err
+		}
+		if r.Records != base.Records || r.GeneratorVersion != base.GeneratorVersion {
+			return fmt.Errorf("%s covers %d records of generator version %d, %s covers %d of version %d",
+				path, r.Records, r.GeneratorVersion, paths[0], base.Records, base.GeneratorVersion)
+		}
+		differ := compareChecksums(base, r)
+		if len(differ) == 0 {
+			fmt.Printf("✅ %s matches %s\n", r.platform(), base.platform())
+			continue
+		}
+		failed++
+		fmt.Printf("❌ %s differs from %s in %d of %d ranges:\n", r.platform(), base.platform(), len(differ), len(base.Checksums))
+		for _, key := range differ {
+			fmt.Printf("   %s\n", key)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d platforms do not reproduce %s", failed, len(paths)-1, paths[0])
+	}
+	return nil
+}
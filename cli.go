@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Commands
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"anonymize":    {summary: "replace names, emails, phones and logins in JSONL with deterministic salted tokens", run: runAnonymize},
+	"migrate":      {summary: "upgrade config files to the current format, reporting filled-in defaults", run: runMigrate},
+	"presets":      {summary: "list the built-in config presets or print one as JSON", run: runPresets},
+	"cql":          {summary: "load a record range into Cassandra/ScyllaDB in token-aware prepared batches, or write the CQL script", run: runCQL},
+	"diff":         {summary: "compare configs, manifests or record files", run: runDiff},
+	"edges":        {summary: "export referral, emergency-contact and employer edges of the profiles in a range", run: runEdges},
+	"elastic":      {summary: "write a range as an Elasticsearch/OpenSearch _bulk body or index it into a cluster", run: runElastic},
+	"entities":     {summary: "write a timestamp-ordered stream mixing person, organization, consent and transaction entities with type tags", run: runEntities},
+	"explain":      {summary: "print the full derivation of a record: seeds, bucket, variant, distortions, choices", run: runExplain},
+	"generate":     {summary: "write a record range to a JSONL file with a manifest; erased records are redacted, but their tombstones are only in the entities stream", run: runGenerate},
+	"lookup":       {summary: "print records for indices read from stdin, or search a range by identifier", run: runLookup},
+	"parquet":      {summary: "write a range as a Parquet file with tunable row groups, pages, dictionaries, statistics and sort order", run: runParquet},
+	"orc":          {summary: "write a range as an ORC file with tunable stripes and compression", run: runORC},
+	"paired":       {summary: "write aligned clear and masked copies of a range plus their mapping, for privacy-preserving linkage", run: runPaired},
+	"plan":         {summary: "split a range into balanced, aligned sub-ranges and write them as a plan file", run: runPlan},
+	"profiles":     {summary: "export the distinct profiles referenced by a record range", run: runProfiles},
+	"amqp":         {summary: "publish records to RabbitMQ or another AMQP 0-9-1 broker with publisher confirms", run: runAMQP},
+	"kafka":        {summary: "produce records to Kafka, optionally in the Confluent wire format with a Schema Registry", run: runKafka},
+	"mongo":        {summary: "upsert records into a MongoDB collection keyed by record index, in unordered bulk writes", run: runMongo},
+	"kinesis":      {summary: "put records to an AWS Kinesis data stream or Firehose delivery stream", run: runKinesis},
+	"upload":       {summary: "deliver a manifest's files to an SFTP or WebDAV directory, resuming interrupted deliveries", run: runUpload},
+	"invariants":   {summary: "check determinism, identity-pool and variant invariants on drawn indices and configs", run: runInvariants},
+	"flight":       {summary: "serve Arrow record batches for index ranges over Arrow Flight", run: runFlight},
+	"fuzz":         {summary: "mutate configs and derive records from them, saving inputs that crash", run: runFuzz},
+	"snapshot":     {summary: "compare records at chosen indices with a golden JSON file, or -update it", run: runSnapshot},
+	"profile-data": {summary: "write a data dictionary of a range: per-field types, null rates, cardinality, top values and histograms, as JSON or HTML", run: runProfileData},
+	"schema":       {summary: "print the record layout as a JSON Schema or Avro schema document", run: runSchema},
+	"collisions":   {summary: "count accidental duplicates from the index-to-profile hash and recommend a profileSpaceSize", run: runCollisions},
+	"checksums":    {summary: "write checksums of canonical ranges for this platform, or compare reports across platforms", run: runChecksums},
+	"bench":        {summary: "compare two go test -bench outputs and fail on regressions", run: runBench},
+	"validate":     {summary: "check JSONL records against field rules: schema types, email syntax, E.164 phones, RFC 3339 times, amount bounds", run: runValidate},
+	"canaries":     {summary: "list the canary records of a range, or check an output for all of them in order", run: runCanaries},
+	"sweep":        {summary: "generate one dataset per combination of a grid of config overrides and summarize them", run: runSweep},
+	"scenario":     {summary: "compile a multi-phase scenario file into index ranges and time windows, or generate every phase", run: runScenario},
+	"redis":        {summary: "load profiles and identifier→profile lookups into Redis, or write them for redis-cli --pipe", run: runRedis},
+	"registry":     {summary: "list, verify and add named frozen datasets", run: runRegistry},
+	"sample":       {summary: "emit a reproducible stride or hash-based subset of a range", run: runSample},
+	"serve":        {summary: "run the HTTP data-generation service", run: runServe},
+	"bigquery":     {summary: "write a range as JSONL with a BigQuery table schema and load it with a load job, optionally staged in Cloud Storage", run: runBigQuery},
+	"coordinate":   {summary: "split a range across workers and merge their manifests", run: runCoordinate},
+	"source":       {summary: "read a bounded or unbounded record range to stdout with checkpoint files a restarted reader resumes from", run: runSource},
+	"sql":          {summary: "load a record range, and optionally its profiles, into a SQLite or DuckDB database", run: runSQL},
+	"snowflake":    {summary: "write gzip CSV chunks for a Snowflake stage with the COPY INTO load script", run: runSnowflake},
+	"socket":       {summary: "stream length-prefixed records over a unix socket", run: runSocket},
+	"stats":        {summary: "report cluster sizes, distortion rates and distributions for a range", run: runStats},
+	"work":         {summary: "generate ranges leased from a coordinator", run: runWork},
+}
+
+func runCommand(name string, args []string) int {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage()
+		return 2
+	}
+
+	if err := setupLogging(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 2
+	}
+	flushTraces := setupTracing()
+	defer flushTraces()
+
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: generator [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nWithout a command the 1M-record benchmark is run.\n\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+// configFlags are the flags every command uses to pick its generator config.
+// After load, overrides holds the -set and GEN_* values that were applied.
+type configFlags struct {
+	path      string
+	preset    string
+	named     string
+	sets      stringList
+	overrides []ConfigOverride
+}
+
+func addConfigFlags(fs *flag.FlagSet, usage string) *configFlags {
+	c := &configFlags{}
+	fs.StringVar(&c.path, "config", "", usage)
+	fs.StringVar(&c.preset, "preset", "", "start from a named preset ("+strings.Join(presetNames(), ", ")+"); -config is applied on top")
+	fs.StringVar(&c.named, "named", "", "use a registered dataset by name (see the registry command); excludes other config flags")
+	fs.Var(&c.sets, "set", "override a config value as key=value, e.g. distortions.typo=0.2 (repeatable; GEN_* env vars work too)")
+	return c
+}
+
+// load resolves the config: a registered dataset, or else preset, then config file, then environment
+// overrides, then -set flags.
+func (c *configFlags) load() (GeneratorConfig, error) {
+	if c.named != "" {
+		if c.path != "" || c.preset != "" || len(c.sets) > 0 || len(envOverrides()) > 0 {
+			return GeneratorConfig{}, errors.New("-named datasets are frozen and cannot be combined with -config, -preset, -set or GEN_* overrides")
+		}
+		return resolveRegistered(c.named)
+	}
+	cfg, err := loadConfig(c.preset, c.path)
+	if err != nil {
+		return cfg, err
+	}
+	sets, err := parseSetFlags(c.sets)
+	if err != nil {
+		return cfg, err
+	}
+	c.overrides = append(envOverrides(), sets...)
+	return applyOverrides(cfg, c.overrides)
+}
+
+// loadConfig starts from the named preset (or the default config when
+// preset is empty) and applies the JSON config at path on top of it.
+func loadConfig(preset, path string) (GeneratorConfig, error) {
+	base := cloneConfig(defaultConfig)
+	if preset != "" {
+		var err error
+		if base, err = presetConfig(preset); err != nil {
+			return GeneratorConfig{}, err
+		}
+	}
+	if path == "" {
+		return base, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GeneratorConfig{}, err
+	}
+	return parseConfigOnto(base, data)
+}
+
+// loadConfigFile reads a JSON config from path, or returns the default config
+// when path is empty.
+func loadConfigFile(path string) (GeneratorConfig, error) {
+	return loadConfig("", path)
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// interruptContext is canceled on SIGINT or SIGTERM, so long-running
+// commands stop between batches instead of dying mid-write.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Collision analysis: records are assigned to profiles by hashing the index
+// modulo ProfileSpaceSize, so two records can land on the same profile and
+// variant by chance. Such a record is an accidental extra duplicate — a
+// copy the bucket's repeat multiplier did not ask for, and in a x1 bucket a
+// cluster that should not exist at all. The analyzer counts them in a range,
+// compares the count with the birthday-problem expectation and recommends
+// the smallest profile space keeping them under a target rate.
+
+// CollisionReport is the analysis of one range.
+type CollisionReport struct {
+	Start            uint64 `json:"start"`
+	Count            uint64 `json:"count"`
+	ProfileSpaceSize uint64 `json:"profileSpaceSize"`
+	DistinctProfiles int    `json:"distinctProfiles"`
+	// Collisions are records whose profile and variant an earlier record in
+	// the range already had.
+	Collisions         uint64  `json:"collisions"`
+	CollisionRate      float64 `json:"collisionRate"`
+	ExpectedCollisions float64 `json:"expectedCollisions"`
+	// OverfullProfiles have more records than their bucket's multiplier.
+	OverfullProfiles int                     `json:"overfullProfiles"`
+	Buckets          []BucketCollisionReport `json:"buckets"`
+	TargetRate       float64                 `json:"targetRate"`
+	// RecommendedProfileSpaceSize is the smallest space whose expected
+	// collision rate over Count records is at most TargetRate.
+	RecommendedProfileSpaceSize uint64 `json:"recommendedProfileSpaceSize"`
+}
+
+type BucketCollisionReport struct {
+	Weight             int     `json:"weight"`
+	RepeatMultiplier   int     `json:"repeatMultiplier"`
+	Records            uint64  `json:"records"`
+	Collisions         uint64  `json:"collisions"`
+	ExpectedCollisions float64 `json:"expectedCollisions"`
+}
+
+// expectedCollisions is the expected number of count records that hit an
+// already-used profile/variant slot when each picks a profile uniformly
+// from space and a variant uniformly below its bucket's multiplier.
+func expectedCollisions(buckets []FrequencyBucket, space, count uint64) (total float64, perBucket []float64) {
+	totalWeight := 0
+	for _, b := range buckets {
+		totalWeight += b.Weight
+	}
+	perBucket = make([]float64, len(buckets))
+	for i, b := range buckets {
+		share := float64(b.Weight) / float64(totalWeight)
+		n := float64(count) * share
+		slots := float64(space) * share * float64(max(b.RepeatMultiplier, 1))
+		if slots == 0 {
+			continue
+		}
+		// n records into slots bins leave slots·(1-e^(-n/slots)) bins used.
+		perBucket[i] = n + slots*math.Expm1(-n/slots)
+		total += perBucket[i]
+	}
+	return total, perBucket
+}
+
+// recommendedSpace is the smallest profile space whose expected collision
+// rate over count records is at most target.
+func recommendedSpace(buckets []FrequencyBucket, count uint64, target float64) uint64 {
+	if count == 0 {
+		return 1
+	}
+	rate := func(space uint64) float64 {
+		c, _ := expectedCollisions(buckets, space, count)
+		return c / float64(count)
+	}
+	lo, hi := uint64(1), uint64(1)
+	for rate(hi) > target {
+		if hi > math.MaxUint64/2 {
+			return hi
+		}
+		lo, hi = hi, hi*2
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if rate(mid) > target {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+func analyzeCollisions(ctx context.Context, cfg GeneratorConfig, start, count uint64, target float64) (CollisionReport, error) {
+	r := CollisionReport{Start: start, Count: count, ProfileSpaceSize: cfg.ProfileSpaceSize, TargetRate: target}
+	type slot struct {
+		profileID uint64
+		variant   int
+	}
+	used := make(map[slot]struct{})
+	perProfile := make(map[uint64]int)
+	r.Buckets = make([]BucketCollisionReport, len(cfg.Buckets))
+	for i, b := range cfg.Buckets {
+		r.Buckets[i] = BucketCollisionReport{Weight: b.Weight, RepeatMultiplier: b.RepeatMultiplier}
+	}
+
+	// Only the index-to-profile mapping is analyzed, so records are not
+	// built: fraud pinning and refunds, which reassign profiles on purpose,
+	// are left out.
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return r, err
+			}
+		}
+		idx := start + i
+		profileID := profileIDForIndex(idx, cfg)
+		b := bucketPosition(profileID, cfg)
+		s := slot{profileID, variantForIndex(idx, cfg.Buckets[b].RepeatMultiplier, cfg.Seed)}
+		r.Buckets[b].Records++
+		if _, ok := used[s]; ok {
+			r.Collisions++
+			r.Buckets[b].Collisions++
+		} else {
+			used[s] = struct{}{}
+		}
+		perProfile[profileID]++
+	}
+
+	r.DistinctProfiles = len(perProfile)
+	for profileID, n := range perProfile {
+		if n > max(cfg.Buckets[bucketPosition(profileID, cfg)].RepeatMultiplier, 1) {
+			r.OverfullProfiles++
+		}
+	}
+	if count > 0 {
+		r.CollisionRate = float64(r.Collisions) / float64(count)
+	}
+	var perBucket []float64
+	r.ExpectedCollisions, perBucket = expectedCollisions(cfg.Buckets, cfg.ProfileSpaceSize, count)
+	for i := range r.Buckets {
+		r.Buckets[i].ExpectedCollisions = perBucket[i]
+	}
+	r.RecommendedProfileSpaceSize = recommendedSpace(cfg.Buckets, count, target)
+	return r, nil
+}
+
+func printCollisions(w io.Writer, r CollisionReport) {
+	fmt.Fprintf(w, "🎲 Records [%d, +%d) over a profile space of %d\n", r.Start, r.Count, r.ProfileSpaceSize)
+	fmt.Fprintf(w, "   Distinct profiles: %d\n", r.DistinctProfiles)
+	fmt.Fprintf(w, "   Collisions:        %d (%.3f%%, expected %.0f)\n", r.Collisions, r.CollisionRate*100, r.ExpectedCollisions)
+	fmt.Fprintf(w, "   Overfull profiles: %d (more records than their bucket's multiplier)\n", r.OverfullProfiles)
+	fmt.Fprintln(w, "\n🪣 By bucket:")
+	for _, b := range r.Buckets {
+		fmt.Fprintf(w, "   weight %-4d x%-4d %d records, %d collisions (expected %.0f)\n", b.Weight, b.RepeatMultiplier, b.Records, b.Collisions, b.ExpectedCollisions)
+	}
+	fmt.Fprintf(w, "\n💡 For at most %.3f%% collisions over %d records use profileSpaceSize ≥ %d", r.TargetRate*100, r.Count, r.RecommendedProfileSpaceSize)
+	if r.ProfileSpaceSize >= r.RecommendedProfileSpaceSize {
+		fmt.Fprintln(w, " (the config's space is large enough)")
+	} else {
+		fmt.Fprintln(w)
+	}
+}
+
+func runCollisions(args []string) error {
+	fs := flag.NewFlagSet("collisions", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records to analyze")
+	target := fs.Float64("target", 0.01, "acceptable share of accidental duplicates for the recommendation")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target <= 0 || *target >= 1 {
+		return fmt.Errorf("-target must be in (0, 1), got %g", *target)
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	r, err := analyzeCollisions(ctx, cfg, *start, *count, *target)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	printCollisions(os.Stdout, r)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+)
+
+// Columnar output writes a range in groups of rows — Parquet row groups,
+// ORC stripes — each encoded column by column. Records of a group can be
+// written in index order or ordered by profile, which clusters a profile's
+// duplicates in the same pages and lets readers prune on profileId. The
+// generator derives any record from its index, so ordering costs one sort
+// of the group's indices by owner, not a buffer of records.
+
+// ColumnarLayout is the row grouping of a columnar file.
+type ColumnarLayout struct {
+	// GroupRows is the number of records per row group or stripe.
+	GroupRows uint64
+	// SortByProfile orders each group by profileId, then recordIndex.
+	SortByProfile bool
+}
+
+func (l ColumnarLayout) validate() error {
+	if l.GroupRows == 0 {
+		return errors.New("rows per group must be positive")
+	}
+	return nil
+}
+
+// groupOrder returns the indices [from, from+n) in the order they are
+// written.
+func (g *IdempotentGenerator) groupOrder(from, n uint64, byProfile bool) []uint64 {
+	order := make([]uint64, n)
+	if !byProfile {
+		for i := range order {
+			order[i] = from + uint64(i)
+		}
+		return order
+	}
+	type owned struct{ profileID, index uint64 }
+	rows := make([]owned, n)
+	for i := range rows {
+		idx := from + uint64(i)
+		rows[i] = owned{g.owner(idx).profileID, idx}
+	}
+	slices.SortFunc(rows, func(a, b owned) int {
+		return cmp.Or(cmp.Compare(a.profileID, b.profileID), cmp.Compare(a.index, b.index))
+	})
+	for i, r := range rows {
+		order[i] = r.index
+	}
+	return order
+}
+
+// writeColumnarGroups builds the records of [start, start+count) group by
+// group in the layout's order. It hands them to write in batches and calls
+// endGroup after the last batch of each group.
+func writeColumnarGroups(ctx context.Context, g *IdempotentGenerator, start, count uint64, l ColumnarLayout, write func([]RawRecord) error, endGroup func() error) error {
+	if err := l.validate(); err != nil {
+		return err
+	}
+	batch := make([]RawRecord, 0, progressInterval)
+	for off := uint64(0); off < count; off += l.GroupRows {
+		order := g.groupOrder(start+off, min(l.GroupRows, count-off), l.SortByProfile)
+		for len(order) > 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			n := min(len(order), progressInterval)
+			batch = batch[:0]
+			for _, idx := range order[:n] {
+				batch = append(batch, g.RecordByIndex(idx))
+			}
+			if err := write(batch); err != nil {
+				return err
+			}
+			order = order[n:]
+		}
+		if err := endGroup(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
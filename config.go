@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// cloneConfig returns a deep copy of cfg so callers can mutate slices freely.
+func cloneConfig(cfg GeneratorConfig) GeneratorConfig {
+	out := cfg
+	out.Buckets = append([]FrequencyBucket(nil), cfg.Buckets...)
+	out.Pools = Pools{
+		FirstNames: append([]string(nil), cfg.Pools.FirstNames...),
+		LastNames:  append([]string(nil), cfg.Pools.LastNames...),
+		Cities:     append([]string(nil), cfg.Pools.Cities...),
+		Channels:   append([]string(nil), cfg.Pools.Channels...),
+		POS:        append([]string(nil), cfg.Pools.POS...),
+	}
+	out.Emails = cfg.Emails.clone()
+	out.EmailAliases = cfg.EmailAliases.clone()
+	out.Logins = cfg.Logins.clone()
+	out.CityTimezones = cloneTimezones(cfg.CityTimezones)
+	out.Geography = cfg.Geography.clone()
+	out.FieldAvailability = cloneAvailability(cfg.FieldAvailability)
+	out.Amounts = cfg.Amounts.clone()
+	out.Demographics = cfg.Demographics.clone()
+	out.Lifecycle = cfg.Lifecycle.clone()
+	out.Sessions = cfg.Sessions.clone()
+	out.Events = cfg.Events.clone()
+	out.Fraud = cfg.Fraud.clone()
+	out.Relationships = cfg.Relationships.clone()
+	out.Organizations = cfg.Organizations.clone()
+	out.Retention = cfg.Retention.clone()
+	out.Erasure = cfg.Erasure.clone()
+	if cfg.Plugins != nil {
+		out.Plugins = append([]PluginConfig(nil), cfg.Plugins...)
+		for i := range out.Plugins {
+			out.Plugins[i].Fields = slices.Clone(out.Plugins[i].Fields)
+		}
+	}
+	out.Canaries = cfg.Canaries.clone()
+	if cfg.OutputFields != nil {
+		out.OutputFields = append([]string(nil), cfg.OutputFields...)
+	}
+	return out
+}
+
+// parseConfig decodes a JSON config on top of the defaults, so partial
+// configs only need to mention the knobs they change.
+func parseConfig(data []byte) (GeneratorConfig, error) {
+	return parseConfigOnto(cloneConfig(defaultConfig), data)
+}
+
+// parseConfigOnto is parseConfig with an explicit base, used for presets.
+func parseConfigOnto(base GeneratorConfig, data []byte) (GeneratorConfig, error) {
+	data, _, err := migrateConfig(data)
+	if err != nil {
+		return GeneratorConfig{}, err
+	}
+	cfg := base
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return GeneratorConfig{}, fmt.Errorf("parse config: %w", err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		return GeneratorConfig{}, err
+	}
+	return cfg, nil
+}
+
+func validateConfig(cfg GeneratorConfig) error {
+	if cfg.ProfileSpaceSize == 0 {
+		return errors.New("profileSpaceSize must be positive")
+	}
+	if len(cfg.Buckets) == 0 {
+		return errors.New("at least one bucket is required")
+	}
+	total := 0
+	for i, b := range cfg.Buckets {
+		if b.Weight < 0 {
+			return fmt.Errorf("buckets[%d]: weight must not be negative", i)
+		}
+		if b.RepeatMultiplier < 1 {
+			return fmt.Errorf("buckets[%d]: repeatMultiplier must be at least 1", i)
+		}
+		total += b.Weight
+	}
+	if total == 0 {
+		return errors.New("bucket weights must not all be zero")
+	}
+	if !cfg.DateSpread.End.After(cfg.DateSpread.Start) {
+		return errors.New("dateSpread.end must be after dateSpread.start")
+	}
+
+	pools := map[string][]string{
+		"firstNames": cfg.Pools.FirstNames,
+		"lastNames":  cfg.Pools.LastNames,
+		"cities":     cfg.Pools.Cities,
+		"channels":   cfg.Pools.Channels,
+		"pos":        cfg.Pools.POS,
+	}
+	for name, pool := range pools {
+		if len(pool) == 0 {
+			return fmt.Errorf("pools.%s must not be empty", name)
+		}
+	}
+
+	if cfg.Emails != nil {
+		if err := cfg.Emails.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.EmailAliases != nil {
+		if err := cfg.EmailAliases.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Logins != nil {
+		if err := cfg.Logins.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Geography != nil {
+		if err := cfg.Geography.validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateAvailability(cfg.FieldAvailability); err != nil {
+		return err
+	}
+	if cfg.Amounts != nil {
+		if err := cfg.Amounts.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Demographics != nil {
+		if err := cfg.Demographics.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Lifecycle != nil {
+		if err := cfg.Lifecycle.validate(cfg.DateSpread); err != nil {
+			return err
+		}
+	}
+	if cfg.Sessions != nil {
+		if err := cfg.Sessions.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Events != nil {
+		if err := cfg.Events.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Fraud != nil {
+		if err := cfg.Fraud.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Relationships != nil {
+		if err := cfg.Relationships.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Organizations != nil {
+		if err := cfg.Organizations.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Retention != nil {
+		if err := cfg.Retention.validate(cfg.Pools.Cities); err != nil {
+			return err
+		}
+	}
+	if cfg.Erasure != nil {
+		if err := cfg.Erasure.validate(cfg.ProfileSpaceSize); err != nil {
+			return err
+		}
+	}
+	if err := validatePlugins(cfg.Plugins); err != nil {
+		return err
+	}
+	if cfg.Canaries != nil {
+		if err := cfg.Canaries.validate(); err != nil {
+			return err
+		}
+	}
+	if _, err := newSchema(cfg.OutputFields, customFields(cfg.Plugins)); err != nil {
+		return err
+	}
+	if cfg.CityTimezones != nil {
+		if err := validateTimezones(cfg.CityTimezones, cfg.Pools.Cities); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configHash identifies a config by the hash of its canonical JSON encoding.
+// The format version is left out: it describes the file, not the data, so
+// migrating a config must not change the hash of what it generates.
+func configHash(cfg GeneratorConfig) string {
+	cfg.ConfigVersion = 0
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%016x", fnv1a64(string(data)))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka Connect output: each record in the shape a source connector emits
+// through the JsonConverter with schemas enabled, so the stream can replace
+// a real connector in pipeline tests. Records are keyed by profileId, so
+// Kafka's default partitioner keeps a profile's records in order.
+
+const connectTopic = "records"
+
+var connectTypes = map[FieldType]string{FieldString: "string", FieldInt: "int64", FieldUint: "int64", FieldFloat: "float64"}
+
+var formatConnect = recordFormat{name: "connect", appendRecord: appendConnectRecord}
+
+func init() {
+	recordFormats[formatConnect.name] = formatConnect
+}
+
+// connectSchemas caches the value schema JSON of each Schema.
+var connectSchemas sync.Map // *Schema → []byte
+
+// connectValueSchema is the Connect struct schema of records under s.
+func connectValueSchema(s *Schema) []byte {
+	if b, ok := connectSchemas.Load(s); ok {
+		return b.([]byte)
+	}
+	b := []byte(`{"type":"struct","name":"generator.Record","optional":false,"version":`)
+	b = strconv.AppendInt(b, recordSchemaVersion, 10)
+	b = append(b, `,"fields":[`...)
+	for i, f := range s.Fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"field":`...)
+		b = appendJSONString(b, f.Name)
+		b = append(b, `,"type":"`...)
+		b = append(b, connectTypes[f.Type]...)
+		b = append(b, `","optional":`...)
+		b = strconv.AppendBool(b, f.Optional || f.ptr)
+		if f.Sensitivity != "" {
+			b = append(b, `,"parameters":{"sensitivity":`...)
+			b = appendJSONString(b, string(f.Sensitivity))
+			b = append(b, '}')
+		}
+		b = append(b, '}')
+	}
+	b = append(b, "]}"...)
+	connectSchemas.Store(s, b)
+	return b
+}
+
+func appendConnectRecord(s *Schema, b []byte, rec *RawRecord) []byte {
+	b = append(b, `{"topic":"`+connectTopic+`","kafkaPartition":null,"sourcePartition":{"generator":"idempotent-entries"},"sourceOffset":{"recordIndex":`...)
+	b = strconv.AppendUint(b, rec.RecordIndex, 10)
+	b = append(b, `},"timestamp":`...)
+	ts, _ := time.Parse(time.RFC3339, rec.Timestamp)
+	b = strconv.AppendInt(b, ts.UnixMilli(), 10)
+	b = append(b, `,"key":{"schema":{"type":"int64","optional":false},"payload":`...)
+	b = strconv.AppendUint(b, rec.ProfileID, 10)
+	b = append(b, `},"value":{"schema":`...)
+	b = append(b, connectValueSchema(s)...)
+	b = append(b, `,"payload":`...)
+	b = s.AppendJSON(b, rec)
+	return append(b, "}}\n"...)
+}
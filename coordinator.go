@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Distributed generation: a coordinator splits an index range into chunks
+// and leases them to workers. A lease that is not renewed by progress reports
+// expires and the chunk goes back to the queue; since record content depends
+// only on index and config, a re-run chunk is byte-identical.
+type rangeState string
+
+const (
+	rangePending rangeState = "pending"
+	rangeLeased  rangeState = "leased"
+	rangeDone    rangeState = "done"
+)
+
+type workRange struct {
+	ID       int           `json:"id"`
+	Start    uint64        `json:"start"`
+	Count    uint64        `json:"count"`
+	State    rangeState    `json:"state"`
+	Worker   string        `json:"worker,omitempty"`
+	Written  uint64        `json:"written"`
+	Attempts int           `json:"attempts"`
+	LastErr  string        `json:"lastError,omitempty"`
+	File     *ManifestFile `json:"file,omitempty"`
+
+	deadline time.Time
+}
+
+// Assignment is what a worker receives for one leased range.
+type Assignment struct {
+	RangeID    int             `json:"rangeId"`
+	Start      uint64          `json:"start"`
+	Count      uint64          `json:"count"`
+	ConfigHash string          `json:"configHash"`
+	Config     GeneratorConfig `json:"config"`
+	LeaseUntil time.Time       `json:"leaseUntil"`
+}
+
+var (
+	errNoWork       = errors.New("no range available right now")
+	errAllDone      = errors.New("all ranges completed")
+	errUnknownRange = errors.New("unknown range")
+	errLeaseLost    = errors.New("range is not leased to this worker")
+)
+
+type Coordinator struct {
+	cfg          GeneratorConfig
+	cfgHash      string
+	start, count uint64
+	leaseTimeout time.Duration
+
+	mu      sync.Mutex
+	ranges  []*workRange
+	workers map[string]time.Time
+	done    chan struct{}
+}
+
+func NewCoordinator(cfg GeneratorConfig, start, count, chunk uint64, leaseTimeout time.Duration) *Coordinator {
+	c := &Coordinator{
+		cfg:          cfg,
+		cfgHash:      configHash(cfg),
+		start:        start,
+		count:        count,
+		leaseTimeout: leaseTimeout,
+		workers:      make(map[string]time.Time),
+		done:         make(chan struct{}),
+	}
+	for off := uint64(0); off < count; off += chunk {
+		n := chunk
+		if count-off < n {
+			n = count - off
+		}
+		c.ranges = append(c.ranges, &workRange{ID: len(c.ranges), Start: start + off, Count: n, State: rangePending})
+	}
+	if len(c.ranges) == 0 {
+		close(c.done)
+	}
+	return c
+}
+
+// Done is closed once every range has completed.
+func (c *Coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Coordinator) Lease(worker string) (Assignment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.workers[worker] = now
+	c.expireLocked(now)
+
+	allDone := true
+	for _, r := range c.ranges {
+		if r.State != rangeDone {
+			allDone = false
+		}
+		if r.State != rangePending {
+			continue
+		}
+		r.State = rangeLeased
+		r.Worker = worker
+		r.Written = 0
+		r.Attempts++
+		r.deadline = now.Add(c.leaseTimeout)
+		return Assignment{
+			RangeID:    r.ID,
+			Start:      r.Start,
+			Count:      r.Count,
+			ConfigHash: c.cfgHash,
+			Config:     c.cfg,
+			LeaseUntil: r.deadline,
+		}, nil
+	}
+	if allDone {
+		return Assignment{}, errAllDone
+	}
+	return Assignment{}, errNoWork
+}
+
+func (c *Coordinator) Progress(worker string, rangeID int, written uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, err := c.leasedLocked(worker, rangeID)
+	if err != nil {
+		return err
+	}
+	r.Written = written
+	r.deadline = time.Now().Add(c.leaseTimeout)
+	c.workers[worker] = time.Now()
+	return nil
+}
+
+func (c *Coordinator) Complete(worker string, rangeID int, file ManifestFile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, err := c.leasedLocked(worker, rangeID)
+	if err != nil {
+		return err
+	}
+	if file.Start != r.Start || file.Count != r.Count {
+		return fmt.Errorf("file covers [%d,+%d), range is [%d,+%d)", file.Start, file.Count, r.Start, r.Count)
+	}
+	file.Worker = worker
+	r.File = &file
+	r.State = rangeDone
+	r.Written = r.Count
+	c.workers[worker] = time.Now()
+
+	for _, other := range c.ranges {
+		if other.State != rangeDone {
+			return nil
+		}
+	}
+	close(c.done)
+	return nil
+}
+
+func (c *Coordinator) Fail(worker string, rangeID int, msg string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, err := c.leasedLocked(worker, rangeID)
+	if err != nil {
+		return err
+	}
+	r.State = rangePending
+	r.Worker = ""
+	r.LastErr = msg
+	return nil
+}
+
+func (c *Coordinator) leasedLocked(worker string, rangeID int) (*workRange, error) {
+	if rangeID < 0 || rangeID >= len(c.ranges) {
+		return nil, errUnknownRange
+	}
+	r := c.ranges[rangeID]
+	if r.State != rangeLeased || r.Worker != worker {
+		return nil, errLeaseLost
+	}
+	return r, nil
+}
+
+// expireLocked returns ranges whose lease ran out to the queue.
+func (c *Coordinator) expireLocked(now time.Time) {
+	for _, r := range c.ranges {
+		if r.State == rangeLeased && now.After(r.deadline) {
+			r.State = rangePending
+			r.LastErr = fmt.Sprintf("lease held by %s expired", r.Worker)
+			r.Worker = ""
+		}
+	}
+}
+
+// Manifest merges the per-range files into one manifest for the whole run.
+func (c *Coordinator) Manifest() Manifest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := Manifest{
+		ConfigHash: c.cfgHash,
+		Config:     c.cfg,
+		Format:     "jsonl",
+		Start:      c.start,
+		Count:      c.count,
+		CreatedAt:  time.Now().UTC(),
+	}
+	for _, r := range c.ranges {
+		if r.File != nil {
+			m.Files = append(m.Files, *r.File)
+		}
+	}
+	return m
+}
+
+type coordinatorStatus struct {
+	ConfigHash string               `json:"configHash"`
+	Ranges     []workRange          `json:"ranges"`
+	Workers    map[string]time.Time `json:"workers"`
+	Done       int                  `json:"done"`
+	Total      int                  `json:"total"`
+}
+
+func (c *Coordinator) Status() coordinatorStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLocked(time.Now())
+	st := coordinatorStatus{ConfigHash: c.cfgHash, Workers: make(map[string]time.Time), Total: len(c.ranges)}
+	for _, r := range c.ranges {
+		st.Ranges = append(st.Ranges, *r)
+		if r.State == rangeDone {
+			st.Done++
+		}
+	}
+	for w, seen := range c.workers {
+		st.Workers[w] = seen
+	}
+	return st
+}
+
+// HTTP transport
+type rangeReport struct {
+	Worker  string        `json:"worker"`
+	RangeID int           `json:"rangeId"`
+	Written uint64        `json:"written,omitempty"`
+	File    *ManifestFile `json:"file,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.Status())
+	})
+	mux.HandleFunc("GET /manifest", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.Manifest())
+	})
+	mux.HandleFunc("POST /lease", func(w http.ResponseWriter, r *http.Request) {
+		var req rangeReport
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Worker == "" {
+			writeError(w, http.StatusBadRequest, errors.New("worker id is required"))
+			return
+		}
+		a, err := c.Lease(req.Worker)
+		switch {
+		case errors.Is(err, errNoWork):
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, errAllDone):
+			writeError(w, http.StatusGone, err)
+		default:
+			writeJSON(w, http.StatusOK, a)
+		}
+	})
+	mux.HandleFunc("POST /progress", c.reportHandler(func(rep rangeReport) error {
+		return c.Progress(rep.Worker, rep.RangeID, rep.Written)
+	}))
+	mux.HandleFunc("POST /complete", c.reportHandler(func(rep rangeReport) error {
+		if rep.File == nil {
+			return errors.New("file is required")
+		}
+		return c.Complete(rep.Worker, rep.RangeID, *rep.File)
+	}))
+	mux.HandleFunc("POST /fail", c.reportHandler(func(rep rangeReport) error {
+		return c.Fail(rep.Worker, rep.RangeID, rep.Error)
+	}))
+	return mux
+}
+
+func (c *Coordinator) reportHandler(apply func(rangeReport) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rep rangeReport
+		if err := json.NewDecoder(r.Body).Decode(&rep); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := apply(rep); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errLeaseLost) {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func runCoordinate(args []string) error {
+	fs := flag.NewFlagSet("coordinate", flag.ContinueOnError)
+	addr := fs.String("addr", ":8090", "listen address for workers")
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	chunk := fs.Uint64("chunk", 10_000_000, "records per leased range")
+	lease := fs.Duration("lease", time.Minute, "lease timeout before a silent worker's range is reassigned")
+	manifestPath := fs.String("manifest", "manifest.json", "where to write the merged manifest")
+	grpcAddr := fs.String("grpc-addr", "", "also serve the gRPC worker protocol (worker.proto) on this address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *chunk == 0 {
+		return errors.New("-chunk must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	coord := NewCoordinator(cfg, *start, *count, *chunk, *lease)
+	srv := &http.Server{Addr: *addr, Handler: coord.Handler()}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logFor("coordinator").Error("http server failed", "err", err)
+			os.Exit(1)
+		}
+	}()
+	if *grpcAddr != "" {
+		ln, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		defer serveWorkerGRPC(coord, ln).Stop()
+	}
+	logFor("coordinator").Info("coordinating", "records", *count, "ranges", len(coord.ranges), "addr", *addr, "configHash", coord.cfgHash)
+
+	<-coord.Done()
+	manifest := coord.Manifest()
+	manifest.Overrides = config.overrides
+	if err := writeManifest(*manifestPath, manifest); err != nil {
+		return err
+	}
+	logFor("coordinator").Info("all ranges complete", "manifest", *manifestPath)
+
+	// Give polling workers a moment to learn that the run is over.
+	time.Sleep(2 * time.Second)
+	return srv.Shutdown(context.Background())
+}
+
+func runWork(args []string) error {
+	fs := flag.NewFlagSet("work", flag.ContinueOnError)
+	coordURL := fs.String("coordinator", "http://localhost:8090", "coordinator URL (http://host:port or grpc://host:port)")
+	hostname, _ := os.Hostname()
+	workerID := fs.String("worker-id", fmt.Sprintf("%s-%d", hostname, os.Getpid()), "unique worker name")
+	outputDir := fs.String("output-dir", "output", "directory for generated range files")
+	out := addOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return err
+	}
+
+	client, err := dialCoordinator(*coordURL, *workerID)
+	if err != nil {
+		return err
+	}
+	log := out.logger("worker").With("worker", *workerID)
+	for {
+		a, err := client.AssignRange()
+		switch {
+		case errors.Is(err, errAllDone):
+			log.Info("coordinator reports all ranges complete")
+			return nil
+		case errors.Is(err, errNoWork):
+			time.Sleep(2 * time.Second)
+			continue
+		case err != nil:
+			return err
+		}
+
+		if err := validateConfig(a.Config); err != nil || configHash(a.Config) != a.ConfigHash {
+			return fmt.Errorf("config from coordinator does not match hash %s", a.ConfigHash)
+		}
+
+		log.Info("range assigned", "range", a.RangeID, "start", a.Start, "count", a.Count)
+		path := filepath.Join(*outputDir, fmt.Sprintf("part-%020d-%d.jsonl", a.Start, a.Count))
+		gen, err := NewIdempotentGenerator(a.Config)
+		if err != nil {
+			client.ReportProgress(a.RangeID, 0, err.Error())
+			return err
+		}
+		progress := out.tracker(path, a.Count)
+		var lastReport time.Time
+		file, err := writeRangeFile(context.Background(), gen, formatJSONL, path, a.Start, a.Count, func(n uint64, bytes int64) {
+			progress.update(n, bytes)
+			if time.Since(lastReport) > 2*time.Second {
+				lastReport = time.Now()
+				client.ReportProgress(a.RangeID, n, "")
+			}
+		})
+		progress.finish()
+		if err != nil {
+			client.ReportProgress(a.RangeID, 0, err.Error())
+			return err
+		}
+		if err := client.UploadManifest(a.RangeID, file); err != nil {
+			log.Warn("manifest upload failed", "range", a.RangeID, "err", err)
+		}
+	}
+}
+
+// coordinatorTransport is how a worker talks to the coordinator. A non-empty
+// failure passed to ReportProgress gives the range back for reassignment.
+type coordinatorTransport interface {
+	AssignRange() (Assignment, error)
+	ReportProgress(rangeID int, written uint64, failure string) error
+	UploadManifest(rangeID int, file ManifestFile) error
+}
+
+func dialCoordinator(url, worker string) (coordinatorTransport, error) {
+	if addr, ok := strings.CutPrefix(url, "grpc://"); ok {
+		return dialGRPCCoordinator(addr, worker)
+	}
+	return &httpCoordinatorClient{base: strings.TrimRight(url, "/"), worker: worker}, nil
+}
+
+type httpCoordinatorClient struct {
+	base   string
+	worker string
+}
+
+func (c *httpCoordinatorClient) AssignRange() (Assignment, error) {
+	var a Assignment
+	body, _ := json.Marshal(rangeReport{Worker: c.worker})
+	resp, err := http.Post(c.base+"/lease", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return a, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.NewDecoder(resp.Body).Decode(&a)
+		return a, err
+	case http.StatusNoContent:
+		return a, errNoWork
+	case http.StatusGone:
+		return a, errAllDone
+	default:
+		return a, fmt.Errorf("lease: unexpected status %s", resp.Status)
+	}
+}
+
+func (c *httpCoordinatorClient) ReportProgress(rangeID int, written uint64, failure string) error {
+	if failure != "" {
+		return c.post("/fail", rangeReport{RangeID: rangeID, Error: failure})
+	}
+	return c.post("/progress", rangeReport{RangeID: rangeID, Written: written})
+}
+
+func (c *httpCoordinatorClient) UploadManifest(rangeID int, file ManifestFile) error {
+	return c.post("/complete", rangeReport{RangeID: rangeID, File: &file})
+}
+
+func (c *httpCoordinatorClient) post(path string, rep rangeReport) error {
+	rep.Worker = c.worker
+	body, _ := json.Marshal(rep)
+	resp, err := http.Post(c.base+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s: %s %s", path, resp.Status, e.Error)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+)
+
+// Cassandra/ScyllaDB loading into a table partitioned by profileId, either
+// through the driver or as a CQL script for cqlsh. Records are grouped by
+// partition within each window of progressInterval records and written as
+// one unlogged batch per partition. The driver prepares the insert and
+// routes each batch to a replica owning its partition.
+
+var cqlTypes = map[FieldType]string{FieldString: "text", FieldInt: "bigint", FieldUint: "bigint", FieldFloat: "double"}
+
+// appendCQLInsert appends an INSERT of rec. Unset fields are left out of
+// the column list rather than written as null, which would create
+// tombstones.
+func appendCQLInsert(b []byte, table string, s *Schema, rec *RawRecord) []byte {
+	var cols, vals []byte
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			continue
+		}
+		if len(cols) > 0 {
+			cols, vals = append(cols, ','), append(vals, ',')
+		}
+		cols = append(cols, quoteSQLIdent(f.Name)...)
+		switch f.Type {
+		case FieldString:
+			vals = appendSQLString(vals, v.String())
+		case FieldInt:
+			vals = strconv.AppendInt(vals, v.Int(), 10)
+		case FieldUint:
+			vals = strconv.AppendUint(vals, v.Uint(), 10)
+		case FieldFloat:
+			vals = appendJSONFloat(vals, v.Float())
+		}
+	}
+	return fmt.Appendf(b, "INSERT INTO %s (%s) VALUES (%s);\n", table, cols, vals)
+}
+
+// cqlSchema returns the statements creating the keyspace and the table,
+// partitioned by profileId and clustered by recordIndex.
+func cqlSchema(schema *Schema, keyspace, table string, replication int) ([]string, error) {
+	if !slices.ContainsFunc(schema.Fields, func(f FieldDescriptor) bool { return f.Name == "profileId" }) ||
+		!slices.ContainsFunc(schema.Fields, func(f FieldDescriptor) bool { return f.Name == "recordIndex" }) {
+		return nil, errors.New("outputFields must include profileId and recordIndex, the table's primary key")
+	}
+	cols := make([]string, len(schema.Fields))
+	var tagged []string
+	for i, f := range schema.Fields {
+		cols[i] = quoteSQLIdent(f.Name) + " " + cqlTypes[f.Type]
+		if f.Sensitivity != "" {
+			tagged = append(tagged, f.Name+"="+string(f.Sensitivity))
+		}
+	}
+	// CQL has no column comments; the sensitivity of the columns goes in
+	// the table comment.
+	var comment string
+	if len(tagged) > 0 {
+		comment = " WITH comment = " + string(appendSQLString(nil, "sensitivity: "+strings.Join(tagged, ", ")))
+	}
+	return []string{
+		fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = {'class': 'SimpleStrategy', 'replication_factor': %d}", quoteSQLIdent(keyspace), replication),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s, PRIMARY KEY ((\"profileId\"), \"recordIndex\"))%s", quoteSQLIdent(keyspace), quoteSQLIdent(table), strings.Join(cols, ", "), comment),
+	}, nil
+}
+
+// cqlPartitions calls yield with the records of each profile in each
+// window of progressInterval records, so every batch touches a single
+// partition and replica set.
+func cqlPartitions(ctx context.Context, gen *IdempotentGenerator, start, count uint64, yield func([]RawRecord) error) error {
+	window := make([]RawRecord, 0, progressInterval)
+	for done := uint64(0); done < count; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(count-done, progressInterval)
+		window = window[:0]
+		for i := uint64(0); i < n; i++ {
+			window = append(window, gen.RecordByIndex(start+done+i))
+		}
+		done += n
+		slices.SortStableFunc(window, func(a, b RawRecord) int {
+			switch {
+			case a.ProfileID < b.ProfileID:
+				return -1
+			case a.ProfileID > b.ProfileID:
+				return 1
+			}
+			return 0
+		})
+		for i := 0; i < len(window); {
+			j := i + 1
+			for j < len(window) && window[j].ProfileID == window[i].ProfileID {
+				j++
+			}
+			if err := yield(window[i:j]); err != nil {
+				return err
+			}
+			i = j
+		}
+	}
+	return nil
+}
+
+func writeCQLScript(ctx context.Context, gen *IdempotentGenerator, w io.Writer, keyspace, table string, replication int, start, count uint64) error {
+	schema := gen.Schema()
+	ddl, err := cqlSchema(schema, keyspace, table, replication)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, 1<<16)
+	for _, stmt := range ddl {
+		fmt.Fprintf(bw, "%s;\n", stmt)
+	}
+	qualified := quoteSQLIdent(keyspace) + "." + quoteSQLIdent(table)
+	var buf []byte
+	err = cqlPartitions(ctx, gen, start, count, func(part []RawRecord) error {
+		buf = buf[:0]
+		if len(part) > 1 {
+			buf = append(buf, "BEGIN UNLOGGED BATCH\n"...)
+		}
+		for k := range part {
+			buf = appendCQLInsert(buf, qualified, schema, &part[k])
+		}
+		if len(part) > 1 {
+			buf = append(buf, "APPLY BATCH;\n"...)
+		}
+		_, err := bw.Write(buf)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// cqlValues returns the values of rec bound to the columns of the prepared
+// insert, in schema order. Unset fields are bound as UnsetValue, which
+// leaves the column alone rather than writing a tombstone.
+func cqlValues(s *Schema, rec *RawRecord) []any {
+	values := make([]any, len(s.Fields))
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			values[i] = gocql.UnsetValue
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			values[i] = v.String()
+		case FieldInt:
+			values[i] = v.Int()
+		case FieldUint:
+			values[i] = int64(v.Uint())
+		case FieldFloat:
+			values[i] = v.Float()
+		}
+	}
+	return values
+}
+
+// loadCQL creates the table and writes each partition's records as one
+// unlogged batch of a prepared insert, with up to concurrency batches in
+// flight. The session routes every batch to a replica of its partition.
+func loadCQL(ctx context.Context, session *gocql.Session, gen *IdempotentGenerator, keyspace, table string, replication, concurrency int, start, count uint64) error {
+	schema := gen.Schema()
+	ddl, err := cqlSchema(schema, keyspace, table, replication)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range ddl {
+		if err := session.Query(stmt).ExecContext(ctx); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	cols := make([]string, len(schema.Fields))
+	for i, f := range schema.Fields {
+		cols[i] = quoteSQLIdent(f.Name)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s)", quoteSQLIdent(keyspace), quoteSQLIdent(table),
+		strings.Join(cols, ", "), strings.Repeat("?, ", len(cols)-1)+"?")
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	err = cqlPartitions(ctx, gen, start, count, func(part []RawRecord) error {
+		batch := session.Batch(gocql.UnloggedBatch)
+		for k := range part {
+			batch.Query(insert, cqlValues(schema, &part[k])...)
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		wg.Add(1)
+		go func(n int) {
+			defer func() { <-slots; wg.Done() }()
+			if err := batch.ExecContext(ctx); err != nil {
+				cancel(err)
+				return
+			}
+			metrics.recordsGenerated.Add("cql", float64(n))
+		}(len(part))
+		return nil
+	})
+	wg.Wait()
+	if err == nil {
+		err = context.Cause(ctx)
+	}
+	return err
+}
+
+func runCQL(args []string) error {
+	fs := flag.NewFlagSet("cql", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	hosts := fs.String("hosts", "", "comma-separated cluster contact points to load; without them the CQL script is written to -output")
+	keyspace := fs.String("keyspace", "generator", "keyspace, created if missing")
+	table := fs.String("table", "records", "table, created if missing")
+	replication := fs.Int("replication-factor", 1, "replication factor of a created keyspace")
+	consistency := fs.String("consistency", "LOCAL_QUORUM", "write consistency level")
+	concurrency := fs.Int("concurrency", 16, "partition batches in flight")
+	output := fs.String("output", "-", "CQL script file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	level, err := gocql.ParseConsistencyWrapper(*consistency)
+	if err != nil {
+		return fmt.Errorf("-consistency: %w", err)
+	}
+	if *concurrency <= 0 {
+		return errors.New("-concurrency must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if *hosts == "" {
+		if *output == "-" {
+			return writeCQLScript(ctx, gen, os.Stdout, *keyspace, *table, *replication, *start, *count)
+		}
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := writeCQLScript(ctx, gen, file, *keyspace, *table, *replication, *start, *count); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+
+	cluster := gocql.NewCluster(strings.Split(*hosts, ",")...)
+	cluster.Consistency = level
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	began := time.Now()
+	if err := loadCQL(ctx, session, gen, *keyspace, *table, *replication, *concurrency, *start, *count); err != nil {
+		return err
+	}
+	logFor("cql").Info("loaded", "table", *keyspace+"."+*table, "records", *count, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+)
+
+func TestCQLPartitions(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	schema := gen.Schema()
+	const start, count = 300, 2500
+	seen := make(map[uint64]bool)
+	err := cqlPartitions(context.Background(), gen, start, count, func(part []RawRecord) error {
+		for _, rec := range part {
+			if rec.ProfileID != part[0].ProfileID {
+				t.Fatalf("batch mixes profiles %d and %d", part[0].ProfileID, rec.ProfileID)
+			}
+			if (rec.RecordIndex-start)/progressInterval != (part[0].RecordIndex-start)/progressInterval {
+				t.Fatalf("batch spans windows: records %d and %d", part[0].RecordIndex, rec.RecordIndex)
+			}
+			if seen[rec.RecordIndex] {
+				t.Fatalf("record %d batched twice", rec.RecordIndex)
+			}
+			seen[rec.RecordIndex] = true
+
+			values := cqlValues(schema, &rec)
+			for i := range schema.Fields {
+				want := wantFieldValue(&schema.Fields[i], &rec)
+				if u, ok := want.(uint64); ok {
+					want = int64(u)
+				}
+				if want == nil {
+					want = gocql.UnsetValue
+				}
+				if values[i] != want {
+					t.Fatalf("record %d binds %s to %#v, want %#v", rec.RecordIndex, schema.Fields[i].Name, values[i], want)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != count {
+		t.Errorf("batched %d records, want %d", len(seen), count)
+	}
+}
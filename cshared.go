@@ -0,0 +1,156 @@
+//go:build cshared
+
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// C shared library: the generator behind a small, stable C ABI so Python,
+// Java or C# test harnesses embed the exact derivation rather than
+// re-implementing it:
+//
+//	go build -tags cshared -buildmode=c-shared -o libgenerator.so .
+//
+// which also writes libgenerator.h. Configs are referred to by handle;
+// handle 0 is the built-in config. Every returned string is allocated with
+// malloc and must be released with GenFree. On failure functions return
+// NULL or a negative handle, and GenLastError describes the most recent
+// failure of the calling process.
+
+var cGenerators = struct {
+	sync.RWMutex
+	byHandle map[int64]*IdempotentGenerator
+	next     int64
+	lastErr  string
+}{byHandle: map[int64]*IdempotentGenerator{0: mustNewGenerator(defaultConfig)}, next: 1}
+
+func cFail(err error) {
+	cGenerators.Lock()
+	cGenerators.lastErr = err.Error()
+	cGenerators.Unlock()
+}
+
+func cGenerator(handle int64) *IdempotentGenerator {
+	cGenerators.RLock()
+	gen := cGenerators.byHandle[handle]
+	cGenerators.RUnlock()
+	if gen == nil {
+		cFail(fmt.Errorf("unknown config handle %d", handle))
+	}
+	return gen
+}
+
+// GenVersion returns the generator version; records are only comparable
+// between libraries of the same version.
+//
+//export GenVersion
+func GenVersion() C.int {
+	return C.int(generatorVersion)
+}
+
+// GenNewConfig registers a JSON config, or a preset name, and returns its
+// handle, or -1 when it does not parse or validate.
+//
+//export GenNewConfig
+func GenNewConfig(config *C.char) C.longlong {
+	s := C.GoString(config)
+	cfg, err := presetConfig(s)
+	if err != nil {
+		if cfg, err = parseConfig([]byte(s)); err != nil {
+			cFail(err)
+			return -1
+		}
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		cFail(err)
+		return -1
+	}
+	cGenerators.Lock()
+	defer cGenerators.Unlock()
+	handle := cGenerators.next
+	cGenerators.next++
+	cGenerators.byHandle[handle] = gen
+	return C.longlong(handle)
+}
+
+// GenFreeConfig releases a handle from GenNewConfig.
+//
+//export GenFreeConfig
+func GenFreeConfig(handle C.longlong) {
+	if handle == 0 {
+		return
+	}
+	cGenerators.Lock()
+	delete(cGenerators.byHandle, int64(handle))
+	cGenerators.Unlock()
+}
+
+// GenConfigHash returns the config hash of a handle, as in manifests.
+//
+//export GenConfigHash
+func GenConfigHash(handle C.longlong) *C.char {
+	gen := cGenerator(int64(handle))
+	if gen == nil {
+		return nil
+	}
+	return C.CString(configHash(gen.cfg))
+}
+
+// GenRecordJSON returns record idx of the config handle as a JSON object,
+// byte for byte the line the jsonl format writes.
+//
+//export GenRecordJSON
+func GenRecordJSON(idx C.ulonglong, handle C.longlong) *C.char {
+	gen := cGenerator(int64(handle))
+	if gen == nil {
+		return nil
+	}
+	rec := gen.RecordByIndex(uint64(idx))
+	return C.CString(string(gen.Schema().AppendJSON(nil, &rec)))
+}
+
+// GenProfileJSON returns profile id of the config handle as a JSON object.
+//
+//export GenProfileJSON
+func GenProfileJSON(id C.ulonglong, handle C.longlong) *C.char {
+	gen := cGenerator(int64(handle))
+	if gen == nil {
+		return nil
+	}
+	data, err := json.Marshal(gen.ProfileByID(uint64(id)))
+	if err != nil {
+		cFail(err)
+		return nil
+	}
+	return C.CString(string(data))
+}
+
+// GenLastError returns the most recent failure, or NULL when nothing
+// failed yet.
+//
+//export GenLastError
+func GenLastError() *C.char {
+	cGenerators.RLock()
+	defer cGenerators.RUnlock()
+	if cGenerators.lastErr == "" {
+		return nil
+	}
+	return C.CString(cGenerators.lastErr)
+}
+
+// GenFree releases a string returned by the library.
+//
+//export GenFree
+func GenFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const cursorVersion = 1
+
+// cursorScanLimit bounds the indices one page scans, so a sparse filter
+// returns a short (possibly empty) page with a cursor instead of scanning
+// the whole range in one call.
+const cursorScanLimit = 1 << 20
+
+// ErrCursorConfigChanged is returned for a cursor issued by a generator
+// with a different config: its positions no longer address the same
+// records.
+var ErrCursorConfigChanged = errors.New("cursor was issued for a different config")
+
+// cursorState is the content of a cursor token.
+type cursorState struct {
+	Version    int    `json:"v"`
+	ConfigHash string `json:"h"`
+	// Next is the first index not yet scanned; End is exclusive.
+	Next uint64 `json:"i"`
+	End  uint64 `json:"e"`
+
+	Cities            []string `json:"c,omitempty"`
+	Channels          []string `json:"ch,omitempty"`
+	From              int64    `json:"from,omitempty"`
+	To                int64    `json:"to,omitempty"`
+	RepeatMultipliers []int    `json:"rm,omitempty"`
+}
+
+func (c *cursorState) filter() Filter {
+	f := Filter{Cities: c.Cities, Channels: c.Channels, RepeatMultipliers: c.RepeatMultipliers}
+	if c.From != 0 {
+		f.From = time.Unix(c.From, 0).UTC()
+	}
+	if c.To != 0 {
+		f.To = time.Unix(c.To, 0).UTC()
+	}
+	return f
+}
+
+func (c *cursorState) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// NewCursor returns the cursor of the first page of records in [start, end)
+// matching f. Filters are part of the cursor, so f.Match, which cannot be
+// encoded, must be nil; time bounds keep second precision.
+func (g *IdempotentGenerator) NewCursor(start, end uint64, f Filter) (string, error) {
+	if f.Match != nil {
+		return "", errors.New("cursor filters cannot carry a Match function")
+	}
+	if end < start {
+		return "", fmt.Errorf("cursor range end %d is before start %d", end, start)
+	}
+	c := cursorState{
+		Version:           cursorVersion,
+		ConfigHash:        configHash(g.cfg),
+		Next:              start,
+		End:               end,
+		Cities:            f.Cities,
+		Channels:          f.Channels,
+		RepeatMultipliers: f.RepeatMultipliers,
+	}
+	if !f.From.IsZero() {
+		c.From = f.From.Unix()
+	}
+	if !f.To.IsZero() {
+		c.To = f.To.Unix()
+	}
+	return c.encode(), nil
+}
+
+// NextPage returns up to pageSize matching records from cursor and the
+// cursor of the following page, which is empty once the range is
+// exhausted. A page may be short, or empty, when the filter is sparse.
+// Cursors from a generator with another config fail with
+// ErrCursorConfigChanged.
+func (g *IdempotentGenerator) NextPage(cursor string, pageSize int) ([]RawRecord, string, error) {
+	if pageSize <= 0 {
+		return nil, "", errors.New("page size must be positive")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+	var c cursorState
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, "", fmt.Errorf("invalid cursor: %w", err)
+	}
+	if c.Version != cursorVersion {
+		return nil, "", fmt.Errorf("unsupported cursor version %d", c.Version)
+	}
+	if c.ConfigHash != configHash(g.cfg) {
+		return nil, "", ErrCursorConfigChanged
+	}
+
+	f := c.filter()
+	page := make([]RawRecord, 0, pageSize)
+	limit := c.Next + min(c.End-c.Next, cursorScanLimit)
+	for ; c.Next < limit && len(page) < pageSize; c.Next++ {
+		if rec, ok := g.matchAt(c.Next, &f); ok {
+			page = append(page, rec)
+		}
+	}
+	if c.Next >= c.End {
+		return page, "", nil
+	}
+	return page, c.encode(), nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Datasets are named configs. Each distinct config a dataset has been given
+// is kept as a version addressable by its config hash, so records generated
+// before a reload can still be looked up after it.
+type DatasetVersion struct {
+	Hash      string          `json:"hash"`
+	Config    GeneratorConfig `json:"config"`
+	CreatedAt time.Time       `json:"createdAt"`
+
+	gen *IdempotentGenerator
+}
+
+type Dataset struct {
+	Name string
+
+	current atomic.Pointer[DatasetVersion]
+
+	mu       sync.Mutex
+	versions map[string]*DatasetVersion
+}
+
+func newDataset(name string) *Dataset {
+	return &Dataset{Name: name, versions: make(map[string]*DatasetVersion)}
+}
+
+func (d *Dataset) Current() *DatasetVersion {
+	return d.current.Load()
+}
+
+// Version returns the version with the given config hash, or the current one
+// when hash is empty.
+func (d *Dataset) Version(hash string) (*DatasetVersion, bool) {
+	if hash == "" {
+		return d.Current(), true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.versions[hash]
+	return v, ok
+}
+
+// activate makes cfg the current version, reusing an existing version with
+// the same hash. It reports whether the current version changed.
+func (d *Dataset) activate(cfg GeneratorConfig) (*DatasetVersion, bool, error) {
+	hash := configHash(cfg)
+
+	d.mu.Lock()
+	v, ok := d.versions[hash]
+	if !ok {
+		gen, err := NewIdempotentGenerator(cfg)
+		if err != nil {
+			d.mu.Unlock()
+			return nil, false, err
+		}
+		v = &DatasetVersion{
+			Hash:      hash,
+			Config:    cfg,
+			CreatedAt: time.Now().UTC(),
+			gen:       gen,
+		}
+		d.versions[hash] = v
+	}
+	d.mu.Unlock()
+
+	prev := d.current.Swap(v)
+	return v, prev != v, nil
+}
+
+func (d *Dataset) Versions() []*DatasetVersion {
+	d.mu.Lock()
+	list := make([]*DatasetVersion, 0, len(d.versions))
+	for _, v := range d.versions {
+		list = append(list, v)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(list, func(a, b int) bool { return list[a].CreatedAt.Before(list[b].CreatedAt) })
+	return list
+}
+
+func (d *Dataset) MarshalJSON() ([]byte, error) {
+	cur := d.Current()
+	hashes := make([]string, 0)
+	for _, v := range d.Versions() {
+		hashes = append(hashes, v.Hash)
+	}
+	return json.Marshal(struct {
+		Name      string          `json:"name"`
+		Version   string          `json:"version"`
+		Config    GeneratorConfig `json:"config"`
+		UpdatedAt time.Time       `json:"updatedAt"`
+		Versions  []string        `json:"versions"`
+	}{d.Name, cur.Hash, cur.Config, cur.CreatedAt, hashes})
+}
+
+// watchConfigFile polls path and, whenever it changes, calls onChange with
+// the config returned by load. Invalid revisions are reported and skipped,
+// leaving the previous config active.
+func watchConfigFile(path string, interval time.Duration, load func() (GeneratorConfig, error), onChange func(GeneratorConfig)) {
+	var lastMod time.Time
+	var lastSize int64
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+
+		cfg, err := load()
+		if err != nil {
+			logFor("server").Warn("ignoring invalid config change", "path", path, "err", err)
+			continue
+		}
+		onChange(cfg)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DemographicsConfig gives profiles a birth date and gender and lets channel
+// preference and purchase category depend on them. Pools are keyed by
+// segment; a record uses the first pool found among "gender/band", "gender",
+// "band" and "*", where band is the profile's age band at the record's time,
+// e.g. "female/30-44".
+type DemographicsConfig struct {
+	// AgeBands are the ascending lower bounds of the age bands after the
+	// first, so [30, 45, 60] yields 0-29, 30-44, 45-59 and 60+.
+	AgeBands []int `json:"ageBands"`
+	// Channels replaces the channel pool for matching segments.
+	Channels map[string][]WeightedValue `json:"channels,omitempty"`
+	// Categories, when set, adds a purchase category to every record.
+	Categories map[string][]WeightedValue `json:"categories,omitempty"`
+}
+
+var defaultDemographicsConfig = DemographicsConfig{
+	AgeBands: []int{30, 45, 60},
+	Channels: map[string][]WeightedValue{
+		"0-29": {
+			{Value: "mobile", Weight: 55}, {Value: "web", Weight: 30},
+			{Value: "offline", Weight: 12}, {Value: "callcenter", Weight: 3},
+		},
+		"30-44": {
+			{Value: "mobile", Weight: 40}, {Value: "web", Weight: 35},
+			{Value: "offline", Weight: 20}, {Value: "callcenter", Weight: 5},
+		},
+		"45-59": {
+			{Value: "web", Weight: 35}, {Value: "offline", Weight: 35},
+			{Value: "mobile", Weight: 20}, {Value: "callcenter", Weight: 10},
+		},
+		"60+": {
+			{Value: "offline", Weight: 50}, {Value: "callcenter", Weight: 25},
+			{Value: "web", Weight: 15}, {Value: "mobile", Weight: 10},
+		},
+	},
+	Categories: map[string][]WeightedValue{
+		"female": {
+			{Value: "apparel", Weight: 30}, {Value: "beauty", Weight: 25}, {Value: "groceries", Weight: 20},
+			{Value: "home", Weight: 15}, {Value: "electronics", Weight: 10},
+		},
+		"male": {
+			{Value: "electronics", Weight: 30}, {Value: "groceries", Weight: 20}, {Value: "sports", Weight: 20},
+			{Value: "apparel", Weight: 15}, {Value: "home", Weight: 15},
+		},
+		"*": {
+			{Value: "groceries", Weight: 30}, {Value: "apparel", Weight: 20}, {Value: "electronics", Weight: 20},
+			{Value: "home", Weight: 20}, {Value: "sports", Weight: 10},
+		},
+	},
+}
+
+// nameGenders covers the default first names; other names are guessed from
+// their ending.
+var nameGenders = map[string]string{
+	"Анна": "female", "Мария": "female", "София": "female", "Елена": "female", "Ольга": "female",
+	"Иван": "male", "Алексей": "male", "Дмитрий": "male", "Сергей": "male", "Павел": "male",
+}
+
+func (c *DemographicsConfig) clone() *DemographicsConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.AgeBands = append([]int(nil), c.AgeBands...)
+	out.Channels = cloneWeightedMap(c.Channels)
+	out.Categories = cloneWeightedMap(c.Categories)
+	return &out
+}
+
+func cloneWeightedMap(m map[string][]WeightedValue) map[string][]WeightedValue {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string][]WeightedValue, len(m))
+	for k, pool := range m {
+		out[k] = append([]WeightedValue(nil), pool...)
+	}
+	return out
+}
+
+func (c *DemographicsConfig) validate() error {
+	for i, b := range c.AgeBands {
+		if b <= 0 || (i > 0 && b <= c.AgeBands[i-1]) {
+			return errors.New("demographics.ageBands must be positive and ascending")
+		}
+	}
+	for name, m := range map[string]map[string][]WeightedValue{"channels": c.Channels, "categories": c.Categories} {
+		for segment, pool := range m {
+			if err := validateWeighted(pool); err != nil {
+				return fmt.Errorf("demographics.%s[%s]: %w", name, segment, err)
+			}
+		}
+	}
+	return nil
+}
+
+// birthDate is a profile's date of birth, derived from its own hash so any
+// field can use it without disturbing other draws.
+func birthDate(profileID, seed uint64) time.Time {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("birth:%d", profileID)), seed))
+	year := 1955 + rng.NextInt(51)
+	return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, rng.NextInt(365))
+}
+
+// genderOf infers a gender from a first name.
+func genderOf(firstName string) string {
+	if g, ok := nameGenders[firstName]; ok {
+		return g
+	}
+	if strings.HasSuffix(firstName, "а") || strings.HasSuffix(firstName, "я") {
+		return "female"
+	}
+	return "male"
+}
+
+// ageBand names the band of an age.
+func (c *DemographicsConfig) ageBand(age int) string {
+	low := 0
+	for _, b := range c.AgeBands {
+		if age < b {
+			return fmt.Sprintf("%d-%d", low, b-1)
+		}
+		low = b
+	}
+	return fmt.Sprintf("%d+", low)
+}
+
+// segmentPool finds the pool of the most specific segment matching the
+// profile at time t.
+func (c *DemographicsConfig) segmentPool(m map[string][]WeightedValue, p Profile, t time.Time) ([]WeightedValue, bool) {
+	gender, band := c.segmentOf(p, t)
+	for _, key := range []string{gender + "/" + band, gender, band, "*"} {
+		if pool, ok := m[key]; ok {
+			return pool, true
+		}
+	}
+	return nil, false
+}
+
+// segmentOf returns the gender and age band of a profile at time t.
+func (c *DemographicsConfig) segmentOf(p Profile, t time.Time) (string, string) {
+	born, err := time.Parse(time.DateOnly, p.BirthDate)
+	if err != nil {
+		return p.Gender, ""
+	}
+	age := t.Year() - born.Year()
+	if t.YearDay() < born.YearDay() {
+		age--
+	}
+	return p.Gender, c.ageBand(age)
+}
@@ -0,0 +1,121 @@
+// Package determ holds the hashing, random number and weighted picking
+// primitives every derived value of the generator is built from. Fields
+// built with them the way the generator builds its own stay bit-compatible
+// with its output on every platform:
+//
+//	rng := determ.Derive(fmt.Sprintf("myfield:%d", idx), datasetSeed)
+//	value := determ.PickWeighted(rng, pool)
+//
+// Each value gets its own stream, keyed by a tag naming the value and the
+// index or profile ID it belongs to, so no value depends on the order
+// others are drawn in.
+package determ
+
+import "encoding/binary"
+
+// FNV1a64 is the 64-bit FNV-1a hash of s.
+func FNV1a64(s string) uint64 {
+	hash := uint64(0xcbf29ce484222325)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint64(s[i])
+		hash *= 0x100000001b3
+	}
+	return hash
+}
+
+// FNV1a64Uint64 is the 64-bit FNV-1a hash of v's little-endian bytes.
+func FNV1a64Uint64(v uint64) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return FNV1a64(string(b[:]))
+}
+
+// SplitMix64 is the generator's pseudorandom number generator.
+type SplitMix64 struct {
+	state uint64
+}
+
+func NewSplitMix64(seed uint64) *SplitMix64 {
+	return &SplitMix64{state: seed}
+}
+
+func (s *SplitMix64) NextUint64() uint64 {
+	z := s.state + 0x9E3779B97F4A7C15
+	s.state = z
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// NextFloat returns a float in [0, 1) from the top 53 bits of NextUint64.
+func (s *SplitMix64) NextFloat() float64 {
+	u := s.NextUint64()
+	return float64(u>>11) / float64(1<<53)
+}
+
+// NextInt returns an int in [0, maxExclusive).
+func (s *SplitMix64) NextInt(maxExclusive int) int {
+	return int(s.NextFloat() * float64(maxExclusive))
+}
+
+// WithSeed mixes a dataset seed into a derivation hash. Seed 0 leaves the
+// hash untouched so unseeded configs keep producing the original data.
+func WithSeed(h, seed uint64) uint64 {
+	if seed == 0 {
+		return h
+	}
+	return NewSplitMix64(h ^ seed).NextUint64()
+}
+
+// Derive returns the stream of the value named by tag under a dataset
+// seed: a SplitMix64 seeded with WithSeed(FNV1a64(tag), seed).
+func Derive(tag string, seed uint64) *SplitMix64 {
+	return NewSplitMix64(WithSeed(FNV1a64(tag), seed))
+}
+
+// WeightedPick draws one of values, the i-th with weight weights[i].
+// Weights beyond the end of a short pool have no value to pick; without
+// weights the pick is uniform.
+func WeightedPick(rng *SplitMix64, values []string, weights []int) string {
+	weights = weights[:min(len(weights), len(values))]
+	if len(weights) == 0 {
+		return values[rng.NextInt(len(values))]
+	}
+
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+
+	r := rng.NextFloat() * float64(total)
+	for i, w := range weights {
+		r -= float64(w)
+		if r <= 0 {
+			return values[i]
+		}
+	}
+	return values[len(values)-1]
+}
+
+// WeightedValue is a value with its relative weight in a pool.
+type WeightedValue struct {
+	Value  string `json:"value"`
+	Weight int    `json:"weight"`
+}
+
+// PickWeighted draws a value from pool by weight; values of weight 0 are
+// never drawn unless they come last.
+func PickWeighted(rng *SplitMix64, pool []WeightedValue) string {
+	total := 0
+	for _, v := range pool {
+		total += v.Weight
+	}
+	r := rng.NextFloat() * float64(total)
+	for _, v := range pool {
+		r -= float64(v.Weight)
+		if r <= 0 && v.Weight > 0 {
+			return v.Value
+		}
+	}
+	return pool[len(pool)-1].Value
+}
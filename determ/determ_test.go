@@ -0,0 +1,67 @@
+package determ
+
+import "testing"
+
+// The values below are what every released dataset was derived from; a
+// change to any of them changes every record.
+
+func TestFNV1a64(t *testing.T) {
+	for _, c := range []struct {
+		got, want uint64
+	}{
+		{FNV1a64(""), 0xcbf29ce484222325},
+		{FNV1a64("time:42"), 0xdb948753c1d9d488},
+		{FNV1a64Uint64(7), 0x4bd7a317074c5b62},
+	} {
+		if c.got != c.want {
+			t.Errorf("got %#x, want %#x", c.got, c.want)
+		}
+	}
+}
+
+func TestSplitMix64(t *testing.T) {
+	rng := NewSplitMix64(1)
+	for _, want := range []uint64{0x910a2dec89025cc1, 0xbeeb8da1658eec67} {
+		if got := rng.NextUint64(); got != want {
+			t.Errorf("NextUint64 = %#x, want %#x", got, want)
+		}
+	}
+}
+
+func TestDerive(t *testing.T) {
+	if got := WithSeed(123, 0); got != 123 {
+		t.Errorf("WithSeed(123, 0) = %d, want the hash unchanged", got)
+	}
+	if got := WithSeed(123, 42); got != 0x9192105c8367ccf5 {
+		t.Errorf("WithSeed(123, 42) = %#x", got)
+	}
+	rng := Derive("rec:5", 42)
+	if got := rng.NextUint64(); got != 0xd8fab015af1ac9b6 {
+		t.Errorf("NextUint64 = %#x", got)
+	}
+	if got := rng.NextFloat(); got != 0.8464703229254161 {
+		t.Errorf("NextFloat = %v", got)
+	}
+	if got := rng.NextInt(10); got != 7 {
+		t.Errorf("NextInt = %d", got)
+	}
+}
+
+func TestWeightedPicks(t *testing.T) {
+	if got := WeightedPick(Derive("a", 1), []string{"x", "y", "z"}, []int{1, 2, 3}); got != "z" {
+		t.Errorf("WeightedPick = %q", got)
+	}
+	if got := WeightedPick(Derive("a", 1), []string{"x"}, []int{1, 2, 3}); got != "x" {
+		t.Errorf("WeightedPick on a short pool = %q", got)
+	}
+	pool := []WeightedValue{{"p", 1}, {"q", 0}, {"r", 5}}
+	if got := PickWeighted(Derive("b", 1), pool); got != "p" {
+		t.Errorf("PickWeighted = %q", got)
+	}
+	rng := Derive("c", 1)
+	for i := 0; i < 1000; i++ {
+		if PickWeighted(rng, pool) == "q" {
+			t.Fatal("PickWeighted drew a value of weight 0")
+		}
+	}
+}
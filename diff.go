@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// diffInput is one side of a diff: either a config (possibly taken from a
+// manifest, which also pins an index range) or a file of generated records.
+type diffInput struct {
+	Path     string
+	Config   *GeneratorConfig
+	Manifest *Manifest
+	Records  string
+}
+
+func loadDiffInput(path string) (diffInput, error) {
+	in := diffInput{Path: path}
+	if strings.HasSuffix(path, ".jsonl") {
+		in.Records = path
+		return in, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return in, err
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return in, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := probe["configHash"]; ok {
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return in, fmt.Errorf("%s: %w", path, err)
+		}
+		in.Manifest = &m
+		in.Config = &m.Config
+		return in, nil
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return in, fmt.Errorf("%s: %w", path, err)
+	}
+	in.Config = &cfg
+	return in, nil
+}
+
+// flattenJSON turns a JSON-encodable value into dotted key paths.
+func flattenJSON(v interface{}) map[string]string {
+	data, _ := json.Marshal(v)
+	var generic interface{}
+	json.Unmarshal(data, &generic)
+
+	out := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, child := range t {
+				key := k
+				if prefix != "" {
+					key = prefix + "." + k
+				}
+				walk(key, child)
+			}
+		case []interface{}:
+			for i, child := range t {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+			}
+			if len(t) == 0 {
+				out[prefix] = "[]"
+			}
+		default:
+			b, _ := json.Marshal(t)
+			out[prefix] = string(b)
+		}
+	}
+	walk("", generic)
+	return out
+}
+
+type valueChange struct {
+	Key  string
+	From string
+	To   string
+}
+
+func diffFlat(a, b map[string]string) []valueChange {
+	keys := make(map[string]struct{})
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	var changes []valueChange
+	for _, k := range sortedKeys(keys) {
+		from, inA := a[k]
+		to, inB := b[k]
+		if !inA {
+			from = "(absent)"
+		}
+		if !inB {
+			to = "(absent)"
+		}
+		if from != to {
+			changes = append(changes, valueChange{Key: k, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// recordDiff accumulates field-level differences between record streams.
+type recordDiff struct {
+	maxExamples int
+
+	compared uint64
+	changed  uint64
+	byField  map[string]uint64
+	examples []string
+	onlyA    uint64
+	onlyB    uint64
+}
+
+func newRecordDiff(maxExamples int) *recordDiff {
+	return &recordDiff{maxExamples: maxExamples, byField: make(map[string]uint64)}
+}
+
+func (d *recordDiff) compare(idx uint64, a, b map[string]interface{}) {
+	d.compared++
+	var fields []string
+	for k, av := range a {
+		if k == "recordIndex" {
+			continue
+		}
+		if !reflect.DeepEqual(av, b[k]) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	sort.Strings(fields)
+	d.changed++
+	for _, f := range fields {
+		d.byField[f]++
+	}
+	if len(d.examples) < d.maxExamples {
+		parts := make([]string, len(fields))
+		for i, f := range fields {
+			parts[i] = fmt.Sprintf("%s: %v → %v", f, a[f], b[f])
+		}
+		d.examples = append(d.examples, fmt.Sprintf("#%d  %s", idx, strings.Join(parts, "; ")))
+	}
+}
+
+func (d *recordDiff) print(w io.Writer) {
+	fmt.Fprintf(w, "\n📄 Records: %d compared, %d changed", d.compared, d.changed)
+	if d.onlyA > 0 || d.onlyB > 0 {
+		fmt.Fprintf(w, ", %d only in first, %d only in second", d.onlyA, d.onlyB)
+	}
+	fmt.Fprintln(w)
+	for _, f := range sortedKeys(d.byField) {
+		fmt.Fprintf(w, "   %-14s %d\n", f, d.byField[f])
+	}
+	if len(d.examples) > 0 {
+		fmt.Fprintln(w, "   Examples:")
+		for _, e := range d.examples {
+			fmt.Fprintf(w, "   %s\n", e)
+		}
+	}
+}
+
+func recordMap(rec RawRecord) map[string]interface{} {
+	data, _ := json.Marshal(rec)
+	var m map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	dec.Decode(&m)
+	return m
+}
+
+// readRecordFile indexes a JSONL file by recordIndex.
+func readRecordFile(path string) (map[uint64]map[string]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	out := make(map[uint64]map[string]interface{})
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		var m map[string]interface{}
+		dec := json.NewDecoder(strings.NewReader(scanner.Text()))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		n, ok := m["recordIndex"].(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s: record without recordIndex", path)
+		}
+		idx, err := n.Int64()
+		if err != nil {
+			return nil, err
+		}
+		out[uint64(idx)] = m
+	}
+	return out, scanner.Err()
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	start := fs.Uint64("start", 0, "first index to compare when neither side is a manifest")
+	count := fs.Uint64("count", 1000, "records to compare; with manifests, a cap on the overlap (0 = all of it)")
+	maxExamples := fs.Int("examples", 10, "changed records to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: diff [flags] <config|manifest|records.jsonl> <config|manifest|records.jsonl>")
+	}
+
+	a, err := loadDiffInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadDiffInput(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	out := os.Stdout
+	rd := newRecordDiff(*maxExamples)
+
+	if (a.Records == "") != (b.Records == "") {
+		return errors.New("compare configs/manifests with each other, or record files with each other")
+	}
+
+	if a.Records != "" {
+		ra, err := readRecordFile(a.Records)
+		if err != nil {
+			return err
+		}
+		rb, err := readRecordFile(b.Records)
+		if err != nil {
+			return err
+		}
+		indices := make([]uint64, 0, len(ra))
+		for idx := range ra {
+			if _, ok := rb[idx]; ok {
+				indices = append(indices, idx)
+			} else {
+				rd.onlyA++
+			}
+		}
+		for idx := range rb {
+			if _, ok := ra[idx]; !ok {
+				rd.onlyB++
+			}
+		}
+		sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+		for _, idx := range indices {
+			rd.compare(idx, ra[idx], rb[idx])
+		}
+		rd.print(out)
+		return nil
+	}
+
+	fmt.Fprintf(out, "⚙️  Config %s vs %s\n", configHash(*a.Config), configHash(*b.Config))
+	changes := diffFlat(flattenJSON(a.Config), flattenJSON(b.Config))
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "   identical")
+	}
+	for _, c := range changes {
+		fmt.Fprintf(out, "   %-32s %s → %s\n", c.Key, c.From, c.To)
+	}
+
+	lo, hi := *start, *start+*count
+	if a.Manifest != nil || b.Manifest != nil {
+		lo, hi = 0, ^uint64(0)
+		for _, m := range []*Manifest{a.Manifest, b.Manifest} {
+			if m != nil {
+				lo, hi = max(lo, m.Start), min(hi, m.Start+m.Count)
+			}
+		}
+		if *count > 0 && hi > lo && hi-lo > *count {
+			hi = lo + *count
+		}
+	}
+	if hi <= lo {
+		fmt.Fprintln(out, "\n📄 Index ranges do not overlap")
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n🔁 Regenerating overlap [%d, %d)\n", lo, hi)
+	ga, err := NewIdempotentGenerator(*a.Config)
+	if err != nil {
+		return err
+	}
+	gb, err := NewIdempotentGenerator(*b.Config)
+	if err != nil {
+		return err
+	}
+	for idx := lo; idx < hi; idx++ {
+		rd.compare(idx, recordMap(ga.RecordByIndex(idx)), recordMap(gb.RecordByIndex(idx)))
+	}
+	rd.print(out)
+	return nil
+}
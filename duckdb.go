@@ -0,0 +1,160 @@
+//go:build duckdb
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// DuckDB's appender writes rows straight into the table's columnar storage,
+// without the parsing and planning of INSERT statements. The driver links
+// DuckDB itself through cgo, so it is built only with -tags duckdb; without
+// the tag the duckdb dialect loads through the duckdb shell.
+
+func init() {
+	d := sqlDialects["duckdb"]
+	d.load = loadDuckDB
+	sqlDialects["duckdb"] = d
+}
+
+// loadDuckDB creates db and fills its tables through appenders, then
+// indexes them as the load script does.
+func loadDuckDB(ctx context.Context, gen *IdempotentGenerator, d sqlDialect, db string, start, count uint64, profiles bool) error {
+	connector, err := duckdb.NewConnector(db, nil)
+	if err != nil {
+		return err
+	}
+	defer connector.Close()
+	pool := sql.OpenDB(connector)
+	defer pool.Close()
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	exec := func(stmts ...string) error {
+		for _, stmt := range stmts {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%s: %w", stmt, err)
+			}
+		}
+		return nil
+	}
+
+	schema := gen.Schema()
+	if err := exec(sqlRecordsTable(schema, d)...); err != nil {
+		return err
+	}
+	err = appendDuckDB(ctx, conn, "records", func(appendRow func(...driver.Value) error) error {
+		row := make([]driver.Value, len(schema.Fields))
+		var i uint64
+		for rec := range gen.Records(start, count) {
+			if i%progressInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			i++
+			duckDBRow(row, schema, &rec)
+			if err := appendRow(row...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	metrics.recordsGenerated.Add("duckdb", float64(count))
+
+	if profiles {
+		rows, err := profilesInRange(ctx, gen, start, count)
+		if err != nil {
+			return err
+		}
+		if err := exec(sqlProfilesTable(d)); err != nil {
+			return err
+		}
+		err = appendDuckDB(ctx, conn, "profiles", func(appendRow func(...driver.Value) error) error {
+			for i := range rows {
+				if err := appendRow(duckDBProfileRow(&rows[i])...); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return exec(sqlIndexes(schema)...)
+}
+
+// appendDuckDB runs fill with the row appender of table, flushing what it
+// appended when it returns.
+func appendDuckDB(ctx context.Context, conn *sql.Conn, table string, fill func(appendRow func(...driver.Value) error) error) error {
+	return conn.Raw(func(raw any) error {
+		a, err := duckdb.NewAppenderFromConn(raw.(driver.Conn), "", table)
+		if err != nil {
+			return err
+		}
+		if err := fill(a.AppendRow); err != nil {
+			a.Close()
+			return err
+		}
+		return a.CloseWithCancel(ctx)
+	})
+}
+
+// duckDBRow fills row with the column values of rec. Fields JSON would omit
+// are NULL; invalid UTF-8, which DuckDB rejects, is replaced by U+FFFD.
+func duckDBRow(row []driver.Value, s *Schema, rec *RawRecord) {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			row[i] = nil
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			row[i] = strings.ToValidUTF8(v.String(), "\uFFFD")
+		case FieldInt:
+			row[i] = v.Int()
+		case FieldUint:
+			row[i] = v.Uint()
+		case FieldFloat:
+			row[i] = v.Float()
+		}
+	}
+}
+
+// duckDBProfileRow is the profiles row of row, laid out as
+// appendSQLProfileRow writes it.
+func duckDBProfileRow(row *ProfileRow) []driver.Value {
+	nullable := func(s string) driver.Value {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	list := func(values []string) driver.Value {
+		data, _ := json.Marshal(values)
+		return string(data)
+	}
+	p := &row.Profile
+	return []driver.Value{
+		p.ProfileID, p.FirstName, p.LastName, p.Locale,
+		nullable(p.HomeCity), nullable(p.BirthDate), nullable(p.Gender),
+		nullable(p.ActiveFrom), nullable(p.ChurnedAt), nullable(p.ErasedAt),
+		list(p.Phones), list(p.Emails), list(p.Logins),
+		int64(row.RepeatMultiplier), row.RecordsInRange, row.FirstRecordIndex,
+	}
+}
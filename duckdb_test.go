@@ -0,0 +1,58 @@
+//go:build duckdb
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestDuckDBAppender(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	path := filepath.Join(t.TempDir(), "records.duckdb")
+	const start, count = 900, 3000
+	if err := runSQL([]string{"-dialect", "duckdb", "-db", path, "-start", "900", "-count", "3000", "-profiles"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSQL([]string{"-dialect", "duckdb", "-db", path}); err == nil {
+		t.Error("loading into an existing database file succeeded")
+	}
+
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n, indexes int
+	if err := db.QueryRow(`SELECT count(*) FROM records`).Scan(&n); err != nil || n != count {
+		t.Fatalf("records table holds %d rows (%v), want %d", n, err, count)
+	}
+	if err := db.QueryRow(`SELECT count(*) FROM duckdb_indexes() WHERE table_name = 'records'`).Scan(&indexes); err != nil || indexes != len(sqlIndexes(gen.Schema())) {
+		t.Errorf("records has %d indexes (%v), want %d", indexes, err, len(sqlIndexes(gen.Schema())))
+	}
+	rows, err := profilesInRange(context.Background(), gen, start, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT count(*) FROM profiles`).Scan(&n); err != nil || n != len(rows) {
+		t.Errorf("profiles table holds %d rows (%v), want %d", n, err, len(rows))
+	}
+
+	for _, idx := range []uint64{start, start + 1, start + 1777, start + count - 1} {
+		rec := gen.RecordByIndex(idx)
+		var profileID uint64
+		var email sql.NullString
+		err := db.QueryRow(`SELECT "profileId", "email" FROM records WHERE "recordIndex" = ?`, idx).Scan(&profileID, &email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profileID != rec.ProfileID || email.String != strings.ToValidUTF8(rec.Email, "\uFFFD") {
+			t.Errorf("record %d reads back as profile %d with email %v, want %d with %q", idx, profileID, email, rec.ProfileID, rec.Email)
+		}
+	}
+}
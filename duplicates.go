@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// DuplicateStats is the duplicate load of a range: the share of records
+// that are not the first record of their profile in the range, overall and
+// per frequency bucket.
+type DuplicateStats struct {
+	Start      uint64                 `json:"start"`
+	Count      uint64                 `json:"count"`
+	Duplicates uint64                 `json:"duplicates"`
+	Rate       float64                `json:"rate"`
+	Buckets    []BucketDuplicateStats `json:"buckets"`
+}
+
+type BucketDuplicateStats struct {
+	Weight           int     `json:"weight"`
+	RepeatMultiplier int     `json:"repeatMultiplier"`
+	Records          uint64  `json:"records"`
+	Duplicates       uint64  `json:"duplicates"`
+	Rate             float64 `json:"rate"`
+}
+
+// DuplicateStats counts the records of [start, start+count) that repeat a
+// profile seen earlier in the range. Only record owners are derived, not
+// whole records, so it is much cheaper than generating the range.
+func (g *IdempotentGenerator) DuplicateStats(start, count uint64) DuplicateStats {
+	cfg := g.cfg
+	st := DuplicateStats{Start: start, Count: count, Buckets: make([]BucketDuplicateStats, len(cfg.Buckets))}
+	for i, b := range cfg.Buckets {
+		st.Buckets[i] = BucketDuplicateStats{Weight: b.Weight, RepeatMultiplier: b.RepeatMultiplier}
+	}
+	seen := make(map[uint64]struct{})
+	for i := uint64(0); i < count; i++ {
+		profileID := g.owner(start + i).profileID
+		b := &st.Buckets[bucketPosition(profileID, cfg)]
+		b.Records++
+		if _, ok := seen[profileID]; ok {
+			b.Duplicates++
+			st.Duplicates++
+			continue
+		}
+		seen[profileID] = struct{}{}
+	}
+	if count > 0 {
+		st.Rate = float64(st.Duplicates) / float64(count)
+	}
+	for i := range st.Buckets {
+		if b := &st.Buckets[i]; b.Records > 0 {
+			b.Rate = float64(b.Duplicates) / float64(b.Records)
+		}
+	}
+	return st
+}
+
+func printDuplicateStats(w io.Writer, st DuplicateStats) {
+	fmt.Fprintf(w, "🔁 Records [%d, +%d): %d duplicates (%.2f%%)\n", st.Start, st.Count, st.Duplicates, st.Rate*100)
+	for _, b := range st.Buckets {
+		fmt.Fprintf(w, "   weight %-4d x%-4d %d of %d records (%.2f%%)\n", b.Weight, b.RepeatMultiplier, b.Duplicates, b.Records, b.Rate*100)
+	}
+}
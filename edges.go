@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Relationship edge types.
+const (
+	edgeReferredBy       = "referred_by"
+	edgeEmergencyContact = "emergency_contact"
+	edgeEmployedBy       = "employed_by"
+)
+
+// RelationshipConfig links profiles into a graph. Every edge is derived from
+// the source profile alone, so the graph around any profile is available in
+// O(1) like its records.
+type RelationshipConfig struct {
+	// ReferralRate is the share of profiles referred by another profile.
+	ReferralRate float64 `json:"referralRate"`
+	// EmergencyContactRate is the share of profiles naming another profile
+	// as emergency contact.
+	EmergencyContactRate float64 `json:"emergencyContactRate"`
+	// EmployedRate is the share of profiles with an employer, chosen among
+	// Employers employer IDs; profiles with the same employer are coworkers.
+	EmployedRate float64 `json:"employedRate"`
+	Employers    uint64  `json:"employers"`
+}
+
+var defaultRelationshipConfig = RelationshipConfig{
+	ReferralRate:         0.2,
+	EmergencyContactRate: 0.5,
+	EmployedRate:         0.6,
+	Employers:            50_000,
+}
+
+// Edge is one relationship. For employed_by edges To is an employer ID, not
+// a profile ID.
+type Edge struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	Type string `json:"type"`
+}
+
+func (c *RelationshipConfig) clone() *RelationshipConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	return &out
+}
+
+func (c *RelationshipConfig) validate() error {
+	for name, rate := range map[string]float64{
+		"referralRate":         c.ReferralRate,
+		"emergencyContactRate": c.EmergencyContactRate,
+		"employedRate":         c.EmployedRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("relationships.%s must be in [0, 1]", name)
+		}
+	}
+	if c.EmployedRate > 0 && c.Employers == 0 {
+		return errors.New("relationships.employers must be positive when employedRate is set")
+	}
+	return nil
+}
+
+// Relationships returns the outgoing edges of a profile; it is empty when
+// relationships are not configured.
+func (g *IdempotentGenerator) Relationships(profileID uint64) []Edge {
+	c := g.cfg.Relationships
+	if c == nil {
+		return nil
+	}
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("edges:%d", profileID)), g.cfg.Seed))
+	var edges []Edge
+	other := func() uint64 {
+		// Skip the profile itself so there are no self-loops.
+		id := rng.NextUint64() % (g.cfg.ProfileSpaceSize - 1)
+		if id >= profileID {
+			id++
+		}
+		return id
+	}
+	if rng.NextFloat() < c.ReferralRate && g.cfg.ProfileSpaceSize > 1 {
+		edges = append(edges, Edge{From: profileID, To: other(), Type: edgeReferredBy})
+	}
+	if rng.NextFloat() < c.EmergencyContactRate && g.cfg.ProfileSpaceSize > 1 {
+		edges = append(edges, Edge{From: profileID, To: other(), Type: edgeEmergencyContact})
+	}
+	if rng.NextFloat() < c.EmployedRate {
+		edges = append(edges, Edge{From: profileID, To: rng.NextUint64() % c.Employers, Type: edgeEmployedBy})
+	}
+	return edges
+}
+
+// runEdges writes the edges of the profiles referenced by a record range.
+func runEdges(args []string) error {
+	fs := flag.NewFlagSet("edges", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records whose profiles' edges are exported")
+	output := fs.String("output", "-", "output file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	if cfg.Relationships == nil {
+		return errors.New("the config has no relationships section")
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, 1<<16)
+	enc := json.NewEncoder(bw)
+	ctx, stop := interruptContext()
+	defer stop()
+	seen := make(map[uint64]bool)
+	for i := uint64(0); i < *count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		id := profileIDForIndex(*start+i, cfg)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		for _, e := range gen.Relationships(id) {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
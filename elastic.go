@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Elasticsearch/OpenSearch output: the _bulk NDJSON format, written to a
+// file or sent straight to a cluster. A document's _id is the hash of its
+// content, so re-indexing a range overwrites instead of duplicating.
+
+const defaultBulkIndex = "records"
+
+// Bulk retry backoff: doubling from the first delay up to the cap.
+const (
+	bulkBackoffFirst = 500 * time.Millisecond
+	bulkBackoffMax   = 30 * time.Second
+)
+
+// indexDatePattern matches {date:LAYOUT} in an index name; the record's
+// timestamp is formatted with the Go time layout.
+var indexDatePattern = regexp.MustCompile(`\{date:([^}]+)\}`)
+
+func init() {
+	f := bulkFormat(defaultBulkIndex)
+	recordFormats[f.name] = f
+}
+
+// bulkIndexName resolves an index name pattern for a record.
+func bulkIndexName(pattern string, rec *RawRecord) string {
+	if !strings.Contains(pattern, "{date:") {
+		return pattern
+	}
+	ts, _ := time.Parse(time.RFC3339, rec.Timestamp)
+	return indexDatePattern.ReplaceAllStringFunc(pattern, func(m string) string {
+		return ts.Format(indexDatePattern.FindStringSubmatch(m)[1])
+	})
+}
+
+// bulkFormat writes an index action and the document for each record.
+func bulkFormat(index string) recordFormat {
+	return recordFormat{
+		name: "esbulk",
+		appendRecord: func(s *Schema, b []byte, rec *RawRecord) []byte {
+			doc := s.AppendJSON(nil, rec)
+			b = append(b, `{"index":{"_index":`...)
+			b = appendJSONString(b, bulkIndexName(index, rec))
+			b = fmt.Appendf(b, `,"_id":"%016x"}}`+"\n", fnv1a64(string(doc)))
+			b = append(b, doc...)
+			return append(b, '\n')
+		},
+	}
+}
+
+// bulkResponse is the part of a _bulk response needed to find failed items.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulkClient sends _bulk requests, backing off while the cluster answers
+// 429 Too Many Requests, for the whole request or for single items.
+type bulkClient struct {
+	url        string
+	client     *http.Client
+	maxRetries int
+}
+
+// send indexes the actions in lines, two lines per document.
+func (c *bulkClient) send(ctx context.Context, lines [][]byte) error {
+	delay := bulkBackoffFirst
+	for attempt := 0; ; attempt++ {
+		retry, err := c.post(ctx, lines)
+		if err != nil || len(retry) == 0 {
+			return err
+		}
+		if attempt == c.maxRetries {
+			return fmt.Errorf("bulk: still throttled after %d retries", c.maxRetries)
+		}
+		metrics.errors.Inc("elastic")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, bulkBackoffMax)
+		lines = retry
+	}
+}
+
+// post sends one request and returns the lines to retry.
+func (c *bulkClient) post(ctx context.Context, lines [][]byte) ([][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/_bulk", bytes.NewReader(bytes.Join(lines, nil)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		io.Copy(io.Discard, resp.Body)
+		return lines, nil
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("bulk: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var br bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		return nil, fmt.Errorf("bulk: decode response: %w", err)
+	}
+	if !br.Errors {
+		return nil, nil
+	}
+	var retry [][]byte
+	for i, item := range br.Items {
+		for _, result := range item {
+			switch {
+			case result.Status == http.StatusTooManyRequests:
+				retry = append(retry, lines[2*i], lines[2*i+1])
+			case result.Status >= 300:
+				return nil, fmt.Errorf("bulk: document %d: status %d: %s", i, result.Status, result.Error)
+			}
+		}
+	}
+	return retry, nil
+}
+
+func runElastic(args []string) error {
+	fs := flag.NewFlagSet("elastic", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	index := fs.String("index", defaultBulkIndex, "index name; {date:LAYOUT} is replaced by the record timestamp in Go time layout, e.g. records-{date:2006.01}")
+	url := fs.String("url", "", "cluster URL to index into; without it the _bulk body is written to -output")
+	output := fs.String("output", "-", "bulk file, \"-\" for stdout")
+	batch := fs.Int("batch", 1000, "documents per _bulk request")
+	retries := fs.Int("max-retries", 8, "retries of throttled (429) requests and documents")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *batch <= 0 {
+		return errors.New("-batch must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	format := bulkFormat(*index)
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if *url == "" {
+		var w io.Writer = os.Stdout
+		if *output != "-" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		bw := bufio.NewWriterSize(w, 1<<16)
+		if _, _, err := writeRecords(ctx, gen, bw, format, *start, *count, nil); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+
+	client := &bulkClient{url: strings.TrimRight(*url, "/"), client: &http.Client{Timeout: time.Minute}, maxRetries: *retries}
+	schema := gen.Schema()
+	lines := make([][]byte, 0, 2**batch)
+	for i := uint64(0); i < *count; i++ {
+		rec := gen.RecordByIndex(*start + i)
+		doc := format.appendRecord(schema, nil, &rec)
+		cut := bytes.IndexByte(doc, '\n') + 1
+		lines = append(lines, doc[:cut], doc[cut:])
+		if len(lines) == cap(lines) || i+1 == *count {
+			if err := client.send(ctx, lines); err != nil {
+				return err
+			}
+			metrics.recordsGenerated.Add("elastic", float64(len(lines)/2))
+			lines = lines[:0]
+		}
+	}
+	logFor("elastic").Info("indexed records", "start", *start, "count", *count, "url", *url, "index", *index)
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/damir-manapov/idempotent-entries-idea/determ"
+)
+
+// WeightedValue is one entry of a weighted pool.
+type WeightedValue = determ.WeightedValue
+
+// EmailConfig controls which domains profile emails use. When a config has
+// no emails section the original fixed five-domain list is used, so existing
+// datasets are unaffected.
+type EmailConfig struct {
+	// Domains maps a profile locale to its weighted domain pool; the "*"
+	// entry is used for locales without one of their own.
+	Domains map[string][]WeightedValue `json:"domains"`
+	// CorporateRate is the share of addresses on a company domain derived
+	// from the profile's last name instead of a public provider.
+	CorporateRate float64 `json:"corporateRate"`
+}
+
+var defaultEmailConfig = EmailConfig{
+	Domains: map[string][]WeightedValue{
+		"ru": {
+			{Value: "yandex.ru", Weight: 35},
+			{Value: "mail.ru", Weight: 30},
+			{Value: "gmail.com", Weight: 20},
+			{Value: "rambler.ru", Weight: 5},
+			{Value: "bk.ru", Weight: 5},
+			{Value: "list.ru", Weight: 5},
+		},
+		"*": {
+			{Value: "gmail.com", Weight: 55},
+			{Value: "outlook.com", Weight: 20},
+			{Value: "yahoo.com", Weight: 15},
+			{Value: "icloud.com", Weight: 10},
+		},
+	},
+	CorporateRate: 0.1,
+}
+
+// legacyEmailDomains is the domain list used when no EmailConfig is set.
+var legacyEmailDomains = []string{"gmail.com", "mail.ru", "yahoo.com", "outlook.com", "yandex.ru"}
+
+func (c *EmailConfig) clone() *EmailConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Domains = make(map[string][]WeightedValue, len(c.Domains))
+	for locale, pool := range c.Domains {
+		out.Domains[locale] = append([]WeightedValue(nil), pool...)
+	}
+	return &out
+}
+
+func (c *EmailConfig) validate() error {
+	if c.CorporateRate < 0 || c.CorporateRate > 1 {
+		return errors.New("emails.corporateRate must be in [0, 1]")
+	}
+	if _, ok := c.Domains["*"]; !ok {
+		return errors.New(`emails.domains must have a "*" fallback pool`)
+	}
+	for locale, pool := range c.Domains {
+		if err := validateWeighted(pool); err != nil {
+			return fmt.Errorf("emails.domains[%s]: %w", locale, err)
+		}
+	}
+	return nil
+}
+
+func validateWeighted(pool []WeightedValue) error {
+	total := 0
+	for i, v := range pool {
+		if v.Weight < 0 {
+			return fmt.Errorf("[%d]: weight must not be negative", i)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return errors.New("weights must not all be zero")
+	}
+	return nil
+}
+
+func pickWeighted(rng *SplitMix64, pool []WeightedValue) string {
+	return determ.PickWeighted(rng, pool)
+}
+
+var corporateSuffixes = []string{"", "-group", "-consulting", "corp", "-tech", "-trade"}
+
+// emailDomain picks the domain of one profile email.
+func emailDomain(rng *SplitMix64, cfg *EmailConfig, locale, lastName string) string {
+	if cfg == nil {
+		return legacyEmailDomains[rng.NextInt(len(legacyEmailDomains))]
+	}
+	if rng.NextFloat() < cfg.CorporateRate {
+		return corporateDomain(rng, locale, lastName)
+	}
+	pool, ok := cfg.Domains[locale]
+	if !ok {
+		pool = cfg.Domains["*"]
+	}
+	return pickWeighted(rng, pool)
+}
+
+// corporateDomain derives a company domain such as petrov-group.ru from a
+// last name.
+func corporateDomain(rng *SplitMix64, locale, lastName string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(transliterateCyrillicToLatin(lastName)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteString("company")
+	}
+	tld := ".com"
+	if locale == "ru" {
+		tld = ".ru"
+	}
+	return b.String() + corporateSuffixes[rng.NextInt(len(corporateSuffixes))] + tld
+}
+
+// EmailAliasConfig makes records of a profile use provider-equivalent
+// aliases of its mailboxes: dot variants where the provider ignores dots and
+// plus-tags where it supports subaddressing. Records then carry the
+// underlying address as emailCanonical, the ground truth for normalization.
+type EmailAliasConfig struct {
+	// Rate is the share of records whose email is written as an alias.
+	Rate float64 `json:"rate"`
+	// Tags are the plus-tags to choose from.
+	Tags []string `json:"tags"`
+}
+
+var defaultEmailAliasConfig = EmailAliasConfig{
+	Rate: 0.15,
+	Tags: []string{"promo", "shop", "news", "work", "spam", "orders"},
+}
+
+// emailAliasRules describes how providers treat local parts.
+var emailAliasRules = map[string]struct{ ignoresDots, plusTags bool }{
+	"gmail.com":      {ignoresDots: true, plusTags: true},
+	"googlemail.com": {ignoresDots: true, plusTags: true},
+	"outlook.com":    {plusTags: true},
+	"hotmail.com":    {plusTags: true},
+	"icloud.com":     {plusTags: true},
+	"yandex.ru":      {plusTags: true},
+	"fastmail.com":   {plusTags: true},
+}
+
+func (c *EmailAliasConfig) clone() *EmailAliasConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Tags = append([]string(nil), c.Tags...)
+	return &out
+}
+
+func (c *EmailAliasConfig) validate() error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return errors.New("emailAliases.rate must be in [0, 1]")
+	}
+	if len(c.Tags) == 0 {
+		return errors.New("emailAliases.tags must not be empty")
+	}
+	return nil
+}
+
+// aliasEmail returns the address as written on a record: the mailbox itself
+// or, at the configured rate, an alias the provider delivers to it.
+func aliasEmail(rng *SplitMix64, cfg *EmailAliasConfig, mailbox string) string {
+	local, domain, ok := strings.Cut(mailbox, "@")
+	if !ok || rng.NextFloat() >= cfg.Rate {
+		return mailbox
+	}
+	rules := emailAliasRules[strings.ToLower(domain)]
+	dots, plus := rules.ignoresDots, rules.plusTags
+	if dots && plus {
+		// Use one kind or both, so every combination shows up.
+		switch rng.NextInt(3) {
+		case 0:
+			plus = false
+		case 1:
+			dots = false
+		}
+	}
+
+	if dots {
+		local = dotVariant(rng, local)
+	}
+	if plus {
+		local += "+" + cfg.Tags[rng.NextInt(len(cfg.Tags))]
+	}
+	return local + "@" + domain
+}
+
+// dotVariant removes the dots of a local part or, if it has none, inserts
+// one between two letters.
+func dotVariant(rng *SplitMix64, local string) string {
+	if strings.Contains(local, ".") {
+		return strings.ReplaceAll(local, ".", "")
+	}
+	runes := []rune(local)
+	if len(runes) < 2 {
+		return local
+	}
+	i := 1 + rng.NextInt(len(runes)-1)
+	return string(runes[:i]) + "." + string(runes[i:])
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Multi-entity stream: one JSONL stream mixing entity types the way an
+// event bus carries them — person profiles, organizations, transactions
+// consent events and, when erasure is configured, erasure tombstones —
+// each line an envelope with a type tag, a timestamp
+// and the entity in its own schema:
+//
+//	{"type":"person","ts":"2025-03-01T10:00:00Z","data":{"profileId":...}}
+//
+// A person or organization is emitted at its first transaction in the
+// range, followed by the person's consent grants; withdrawals come later.
+// An erased person's tombstone comes at the erasure time; a person first
+// seen after it is not emitted, only their redacted transactions.
+// Lines are ordered by timestamp, then by type in the order of entityTypes,
+// then by the record index that produced them, so a range always yields
+// the same stream. Ranges are sorted in memory.
+
+// entityTypes are the entity types in the order ties are broken.
+var entityTypes = []string{"person", "organization", "consent", "erasure", "transaction"}
+
+// ConsentEvent is a person granting or withdrawing consent to a purpose.
+type ConsentEvent struct {
+	ProfileID uint64 `json:"profileId"`
+	Purpose   string `json:"purpose"`
+	// Action is "granted" or "withdrawn".
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+}
+
+// consentPurposes are the purposes consent is asked for, with the share of
+// people granting each.
+var consentPurposes = []struct {
+	name  string
+	grant float64
+}{{"marketing", 0.55}, {"analytics", 0.8}, {"third-party-sharing", 0.2}}
+
+// consentWithdrawRate is the share of grants withdrawn later in the date
+// spread.
+const consentWithdrawRate = 0.1
+
+// consentEvents derives a person's consent history: grants at first
+// contact, through the channel of that transaction, and withdrawals between
+// then and the end of the date spread.
+func consentEvents(profileID uint64, first time.Time, channel string, cfg GeneratorConfig) []streamEntity {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("consent:%d", profileID)), cfg.Seed))
+	var out []streamEntity
+	for _, p := range consentPurposes {
+		if rng.NextFloat() >= p.grant {
+			// Draw anyway so later purposes do not depend on this one.
+			rng.NextFloat()
+			rng.NextFloat()
+			continue
+		}
+		out = append(out, streamEntity{ts: first, data: ConsentEvent{profileID, p.name, "granted", channel}})
+		withdraw, at := rng.NextFloat() < consentWithdrawRate, rng.NextFloat()
+		if rest := cfg.DateSpread.End.Sub(first); withdraw && rest > 0 {
+			ts := first.Add(time.Duration(at * float64(rest))).Truncate(time.Second)
+			out = append(out, streamEntity{ts: ts, data: ConsentEvent{profileID, p.name, "withdrawn", "web"}})
+		}
+	}
+	return out
+}
+
+// streamEntity is one line of the stream before encoding.
+type streamEntity struct {
+	ts    time.Time
+	order int
+	index uint64
+	data  any
+	line  []byte
+}
+
+// entityStream collects the entities of records [start, start+count) of
+// the selected types, sorted.
+func entityStream(ctx context.Context, gen *IdempotentGenerator, start, count uint64, types map[string]bool) ([]streamEntity, error) {
+	order := make(map[string]int, len(entityTypes))
+	for i, t := range entityTypes {
+		order[t] = i
+	}
+	var out []streamEntity
+	add := func(kind string, idx uint64, e streamEntity) {
+		if types[kind] {
+			e.order, e.index = order[kind], idx
+			out = append(out, e)
+		}
+	}
+	people, orgs := make(map[uint64]bool), make(map[uint64]bool)
+	schema := gen.Schema()
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		idx := start + i
+		rec := gen.RecordByIndex(idx)
+		ts, err := time.Parse(time.RFC3339, rec.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", idx, err)
+		}
+		if !people[rec.ProfileID] {
+			people[rec.ProfileID] = true
+			if rec.ErasedAt == "" {
+				p := gen.ProfileByID(rec.ProfileID)
+				// The stream must not announce an erasure before its tombstone.
+				p.ErasedAt = ""
+				add("person", idx, streamEntity{ts: ts, data: p})
+				for _, e := range consentEvents(rec.ProfileID, ts, rec.Channel, gen.cfg) {
+					add("consent", idx, e)
+				}
+			}
+			if gen.cfg.Erasure != nil {
+				if at, ok := erasedAt(rec.ProfileID, gen.cfg); ok {
+					add("erasure", idx, streamEntity{ts: at, data: ErasureEvent{rec.ProfileID}})
+				}
+			}
+		}
+		if rec.OrgID != nil && !orgs[*rec.OrgID] {
+			orgs[*rec.OrgID] = true
+			add("organization", idx, streamEntity{ts: ts, data: organization(*rec.OrgID, gen.cfg.Organizations, gen.cfg.Seed)})
+		}
+		add("transaction", idx, streamEntity{ts: ts, line: schema.AppendJSON(nil, &rec)})
+	}
+	slices.SortStableFunc(out, func(a, b streamEntity) int {
+		if c := a.ts.Compare(b.ts); c != 0 {
+			return c
+		}
+		if a.order != b.order {
+			return a.order - b.order
+		}
+		return cmp.Compare(a.index, b.index)
+	})
+	return out, nil
+}
+
+// appendEntity appends the envelope line of e.
+func appendEntity(b []byte, e streamEntity) ([]byte, error) {
+	b = append(b, `{"type":`...)
+	b = appendJSONString(b, entityTypes[e.order])
+	b = append(b, `,"ts":`...)
+	b = appendJSONString(b, e.ts.UTC().Format(time.RFC3339))
+	b = append(b, `,"data":`...)
+	if e.line != nil {
+		b = append(b, e.line...)
+	} else {
+		data, err := json.Marshal(e.data)
+		if err != nil {
+			return b, err
+		}
+		b = append(b, data...)
+	}
+	return append(b, "}\n"...), nil
+}
+
+func runEntities(args []string) error {
+	fs := flag.NewFlagSet("entities", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of transactions; the range is sorted in memory")
+	output := fs.String("output", "-", "output file, \"-\" for stdout")
+	typeList := fs.String("types", strings.Join(entityTypes, ","), "entity types to emit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	types := make(map[string]bool)
+	for _, t := range strings.Split(*typeList, ",") {
+		if !slices.Contains(entityTypes, t) {
+			return fmt.Errorf("unknown entity type %q, want %s", t, strings.Join(entityTypes, ", "))
+		}
+		types[t] = true
+	}
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	entities, err := entityStream(ctx, gen, *start, *count, types)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, 1<<16)
+	var line []byte
+	for _, e := range entities {
+		if line, err = appendEntity(line[:0], e); err != nil {
+			return err
+		}
+		if _, err := bw.Write(line); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
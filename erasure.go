@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErasureConfig makes a set of profiles request erasure, the GDPR right to
+// be forgotten, at a deterministic time in the date spread. From then on
+// their records are redacted — personal fields emptied, erasedAt set — and
+// the entities stream carries an erasure tombstone at that time, so a
+// pipeline can be checked end to end: everything it holds for the profile
+// from before the tombstone must be erased, and nothing personal may come
+// after it. Profiles report their erasure time as ground truth.
+type ErasureConfig struct {
+	// Rate is the share of profiles requesting erasure.
+	Rate float64 `json:"rate"`
+	// Profiles always request erasure, on top of Rate.
+	Profiles []uint64 `json:"profiles,omitempty"`
+}
+
+var defaultErasureConfig = ErasureConfig{Rate: 0.01}
+
+func (c *ErasureConfig) clone() *ErasureConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Profiles = append([]uint64(nil), c.Profiles...)
+	return &out
+}
+
+func (c *ErasureConfig) validate(profileSpace uint64) error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return fmt.Errorf("erasure.rate must be in [0, 1]")
+	}
+	for _, id := range c.Profiles {
+		if id >= profileSpace {
+			return fmt.Errorf("erasure.profiles: profile %d is outside the profile space", id)
+		}
+	}
+	return nil
+}
+
+// erasedAt returns when a profile requests erasure, if it does. The time is
+// drawn whether or not the profile is selected, so listing a profile does
+// not move it.
+func erasedAt(profileID uint64, cfg GeneratorConfig) (time.Time, bool) {
+	c := cfg.Erasure
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("erasure:%d", profileID)), cfg.Seed))
+	selected := rng.NextFloat() < c.Rate || slices.Contains(c.Profiles, profileID)
+	start, end := cfg.DateSpread.Start.UTC(), cfg.DateSpread.End.UTC()
+	at := start.Add(time.Duration(rng.NextFloat() * float64(end.Sub(start)))).Truncate(time.Second)
+	return at, selected
+}
+
+// redact empties the personal fields of a record of an erased profile,
+// including the session and device that would link it to other records
+// and the organization, whose numbers identify a sole trader. Custom fields
+// plugins declare as direct identifiers are emptied too; profileId is the
+// only direct identifier left.
+func redact(rec *RawRecord, at time.Time, custom []PluginField) {
+	rec.FirstName, rec.LastName = "", ""
+	rec.Email, rec.EmailCanonical = "", ""
+	rec.Phone, rec.Login = "", ""
+	rec.BirthDate, rec.Gender = "", ""
+	rec.SessionID, rec.Device = "", ""
+	rec.OrgID, rec.INN, rec.OGRN, rec.KPP = nil, "", "", ""
+	for _, f := range custom {
+		if f.Sensitivity != SensitivityDirect {
+			continue
+		}
+		for i := range rec.Custom {
+			if rec.Custom[i].Name == f.Name {
+				rec.Custom[i].Value = ""
+			}
+		}
+	}
+	rec.ErasedAt = at.Format(time.RFC3339)
+}
+
+// ErasureEvent is the tombstone of a profile in the entities stream.
+type ErasureEvent struct {
+	ProfileID uint64 `json:"profileId"`
+}
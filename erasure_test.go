@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func erasureConfig() GeneratorConfig {
+	cfg := cloneConfig(defaultConfig)
+	cfg.Erasure = &ErasureConfig{Rate: 1}
+	cfg.Organizations = defaultOrganizationConfig.clone()
+	cfg.Organizations.Rate = 1
+	cfg.Sessions = defaultSessionConfig.clone()
+	cfg.EmailAliases = defaultEmailAliasConfig.clone()
+	cfg.Demographics = defaultDemographicsConfig.clone()
+	cfg.Canaries = &CanaryConfig{Every: 3}
+	return cfg
+}
+
+func TestErasedRecordsCarryNoDirectIdentifiers(t *testing.T) {
+	cfg := erasureConfig()
+	if err := validateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	gen := mustNewGenerator(cfg)
+	erased, sessions := 0, 0
+	for idx := uint64(0); idx < 2000; idx++ {
+		rec := gen.RecordByIndex(idx)
+		if rec.ErasedAt == "" {
+			if rec.SessionID != "" {
+				sessions++
+			}
+			continue
+		}
+		erased++
+		for i := range recordFields {
+			f := &recordFields[i]
+			if f.Sensitivity != SensitivityDirect || f.Name == "profileId" {
+				continue
+			}
+			if _, empty := f.value(&rec); !empty {
+				t.Errorf("erased record %d keeps %s", idx, f.Name)
+			}
+		}
+		if rec.KPP != "" || rec.SessionID != "" || rec.Device != "" {
+			t.Errorf("erased record %d keeps kpp %q, session %q or device %q", idx, rec.KPP, rec.SessionID, rec.Device)
+		}
+		if strings.HasPrefix(rec.Login, "canary-") || rec.FirstName == "Canary" {
+			t.Errorf("erased record %d is a canary", idx)
+		}
+	}
+	if erased == 0 || sessions == 0 {
+		t.Fatalf("%d erased records and %d with sessions; the test covers nothing", erased, sessions)
+	}
+}
+
+func TestRedactCustomFields(t *testing.T) {
+	custom := []PluginField{{Name: "loyaltyId", Sensitivity: SensitivityDirect}, {Name: "tier"}}
+	rec := RawRecord{Custom: []CustomField{{"loyaltyId", "L1"}, {"tier", "gold"}}}
+	redact(&rec, time.Unix(0, 0), custom)
+	if rec.Custom[0].Value != "" || rec.Custom[1].Value != "gold" {
+		t.Errorf("custom fields after redact: %+v, want loyaltyId emptied and tier kept", rec.Custom)
+	}
+}
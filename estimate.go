@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// dryRunSample is how many records a dry run actually generates.
+const dryRunSample = 100_000
+
+// sinkSample is the measured cost of pushing a sample through one sink.
+type sinkSample struct {
+	Sink    string
+	Records uint64
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// SinkEstimate is a sinkSample extrapolated to a full run.
+type SinkEstimate struct {
+	Sink             string  `json:"sink"`
+	RecordsPerSecond float64 `json:"recordsPerSecond"`
+	MBPerSecond      float64 `json:"mbPerSecond,omitempty"`
+	EstimatedSeconds float64 `json:"estimatedSeconds"`
+}
+
+func (s sinkSample) project(target uint64) SinkEstimate {
+	secs := s.Elapsed.Seconds()
+	if secs <= 0 {
+		secs = 1e-9
+	}
+	rate := float64(s.Records) / secs
+	return SinkEstimate{
+		Sink:             s.Sink,
+		RecordsPerSecond: rate,
+		MBPerSecond:      float64(s.Bytes) / (1 << 20) / secs,
+		EstimatedSeconds: float64(target) / rate,
+	}
+}
+
+// Estimate projects the output size and duration of generating Records
+// records from a calibrated sample of SampleRecords.
+type Estimate struct {
+	Format         string         `json:"format"`
+	Records        uint64         `json:"records"`
+	SampleRecords  uint64         `json:"sampleRecords"`
+	BytesPerRecord float64        `json:"bytesPerRecord"`
+	TotalBytes     int64          `json:"totalBytes"`
+	Sinks          []SinkEstimate `json:"sinks"`
+}
+
+// estimateRun generates a sample from the start of [start, start+count)
+// through each sink and projects the results to count records. The file
+// sink writes a temporary file in dir.
+func estimateRun(ctx context.Context, gen RecordSource, start, count, sample uint64, dir string) (Estimate, error) {
+	sample = min(sample, count)
+	est := Estimate{Format: "jsonl", Records: count}
+	if sample == 0 {
+		return est, nil
+	}
+
+	began := time.Now()
+	for i := uint64(0); i < sample; i++ {
+		gen.RecordByIndex(start + i)
+	}
+	samples := []sinkSample{{Sink: "generate", Records: sample, Elapsed: time.Since(began)}}
+
+	encoded := &countingWriter{w: io.Discard}
+	began = time.Now()
+	if _, err := writeJSONL(ctx, gen, encoded, start, sample, nil); err != nil {
+		return est, err
+	}
+	samples = append(samples, sinkSample{Sink: "encode", Records: sample, Bytes: encoded.n, Elapsed: time.Since(began)})
+
+	file, err := os.CreateTemp(dir, "dry-run-*.jsonl")
+	if err != nil {
+		return est, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	began = time.Now()
+	if _, err := writeJSONL(ctx, gen, file, start, sample, nil); err != nil {
+		return est, err
+	}
+	if err := file.Sync(); err != nil {
+		return est, err
+	}
+	samples = append(samples, sinkSample{Sink: "file", Records: sample, Bytes: encoded.n, Elapsed: time.Since(began)})
+
+	return projectSamples("jsonl", count, samples), nil
+}
+
+// projectSamples extrapolates measured samples to target records. Output
+// size is taken from the first sample that wrote any bytes.
+func projectSamples(format string, target uint64, samples []sinkSample) Estimate {
+	est := Estimate{Format: format, Records: target}
+	for _, s := range samples {
+		est.SampleRecords = max(est.SampleRecords, s.Records)
+		if est.BytesPerRecord == 0 && s.Bytes > 0 {
+			est.BytesPerRecord = float64(s.Bytes) / float64(s.Records)
+		}
+		est.Sinks = append(est.Sinks, s.project(target))
+	}
+	est.TotalBytes = int64(est.BytesPerRecord * float64(target))
+	return est
+}
+
+func printEstimate(w io.Writer, est Estimate) {
+	fmt.Fprintf(w, "🔮 Estimate for %d %s records (calibrated on %d):\n", est.Records, est.Format, est.SampleRecords)
+	fmt.Fprintf(w, "💾 Size: %.2f GB (%.0f bytes/record)\n", float64(est.TotalBytes)/(1<<30), est.BytesPerRecord)
+	for _, s := range est.Sinks {
+		d := time.Duration(s.EstimatedSeconds * float64(time.Second))
+		fmt.Fprintf(w, "⏱️  %-9s %12.0f records/s", s.Sink, s.RecordsPerSecond)
+		if s.MBPerSecond > 0 {
+			fmt.Fprintf(w, " %8.2f MB/s", s.MBPerSecond)
+		} else {
+			fmt.Fprintf(w, " %13s", "")
+		}
+		fmt.Fprintf(w, "  → %s\n", formatDuration(d))
+	}
+}
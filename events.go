@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	eventPurchase = "purchase"
+	eventRefund   = "refund"
+)
+
+// refundLookback bounds how far back a refund looks for the purchase it
+// reverses.
+const refundLookback = 64
+
+// EventConfig gives every record an event type drawn from funnel ratios.
+// A refund reverses the nearest earlier purchase record: it takes that
+// record's profile and amount and happens after it, within the profile's
+// active span, so refunds never precede or mismatch their purchase. A
+// purchase is refunded at most once.
+type EventConfig struct {
+	// Funnel weights the event types, e.g. view, add_to_cart, purchase and
+	// refund.
+	Funnel []WeightedValue `json:"funnel"`
+	// RefundMaxDays bounds the delay between a purchase and its refund.
+	RefundMaxDays int `json:"refundMaxDays"`
+}
+
+var defaultEventConfig = EventConfig{
+	Funnel: []WeightedValue{
+		{Value: "view", Weight: 60},
+		{Value: "add_to_cart", Weight: 20},
+		{Value: eventPurchase, Weight: 18},
+		{Value: eventRefund, Weight: 2},
+	},
+	RefundMaxDays: 30,
+}
+
+func (c *EventConfig) clone() *EventConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Funnel = append([]WeightedValue(nil), c.Funnel...)
+	return &out
+}
+
+func (c *EventConfig) validate() error {
+	if err := validateWeighted(c.Funnel); err != nil {
+		return fmt.Errorf("events.funnel: %w", err)
+	}
+	weights := map[string]int{}
+	for _, v := range c.Funnel {
+		weights[v.Value] += v.Weight
+	}
+	if weights[eventRefund] > 0 && weights[eventPurchase] == 0 {
+		return errors.New("events.funnel has refunds but no purchases")
+	}
+	if c.RefundMaxDays <= 0 {
+		return errors.New("events.refundMaxDays must be positive")
+	}
+	return nil
+}
+
+func (c *EventConfig) eventType(idx, seed uint64) string {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("etype:%d", idx)), seed))
+	return pickWeighted(rng, c.Funnel)
+}
+
+// refundOrigin finds the purchase a refund at idx reverses. Only the first
+// refund after a purchase reverses it; a refund with no purchase in reach,
+// or behind an earlier refund, is recorded as a purchase itself.
+func (c *EventConfig) refundOrigin(idx, seed uint64) (uint64, bool) {
+	for k := uint64(1); k <= refundLookback && k <= idx; k++ {
+		switch c.eventType(idx-k, seed) {
+		case eventPurchase:
+			return idx - k, true
+		case eventRefund:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// refundTime places a refund after its purchase and before hi, the end of
+// the profile's active span; a refund that would come later is squeezed
+// into what is left of the span.
+func (c *EventConfig) refundTime(idx uint64, purchase, hi time.Time, seed uint64) time.Time {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("refund:%d", idx)), seed))
+	delay := time.Hour + time.Duration(rng.NextFloat()*float64(time.Duration(c.RefundMaxDays)*24*time.Hour))
+	if room := hi.Sub(purchase); delay >= room {
+		delay = time.Duration(rng.NextFloat() * float64(max(room, 0)))
+	}
+	return purchase.Add(delay).Truncate(time.Second)
+}
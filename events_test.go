@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefundsReverseOnePurchaseInsideSpan(t *testing.T) {
+	withEvents := cloneConfig(defaultConfig)
+	withEvents.Events = defaultEventConfig.clone()
+	withLifecycle := cloneConfig(withEvents)
+	withLifecycle.Lifecycle = defaultLifecycleConfig.clone()
+	for name, cfg := range map[string]GeneratorConfig{"date spread": withEvents, "lifecycle": withLifecycle} {
+		gen := mustNewGenerator(cfg)
+		refunded := make(map[uint64]uint64)
+		for idx := uint64(0); idx < 20000; idx++ {
+			rec := gen.RecordByIndex(idx)
+			if rec.RefundOf == nil {
+				continue
+			}
+			origin := *rec.RefundOf
+			if other, ok := refunded[origin]; ok {
+				t.Fatalf("%s: purchase %d refunded by records %d and %d", name, origin, other, idx)
+			}
+			refunded[origin] = idx
+			purchase := gen.RecordByIndex(origin)
+			bought, _ := time.Parse(time.RFC3339, purchase.Timestamp)
+			at, _ := time.Parse(time.RFC3339, rec.Timestamp)
+			switch {
+			case purchase.EventType != eventPurchase || purchase.ProfileID != rec.ProfileID:
+				t.Fatalf("%s: record %d refunds %s record %d of profile %d", name, idx, purchase.EventType, origin, purchase.ProfileID)
+			case at.Before(bought):
+				t.Fatalf("%s: refund %d at %s precedes purchase %d at %s", name, idx, rec.Timestamp, origin, purchase.Timestamp)
+			}
+			if err := CheckActiveSpan(gen, idx); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if len(refunded) == 0 {
+			t.Fatalf("%s: no refunds", name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Filter selects records for IterateWhere. Empty fields match everything.
+// City, channel, time window and bucket are checked before a record is
+// built, so skipped indices cost only the index→profile mapping and the
+// placement draws, not name distortion. Whatever can still change those
+// fields afterwards — sessions, refunds, field availability — is checked
+// again on the built record; with plugins, which may rewrite any of them,
+// only the bucket is checked early.
+type Filter struct {
+	Cities   []string
+	Channels []string
+	// From and To bound Timestamp to [From, To); zero values are open.
+	From, To time.Time
+	// RepeatMultipliers selects profiles by the frequency bucket they fall
+	// in.
+	RepeatMultipliers []int
+	// Match, if set, is applied to the built record last.
+	Match func(*RawRecord) bool
+}
+
+func (f *Filter) matchTime(ts time.Time) bool {
+	return (f.From.IsZero() || !ts.Before(f.From)) && (f.To.IsZero() || ts.Before(f.To))
+}
+
+// matchPlacement checks the pushed-down fields. exactTime is false when
+// sessions may still move the timestamp, in which case the window is
+// checked on the built record instead; blankCity is true when field
+// availability may still empty the city.
+func (f *Filter) matchPlacement(ts time.Time, city, channel string, exactTime, blankCity bool) bool {
+	if len(f.Cities) > 0 && !slices.Contains(f.Cities, city) && !(blankCity && slices.Contains(f.Cities, "")) {
+		return false
+	}
+	if len(f.Channels) > 0 && !slices.Contains(f.Channels, channel) {
+		return false
+	}
+	return !exactTime || f.matchTime(ts)
+}
+
+// IterateWhere returns the records in [start, start+count) that match f, in
+// index order.
+func (g *IdempotentGenerator) IterateWhere(start, count uint64, f Filter) []RawRecord {
+	records, _ := g.IterateWhereContext(context.Background(), start, count, f)
+	return records
+}
+
+// IterateWhereContext is IterateWhere with cancellation.
+func (g *IdempotentGenerator) IterateWhereContext(ctx context.Context, start, count uint64, f Filter) ([]RawRecord, error) {
+	var out []RawRecord
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if rec, ok := g.matchAt(start+i, &f); ok {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+// matchAt builds record idx if it matches f.
+func (g *IdempotentGenerator) matchAt(idx uint64, f *Filter) (RawRecord, bool) {
+	o := g.owner(idx)
+	if len(f.RepeatMultipliers) > 0 {
+		bucket := classifyBucket(o.profileID, g.cfg.Buckets, g.cfg.Seed)
+		if !slices.Contains(f.RepeatMultipliers, bucket.RepeatMultiplier) {
+			return RawRecord{}, false
+		}
+	}
+	// Refunds are placed relative to their purchase, which has to be
+	// built; they are checked on the full record.
+	if !o.refund && len(g.plugins) == 0 {
+		ts, city, channel, _ := g.placement(idx, o, g.profile(o.profileID), nil)
+		exact := g.cfg.Sessions == nil || o.isFraud || !g.cfg.Sessions.hasChannel(channel)
+		if !f.matchPlacement(ts, city, channel, exact, g.cfg.FieldAvailability != nil) {
+			return RawRecord{}, false
+		}
+	}
+	rec := g.RecordByIndex(idx)
+	if o.refund || g.cfg.Sessions != nil || g.cfg.FieldAvailability != nil || len(g.plugins) > 0 {
+		ts, _ := time.Parse(time.RFC3339, rec.Timestamp)
+		if !f.matchPlacement(ts, rec.City, rec.Channel, true, false) {
+			return RawRecord{}, false
+		}
+	}
+	if f.Match != nil && !f.Match(&rec) {
+		return RawRecord{}, false
+	}
+	return rec, true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// naiveWhere filters built records, the reference IterateWhere must match.
+func naiveWhere(gen *IdempotentGenerator, start, count uint64, f Filter) []uint64 {
+	var out []uint64
+	for _, rec := range gen.Iterate(start, count) {
+		ts, _ := time.Parse(time.RFC3339, rec.Timestamp)
+		bucket := classifyBucket(rec.ProfileID, gen.cfg.Buckets, gen.cfg.Seed)
+		if (len(f.Cities) == 0 || slices.Contains(f.Cities, rec.City)) &&
+			(len(f.Channels) == 0 || slices.Contains(f.Channels, rec.Channel)) &&
+			f.matchTime(ts) &&
+			(len(f.RepeatMultipliers) == 0 || slices.Contains(f.RepeatMultipliers, bucket.RepeatMultiplier)) {
+			out = append(out, rec.RecordIndex)
+		}
+	}
+	return out
+}
+
+func TestIterateWhere(t *testing.T) {
+	withAvailability := cloneConfig(defaultConfig)
+	withAvailability.FieldAvailability = map[string]map[string]float64{"web": {"city": 0}, "mobile": {"city": 0.5}}
+	withSessions := cloneConfig(withAvailability)
+	withSessions.Sessions = defaultSessionConfig.clone()
+	withSessions.Events = defaultEventConfig.clone()
+
+	mid := defaultConfig.DateSpread.Start.Add(defaultConfig.DateSpread.End.Sub(defaultConfig.DateSpread.Start) / 2)
+	filters := map[string]Filter{
+		"city and channel": {Cities: []string{"Москва"}, Channels: []string{"web"}},
+		"blanked city":     {Cities: []string{""}},
+		"city":             {Cities: []string{"Москва", ""}, Channels: []string{"mobile", "offline"}},
+		"window":           {From: mid, Channels: []string{"web", "mobile"}},
+		"bucket":           {RepeatMultipliers: []int{1}, Cities: []string{"Москва"}},
+	}
+	for name, cfg := range map[string]GeneratorConfig{"availability": withAvailability, "sessions": withSessions} {
+		gen := mustNewGenerator(cfg)
+		for fname, f := range filters {
+			var got []uint64
+			for _, rec := range gen.IterateWhere(0, 2000, f) {
+				got = append(got, rec.RecordIndex)
+			}
+			if want := naiveWhere(gen, 0, 2000, f); !slices.Equal(got, want) {
+				t.Errorf("%s, %s: IterateWhere returned %d records, want %d", name, fname, len(got), len(want))
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package fixtures serves tiny named datasets the generator produced and
+// the repository checks in, so unit tests across teams can depend on
+// identical miniature data without running the CLI:
+//
+//	d := fixtures.SmallClustered100()
+//	clusters := myMatcher(d.Records)
+//	fixtures.AssertClusters(t, d, clusters)
+//
+// Each dataset is records [0, n) of a config kept next to it in data/; the
+// generator's tests fail when a dataset no longer matches what the config
+// generates, and go test -update there regenerates it.
+package fixtures
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+//go:embed data
+var data embed.FS
+
+// counts are the record counts of the datasets, by name.
+var counts = map[string]uint64{
+	"small-clustered-100": 100,
+	"singletons-10":       10,
+}
+
+// Record is a generated record. It has every field the generator writes,
+// in the same order and under the same JSON names; fields a dataset's
+// config does not enable are empty.
+type Record struct {
+	RecordIndex    uint64  `json:"recordIndex"`
+	ProfileID      uint64  `json:"profileId"`
+	VariantIndex   int     `json:"variantIndex"`
+	FirstName      string  `json:"firstName"`
+	LastName       string  `json:"lastName"`
+	Email          string  `json:"email"`
+	Phone          string  `json:"phone"`
+	Login          string  `json:"login"`
+	PointOfSale    string  `json:"pointOfSale"`
+	City           string  `json:"city"`
+	Channel        string  `json:"channel"`
+	Amount         float64 `json:"amount"`
+	Timestamp      string  `json:"timestamp"`
+	EmailCanonical string  `json:"emailCanonical,omitempty"`
+	LocalTimestamp string  `json:"localTimestamp,omitempty"`
+	Timezone       string  `json:"timezone,omitempty"`
+	BirthDate      string  `json:"birthDate,omitempty"`
+	Gender         string  `json:"gender,omitempty"`
+	Category       string  `json:"category,omitempty"`
+	SessionID      string  `json:"sessionId,omitempty"`
+	SessionStart   string  `json:"sessionStart,omitempty"`
+	Device         string  `json:"device,omitempty"`
+	EventType      string  `json:"eventType,omitempty"`
+	RefundOf       *uint64 `json:"refundOf,omitempty"`
+	FraudLabel     string  `json:"fraudLabel,omitempty"`
+	FraudIncident  string  `json:"fraudIncident,omitempty"`
+	OrgID          *uint64 `json:"orgId,omitempty"`
+	INN            string  `json:"inn,omitempty"`
+	OGRN           string  `json:"ogrn,omitempty"`
+	KPP            string  `json:"kpp,omitempty"`
+	Jurisdiction   string  `json:"jurisdiction,omitempty"`
+	ExpiresAt      string  `json:"expiresAt,omitempty"`
+	ErasedAt       string  `json:"erasedAt,omitempty"`
+}
+
+// Dataset is a named fixture.
+type Dataset struct {
+	Name string
+	// Config is the generator config the records come from.
+	Config     json.RawMessage
+	ConfigHash string
+	// Records are records [0, len(Records)) of Config, in index order.
+	Records []Record
+}
+
+// Names lists the datasets.
+func Names() []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Source returns the config of a dataset and its number of records.
+func Source(name string) (config []byte, count uint64, err error) {
+	count, ok := counts[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown fixture %q (available: %v)", name, Names())
+	}
+	config, err = data.ReadFile("data/" + name + ".config.json")
+	return config, count, err
+}
+
+// GoldenFile is the path of a dataset's records under the package
+// directory.
+func GoldenFile(name string) string {
+	return "data/" + name + ".json"
+}
+
+// Load returns a dataset by name.
+func Load(name string) (Dataset, error) {
+	config, count, err := Source(name)
+	if err != nil {
+		return Dataset{}, err
+	}
+	raw, err := data.ReadFile(GoldenFile(name))
+	if err != nil {
+		return Dataset{}, err
+	}
+	var golden struct {
+		ConfigHash string   `json:"configHash"`
+		Records    []Record `json:"records"`
+	}
+	if err := json.Unmarshal(raw, &golden); err != nil {
+		return Dataset{}, fmt.Errorf("fixture %s: %w", name, err)
+	}
+	if uint64(len(golden.Records)) != count {
+		return Dataset{}, fmt.Errorf("fixture %s has %d records, want %d", name, len(golden.Records), count)
+	}
+	return Dataset{Name: name, Config: config, ConfigHash: golden.ConfigHash, Records: golden.Records}, nil
+}
+
+func mustLoad(name string) Dataset {
+	d, err := Load(name)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// SmallClustered100 is 100 records of 30 profiles, clusters of one to six
+// records each. A third of the profiles appear in up to four variants, and
+// names are swapped, transliterated and misspelled.
+func SmallClustered100() Dataset { return mustLoad("small-clustered-100") }
+
+// Singletons10 is 10 records of the default config, each of its own
+// profile.
+func Singletons10() Dataset { return mustLoad("singletons-10") }
+
+// Clusters groups the record indices of d by profile, the ground truth of
+// entity resolution: each cluster is sorted, and clusters are ordered by
+// their first index.
+func (d Dataset) Clusters() [][]uint64 {
+	byProfile := map[uint64][]uint64{}
+	var order []uint64
+	for _, rec := range d.Records {
+		if _, ok := byProfile[rec.ProfileID]; !ok {
+			order = append(order, rec.ProfileID)
+		}
+		byProfile[rec.ProfileID] = append(byProfile[rec.ProfileID], rec.RecordIndex)
+	}
+	out := make([][]uint64, len(order))
+	for i, id := range order {
+		out[i] = byProfile[id]
+	}
+	return out
+}
+
+// AssertRecords fails t unless got are the records of d, reporting each
+// differing field.
+func AssertRecords(t testing.TB, d Dataset, got []Record) {
+	t.Helper()
+	if len(got) != len(d.Records) {
+		t.Errorf("fixture %s: got %d records, want %d", d.Name, len(got), len(d.Records))
+	}
+	for i := range min(len(got), len(d.Records)) {
+		want, have := reflect.ValueOf(d.Records[i]), reflect.ValueOf(got[i])
+		for f := 0; f < want.NumField(); f++ {
+			if w, h := want.Field(f).Interface(), have.Field(f).Interface(); !reflect.DeepEqual(w, h) {
+				t.Errorf("fixture %s: record %d: %s is %v, want %v", d.Name, d.Records[i].RecordIndex, want.Type().Field(f).Name, show(h), show(w))
+			}
+		}
+	}
+}
+
+// AssertClusters fails t unless clusters, a label per record index,
+// groups the records of d exactly as their profiles do. It reports pairs
+// put together that belong apart and pairs of one profile left apart.
+func AssertClusters(t testing.TB, d Dataset, clusters map[uint64]string) {
+	t.Helper()
+	for _, rec := range d.Records {
+		if _, ok := clusters[rec.RecordIndex]; !ok {
+			t.Errorf("fixture %s: record %d has no cluster", d.Name, rec.RecordIndex)
+		}
+	}
+	for i, a := range d.Records {
+		for _, b := range d.Records[i+1:] {
+			la, oka := clusters[a.RecordIndex]
+			lb, okb := clusters[b.RecordIndex]
+			if !oka || !okb {
+				continue
+			}
+			switch same := a.ProfileID == b.ProfileID; {
+			case same && la != lb:
+				t.Errorf("fixture %s: records %d and %d are of profile %d but in clusters %q and %q", d.Name, a.RecordIndex, b.RecordIndex, a.ProfileID, la, lb)
+			case !same && la == lb:
+				t.Errorf("fixture %s: records %d and %d are of profiles %d and %d but both in cluster %q", d.Name, a.RecordIndex, b.RecordIndex, a.ProfileID, b.ProfileID, la)
+			}
+		}
+	}
+}
+
+func show(v any) string {
+	switch v := v.(type) {
+	case *uint64:
+		if v == nil {
+			return "nil"
+		}
+		return fmt.Sprint(*v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprint(v)
+}
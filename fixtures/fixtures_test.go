@@ -0,0 +1,79 @@
+package fixtures
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDatasets(t *testing.T) {
+	for _, name := range Names() {
+		d, err := Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, rec := range d.Records {
+			if rec.RecordIndex != uint64(i) {
+				t.Fatalf("%s: record %d has index %d", name, i, rec.RecordIndex)
+			}
+		}
+	}
+	if n := len(SmallClustered100().Clusters()); n >= 100 || n > 30 {
+		t.Errorf("SmallClustered100 has %d clusters, want at most 30", n)
+	}
+	if n := len(Singletons10().Clusters()); n != 10 {
+		t.Errorf("Singletons10 has %d clusters, want 10", n)
+	}
+}
+
+// recorder is a testing.TB that collects errors.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertClusters(t *testing.T) {
+	d := SmallClustered100()
+	truth := map[uint64]string{}
+	for _, rec := range d.Records {
+		truth[rec.RecordIndex] = fmt.Sprint(rec.ProfileID)
+	}
+	AssertClusters(t, d, truth)
+
+	clusters := d.Clusters()
+	var split []uint64
+	for _, c := range clusters {
+		if len(c) > 1 {
+			split = c
+			break
+		}
+	}
+	wrong := map[uint64]string{}
+	for k, v := range truth {
+		wrong[k] = v
+	}
+	wrong[split[0]] = "elsewhere"
+	r := &recorder{TB: t}
+	AssertClusters(r, d, wrong)
+	if len(r.errors) != len(split)-1 {
+		t.Errorf("splitting a record off a cluster of %d gave %d errors: %q", len(split), len(r.errors), r.errors)
+	}
+}
+
+func TestAssertRecords(t *testing.T) {
+	d := Singletons10()
+	AssertRecords(t, d, d.Records)
+
+	got := append([]Record(nil), d.Records...)
+	got[3].Email = "changed@example.com"
+	r := &recorder{TB: t}
+	AssertRecords(r, d, got)
+	if len(r.errors) != 1 {
+		t.Errorf("one changed field gave %d errors: %q", len(r.errors), r.errors)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/damir-manapov/idempotent-entries-idea/fixtures"
+	"github.com/damir-manapov/idempotent-entries-idea/snapshottest"
+)
+
+// TestFixtures checks that every fixture dataset is what its config
+// generates; go test -update regenerates them.
+func TestFixtures(t *testing.T) {
+	for _, name := range fixtures.Names() {
+		t.Run(name, func(t *testing.T) {
+			data, count, err := fixtures.Source(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := parseConfig(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			indices := make([]uint64, count)
+			for i := range indices {
+				indices[i] = uint64(i)
+			}
+			snapshottest.AssertRecords(t, snapshotConfig(t, cfg), indices, filepath.Join("fixtures", fixtures.GoldenFile(name)))
+		})
+	}
+}
+
+// TestFixtureRecordFields checks that fixtures.Record has the JSON fields
+// of RawRecord, in order, under the same names.
+func TestFixtureRecordFields(t *testing.T) {
+	tags := func(v any) []string {
+		var out []string
+		rt := reflect.TypeOf(v)
+		for i := 0; i < rt.NumField(); i++ {
+			if rt.Field(i).Tag.Get("json") == "-" {
+				continue
+			}
+			out = append(out, rt.Field(i).Name+" "+rt.Field(i).Type.String()+" "+rt.Field(i).Tag.Get("json"))
+		}
+		return out
+	}
+	if got, want := tags(fixtures.Record{}), tags(RawRecord{}); !slices.Equal(got, want) {
+		t.Errorf("fixtures.Record fields are\n%q\nwant\n%q", got, want)
+	}
+}
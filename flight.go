@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"math"
+	"net"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Arrow Flight: Python, R and Java clients pull Arrow record batches for any
+// index range over gRPC, without files. A ticket, and the command of a
+// descriptor passed to GetFlightInfo or GetSchema, is a JSON range:
+//
+//	{"start": 0, "count": 1000000}
+//
+// GetFlightInfo splits the range into endpoints of at most -endpoint-rows
+// records, in index order, so a client can fetch them in parallel. Their
+// locations are empty: fetch them from the server that answered.
+type flightRange struct {
+	Start uint64 `json:"start"`
+	Count uint64 `json:"count"`
+}
+
+func parseFlightRange(b []byte) (flightRange, error) {
+	var r flightRange
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, status.Errorf(codes.InvalidArgument, "range %q: %v", b, err)
+	}
+	if r.Start+r.Count < r.Start {
+		return r, status.Error(codes.InvalidArgument, ErrRangeOverflow.Error())
+	}
+	return r, nil
+}
+
+// arrowSchema is the Arrow schema of s. Fields left out of JSON when empty
+// are nullable.
+func arrowSchema(s *Schema, hash string) *arrow.Schema {
+	fields := make([]arrow.Field, len(s.Fields))
+	for i, f := range s.Fields {
+		var typ arrow.DataType
+		switch f.Type {
+		case FieldString:
+			typ = arrow.BinaryTypes.String
+		case FieldInt:
+			typ = arrow.PrimitiveTypes.Int64
+		case FieldUint:
+			typ = arrow.PrimitiveTypes.Uint64
+		case FieldFloat:
+			typ = arrow.PrimitiveTypes.Float64
+		}
+		fields[i] = arrow.Field{Name: f.Name, Type: typ, Nullable: f.Optional || f.ptr}
+	}
+	metadata := arrow.NewMetadata([]string{"generator.configHash"}, []string{hash})
+	return arrow.NewSchema(fields, &metadata)
+}
+
+// appendArrowRecord appends rec to the column builders of b.
+func appendArrowRecord(b *array.RecordBuilder, s *Schema, rec *RawRecord) {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			b.Field(i).AppendNull()
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			b.Field(i).(*array.StringBuilder).Append(v.String())
+		case FieldInt:
+			b.Field(i).(*array.Int64Builder).Append(v.Int())
+		case FieldUint:
+			b.Field(i).(*array.Uint64Builder).Append(v.Uint())
+		case FieldFloat:
+			b.Field(i).(*array.Float64Builder).Append(v.Float())
+		}
+	}
+}
+
+type flightServer struct {
+	flight.BaseFlightServer
+	gen          *IdempotentGenerator
+	schema       *arrow.Schema
+	batchRows    int
+	endpointRows uint64
+}
+
+func newFlightServer(gen *IdempotentGenerator, batchRows int, endpointRows uint64) *flightServer {
+	return &flightServer{
+		gen:          gen,
+		schema:       arrowSchema(gen.Schema(), configHash(gen.cfg)),
+		batchRows:    batchRows,
+		endpointRows: endpointRows,
+	}
+}
+
+func (s *flightServer) descriptorRange(desc *flight.FlightDescriptor) (flightRange, error) {
+	if desc.GetType() != flight.DescriptorCMD {
+		return flightRange{}, status.Error(codes.InvalidArgument, "descriptor must be a command holding a JSON range")
+	}
+	return parseFlightRange(desc.GetCmd())
+}
+
+func (s *flightServer) GetSchema(_ context.Context, desc *flight.FlightDescriptor) (*flight.SchemaResult, error) {
+	if _, err := s.descriptorRange(desc); err != nil {
+		return nil, err
+	}
+	return &flight.SchemaResult{Schema: flight.SerializeSchema(s.schema, memory.DefaultAllocator)}, nil
+}
+
+func (s *flightServer) GetFlightInfo(_ context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	r, err := s.descriptorRange(desc)
+	if err != nil {
+		return nil, err
+	}
+	info := &flight.FlightInfo{
+		Schema:           flight.SerializeSchema(s.schema, memory.DefaultAllocator),
+		FlightDescriptor: desc,
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+		Ordered:          true,
+	}
+	if r.Count <= math.MaxInt64 {
+		info.TotalRecords = int64(r.Count)
+	}
+	for off := uint64(0); off < r.Count; off += s.endpointRows {
+		ticket, _ := json.Marshal(flightRange{Start: r.Start + off, Count: min(s.endpointRows, r.Count-off)})
+		info.Endpoint = append(info.Endpoint, &flight.FlightEndpoint{Ticket: &flight.Ticket{Ticket: ticket}})
+	}
+	return info, nil
+}
+
+func (s *flightServer) DoGet(tkt *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	r, err := parseFlightRange(tkt.GetTicket())
+	if err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(s.schema))
+	defer w.Close()
+	b := array.NewRecordBuilder(memory.DefaultAllocator, s.schema)
+	defer b.Release()
+
+	schema := s.gen.Schema()
+	flush := func() error {
+		batch := b.NewRecordBatch()
+		defer batch.Release()
+		return w.Write(batch)
+	}
+	rows := 0
+	for rec := range s.gen.Records(r.Start, r.Count) {
+		appendArrowRecord(b, schema, &rec)
+		if rows++; rows < s.batchRows {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		rows = 0
+	}
+	if rows > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// serveFlight serves the Flight service for gen on ln until the returned
+// server is stopped.
+func serveFlight(gen *IdempotentGenerator, ln net.Listener, batchRows int, endpointRows uint64) *grpc.Server {
+	srv := grpc.NewServer()
+	flight.RegisterFlightServiceServer(srv, newFlightServer(gen, batchRows, endpointRows))
+	go srv.Serve(ln)
+	return srv
+}
+
+func runFlight(args []string) error {
+	fs := flag.NewFlagSet("flight", flag.ContinueOnError)
+	addr := fs.String("addr", ":8815", "listen address")
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	batchRows := fs.Int("batch-rows", 64<<10, "records per Arrow record batch")
+	endpointRows := fs.Uint64("endpoint-rows", 10_000_000, "records per endpoint in GetFlightInfo")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *batchRows <= 0 || *endpointRows == 0 {
+		return errors.New("-batch-rows and -endpoint-rows must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := serveFlight(gen, ln, *batchRows, *endpointRows)
+	logFor("flight").Info("serving arrow flight", "addr", ln.Addr().String(), "configHash", configHash(cfg))
+
+	ctx, stop := interruptContext()
+	defer stop()
+	<-ctx.Done()
+	// A DoGet can stream for a long time; Stop cancels it rather than
+	// waiting it out.
+	srv.Stop()
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestFlightRanges(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serveFlight(gen, ln, 300, 1000)
+	defer srv.Stop()
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := flight.NewClientFromConn(conn, nil)
+	ctx := context.Background()
+
+	const start, count = 5000, 2500
+	cmd, _ := json.Marshal(flightRange{Start: start, Count: count})
+	info, err := client.GetFlightInfo(ctx, &flight.FlightDescriptor{Type: flight.DescriptorCMD, Cmd: cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalRecords != count || len(info.Endpoint) != 3 {
+		t.Fatalf("flight info has %d records in %d endpoints, want %d in 3", info.TotalRecords, len(info.Endpoint), count)
+	}
+
+	// The endpoints together hold the range, in order, as the generator
+	// derives it.
+	next := uint64(start)
+	for _, ep := range info.Endpoint {
+		stream, err := client.DoGet(ctx, ep.Ticket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := flight.NewRecordReader(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash, _ := r.Schema().Metadata().GetValue("generator.configHash"); hash != configHash(gen.cfg) {
+			t.Errorf("schema carries config hash %q", hash)
+		}
+		index := r.Schema().FieldIndices("recordIndex")[0]
+		email := r.Schema().FieldIndices("email")[0]
+		for r.Next() {
+			batch := r.RecordBatch()
+			if batch.NumRows() > 300 {
+				t.Errorf("batch of %d rows, want at most 300", batch.NumRows())
+			}
+			indices := batch.Column(index).(*array.Uint64)
+			emails := batch.Column(email).(*array.String)
+			for i := 0; i < int(batch.NumRows()); i++ {
+				want := gen.RecordByIndex(next)
+				if indices.Value(i) != next || emails.Value(i) != want.Email {
+					t.Fatalf("row %d of a batch is record %d with %q, want %d with %q", i, indices.Value(i), emails.Value(i), next, want.Email)
+				}
+				next++
+			}
+		}
+		if err := r.Err(); err != nil {
+			t.Fatal(err)
+		}
+		r.Release()
+	}
+	if next != start+count {
+		t.Errorf("endpoints end at record %d, want %d", next, start+count)
+	}
+
+	overflow, _ := json.Marshal(flightRange{Start: 1 << 63, Count: 1 << 63})
+	stream, err := client.DoGet(ctx, &flight.Ticket{Ticket: overflow})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("DoGet past the last index: %v, want InvalidArgument", err)
+	}
+}
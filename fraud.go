@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Anomaly patterns injected by FraudConfig.
+const (
+	fraudCardTesting      = "card_testing"
+	fraudSharedDevice     = "shared_device"
+	fraudImpossibleTravel = "impossible_travel"
+)
+
+// FraudConfig injects labeled anomaly incidents. The index space is cut into
+// blocks; a block holds at most one incident, a contiguous run of records
+// rewritten to follow one pattern:
+//
+//	card_testing       one profile, small amounts seconds apart
+//	shared_device      many profiles on one device with an identical amount
+//	impossible_travel  one profile alternating between distant cities
+//	                   minutes apart
+//
+// Records in an incident carry the pattern as fraudLabel and the incident's
+// ID, so detectors can be scored against the truth.
+type FraudConfig struct {
+	// BlockSize is the number of records per block.
+	BlockSize uint64 `json:"blockSize"`
+	// Rate is the share of blocks holding an incident.
+	Rate float64 `json:"rate"`
+	// Patterns weights the incident patterns.
+	Patterns []WeightedValue `json:"patterns"`
+	// MinRecords and MaxRecords bound the length of an incident.
+	MinRecords int `json:"minRecords"`
+	MaxRecords int `json:"maxRecords"`
+	// TravelPairs are pairs of cities too far apart to travel between in
+	// minutes.
+	TravelPairs [][2]string `json:"travelPairs"`
+}
+
+var defaultFraudConfig = FraudConfig{
+	BlockSize: 10_000,
+	Rate:      0.2,
+	Patterns: []WeightedValue{
+		{Value: fraudCardTesting, Weight: 40},
+		{Value: fraudSharedDevice, Weight: 30},
+		{Value: fraudImpossibleTravel, Weight: 30},
+	},
+	MinRecords: 5,
+	MaxRecords: 40,
+	TravelPairs: [][2]string{
+		{"Москва", "Новосибирск"},
+		{"Санкт-Петербург", "Алматы"},
+		{"Минск", "Екатеринбург"},
+	},
+}
+
+// anomaly holds the overrides of one record in an incident; zero values
+// leave the generated value in place.
+type anomaly struct {
+	pattern   string
+	incident  string
+	profileID uint64
+	pinned    bool
+	at        time.Time
+	city      string
+	channel   string
+	device    string
+	amount    float64
+}
+
+func (c *FraudConfig) clone() *FraudConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Patterns = append([]WeightedValue(nil), c.Patterns...)
+	out.TravelPairs = append([][2]string(nil), c.TravelPairs...)
+	return &out
+}
+
+func (c *FraudConfig) validate() error {
+	if c.BlockSize == 0 {
+		return errors.New("fraud.blockSize must be positive")
+	}
+	if c.Rate < 0 || c.Rate > 1 {
+		return errors.New("fraud.rate must be in [0, 1]")
+	}
+	if c.MinRecords <= 0 || c.MaxRecords < c.MinRecords || uint64(c.MaxRecords) > c.BlockSize {
+		return errors.New("fraud.minRecords and fraud.maxRecords must satisfy 0 < min <= max <= blockSize")
+	}
+	if err := validateWeighted(c.Patterns); err != nil {
+		return fmt.Errorf("fraud.patterns: %w", err)
+	}
+	for _, p := range c.Patterns {
+		switch p.Value {
+		case fraudCardTesting, fraudSharedDevice:
+		case fraudImpossibleTravel:
+			if len(c.TravelPairs) == 0 {
+				return errors.New("fraud.travelPairs must not be empty with impossible_travel")
+			}
+		default:
+			return fmt.Errorf("fraud.patterns: unknown pattern %q", p.Value)
+		}
+	}
+	return nil
+}
+
+// anomalyAt returns the overrides for idx when it falls in an incident.
+func (c *FraudConfig) anomalyAt(idx uint64, cfg GeneratorConfig) (anomaly, bool) {
+	block := idx / c.BlockSize
+	h := withSeed(fnv1a64(fmt.Sprintf("fraud:%d", block)), cfg.Seed)
+	rng := NewSplitMix64(h)
+	if rng.NextFloat() >= c.Rate {
+		return anomaly{}, false
+	}
+	length := c.MinRecords + rng.NextInt(c.MaxRecords-c.MinRecords+1)
+	start := block*c.BlockSize + uint64(rng.NextInt(int(c.BlockSize)-length+1))
+	if idx < start || idx >= start+uint64(length) {
+		return anomaly{}, false
+	}
+
+	k := idx - start
+	a := anomaly{
+		pattern:  pickWeighted(rng, c.Patterns),
+		incident: fmt.Sprintf("f-%016x", h),
+	}
+	base := timeForIndex(start, cfg)
+	r := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("fraud-record:%d", idx)), cfg.Seed))
+
+	switch a.pattern {
+	case fraudCardTesting:
+		a.profileID, a.pinned = profileIDForIndex(start, cfg), true
+		a.channel = "web"
+		a.at = base.Add(time.Duration(k) * 20 * time.Second).Add(time.Duration(r.NextInt(15)) * time.Second)
+		a.amount = math.Round((0.5+r.NextFloat()*1.5)*100) / 100
+	case fraudSharedDevice:
+		a.device = fmt.Sprintf("dev-%08x", uint32(h))
+		a.at = base.Add(time.Duration(k) * 3 * time.Minute).Add(time.Duration(r.NextInt(120)) * time.Second)
+		a.amount = math.Round((50+rng.NextFloat()*450)*100) / 100
+	case fraudImpossibleTravel:
+		a.profileID, a.pinned = profileIDForIndex(start, cfg), true
+		pair := c.TravelPairs[rng.NextInt(len(c.TravelPairs))]
+		a.city = pair[k%2]
+		a.at = base.Add(time.Duration(k) * 15 * time.Minute).Add(time.Duration(r.NextInt(300)) * time.Second)
+	}
+	return a, true
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Fuzzing of config parsing and record derivation: preset configs are
+// mutated at random JSON paths, parsed, and every config that validates is
+// used to build profiles and records at the edges of the index space. Name
+// distortions get random strings. A panic anywhere is a finding; its input
+// is saved so the crash can be replayed with -replay. The same targets run
+// under go test -fuzz as FuzzParseConfig, FuzzDistort and FuzzRecordByIndex.
+
+// fuzzFinding is a crash and the input that caused it.
+type fuzzFinding struct {
+	Stage  string          `json:"stage"`
+	Panic  string          `json:"panic"`
+	Stack  string          `json:"stack,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
+	Index  uint64          `json:"index,omitempty"`
+	// First and Last are the names given to the distortions, with the
+	// record seed and variant they ran under.
+	First   string `json:"first,omitempty"`
+	Last    string `json:"last,omitempty"`
+	Seed    uint64 `json:"seed,omitempty"`
+	Variant int    `json:"variant,omitempty"`
+}
+
+// catchPanic runs fn and returns its panic, if any, as a finding of stage.
+func catchPanic(stage string, fn func()) (f *fuzzFinding) {
+	defer func() {
+		if r := recover(); r != nil {
+			f = &fuzzFinding{Stage: stage, Panic: fmt.Sprint(r), Stack: string(debug.Stack())}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// fuzzValues are the replacement values mutations draw from: empties,
+// boundaries and wrong types.
+var fuzzValues = []any{
+	nil, true, "", "x", "Ёлка", "\xff\xfe", "2006-01-02T15:04:05Z", "0001-01-01T00:00:00Z", "9999-12-31T23:59:59Z",
+	0, 1, -1, 0.5, 1e300, -1e300, math.MaxInt64, uint64(math.MaxUint64),
+	[]any{}, []any{"a"}, []any{0}, map[string]any{},
+}
+
+// mutateJSON replaces the value at a random path of node.
+func mutateJSON(rng *SplitMix64, node any) any {
+	switch n := node.(type) {
+	case map[string]any:
+		if len(n) == 0 || rng.NextInt(8) == 0 {
+			break
+		}
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		k := keys[rng.NextInt(len(keys))]
+		if rng.NextInt(10) == 0 {
+			delete(n, k)
+		} else {
+			n[k] = mutateJSON(rng, n[k])
+		}
+		return n
+	case []any:
+		if len(n) == 0 || rng.NextInt(8) == 0 {
+			break
+		}
+		switch rng.NextInt(4) {
+		case 0:
+			return n[:rng.NextInt(len(n))]
+		case 1:
+			return append(n, n[rng.NextInt(len(n))])
+		}
+		i := rng.NextInt(len(n))
+		n[i] = mutateJSON(rng, n[i])
+		return n
+	}
+	return fuzzValues[rng.NextInt(len(fuzzValues))]
+}
+
+// fuzzString draws a short string mixing ASCII, Cyrillic and invalid bytes.
+func fuzzString(rng *SplitMix64) string {
+	parts := []string{"a", "Z", "ж", "Щ", "ё", " ", "-", "\x00", "\xd0", "😀"}
+	var b strings.Builder
+	for n := rng.NextInt(8); n > 0; n-- {
+		b.WriteString(parts[rng.NextInt(len(parts))])
+	}
+	return b.String()
+}
+
+// deriveAt builds record idx, its profile and its JSON encoding.
+func deriveAt(gen *IdempotentGenerator, idx uint64) {
+	rec := gen.RecordByIndex(idx)
+	gen.ProfileByID(rec.ProfileID)
+	gen.Schema().AppendJSON(nil, &rec)
+}
+
+// fuzzDerivation builds a generator from cfg and derives profiles and
+// records at the edges of the index space and at random indices.
+func fuzzDerivation(cfg GeneratorConfig, data []byte, seed uint64) *fuzzFinding {
+	var gen *IdempotentGenerator
+	var err error
+	if f := catchPanic("new-generator", func() { gen, err = NewIdempotentGenerator(cfg) }); f != nil {
+		f.Config = data
+		return f
+	}
+	if err != nil {
+		return nil
+	}
+	for idx := range QuickIndices(seed, 12) {
+		f := catchPanic("record", func() { deriveAt(gen, idx) })
+		if f != nil {
+			f.Config, f.Index = data, idx
+			return f
+		}
+	}
+	return nil
+}
+
+// fuzzConfig mutates base n times, parses the result and derives records
+// from it when it validates.
+func fuzzConfig(rng *SplitMix64, base []byte, mutations int) *fuzzFinding {
+	var tree any
+	json.Unmarshal(base, &tree)
+	for i := 0; i < mutations; i++ {
+		tree = mutateJSON(rng, tree)
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil
+	}
+	var cfg GeneratorConfig
+	if f := catchPanic("parse", func() { cfg, err = parseConfig(data) }); f != nil {
+		f.Config = data
+		return f
+	}
+	if err != nil {
+		return nil
+	}
+	return fuzzDerivation(cfg, data, rng.NextUint64())
+}
+
+// fuzzDistortionRates fire every distortion, so each runs on every input.
+var fuzzDistortionRates = DistortionRates{SwapFirstLast: 1, Transliterate: 1, Typo: 1}
+
+// distortNames runs the name distortions of a record on first and last.
+func distortNames(first, last string, seed uint64, variant int) *fuzzFinding {
+	cfg := defaultConfig
+	cfg.Distortions = fuzzDistortionRates
+	f := catchPanic("distort", func() {
+		distortFields(Profile{FirstName: first, LastName: last}, variant, cfg, seed, &DistortionTrace{})
+	})
+	if f != nil {
+		f.First, f.Last, f.Seed, f.Variant = first, last, seed, variant
+	}
+	return f
+}
+
+// replayFinding re-runs the input of f.
+func replayFinding(f *fuzzFinding) *fuzzFinding {
+	if f.Stage == "distort" {
+		return distortNames(f.First, f.Last, f.Seed, f.Variant)
+	}
+	var cfg GeneratorConfig
+	var err error
+	if found := catchPanic("parse", func() { cfg, err = parseConfig(f.Config) }); found != nil || err != nil {
+		return found
+	}
+	var gen *IdempotentGenerator
+	if found := catchPanic("new-generator", func() { gen, err = NewIdempotentGenerator(cfg) }); found != nil || err != nil {
+		return found
+	}
+	return catchPanic("record", func() { deriveAt(gen, f.Index) })
+}
+
+func runFuzz(args []string) error {
+	fs := flag.NewFlagSet("fuzz", flag.ContinueOnError)
+	iterations := fs.Int("n", 10_000, "inputs tried per target")
+	seed := fs.Uint64("seed", 1, "seed of the mutations")
+	mutations := fs.Int("mutations", 3, "mutations applied to each config")
+	findings := fs.String("findings", "output/fuzz", "directory crashing inputs are written to")
+	replay := fs.String("replay", "", "re-run a saved finding instead of fuzzing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	log := logFor("fuzz")
+
+	if *replay != "" {
+		data, err := os.ReadFile(*replay)
+		if err != nil {
+			return err
+		}
+		var f fuzzFinding
+		if err := json.Unmarshal(data, &f); err != nil {
+			return err
+		}
+		if again := replayFinding(&f); again != nil {
+			return fmt.Errorf("%s: %s\n%s", again.Stage, again.Panic, again.Stack)
+		}
+		log.Info("finding no longer reproduces", "file", *replay)
+		return nil
+	}
+
+	var bases [][]byte
+	for _, name := range presetNames() {
+		cfg, err := presetConfig(name)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		bases = append(bases, data)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	rng := NewSplitMix64(withSeed(fnv1a64("fuzz"), *seed))
+	seen := map[string]bool{}
+	found := 0
+	for i := 0; i < *iterations; i++ {
+		if i%1000 == 0 && ctx.Err() != nil {
+			break
+		}
+		for _, f := range []*fuzzFinding{
+			fuzzConfig(rng, bases[rng.NextInt(len(bases))], 1+rng.NextInt(*mutations)),
+			distortNames(fuzzString(rng), fuzzString(rng), rng.NextUint64(), rng.NextInt(16)),
+		} {
+			if f == nil {
+				continue
+			}
+			// One report per crash site: the innermost frame in this package.
+			site := f.Stage + ": " + f.Panic
+			if frame := fuzzCrashSite(f.Stack); frame != "" {
+				site = f.Stage + " at " + frame
+			}
+			if seen[site] {
+				continue
+			}
+			seen[site] = true
+			found++
+			data, err := json.MarshalIndent(f, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(*findings, 0755); err != nil {
+				return err
+			}
+			path := filepath.Join(*findings, fmt.Sprintf("finding-%016x.json", fnv1a64(site)))
+			if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+				return err
+			}
+			log.Warn("crash", "site", site, "input", path)
+		}
+	}
+	if found > 0 {
+		return fmt.Errorf("%d distinct crashes; inputs in %s", found, *findings)
+	}
+	log.Info("no crashes", "iterations", *iterations)
+	return nil
+}
+
+// fuzzCrashSite is the innermost frame of stack in this package's code,
+// skipping the panic machinery and the harness itself.
+func fuzzCrashSite(stack string) string {
+	lines := strings.Split(stack, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "main.") || strings.HasPrefix(line, "main.catchPanic") || i+1 == len(lines) {
+			continue
+		}
+		fn, _, _ := strings.Cut(line, "(")
+		return fn + " " + strings.TrimSpace(strings.Fields(lines[i+1])[0])
+	}
+	return ""
+}
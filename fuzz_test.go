@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Native fuzz targets for config parsing, name distortion and record
+// derivation, seeded with the inputs of the crashes the fuzz command found:
+// a first-name pool shorter than its weights, a config of JSON null and a
+// date spread of zero length.
+
+// crashConfigs are configs that crashed derivation or parsing before.
+var crashConfigs = []string{
+	`{"pools":{"firstNames":["Анна"]}}`,
+	`null`,
+	`{"dateSpread":{"start":"2025-01-01T00:00:00Z","end":"2025-01-01T00:00:00Z"}}`,
+}
+
+// addConfigSeeds adds the preset configs and crashConfigs to the corpus of
+// f, each with every index from indices.
+func addConfigSeeds(f *testing.F, indices ...uint64) {
+	f.Helper()
+	var configs [][]byte
+	for _, name := range presetNames() {
+		cfg, err := presetConfig(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		configs = append(configs, data)
+	}
+	for _, c := range crashConfigs {
+		configs = append(configs, []byte(c))
+	}
+	for _, data := range configs {
+		if len(indices) == 0 {
+			f.Add(data)
+		}
+		for _, idx := range indices {
+			f.Add(data, idx)
+		}
+	}
+}
+
+func FuzzParseConfig(f *testing.F) {
+	addConfigSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		migrateConfig(data)
+		cfg, err := parseConfig(data)
+		if err != nil {
+			return
+		}
+		again, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatalf("parsed config does not marshal: %v", err)
+		}
+		reparsed, err := parseConfig(again)
+		if err != nil {
+			t.Fatalf("parsed config %s does not parse again: %v", again, err)
+		}
+		if configHash(reparsed) != configHash(cfg) {
+			t.Fatalf("config %s changes when parsed again", again)
+		}
+	})
+}
+
+func FuzzDistort(f *testing.F) {
+	for _, name := range [][2]string{{"Анна", "Иванова"}, {"", ""}, {"ё", "Щ"}, {"\xd0", "\x00😀"}, {"Mary-Ann", "O'Neil"}} {
+		f.Add(name[0], name[1], uint64(1), 0)
+		f.Add(name[0], name[1], uint64(0xdeadbeef), 3)
+	}
+	f.Fuzz(func(t *testing.T, first, last string, seed uint64, variant int) {
+		if found := distortNames(first, last, seed, variant); found != nil {
+			t.Fatalf("distorting %q %q: %s\n%s", first, last, found.Panic, found.Stack)
+		}
+	})
+}
+
+func FuzzRecordByIndex(f *testing.F) {
+	addConfigSeeds(f, 0, 1, 1<<32, 1<<63, ^uint64(0))
+	f.Fuzz(func(t *testing.T, data []byte, idx uint64) {
+		// Times are derived for unvalidated configs too, so any spread,
+		// even an empty one, must give a time.
+		raw := cloneConfig(defaultConfig)
+		if json.Unmarshal(data, &raw) == nil {
+			timeForIndex(idx, raw)
+		}
+		cfg, err := parseConfig(data)
+		if err != nil || len(cfg.Plugins) > 0 {
+			return
+		}
+		gen, err := NewIdempotentGenerator(cfg)
+		if err != nil {
+			return
+		}
+		deriveAt(gen, idx)
+		if a, b := gen.RecordByIndex(idx), gen.RecordByIndex(idx); a.Timestamp != b.Timestamp || a.ProfileID != b.ProfileID {
+			t.Fatalf("record %d differs between builds", idx)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	output := fs.String("output", "output/records.jsonl", "output file, \"-\" for stdout; {shard} is replaced by the shard index")
+	shardIndex := fs.Int("shard-index", -1, "generate only this shard of the range (0-based)")
+	shardCount := fs.Int("shard-count", 0, "number of shards the range is split into (default $SHARD_COUNT)")
+	shardFromEnv := fs.Bool("shard-index-from-env", false, "derive -shard-index from JOB_COMPLETION_INDEX, array-job variables or the hostname ordinal")
+	formatName := fs.String("format", "jsonl", "output format: "+strings.Join(sortedKeys(recordFormats), ", "))
+	planPath := fs.String("plan", "", "take the range from this plan file (see the plan command); -shard-index selects the entry")
+	shuffle := fs.Bool("shuffle", false, "write the range in a deterministic pseudorandom order; shards and plan ranges are slices of one permutation of the whole range")
+	shuffleKey := fs.Uint64("shuffle-key", 0, "key of the -shuffle order; the same key and range always give the same order")
+	dryRun := fs.Bool("dry-run", false, "generate a small calibration sample and print projected size and duration instead of writing output")
+	out := addOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	format, ok := recordFormats[*formatName]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *formatName)
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	if *shardFromEnv {
+		idx, source, err := shardIndexFromEnv()
+		if err != nil {
+			return err
+		}
+		*shardIndex = idx
+		out.logger("generator").Info("shard index from environment", "shard", idx, "source", source)
+	}
+	// The permuted range is the whole range, before sharding.
+	shuffleStart, shuffleCount := *start, *count
+	switch {
+	case *planPath != "":
+		if *shardIndex < 0 {
+			return errors.New("-plan needs -shard-index or -shard-index-from-env")
+		}
+		r, err := planRange(*planPath, *shardIndex, cfg)
+		if err != nil {
+			return err
+		}
+		p, _ := readRangePlan(*planPath)
+		shuffleStart, shuffleCount = p.Start, p.Count
+		*start, *count = r.Start, r.Count
+		*output = strings.ReplaceAll(*output, "{shard}", fmt.Sprintf("%05d", *shardIndex))
+	case *shardIndex >= 0:
+		total := *shardCount
+		if total == 0 {
+			total, _ = strconv.Atoi(firstEnv("SHARD_COUNT", "SLURM_ARRAY_TASK_COUNT"))
+		}
+		if total <= 0 {
+			return errors.New("-shard-count (or $SHARD_COUNT) is required when sharding")
+		}
+		if *shardIndex >= total {
+			return fmt.Errorf("shard index %d out of range for %d shards", *shardIndex, total)
+		}
+		*start, *count = shardRange(*start, *count, *shardIndex, total)
+		*output = strings.ReplaceAll(*output, "{shard}", fmt.Sprintf("%05d", *shardIndex))
+	}
+
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	var src RecordSource = gen
+	var shuffleInfo *ShuffleInfo
+	if *shuffle {
+		src = shuffled(gen, shuffleStart, shuffleCount, *shuffleKey, cfg.Seed)
+		shuffleInfo = &ShuffleInfo{Key: *shuffleKey, Start: shuffleStart, Count: shuffleCount}
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if *dryRun {
+		dir := os.TempDir()
+		if *output != "-" {
+			dir = filepath.Dir(*output)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		est, err := estimateRun(ctx, gen, *start, *count, dryRunSample, dir)
+		if err != nil {
+			return err
+		}
+		printEstimate(os.Stdout, est)
+		return nil
+	}
+
+	progress := out.tracker(*output, *count)
+	if *output == "-" {
+		_, _, err := writeRecords(ctx, src, os.Stdout, format, *start, *count, progress.update)
+		progress.finish()
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		return err
+	}
+	began := time.Now()
+	file, err := writeRangeFile(ctx, src, format, *output, *start, *count, progress.update)
+	if err != nil {
+		return err
+	}
+	progress.finish()
+	manifest := Manifest{
+		ConfigHash: configHash(cfg),
+		Config:     cfg,
+		Overrides:  config.overrides,
+		Registered: config.named,
+		Format:     format.name,
+		Start:      *start,
+		Count:      *count,
+		Shuffle:    shuffleInfo,
+		Files:      []ManifestFile{file},
+		CreatedAt:  gen.now().UTC(),
+	}
+	if err := writeManifest(*output+".manifest.json", manifest); err != nil {
+		return err
+	}
+	out.logger("generator").Info("wrote records", "start", *start, "count", *count, "output", *output, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
+
+// shardRange splits [start, start+count) into total contiguous shards whose
+// sizes differ by at most one and returns shard index.
+func shardRange(start, count uint64, index, total int) (uint64, uint64) {
+	n := uint64(total)
+	i := uint64(index)
+	base, rem := count/n, count%n
+	size := base
+	if i < rem {
+		size++
+	}
+	return start + i*base + min(i, rem), size
+}
+
+var hostnameOrdinal = regexp.MustCompile(`-(\d+)$`)
+
+// shardIndexFromEnv finds this instance's ordinal the way common schedulers
+// expose it: Kubernetes indexed Jobs, Slurm/AWS Batch array jobs, and
+// StatefulSet pod hostnames ending in "-<ordinal>".
+func shardIndexFromEnv() (int, string, error) {
+	for _, name := range []string{"JOB_COMPLETION_INDEX", "SLURM_ARRAY_TASK_ID", "AWS_BATCH_JOB_ARRAY_INDEX"} {
+		if v := os.Getenv(name); v != "" {
+			idx, err := strconv.Atoi(v)
+			if err != nil || idx < 0 {
+				return 0, "", fmt.Errorf("$%s=%q is not a valid index", name, v)
+			}
+			if name == "SLURM_ARRAY_TASK_ID" {
+				if minID, err := strconv.Atoi(os.Getenv("SLURM_ARRAY_TASK_MIN")); err == nil {
+					idx -= minID
+				}
+			}
+			return idx, "$" + name, nil
+		}
+	}
+
+	host := os.Getenv("HOSTNAME")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	if m := hostnameOrdinal.FindStringSubmatch(host); m != nil {
+		idx, _ := strconv.Atoi(m[1])
+		return idx, "hostname " + host, nil
+	}
+	return 0, "", errors.New("no shard index found in JOB_COMPLETION_INDEX, SLURM_ARRAY_TASK_ID, AWS_BATCH_JOB_ARRAY_INDEX or hostname")
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GeographyConfig correlates the non-profile fields of a record: profiles
+// mostly transact in a home city, some channels concentrate in certain
+// regions and points of sale belong to a city. Without it city, channel and
+// POS are picked independently.
+type GeographyConfig struct {
+	// HomeCityRate is the share of a profile's records in its home city.
+	HomeCityRate float64 `json:"homeCityRate"`
+	// ChannelCities weights the cities of records away from home per
+	// channel; channels not listed use the city pool uniformly.
+	ChannelCities map[string][]WeightedValue `json:"channelCities,omitempty"`
+	// CityPOS lists the points of sale of each city; cities not listed use
+	// the POS pool.
+	CityPOS map[string][]string `json:"cityPos,omitempty"`
+}
+
+var defaultGeographyConfig = GeographyConfig{
+	HomeCityRate: 0.8,
+	ChannelCities: map[string][]WeightedValue{
+		"callcenter": {
+			{Value: "Москва", Weight: 50},
+			{Value: "Санкт-Петербург", Weight: 20},
+			{Value: "Новосибирск", Weight: 15},
+			{Value: "Екатеринбург", Weight: 15},
+		},
+	},
+	CityPOS: map[string][]string{
+		"Москва":          {"msk-store-001", "msk-store-002", "msk-kiosk-01"},
+		"Санкт-Петербург": {"spb-store-001", "spb-kiosk-01"},
+		"Новосибирск":     {"nsk-store-001"},
+		"Екатеринбург":    {"ekb-store-001"},
+		"Казань":          {"kzn-store-001"},
+		"Минск":           {"minsk-partner-by"},
+		"Алматы":          {"partner-az"},
+		"Варшава":         {"waw-store-001"},
+		"Берлин":          {"ber-store-001"},
+		"Рига":            {"rix-store-001"},
+	},
+}
+
+func (c *GeographyConfig) clone() *GeographyConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	if c.ChannelCities != nil {
+		out.ChannelCities = make(map[string][]WeightedValue, len(c.ChannelCities))
+		for channel, pool := range c.ChannelCities {
+			out.ChannelCities[channel] = append([]WeightedValue(nil), pool...)
+		}
+	}
+	if c.CityPOS != nil {
+		out.CityPOS = make(map[string][]string, len(c.CityPOS))
+		for city, pos := range c.CityPOS {
+			out.CityPOS[city] = append([]string(nil), pos...)
+		}
+	}
+	return &out
+}
+
+func (c *GeographyConfig) validate() error {
+	if c.HomeCityRate < 0 || c.HomeCityRate > 1 {
+		return errors.New("geography.homeCityRate must be in [0, 1]")
+	}
+	for channel, pool := range c.ChannelCities {
+		if err := validateWeighted(pool); err != nil {
+			return fmt.Errorf("geography.channelCities[%s]: %w", channel, err)
+		}
+	}
+	for city, pos := range c.CityPOS {
+		if len(pos) == 0 {
+			return fmt.Errorf("geography.cityPos[%s] must not be empty", city)
+		}
+	}
+	return nil
+}
+
+// homeCity is the city a profile mostly transacts in.
+func homeCity(profileID uint64, cfg GeneratorConfig) string {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("home:%d", profileID)), cfg.Seed))
+	return weightedPick(rng, cfg.Pools.Cities, nil)
+}
+
+// correlatedFields picks the city given the channel and the profile's home
+// city, then a POS of that city.
+func correlatedFields(rng *SplitMix64, geo *GeographyConfig, home, channel string, pools Pools) (string, string, string) {
+	city := home
+	if rng.NextFloat() >= geo.HomeCityRate {
+		if pool, ok := geo.ChannelCities[channel]; ok {
+			city = pickWeighted(rng, pool)
+		} else {
+			city = weightedPick(rng, pools.Cities, nil)
+		}
+	}
+
+	pos, ok := geo.CityPOS[city]
+	if !ok {
+		pos = pools.POS
+	}
+	return city, channel, weightedPick(rng, pos, nil)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"iter"
+	"math"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Generator invariants as checks that extensions and embedders can run
+// against their own configs: a record depends only on its index, a profile's
+// identity pool is the same wherever it is used, a record's variant stays
+// within its bucket's repeat multiplier and its time within its profile's
+// active span. QuickIndices and QuickConfigs draw
+// inputs for them the way a property-based test would.
+
+// maxViolations bounds the violations CheckInvariants collects.
+const maxViolations = 20
+
+// InvariantViolation is a record breaking an invariant.
+type InvariantViolation struct {
+	Invariant string
+	Index     uint64
+	Detail    string
+}
+
+func (v *InvariantViolation) Error() string {
+	return fmt.Sprintf("%s: record %d: %s", v.Invariant, v.Index, v.Detail)
+}
+
+// Invariant is a named property every record of every config must have.
+type Invariant struct {
+	Name  string
+	Check func(gen *IdempotentGenerator, idx uint64) error
+}
+
+// Invariants are the checks CheckInvariants runs. Extensions may append
+// their own.
+var Invariants = []Invariant{
+	{"same-index-same-record", CheckSameIndexSameRecord},
+	{"consistent-identity-pool", CheckIdentityPool},
+	{"variant-bounded-by-multiplier", CheckVariantBound},
+	{"within-active-span", CheckActiveSpan},
+}
+
+// CheckSameIndexSameRecord checks that record idx comes out identical when
+// built twice by gen and once by a fresh generator without a profile cache.
+func CheckSameIndexSameRecord(gen *IdempotentGenerator, idx uint64) error {
+	fresh, err := NewIdempotentGenerator(gen.cfg)
+	if err != nil {
+		return err
+	}
+	a := gen.RecordByIndex(idx)
+	for _, b := range []RawRecord{gen.RecordByIndex(idx), fresh.RecordByIndex(idx)} {
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		ma, mb := a.AsMap(), b.AsMap()
+		for _, name := range canonicalFieldOrder {
+			if !reflect.DeepEqual(ma[name], mb[name]) {
+				return &InvariantViolation{"same-index-same-record", idx, fmt.Sprintf("%s is %v, then %v", name, ma[name], mb[name])}
+			}
+		}
+		return &InvariantViolation{"same-index-same-record", idx, "records differ"}
+	}
+	return nil
+}
+
+// CheckIdentityPool checks that the profile of record idx is built the same
+// with and without the cache, and that the record's email, phone and login
+// come from that profile's pools. Fields blanked by field availability are
+// not checked.
+func CheckIdentityPool(gen *IdempotentGenerator, idx uint64) error {
+	rec := gen.RecordByIndex(idx)
+	profile := gen.ProfileByID(rec.ProfileID)
+	if !reflect.DeepEqual(profile, buildProfile(rec.ProfileID, gen.cfg)) {
+		return &InvariantViolation{"consistent-identity-pool", idx, fmt.Sprintf("profile %d differs between cached and fresh builds", rec.ProfileID)}
+	}
+	email := rec.Email
+	if rec.EmailCanonical != "" {
+		email = rec.EmailCanonical
+	}
+	for _, f := range []struct {
+		name, value string
+		pool        []string
+	}{{"email", email, profile.Emails}, {"phone", rec.Phone, profile.Phones}, {"login", rec.Login, profile.Logins}} {
+		if f.value != "" && len(f.pool) > 0 && !slices.Contains(f.pool, f.value) {
+			return &InvariantViolation{"consistent-identity-pool", idx, fmt.Sprintf("%s %q is not in profile %d's pool %q", f.name, f.value, rec.ProfileID, f.pool)}
+		}
+	}
+	return nil
+}
+
+// CheckVariantBound checks that the variant of record idx is below its
+// profile's bucket multiplier.
+func CheckVariantBound(gen *IdempotentGenerator, idx uint64) error {
+	rec := gen.RecordByIndex(idx)
+	limit := max(classifyBucket(rec.ProfileID, gen.cfg.Buckets, gen.cfg.Seed).RepeatMultiplier, 1)
+	if rec.VariantIndex < 0 || rec.VariantIndex >= limit {
+		return &InvariantViolation{"variant-bounded-by-multiplier", idx, fmt.Sprintf("variant %d outside [0, %d)", rec.VariantIndex, limit)}
+	}
+	return nil
+}
+
+// CheckActiveSpan checks that record idx falls in its profile's active
+// span: the lifecycle window when lifecycles are enabled, else the date
+// spread. Fraud incidents keep times of their own.
+func CheckActiveSpan(gen *IdempotentGenerator, idx uint64) error {
+	rec := gen.RecordByIndex(idx)
+	if rec.FraudLabel != "" {
+		return nil
+	}
+	ts, err := time.Parse(time.RFC3339, rec.Timestamp)
+	if err != nil {
+		return &InvariantViolation{"within-active-span", idx, err.Error()}
+	}
+	lo, hi := gen.activeSpan(rec.ProfileID)
+	if ts.Before(lo) || !ts.Before(hi) {
+		return &InvariantViolation{"within-active-span", idx, fmt.Sprintf("timestamp %s outside [%s, %s)", rec.Timestamp, lo.Format(time.RFC3339), hi.Format(time.RFC3339))}
+	}
+	return nil
+}
+
+// CheckInvariants runs every invariant on each index and returns the
+// violations found, joined; it stops after maxViolations.
+func CheckInvariants(ctx context.Context, gen *IdempotentGenerator, indices iter.Seq[uint64]) error {
+	var violations []error
+	n := 0
+	for idx := range indices {
+		if n++; n%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		for _, inv := range Invariants {
+			if err := inv.Check(gen, idx); err != nil {
+				violations = append(violations, err)
+				if len(violations) == maxViolations {
+					return errors.Join(violations...)
+				}
+			}
+		}
+	}
+	return errors.Join(violations...)
+}
+
+// QuickIndices yields n record indices drawn from seed: the edges of the
+// index space first, then uniform draws.
+func QuickIndices(seed uint64, n int) iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		edges := []uint64{0, 1, 2, math.MaxUint32, math.MaxUint32 + 1, math.MaxInt64, math.MaxUint64 - 1, math.MaxUint64}
+		rng := NewSplitMix64(withSeed(fnv1a64("quick:indices"), seed))
+		for i := 0; i < n; i++ {
+			idx := rng.NextUint64()
+			if i < len(edges) {
+				idx = edges[i]
+			}
+			if !yield(idx) {
+				return
+			}
+		}
+	}
+}
+
+// QuickConfigs yields n valid variants of base drawn from seed, with other
+// seeds, profile space sizes, bucket multipliers and distortion rates.
+func QuickConfigs(base GeneratorConfig, seed uint64, n int) iter.Seq[GeneratorConfig] {
+	return func(yield func(GeneratorConfig) bool) {
+		rng := NewSplitMix64(withSeed(fnv1a64("quick:configs"), seed))
+		for i := 0; i < n; {
+			cfg := base
+			cfg.Seed = rng.NextUint64()
+			cfg.ProfileSpaceSize = 1 + uint64(rng.NextInt(1<<uint(1+rng.NextInt(40))))
+			cfg.Buckets = slices.Clone(base.Buckets)
+			for j := range cfg.Buckets {
+				cfg.Buckets[j].RepeatMultiplier = 1 + rng.NextInt(64)
+			}
+			cfg.Distortions = DistortionRates{rng.NextFloat(), rng.NextFloat(), rng.NextFloat()}
+			if validateConfig(cfg) != nil {
+				continue
+			}
+			i++
+			if !yield(cfg) {
+				return
+			}
+		}
+	}
+}
+
+func runInvariants(args []string) error {
+	fs := flag.NewFlagSet("invariants", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	n := fs.Int("n", 1000, "record indices checked per config")
+	configs := fs.Int("configs", 0, "also check this many random variants of the config")
+	seed := fs.Uint64("seed", 1, "seed of the drawn indices and configs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	log := logFor("invariants")
+	check := func(cfg GeneratorConfig) error {
+		gen, err := NewIdempotentGenerator(cfg, WithCache(10_000))
+		if err != nil {
+			return err
+		}
+		if err := CheckInvariants(ctx, gen, QuickIndices(*seed, *n)); err != nil {
+			return fmt.Errorf("config %s:\n%w", configHash(cfg), err)
+		}
+		log.Info("invariants hold", "configHash", configHash(cfg), "indices", *n)
+		return nil
+	}
+	if err := check(cfg); err != nil {
+		return err
+	}
+	for variant := range QuickConfigs(cfg, *seed, *configs) {
+		if err := check(variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
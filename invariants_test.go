@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestInvariants(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range presetNames() {
+		cfg, err := presetConfig(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckInvariants(ctx, mustNewGenerator(cfg), QuickIndices(1, 200)); err != nil {
+			t.Errorf("preset %s:\n%v", name, err)
+		}
+	}
+	n := 0
+	for cfg := range QuickConfigs(defaultConfig, 1, 5) {
+		n++
+		if err := validateConfig(cfg); err != nil {
+			t.Fatalf("QuickConfigs yielded an invalid config: %v", err)
+		}
+		if err := CheckInvariants(ctx, mustNewGenerator(cfg), QuickIndices(uint64(n), 100)); err != nil {
+			t.Errorf("config %s:\n%v", configHash(cfg), err)
+		}
+	}
+	if n != 5 {
+		t.Errorf("QuickConfigs yielded %d configs, want 5", n)
+	}
+}
+
+func TestQuickIndices(t *testing.T) {
+	a, b := slices.Collect(QuickIndices(7, 50)), slices.Collect(QuickIndices(7, 50))
+	if !slices.Equal(a, b) {
+		t.Error("QuickIndices is not deterministic")
+	}
+	if len(a) != 50 || a[0] != 0 || a[7] != ^uint64(0) {
+		t.Errorf("QuickIndices(7, 50) = %d indices starting %v, want 50 starting with the edges", len(a), a[:8])
+	}
+	if slices.Equal(a, slices.Collect(QuickIndices(8, 50))) {
+		t.Error("QuickIndices ignores its seed")
+	}
+}
+
+// TestInvariantsCatchViolations poisons the profile cache, the kind of bug
+// an extension caching derived values could have.
+func TestInvariantsCatchViolations(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig), WithCache(10_000))
+	indices := slices.Collect(QuickIndices(1, 200))
+	for _, idx := range indices {
+		id := gen.owner(idx).profileID
+		gen.profiles.put(id, buildProfile(id+1, gen.cfg))
+	}
+	err := CheckInvariants(context.Background(), gen, slices.Values(indices))
+	var v *InvariantViolation
+	if !errors.As(err, &v) || v.Invariant != "same-index-same-record" {
+		t.Fatalf("CheckInvariants = %v, want same-index-same-record violations", err)
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != maxViolations {
+		t.Errorf("%d violations reported, want the first %d", n, maxViolations)
+	}
+}
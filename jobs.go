@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Background generation jobs
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobCompleted JobState = "completed"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+type JobRequest struct {
+	Dataset string `json:"dataset"`
+	Version string `json:"version,omitempty"`
+	Start   uint64 `json:"start"`
+	Count   uint64 `json:"count"`
+}
+
+type Job struct {
+	ID      string
+	Request JobRequest
+	Output  string
+
+	written atomic.Uint64
+	cancel  context.CancelFunc
+
+	mu         sync.Mutex
+	state      JobState
+	err        string
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// JobStatus is the JSON view of a job returned by the admin API.
+type JobStatus struct {
+	ID            string     `json:"id"`
+	Dataset       string     `json:"dataset"`
+	Version       string     `json:"version"`
+	Start         uint64     `json:"start"`
+	Count         uint64     `json:"count"`
+	Output        string     `json:"output"`
+	State         JobState   `json:"state"`
+	Written       uint64     `json:"written"`
+	Progress      float64    `json:"progress"`
+	RecordsPerSec float64    `json:"recordsPerSecond"`
+	Error         string     `json:"error,omitempty"`
+	StartedAt     time.Time  `json:"startedAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+}
+
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	written := j.written.Load()
+	st := JobStatus{
+		ID:        j.ID,
+		Dataset:   j.Request.Dataset,
+		Version:   j.Request.Version,
+		Start:     j.Request.Start,
+		Count:     j.Request.Count,
+		Output:    j.Output,
+		State:     j.state,
+		Written:   written,
+		Progress:  1,
+		Error:     j.err,
+		StartedAt: j.startedAt,
+	}
+	if j.Request.Count > 0 {
+		st.Progress = float64(written) / float64(j.Request.Count)
+	}
+
+	end := time.Now()
+	if !j.finishedAt.IsZero() {
+		end = j.finishedAt
+		finished := j.finishedAt
+		st.FinishedAt = &finished
+	}
+	if elapsed := end.Sub(j.startedAt).Seconds(); elapsed > 0 {
+		st.RecordsPerSec = float64(written) / elapsed
+	}
+	return st
+}
+
+func (j *Job) finish(state JobState, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = state
+	if err != nil {
+		j.err = err.Error()
+	}
+	j.finishedAt = time.Now()
+
+	log := logFor("jobs").With("job", j.ID, "state", state, "written", j.written.Load(), "duration", j.finishedAt.Sub(j.startedAt).Round(time.Millisecond))
+	if err != nil {
+		log.Error("job finished", "err", err)
+		return
+	}
+	log.Info("job finished")
+}
+
+type JobManager struct {
+	outputDir string
+
+	mu   sync.Mutex
+	seq  int
+	jobs map[string]*Job
+}
+
+func NewJobManager(outputDir string) *JobManager {
+	return &JobManager{outputDir: outputDir, jobs: make(map[string]*Job)}
+}
+
+// ErrRangeOverflow is returned for a job whose start plus count does not
+// fit in a record index.
+var ErrRangeOverflow = errors.New("start plus count overflows")
+
+// Launch starts generating req into a JSONL file in the output directory.
+// Only the trace context of parent is carried over; the job outlives it.
+// Job ids skip those whose output is already in the directory, so a
+// restarted server does not overwrite earlier jobs.
+func (m *JobManager) Launch(parent context.Context, req JobRequest, gen RecordSource) (*Job, error) {
+	if req.Start+req.Count < req.Start {
+		return nil, ErrRangeOverflow
+	}
+	if err := os.MkdirAll(m.outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	var id string
+	for {
+		m.seq++
+		id = fmt.Sprintf("job-%06d", m.seq)
+		if _, err := os.Stat(filepath.Join(m.outputDir, id+".jsonl")); err != nil {
+			break
+		}
+	}
+	ctx := context.Background()
+	if sc, ok := spanContextFrom(parent); ok {
+		ctx = contextWithSpanContext(ctx, sc)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	job := &Job{
+		ID:        id,
+		Request:   req,
+		Output:    filepath.Join(m.outputDir, id+".jsonl"),
+		cancel:    cancel,
+		state:     JobRunning,
+		startedAt: time.Now(),
+	}
+	m.jobs[id] = job
+	m.mu.Unlock()
+
+	logFor("jobs").Info("job started", "job", id, "dataset", req.Dataset, "start", req.Start, "count", req.Count, "output", job.Output)
+
+	go m.run(ctx, job, gen)
+	return job, nil
+}
+
+func (m *JobManager) run(ctx context.Context, job *Job, gen RecordSource) {
+	ctx, span := StartSpan(ctx, "job")
+	span.SetAttr("job.id", job.ID)
+	span.SetAttr("job.dataset", job.Request.Dataset)
+	span.SetAttr("job.start", job.Request.Start)
+	span.SetAttr("job.count", job.Request.Count)
+	defer span.End()
+	defer job.cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.errors.Inc("job")
+			job.finish(JobFailed, fmt.Errorf("panic: %v", r))
+		}
+		span.SetAttr("job.state", job.Status().State)
+		span.SetAttr("job.written", job.written.Load())
+	}()
+
+	file, err := os.Create(job.Output)
+	if err != nil {
+		job.finish(JobFailed, err)
+		return
+	}
+	defer file.Close()
+
+	sink := &instrumentedWriter{w: file, sink: "file"}
+	_, err = writeJSONL(ctx, gen, sink, job.Request.Start, job.Request.Count, func(n uint64, _ int64) {
+		metrics.recordsGenerated.Add("job", float64(n-job.written.Swap(n)))
+	})
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.finish(JobCancelled, nil)
+	case err != nil:
+		metrics.errors.Inc("job")
+		span.RecordError(err)
+		job.finish(JobFailed, err)
+	default:
+		if err := file.Sync(); err != nil {
+			metrics.errors.Inc("job")
+			job.finish(JobFailed, err)
+			return
+		}
+		job.finish(JobCompleted, nil)
+	}
+}
+
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+func (m *JobManager) List() []JobStatus {
+	m.mu.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.Unlock()
+
+	out := make([]JobStatus, len(jobs))
+	for i, job := range jobs {
+		out[i] = job.Status()
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].ID < out[b].ID })
+	return out
+}
+
+// CountByState reports how many jobs are in each state.
+func (m *JobManager) CountByState() map[string]float64 {
+	counts := map[string]float64{
+		string(JobRunning):   0,
+		string(JobCompleted): 0,
+		string(JobFailed):    0,
+		string(JobCancelled): 0,
+	}
+	for _, st := range m.List() {
+		counts[string(st.State)]++
+	}
+	return counts
+}
+
+// Cancel stops a running job; it is a no-op for finished jobs.
+func (m *JobManager) Cancel(id string) bool {
+	job, ok := m.Get(id)
+	if ok {
+		job.cancel()
+	}
+	return ok
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Kafka output: records are produced to a topic keyed by profileId, so a
+// profile's records stay in order on one partition. Values are JSON. With
+// -schema-registry the record schema is registered under the topic's value
+// subject and each value is framed in the Confluent wire format — a zero
+// magic byte and the 4-byte schema id before the Avro, Protobuf or JSON
+// payload — so Confluent deserializers and sink connectors read the topic
+// without manual schema management. The registry returns the existing id
+// for a schema it already holds, so reruns reuse it.
+
+// kafkaFormat is a value encoding and its registry schema.
+type kafkaFormat struct {
+	schemaType string // registry schemaType; empty means Avro
+	schema     func(s *Schema, hash string) []byte
+	// indexes follow the schema id: Protobuf values name their message by
+	// its position in the schema, [0] for the first.
+	indexes []byte
+	encode  func(b []byte, s *Schema, rec *RawRecord) []byte
+}
+
+var kafkaFormats = map[string]kafkaFormat{
+	"json":     {schemaType: "JSON", schema: jsonSchemaOf, encode: func(b []byte, s *Schema, rec *RawRecord) []byte { return s.AppendJSON(b, rec) }},
+	"avro":     {schema: avroSchemaOf, encode: appendAvro},
+	"protobuf": {schemaType: "PROTOBUF", schema: protobufSchemaOf, indexes: []byte{0}, encode: appendProtobuf},
+}
+
+var kafkaCompression = map[string]kgo.CompressionCodec{
+	"none":   kgo.NoCompression(),
+	"gzip":   kgo.GzipCompression(),
+	"snappy": kgo.SnappyCompression(),
+	"lz4":    kgo.Lz4Compression(),
+	"zstd":   kgo.ZstdCompression(),
+}
+
+// appendAvro appends rec in the Avro binary encoding of avroSchemaOf(s):
+// zig-zag varint longs, length-prefixed strings and little-endian doubles,
+// with optional fields led by their union branch, 0 for null and 1 for the
+// value.
+func appendAvro(b []byte, s *Schema, rec *RawRecord) []byte {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if f.Optional || f.ptr {
+			if empty {
+				b = binary.AppendVarint(b, 0)
+				continue
+			}
+			b = binary.AppendVarint(b, 1)
+		}
+		switch f.Type {
+		case FieldString:
+			str := v.String()
+			b = binary.AppendVarint(b, int64(len(str)))
+			b = append(b, str...)
+		case FieldInt:
+			b = binary.AppendVarint(b, v.Int())
+		case FieldUint:
+			b = binary.AppendVarint(b, int64(v.Uint()))
+		case FieldFloat:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float()))
+		}
+	}
+	return b
+}
+
+// appendProtobuf appends rec as the Record message of protobufSchemaOf(s).
+// As in generated code, zero values of fields without explicit presence
+// are left out.
+func appendProtobuf(b []byte, s *Schema, rec *RawRecord) []byte {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		num := protowire.Number(i + 1)
+		v, empty := f.value(rec)
+		optional := f.Optional || f.ptr
+		if empty && optional {
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			if str := v.String(); str != "" || optional {
+				b = protowire.AppendString(protowire.AppendTag(b, num, protowire.BytesType), str)
+			}
+		case FieldInt:
+			if n := v.Int(); n != 0 || optional {
+				b = protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), uint64(n))
+			}
+		case FieldUint:
+			if n := v.Uint(); n != 0 || optional {
+				b = protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), n)
+			}
+		case FieldFloat:
+			if bits := math.Float64bits(v.Float()); bits != 0 || optional {
+				b = protowire.AppendFixed64(protowire.AppendTag(b, num, protowire.Fixed64Type), bits)
+			}
+		}
+	}
+	return b
+}
+
+// confluentHeader is the wire-format prefix of every value written with
+// schema id.
+func confluentHeader(id uint32, indexes []byte) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{0}, id)
+	return append(b, indexes...)
+}
+
+// registerSchema registers schema under subject and returns its id.
+// Credentials in the registry URL are sent as basic auth.
+func registerSchema(ctx context.Context, client *http.Client, registry, subject, schemaType string, schema []byte) (uint32, error) {
+	body, _ := json.Marshal(struct {
+		SchemaType string `json:"schemaType,omitempty"`
+		Schema     string `json:"schema"`
+	}{schemaType, string(schema)})
+	endpoint := strings.TrimRight(registry, "/") + "/subjects/" + url.PathEscape(subject) + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("schema registry: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	var reply struct {
+		ID uint32 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return 0, fmt.Errorf("schema registry: decode response: %w", err)
+	}
+	return reply.ID, nil
+}
+
+// kafkaValues returns the function that encodes each record's value, after
+// registering the schema when registry is set.
+func kafkaValues(ctx context.Context, g *IdempotentGenerator, format, registry, subject string) (func(*RawRecord) []byte, error) {
+	f, ok := kafkaFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown -format %q (want %s)", format, strings.Join(sortedKeys(kafkaFormats), ", "))
+	}
+	schema := g.Schema()
+	var header []byte
+	switch {
+	case registry != "":
+		id, err := registerSchema(ctx, &http.Client{Timeout: time.Minute}, registry, subject, f.schemaType, f.schema(schema, configHash(g.cfg)))
+		if err != nil {
+			return nil, err
+		}
+		header = confluentHeader(id, f.indexes)
+	case format != "json":
+		return nil, fmt.Errorf("-format %s needs -schema-registry; consumers find the schema by the id in each value", format)
+	}
+	return func(rec *RawRecord) []byte {
+		return f.encode(bytes.Clone(header), schema, rec)
+	}, nil
+}
+
+func runKafka(args []string) error {
+	fs := flag.NewFlagSet("kafka", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	brokers := fs.String("brokers", "localhost:9092", "comma-separated seed brokers")
+	topic := fs.String("topic", "", "topic to produce to")
+	format := fs.String("format", "json", "value format: "+strings.Join(sortedKeys(kafkaFormats), ", ")+"; avro and protobuf need -schema-registry")
+	registry := fs.String("schema-registry", "", "Schema Registry URL; values are framed in the Confluent wire format")
+	subject := fs.String("subject", "", "registry subject (default TOPIC-value)")
+	compression := fs.String("compression", "none", "batch compression: "+strings.Join(sortedKeys(kafkaCompression), ", "))
+	batchSize := fs.Int("batch", 5000, "records per synchronous produce")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *topic == "" {
+		return errors.New("-topic is required")
+	}
+	codec, ok := kafkaCompression[*compression]
+	if !ok {
+		return fmt.Errorf("unknown -compression %q", *compression)
+	}
+	if *batchSize <= 0 {
+		return errors.New("-batch must be positive")
+	}
+	if *subject == "" {
+		*subject = *topic + "-value"
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	value, err := kafkaValues(ctx, gen, *format, *registry, *subject)
+	if err != nil {
+		return err
+	}
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(strings.Split(*brokers, ",")...),
+		kgo.DefaultProduceTopic(*topic),
+		kgo.ProducerBatchCompression(codec),
+	)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	batch := make([]*kgo.Record, 0, *batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := client.ProduceSync(ctx, batch...).FirstErr(); err != nil {
+			return err
+		}
+		metrics.recordsGenerated.Add("kafka", float64(len(batch)))
+		batch = batch[:0]
+		return nil
+	}
+	began := time.Now()
+	for i := uint64(0); i < *count; i++ {
+		rec := gen.RecordByIndex(*start + i)
+		batch = append(batch, &kgo.Record{Key: strconv.AppendUint(nil, rec.ProfileID, 10), Value: value(&rec)})
+		if len(batch) == *batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	logFor("kafka").Info("produced", "topic", *topic, "records", *count, "format", *format, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// wantFieldValue is the value f holds in rec as the encoders write it,
+// nil when the field is null.
+func wantFieldValue(f *FieldDescriptor, rec *RawRecord) any {
+	v, empty := f.value(rec)
+	if empty && (f.Optional || f.ptr) {
+		return nil
+	}
+	switch f.Type {
+	case FieldString:
+		return v.String()
+	case FieldInt:
+		return v.Int()
+	case FieldUint:
+		return v.Uint()
+	}
+	return v.Float()
+}
+
+// readAvro decodes an Avro value of avroSchemaOf(s).
+func readAvro(t *testing.T, s *Schema, b []byte) []any {
+	t.Helper()
+	varint := func() int64 {
+		x, n := binary.Varint(b)
+		if n <= 0 {
+			t.Fatalf("bad varint at % x", b)
+		}
+		b = b[n:]
+		return x
+	}
+	var values []any
+	for _, f := range s.Fields {
+		if f.Optional || f.ptr {
+			if varint() == 0 {
+				values = append(values, nil)
+				continue
+			}
+		}
+		switch f.Type {
+		case FieldString:
+			n := varint()
+			values = append(values, string(b[:n]))
+			b = b[n:]
+		case FieldInt:
+			values = append(values, varint())
+		case FieldUint:
+			values = append(values, uint64(varint()))
+		case FieldFloat:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+			b = b[8:]
+		}
+	}
+	if len(b) != 0 {
+		t.Errorf("%d bytes after the last field", len(b))
+	}
+	return values
+}
+
+// readProtobuf decodes a Record message of protobufSchemaOf(s), filling in
+// the proto3 defaults of absent fields without explicit presence.
+func readProtobuf(t *testing.T, s *Schema, b []byte) []any {
+	t.Helper()
+	values := make([]any, len(s.Fields))
+	for i, f := range s.Fields {
+		if !f.Optional && !f.ptr {
+			values[i] = map[FieldType]any{FieldString: "", FieldInt: int64(0), FieldUint: uint64(0), FieldFloat: 0.0}[f.Type]
+		}
+	}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || int(num) > len(s.Fields) {
+			t.Fatalf("bad tag at % x", b)
+		}
+		b = b[n:]
+		f := s.Fields[num-1]
+		switch {
+		case f.Type == FieldString && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(b)
+			values[num-1] = v
+		case f.Type == FieldInt && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			values[num-1] = int64(v)
+		case f.Type == FieldUint && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			values[num-1] = v
+		case f.Type == FieldFloat && typ == protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(b)
+			values[num-1] = math.Float64frombits(v)
+		default:
+			t.Fatalf("field %s has wire type %d", f.Name, typ)
+		}
+		if n < 0 {
+			t.Fatalf("bad %s value", f.Name)
+		}
+		b = b[n:]
+	}
+	return values
+}
+
+func TestKafkaRegistryValues(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	schema := gen.Schema()
+	type registration struct{ subject, schemaType, schema string }
+	var registered []registration
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ SchemaType, Schema string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != http.MethodPost {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		registered = append(registered, registration{r.URL.Path, body.SchemaType, body.Schema})
+		w.Write([]byte(`{"id":258}`))
+	}))
+	defer registry.Close()
+
+	for _, format := range []string{"avro", "protobuf", "json"} {
+		registered = nil
+		value, err := kafkaValues(context.Background(), gen, format, registry.URL, "records-value")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := kafkaFormats[format]
+		want := registration{"/subjects/records-value/versions", f.schemaType, string(f.schema(schema, configHash(gen.cfg)))}
+		if len(registered) != 1 || registered[0] != want {
+			t.Errorf("%s: registered %+v", format, registered)
+		}
+		header := append([]byte{0, 0, 0, 1, 2}, f.indexes...)
+		for idx := range uint64(200) {
+			rec := gen.RecordByIndex(idx)
+			v := value(&rec)
+			if !bytes.HasPrefix(v, header) {
+				t.Fatalf("%s: value of record %d starts % x, want % x", format, idx, v[:min(len(v), 8)], header)
+			}
+			payload := v[len(header):]
+			var got []any
+			switch format {
+			case "avro":
+				got = readAvro(t, schema, payload)
+			case "protobuf":
+				got = readProtobuf(t, schema, payload)
+			case "json":
+				if !bytes.Equal(payload, schema.AppendJSON(nil, &rec)) {
+					t.Fatalf("json: record %d is %s", idx, payload)
+				}
+				continue
+			}
+			for i := range schema.Fields {
+				if w := wantFieldValue(&schema.Fields[i], &rec); got[i] != w {
+					t.Fatalf("%s: record %d field %s decodes as %#v, want %#v", format, idx, schema.Fields[i].Name, got[i], w)
+				}
+			}
+		}
+	}
+
+	if _, err := kafkaValues(context.Background(), gen, "avro", "", ""); err == nil {
+		t.Error("avro values without a registry were accepted")
+	}
+}
+
+func TestKafkaProduce(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, "records"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	brokers := strings.Join(cluster.ListenAddrs(), ",")
+	if err := runKafka([]string{"-brokers", brokers, "-topic", "records", "-start", "40", "-count", "120", "-batch", "50", "-compression", "zstd"}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers), kgo.ConsumeTopics("records"), kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Each profile's records land on one partition, in index order.
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	seen := 0
+	last := make(map[string]uint64)
+	partitions := make(map[string]int32)
+	for seen < 120 {
+		fetches := client.PollFetches(ctx)
+		if err := fetches.Err(); err != nil {
+			t.Fatal(err)
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			var rec struct {
+				RecordIndex uint64 `json:"recordIndex"`
+			}
+			if err := json.Unmarshal(r.Value, &rec); err != nil {
+				t.Fatal(err)
+			}
+			want := gen.RecordByIndex(rec.RecordIndex)
+			if string(r.Key) != strconv.FormatUint(want.ProfileID, 10) || !bytes.Equal(r.Value, gen.Schema().AppendJSON(nil, &want)) {
+				t.Errorf("record %d produced with key %s and a value differing from the generator's", rec.RecordIndex, r.Key)
+			}
+			key := string(r.Key)
+			if p, ok := partitions[key]; ok && (p != r.Partition || last[key] >= rec.RecordIndex) {
+				t.Errorf("profile %s: record %d on partition %d after record %d on %d", key, rec.RecordIndex, r.Partition, last[key], p)
+			}
+			partitions[key], last[key] = r.Partition, rec.RecordIndex
+			seen++
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Kinesis Data Streams and Firehose output: records are sent with
+// PutRecords or PutRecordBatch, signed with Signature Version 4 from the
+// standard AWS_* environment variables. Batches stay under the services'
+// count and size limits, and throttled requests and records are retried
+// with the same backoff as _bulk requests.
+
+// kinesisAPI describes one of the two services' batch put calls.
+type kinesisAPI struct {
+	service        string
+	target         string // X-Amz-Target
+	streamField    string
+	maxRecords     int
+	maxBatchBytes  int
+	maxRecordBytes int
+	partitioned    bool // records carry a partition key
+	newline        bool // records are newline-terminated for delivery to files
+}
+
+var kinesisAPIs = map[string]kinesisAPI{
+	"kinesis": {
+		service: "kinesis", target: "Kinesis_20131202.PutRecords", streamField: "StreamName",
+		maxRecords: 500, maxBatchBytes: 5 << 20, maxRecordBytes: 1 << 20, partitioned: true,
+	},
+	"firehose": {
+		service: "firehose", target: "Firehose_20150804.PutRecordBatch", streamField: "DeliveryStreamName",
+		maxRecords: 500, maxBatchBytes: 4 << 20, maxRecordBytes: 1000 << 10, newline: true,
+	},
+}
+
+// kinesisThrottled are the error codes, for a whole request or one record,
+// that are retried after backing off.
+var kinesisThrottled = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"LimitExceededException":                 true,
+	"ServiceUnavailableException":            true,
+	"InternalFailure":                        true,
+}
+
+type kinesisRecord struct {
+	Data         []byte `json:"Data"` // base64 in JSON, as the API expects
+	PartitionKey string `json:"PartitionKey,omitempty"`
+}
+
+// kinesisBatchBytes is what a record counts against the request limits.
+func kinesisBatchBytes(r kinesisRecord) int {
+	return len(r.Data) + len(r.PartitionKey)
+}
+
+// awsCredentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// the optional AWS_SESSION_TOKEN.
+type awsCredentials struct {
+	accessKey, secretKey, sessionToken string
+}
+
+func awsCredentialsFromEnv() (awsCredentials, error) {
+	c := awsCredentials{os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}
+	if c.accessKey == "" || c.secretKey == "" {
+		return c, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return c, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signAWSv4 adds X-Amz-Date and a Signature Version 4 Authorization header
+// to req, signing its Host, Content-Type and X-Amz-* headers.
+func signAWSv4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := sortedKeys(headers)
+	var canonical strings.Builder
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+	fmt.Fprintf(&canonical, "%s\n%s\n%s\n", req.Method, path, query)
+	for _, name := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", name, headers[name])
+	}
+	signed := strings.Join(names, ";")
+	bodyHash := sha256.Sum256(body)
+	fmt.Fprintf(&canonical, "\n%s\n%s", signed, hex.EncodeToString(bodyHash[:]))
+
+	scope := amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := []byte("AWS4" + creds.secretKey)
+	for _, part := range []string{amzDate[:8], region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// kinesisClient sends batches to one stream, backing off while the service
+// throttles the request or some of its records.
+type kinesisClient struct {
+	api        kinesisAPI
+	endpoint   string
+	region     string
+	stream     string
+	creds      awsCredentials
+	client     *http.Client
+	maxRetries int
+}
+
+// send puts every record in batch.
+func (c *kinesisClient) send(ctx context.Context, batch []kinesisRecord) error {
+	delay := bulkBackoffFirst
+	for attempt := 0; ; attempt++ {
+		retry, err := c.put(ctx, batch)
+		if err != nil || len(retry) == 0 {
+			return err
+		}
+		if attempt == c.maxRetries {
+			return fmt.Errorf("%s: %d records still throttled after %d retries", c.api.service, len(retry), c.maxRetries)
+		}
+		metrics.errors.Inc(c.api.service)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, bulkBackoffMax)
+		batch = retry
+	}
+}
+
+// put sends one request and returns the records to retry.
+func (c *kinesisClient) put(ctx context.Context, batch []kinesisRecord) ([]kinesisRecord, error) {
+	body, err := json.Marshal(map[string]any{c.api.streamField: c.stream, "Records": batch})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", c.api.target)
+	signAWSv4(req, body, c.creds, c.region, c.api.service, time.Now())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		json.Unmarshal(data, &e)
+		code := e.Type[strings.LastIndexByte(e.Type, '#')+1:]
+		if kinesisThrottled[code] || resp.StatusCode >= 500 {
+			return batch, nil
+		}
+		return nil, fmt.Errorf("%s: %s: %s", c.api.service, resp.Status, bytes.TrimSpace(data))
+	}
+	// Kinesis answers with Records, Firehose with RequestResponses; both
+	// list one result per record in order.
+	var out struct {
+		Records          []struct{ ErrorCode, ErrorMessage string }
+		RequestResponses []struct{ ErrorCode, ErrorMessage string }
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("%s: decode response: %w", c.api.service, err)
+	}
+	results := append(out.Records, out.RequestResponses...)
+	var retry []kinesisRecord
+	for i, r := range results {
+		switch {
+		case r.ErrorCode == "":
+		case kinesisThrottled[r.ErrorCode] && i < len(batch):
+			retry = append(retry, batch[i])
+		default:
+			return nil, fmt.Errorf("%s: record %d: %s: %s", c.api.service, i, r.ErrorCode, r.ErrorMessage)
+		}
+	}
+	return retry, nil
+}
+
+func runKinesis(args []string) error {
+	fs := flag.NewFlagSet("kinesis", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	service := fs.String("service", "kinesis", "kinesis (Data Streams, PutRecords) or firehose (PutRecordBatch)")
+	stream := fs.String("stream", "", "stream or delivery stream name")
+	region := fs.String("region", "", "AWS region (default $AWS_REGION, then $AWS_DEFAULT_REGION)")
+	endpoint := fs.String("endpoint", "", "service endpoint URL, e.g. for LocalStack (default https://SERVICE.REGION.amazonaws.com)")
+	batchSize := fs.Int("batch", 500, "records per request; requests are also cut at the service's size limit")
+	retries := fs.Int("max-retries", 8, "retries of throttled requests and records")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	api, ok := kinesisAPIs[*service]
+	if !ok {
+		return fmt.Errorf("unknown -service %q (want %s)", *service, strings.Join(sortedKeys(kinesisAPIs), " or "))
+	}
+	if *stream == "" {
+		return errors.New("-stream is required")
+	}
+	if *batchSize <= 0 || *batchSize > api.maxRecords {
+		return fmt.Errorf("-batch must be between 1 and %d", api.maxRecords)
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if *region == "" {
+			*region = os.Getenv(env)
+		}
+	}
+	if *region == "" {
+		return errors.New("-region or $AWS_REGION is required")
+	}
+	if *endpoint == "" {
+		*endpoint = "https://" + api.service + "." + *region + ".amazonaws.com"
+	}
+	if _, err := url.Parse(*endpoint); err != nil {
+		return err
+	}
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	client := &kinesisClient{api: api, endpoint: *endpoint, region: *region, stream: *stream, creds: creds, client: &http.Client{Timeout: time.Minute}, maxRetries: *retries}
+
+	schema := gen.Schema()
+	batch := make([]kinesisRecord, 0, *batchSize)
+	batchBytes := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := client.send(ctx, batch); err != nil {
+			return err
+		}
+		metrics.recordsGenerated.Add(api.service, float64(len(batch)))
+		batch, batchBytes = batch[:0], 0
+		return nil
+	}
+	for i := uint64(0); i < *count; i++ {
+		rec := gen.RecordByIndex(*start + i)
+		r := kinesisRecord{Data: schema.AppendJSON(nil, &rec)}
+		if api.newline {
+			r.Data = append(r.Data, '\n')
+		}
+		if api.partitioned {
+			// Keyed by profile, so a profile's records stay in order on one shard.
+			r.PartitionKey = strconv.FormatUint(rec.ProfileID, 10)
+		}
+		n := kinesisBatchBytes(r)
+		if n > api.maxRecordBytes {
+			return fmt.Errorf("record %d is %d bytes, over the %s limit of %d", rec.RecordIndex, n, api.service, api.maxRecordBytes)
+		}
+		if batchBytes+n > api.maxBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch, batchBytes = append(batch, r), batchBytes+n
+		if len(batch) == *batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	logFor(api.service).Info("put records", "start", *start, "count", *count, "stream", *stream)
+	return nil
+}
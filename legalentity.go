@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OrganizationConfig links a share of records to an organization and adds
+// its Russian registration numbers: INN, OGRN and, for legal entities, KPP,
+// all with valid check digits. A share of records then carries them
+// corrupted the way manual entry does, while orgId keeps the truth.
+type OrganizationConfig struct {
+	// Rate is the share of records linked to an organization.
+	Rate float64 `json:"rate"`
+	// Count is the number of distinct organizations.
+	Count uint64 `json:"count"`
+	// SoleTraderRate is the share of organizations that are individual
+	// entrepreneurs, with a 12-digit INN, an OGRNIP and no KPP.
+	SoleTraderRate float64 `json:"soleTraderRate"`
+	// CorruptRate is the share of linked records whose numbers are
+	// distorted.
+	CorruptRate float64 `json:"corruptRate"`
+}
+
+var defaultOrganizationConfig = OrganizationConfig{
+	Rate:           0.15,
+	Count:          20_000,
+	SoleTraderRate: 0.3,
+	CorruptRate:    0.1,
+}
+
+// Organization holds the registration numbers of one organization.
+type Organization struct {
+	ID   uint64 `json:"orgId"`
+	INN  string `json:"inn"`
+	OGRN string `json:"ogrn"`
+	KPP  string `json:"kpp,omitempty"`
+}
+
+// Regions whose codes prefix INNs, OGRNs and KPPs.
+var orgRegions = []int{77, 78, 50, 54, 66, 16, 23, 52}
+
+var (
+	inn10Weights = []int{2, 4, 10, 3, 5, 9, 4, 6, 8}
+	inn11Weights = []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}
+	inn12Weights = []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}
+)
+
+func (c *OrganizationConfig) clone() *OrganizationConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	return &out
+}
+
+func (c *OrganizationConfig) validate() error {
+	for name, rate := range map[string]float64{"rate": c.Rate, "soleTraderRate": c.SoleTraderRate, "corruptRate": c.CorruptRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("organizations.%s must be in [0, 1]", name)
+		}
+	}
+	if c.Count == 0 {
+		return errors.New("organizations.count must be positive")
+	}
+	return nil
+}
+
+// innCheckDigit is the INN check digit over digits with the given weights.
+func innCheckDigit(digits string, weights []int) byte {
+	sum := 0
+	for i, w := range weights {
+		sum += int(digits[i]-'0') * w
+	}
+	return byte('0' + sum%11%10)
+}
+
+// ogrnCheckDigit is the OGRN (modulus 11) or OGRNIP (modulus 13) check digit.
+func ogrnCheckDigit(digits string, modulus uint64) byte {
+	var rem uint64
+	for i := 0; i < len(digits); i++ {
+		rem = (rem*10 + uint64(digits[i]-'0')) % modulus
+	}
+	return byte('0' + rem%10)
+}
+
+func randomDigits(rng *SplitMix64, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('0' + rng.NextInt(10))
+	}
+	return string(b)
+}
+
+// organization derives the registration numbers of an organization.
+func organization(id uint64, c *OrganizationConfig, seed uint64) Organization {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("org:%d", id)), seed))
+	region := orgRegions[rng.NextInt(len(orgRegions))]
+	office := fmt.Sprintf("%02d%02d", region, 1+rng.NextInt(50))
+	year := fmt.Sprintf("%02d", 2+rng.NextInt(24))
+
+	org := Organization{ID: id}
+	if rng.NextFloat() < c.SoleTraderRate {
+		inn := office + randomDigits(rng, 6)
+		inn += string(innCheckDigit(inn, inn11Weights))
+		org.INN = inn + string(innCheckDigit(inn, inn12Weights))
+		ogrn := "3" + year + office[:2] + randomDigits(rng, 9)
+		org.OGRN = ogrn + string(ogrnCheckDigit(ogrn, 13))
+		return org
+	}
+	inn := office + randomDigits(rng, 5)
+	org.INN = inn + string(innCheckDigit(inn, inn10Weights))
+	ogrn := "1" + year + office + randomDigits(rng, 5)
+	org.OGRN = ogrn + string(ogrnCheckDigit(ogrn, 11))
+	reason := "01"
+	if rng.NextFloat() < 0.2 {
+		reason = "43" // branch office
+	}
+	org.KPP = office + reason + fmt.Sprintf("%03d", 1+rng.NextInt(5))
+	return org
+}
+
+// corruptNumber distorts a registration number: a mistyped digit, two
+// swapped digits, a lost digit or added separators.
+func corruptNumber(rng *SplitMix64, s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	b := []byte(s)
+	i := rng.NextInt(len(b) - 1)
+	switch rng.NextInt(4) {
+	case 0:
+		b[i] = byte('0' + (int(b[i]-'0')+1+rng.NextInt(9))%10)
+	case 1:
+		b[i], b[i+1] = b[i+1], b[i]
+	case 2:
+		b = append(b[:i], b[i+1:]...)
+	default:
+		var out strings.Builder
+		for j, d := range b {
+			if j > 0 && j%3 == 0 {
+				out.WriteByte(' ')
+			}
+			out.WriteByte(d)
+		}
+		return out.String()
+	}
+	return string(b)
+}
+
+// organizationFor returns the organization a record is linked to, if any,
+// with its numbers as written on the record.
+func organizationFor(idx, profileID uint64, c *OrganizationConfig, seed uint64) (Organization, bool) {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("orglink:%d", idx)), seed))
+	if rng.NextFloat() >= c.Rate {
+		return Organization{}, false
+	}
+	// A profile acts for the same organization on all its records.
+	id := withSeed(fnv1a64(fmt.Sprintf("org-of:%d", profileID)), seed) % c.Count
+	org := organization(id, c, seed)
+	if rng.NextFloat() < c.CorruptRate {
+		switch rng.NextInt(3) {
+		case 0:
+			org.INN = corruptNumber(rng, org.INN)
+		case 1:
+			org.OGRN = corruptNumber(rng, org.OGRN)
+		default:
+			if org.KPP != "" {
+				org.KPP = corruptNumber(rng, org.KPP)
+			} else {
+				org.INN = corruptNumber(rng, org.INN)
+			}
+		}
+	}
+	return org, true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// LifecycleConfig gives every profile an activation date and possibly a churn
+// date inside the date spread. A profile's records fall only in its active
+// window, denser near activation as engagement fades, so retention and
+// cohort analyses see plausible curves.
+type LifecycleConfig struct {
+	// ChurnRate is the share of profiles that churn before the spread ends.
+	ChurnRate float64 `json:"churnRate"`
+	// MinActiveDays is the shortest active window.
+	MinActiveDays int `json:"minActiveDays"`
+	// Engagement is the decay rate of record density over the active
+	// window; 0 spreads records evenly.
+	Engagement float64 `json:"engagement"`
+}
+
+var defaultLifecycleConfig = LifecycleConfig{
+	ChurnRate:     0.35,
+	MinActiveDays: 14,
+	Engagement:    1.5,
+}
+
+func (c *LifecycleConfig) clone() *LifecycleConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	return &out
+}
+
+func (c *LifecycleConfig) validate(spread DateSpreadConfig) error {
+	if c.ChurnRate < 0 || c.ChurnRate > 1 {
+		return errors.New("lifecycle.churnRate must be in [0, 1]")
+	}
+	if c.MinActiveDays < 0 {
+		return errors.New("lifecycle.minActiveDays must not be negative")
+	}
+	if c.Engagement < 0 {
+		return errors.New("lifecycle.engagement must not be negative")
+	}
+	if spread.End.Sub(spread.Start) < time.Duration(c.MinActiveDays)*24*time.Hour {
+		return errors.New("lifecycle.minActiveDays is longer than the date spread")
+	}
+	return nil
+}
+
+// activeWindow returns when a profile activates and when it stops producing
+// records; churned reports whether that is before the end of the spread.
+func activeWindow(profileID uint64, cfg GeneratorConfig) (from, until time.Time, churned bool) {
+	c := cfg.Lifecycle
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("life:%d", profileID)), cfg.Seed))
+	start, end := cfg.DateSpread.Start.UTC(), cfg.DateSpread.End.UTC()
+	minActive := time.Duration(c.MinActiveDays) * 24 * time.Hour
+
+	from = start.Add(time.Duration(rng.NextFloat() * float64(end.Sub(start)-minActive))).Truncate(time.Second)
+	until = end
+	if rng.NextFloat() < c.ChurnRate {
+		earliest := from.Add(minActive)
+		until = earliest.Add(time.Duration(rng.NextFloat() * float64(end.Sub(earliest)))).Truncate(time.Second)
+		churned = until.Before(end)
+	}
+	return from, until, churned
+}
+
+// lifecycleTime places a record of a profile in its active window following
+// the engagement curve, using the record's own time hash.
+func lifecycleTime(idx, profileID uint64, cfg GeneratorConfig) time.Time {
+	from, until, _ := activeWindow(profileID, cfg)
+	h := withSeed(fnv1a64("time:"+fmt.Sprintf("%d", idx)), cfg.Seed)
+	u := float64(h>>11) / (1 << 53)
+
+	// Inverse CDF of a density proportional to exp(-k·x) on [0, 1).
+	x := u
+	if k := cfg.Lifecycle.Engagement; k > 0 {
+		x = -math.Log1p(-u*(1-math.Exp(-k))) / k
+	}
+	offset := time.Duration(x * float64(until.Sub(from)))
+	return from.Add(offset).Truncate(time.Millisecond)
+}
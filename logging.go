@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Logging is configured from the environment so it applies to every command:
+//
+//	LOG_FORMAT=text|json               (default text)
+//	LOG_LEVEL=info,server=debug,...    (default info; per-subsystem overrides)
+//
+// Subsystems are generator, sinks, server, jobs, coordinator, worker and
+// tracing. Command results (stats, explain, diff, ...) are not logs and
+// still go to stdout.
+var (
+	logRoot   slog.Handler = slog.NewTextHandler(os.Stderr, nil)
+	logLevels              = map[string]slog.Level{}
+	logLevel               = slog.LevelInfo
+)
+
+func setupLogging() error {
+	return configureLogging(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+}
+
+func configureLogging(w io.Writer, format, levels string) error {
+	logLevels = map[string]slog.Level{}
+	logLevel = slog.LevelInfo
+	for _, part := range strings.Split(levels, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, scoped := strings.Cut(part, "=")
+		if !scoped {
+			name, value = "", part
+		}
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("LOG_LEVEL: %w", err)
+		}
+		if scoped {
+			logLevels[name] = lvl
+		} else {
+			logLevel = lvl
+		}
+	}
+
+	// The root handler accepts everything; levels are applied per subsystem.
+	opts := &slog.HandlerOptions{Level: slog.Level(-8)}
+	switch format {
+	case "", "text":
+		logRoot = slog.NewTextHandler(w, opts)
+	case "json":
+		logRoot = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT: unknown format %q (want text or json)", format)
+	}
+	return nil
+}
+
+// logFor returns the logger of a subsystem.
+func logFor(subsystem string) *slog.Logger {
+	lvl, ok := logLevels[subsystem]
+	if !ok {
+		lvl = logLevel
+	}
+	h := logRoot.WithAttrs([]slog.Attr{slog.String("subsystem", subsystem)})
+	return slog.New(&leveledHandler{Handler: h, level: lvl})
+}
+
+// leveledHandler filters records below level before they reach the root.
+type leveledHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h *leveledHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return lvl >= h.level && h.Handler.Enabled(ctx, lvl)
+}
+
+func (h *leveledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &leveledHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *leveledHandler) WithGroup(name string) slog.Handler {
+	return &leveledHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LoginConfig replaces the original first-initial+lastname+4-digits login
+// with a weighted library of patterns per locale. Patterns are templates
+// over these placeholders:
+//
+//	{first} {last} {f}      lowercase names and first initial as spelled
+//	{tfirst} {tlast} {tf}   the same, transliterated to Latin
+//	{nick}                  a Latin nickname for the first name
+//	{yyyy} {yy}             the profile's birth year
+//	{nn} {nnnn}             random digits
+type LoginConfig struct {
+	// Patterns maps a profile locale to weighted templates; "*" is the
+	// fallback for locales without their own.
+	Patterns map[string][]WeightedValue `json:"patterns"`
+	// LeetRate is the share of logins rewritten in leetspeak (a→4, e→3, ...).
+	LeetRate float64 `json:"leetRate"`
+}
+
+var defaultLoginConfig = LoginConfig{
+	Patterns: map[string][]WeightedValue{
+		"ru": {
+			{Value: "{tf}{tlast}{nn}", Weight: 15},
+			{Value: "{nick}{yyyy}", Weight: 15},
+			{Value: "{nick}{nnnn}", Weight: 15},
+			{Value: "{tfirst}.{tlast}", Weight: 10},
+			{Value: "{tfirst}_{tlast}{yy}", Weight: 10},
+			{Value: "{nick}_{tlast}", Weight: 10},
+			{Value: "{tlast}{yyyy}", Weight: 10},
+			{Value: "{tfirst}{yy}", Weight: 10},
+			{Value: "{f}{last}{nnnn}", Weight: 5},
+		},
+		"*": {
+			{Value: "{tfirst}{nnnn}", Weight: 20},
+			{Value: "{tfirst}{tlast}", Weight: 15},
+			{Value: "{tfirst}.{tlast}", Weight: 15},
+			{Value: "{tf}{tlast}{yy}", Weight: 15},
+			{Value: "{tfirst}_{tlast}", Weight: 10},
+			{Value: "{tfirst}{yyyy}", Weight: 10},
+			{Value: "{nick}{nn}", Weight: 10},
+			{Value: "{tlast}_{tfirst}", Weight: 5},
+		},
+	},
+	LeetRate: 0.05,
+}
+
+// nicknames are common informal forms of the default first names.
+var nicknames = map[string]string{
+	"Анна":    "anya",
+	"Мария":   "masha",
+	"Иван":    "vanya",
+	"Алексей": "lyosha",
+	"София":   "sonya",
+	"Дмитрий": "dima",
+	"Елена":   "lena",
+	"Сергей":  "seryoga",
+	"Павел":   "pasha",
+	"Ольга":   "olya",
+}
+
+var leetReplacer = strings.NewReplacer("a", "4", "e", "3", "i", "1", "o", "0", "s", "5", "t", "7")
+
+func (c *LoginConfig) clone() *LoginConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Patterns = make(map[string][]WeightedValue, len(c.Patterns))
+	for locale, pool := range c.Patterns {
+		out.Patterns[locale] = append([]WeightedValue(nil), pool...)
+	}
+	return &out
+}
+
+func (c *LoginConfig) validate() error {
+	if c.LeetRate < 0 || c.LeetRate > 1 {
+		return errors.New("logins.leetRate must be in [0, 1]")
+	}
+	if _, ok := c.Patterns["*"]; !ok {
+		return errors.New(`logins.patterns must have a "*" fallback pool`)
+	}
+	for locale, pool := range c.Patterns {
+		if err := validateWeighted(pool); err != nil {
+			return fmt.Errorf("logins.patterns[%s]: %w", locale, err)
+		}
+	}
+	return nil
+}
+
+// birthYear is the year of a profile's birthDate.
+func birthYear(profileID, seed uint64) int {
+	return birthDate(profileID, seed).Year()
+}
+
+// buildLogins generates a profile's logins from the pattern library.
+func buildLogins(cfg *LoginConfig, profileID, seed uint64, count int, locale, firstName, lastName string) []string {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("login:%d", profileID)), seed))
+	pool, ok := cfg.Patterns[locale]
+	if !ok {
+		pool = cfg.Patterns["*"]
+	}
+
+	first, last := strings.ToLower(firstName), strings.ToLower(lastName)
+	tfirst, tlast := latinLower(firstName), latinLower(lastName)
+	nick, ok := nicknames[firstName]
+	if !ok {
+		nick = tfirst
+	}
+	year := fmt.Sprintf("%04d", birthYear(profileID, seed))
+
+	logins := make([]string, count)
+	for i := range logins {
+		login := strings.NewReplacer(
+			"{first}", first,
+			"{last}", last,
+			"{f}", firstRune(first),
+			"{tfirst}", tfirst,
+			"{tlast}", tlast,
+			"{tf}", firstRune(tfirst),
+			"{nick}", nick,
+			"{yyyy}", year,
+			"{yy}", year[2:],
+			"{nn}", fmt.Sprintf("%02d", rng.NextInt(100)),
+			"{nnnn}", fmt.Sprintf("%04d", rng.NextInt(10000)),
+		).Replace(pickWeighted(rng, pool))
+		if rng.NextFloat() < cfg.LeetRate {
+			login = leetReplacer.Replace(login)
+		}
+		logins[i] = login
+	}
+	return logins
+}
+
+func latinLower(s string) string {
+	return strings.ToLower(transliterateCyrillicToLatin(s))
+}
+
+func firstRune(s string) string {
+	for _, r := range s {
+		return string(r)
+	}
+	return ""
+}
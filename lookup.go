@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runLookup has two modes. By default it reads one record index (or profile
+// ID with -profiles) per line from stdin and writes the matching records as
+// JSONL to stdout. With any of -email/-phone/-login/-name it instead scans
+// a range in parallel for records carrying that identifier.
+func runLookup(args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	profiles := fs.Bool("profiles", false, "input lines are profile IDs instead of record indices")
+	var q recordQuery
+	fs.StringVar(&q.Email, "email", "", "find records with this email")
+	fs.StringVar(&q.Phone, "phone", "", "find records with this phone")
+	fs.StringVar(&q.Login, "login", "", "find records with this login")
+	fs.StringVar(&q.Name, "name", "", "find records whose first or last name matches (case-insensitive)")
+	start := fs.Uint64("start", 0, "first index to scan")
+	count := fs.Uint64("count", 1_000_000, "number of indices to scan")
+	workers := fs.Int("workers", runtime.NumCPU(), "parallel scanners")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+
+	if q.empty() {
+		return lookupStream(gen, os.Stdin, os.Stdout, *profiles)
+	}
+
+	began := time.Now()
+	ctx, stop := interruptContext()
+	defer stop()
+	matches, err := searchRange(ctx, gen, *start, *count, *workers, q.match)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(os.Stdout)
+	defer bw.Flush()
+	for _, rec := range matches {
+		if _, err := bw.Write(append(gen.Schema().AppendJSON(nil, &rec), '\n')); err != nil {
+			return err
+		}
+	}
+	logFor("generator").Info("search complete", "matches", len(matches), "start", *start, "count", *count, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
+
+type recordQuery struct {
+	Email string
+	Phone string
+	Login string
+	Name  string
+}
+
+func (q recordQuery) empty() bool {
+	return q.Email == "" && q.Phone == "" && q.Login == "" && q.Name == ""
+}
+
+// match reports whether rec satisfies every identifier set in q.
+func (q recordQuery) match(rec *RawRecord) bool {
+	if q.Email != "" && !strings.EqualFold(rec.Email, q.Email) {
+		return false
+	}
+	if q.Phone != "" && rec.Phone != q.Phone {
+		return false
+	}
+	if q.Login != "" && !strings.EqualFold(rec.Login, q.Login) {
+		return false
+	}
+	if q.Name != "" && !strings.EqualFold(rec.FirstName, q.Name) && !strings.EqualFold(rec.LastName, q.Name) {
+		return false
+	}
+	return true
+}
+
+const searchChunk = 1 << 16
+
+// searchRange scans [start, start+count) with the given number of workers
+// and returns matching records in index order.
+func searchRange(ctx context.Context, gen RecordSource, start, count uint64, workers int, match func(*RawRecord) bool) ([]RawRecord, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	chunks := (count + searchChunk - 1) / searchChunk
+	results := make([][]RawRecord, chunks)
+
+	var next atomic.Uint64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c := next.Add(1) - 1
+				if c >= chunks || ctx.Err() != nil {
+					return
+				}
+				lo := c * searchChunk
+				hi := min(lo+searchChunk, count)
+				for i := lo; i < hi; i++ {
+					rec := gen.RecordByIndex(start + i)
+					if match(&rec) {
+						results[c] = append(results[c], rec)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []RawRecord
+	for _, chunk := range results {
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+func lookupStream(gen *IdempotentGenerator, in io.Reader, out io.Writer, profiles bool) error {
+	bw := bufio.NewWriter(out)
+	defer bw.Flush()
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	scanner := bufio.NewScanner(in)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			logFor("generator").Warn("skipping input that is not an unsigned integer", "line", line, "value", text)
+			continue
+		}
+
+		if !profiles {
+			rec := gen.RecordByIndex(id)
+			if _, err := bw.Write(append(gen.Schema().AppendJSON(nil, &rec), '\n')); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := enc.Encode(gen.ProfileByID(id)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
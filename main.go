@@ -1,13 +1,16 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/damir-manapov/idempotent-entries-idea/determ"
 )
 
 // Types
@@ -28,118 +31,163 @@ type DateSpreadConfig struct {
 }
 
 type GeneratorConfig struct {
+	ConfigVersion    int               `json:"configVersion,omitempty"`
 	ProfileSpaceSize uint64            `json:"profileSpaceSize"`
+	Seed             uint64            `json:"seed,omitempty"`
 	Buckets          []FrequencyBucket `json:"buckets"`
 	Distortions      DistortionRates   `json:"distortions"`
 	DateSpread       DateSpreadConfig  `json:"dateSpread"`
 	Pools            Pools             `json:"pools"`
+	Emails           *EmailConfig      `json:"emails,omitempty"`
+	EmailAliases     *EmailAliasConfig `json:"emailAliases,omitempty"`
+	Logins           *LoginConfig      `json:"logins,omitempty"`
+	// CityTimezones maps cities to IANA zones; when set, records also carry
+	// the local time of the transaction.
+	CityTimezones map[string]string `json:"cityTimezones,omitempty"`
+	Geography     *GeographyConfig  `json:"geography,omitempty"`
+	// FieldAvailability gives, per channel, the probability that a field is
+	// present; fields not listed are always present.
+	FieldAvailability map[string]map[string]float64 `json:"fieldAvailability,omitempty"`
+	Amounts           *AmountConfig                 `json:"amounts,omitempty"`
+	Demographics      *DemographicsConfig           `json:"demographics,omitempty"`
+	Lifecycle         *LifecycleConfig              `json:"lifecycle,omitempty"`
+	Sessions          *SessionConfig                `json:"sessions,omitempty"`
+	Events            *EventConfig                  `json:"events,omitempty"`
+	Fraud             *FraudConfig                  `json:"fraud,omitempty"`
+	Relationships     *RelationshipConfig           `json:"relationships,omitempty"`
+	Organizations     *OrganizationConfig           `json:"organizations,omitempty"`
+	Retention         *RetentionConfig              `json:"retention,omitempty"`
+	Erasure           *ErasureConfig                `json:"erasure,omitempty"`
+	// Plugins add field generators and distortions from WebAssembly
+	// modules.
+	Plugins  []PluginConfig `json:"plugins,omitempty"`
+	Canaries *CanaryConfig  `json:"canaries,omitempty"`
+	// OutputFields selects and orders the fields encoders write; empty
+	// writes every field.
+	OutputFields []string `json:"outputFields,omitempty"`
 }
 
 type Profile struct {
-	ProfileID  uint64   `json:"profileId"`
-	FirstName  string   `json:"firstName"`
-	LastName   string   `json:"lastName"`
-	Phones     []string `json:"phones"`
-	Emails     []string `json:"emails"`
-	Logins     []string `json:"logins"`
-	Locale     string   `json:"locale"`
+	ProfileID uint64   `json:"profileId"`
+	FirstName string   `json:"firstName"`
+	LastName  string   `json:"lastName"`
+	Phones    []string `json:"phones"`
+	Emails    []string `json:"emails"`
+	Logins    []string `json:"logins"`
+	Locale    string   `json:"locale"`
+	// HomeCity is set only when the geography model is enabled.
+	HomeCity string `json:"homeCity,omitempty"`
+	// BirthDate and Gender are set only when demographics are enabled.
+	BirthDate string `json:"birthDate,omitempty"`
+	Gender    string `json:"gender,omitempty"`
+	// ActiveFrom and ChurnedAt bound the profile's records when the
+	// lifecycle model is enabled; ChurnedAt is empty for retained profiles.
+	ActiveFrom string `json:"activeFrom,omitempty"`
+	ChurnedAt  string `json:"churnedAt,omitempty"`
+	// ErasedAt is when the profile requests erasure, set only when erasure
+	// is configured and the profile does.
+	ErasedAt string `json:"erasedAt,omitempty"`
 }
 
 type RawRecord struct {
-	RecordIndex   uint64  `json:"recordIndex"`
-	ProfileID     uint64  `json:"profileId"`
-	VariantIndex  int     `json:"variantIndex"`
-	FirstName     string  `json:"firstName"`
-	LastName      string  `json:"lastName"`
-	Email         string  `json:"email"`
-	Phone         string  `json:"phone"`
-	Login         string  `json:"login"`
-	PointOfSale   string  `json:"pointOfSale"`
-	City          string  `json:"city"`
-	Channel       string  `json:"channel"`
-	Amount        float64 `json:"amount"`
-	Timestamp     string  `json:"timestamp"`
+	RecordIndex  uint64  `json:"recordIndex"`
+	ProfileID    uint64  `json:"profileId"`
+	VariantIndex int     `json:"variantIndex"`
+	FirstName    string  `json:"firstName"`
+	LastName     string  `json:"lastName"`
+	Email        string  `json:"email"`
+	Phone        string  `json:"phone"`
+	Login        string  `json:"login"`
+	PointOfSale  string  `json:"pointOfSale"`
+	City         string  `json:"city"`
+	Channel      string  `json:"channel"`
+	Amount       float64 `json:"amount"`
+	Timestamp    string  `json:"timestamp"`
+
+	// EmailCanonical is the mailbox Email delivers to; set only when email
+	// aliasing is enabled.
+	EmailCanonical string `json:"emailCanonical,omitempty"`
+	// LocalTimestamp and Timezone give Timestamp in the city's zone; set
+	// only when cityTimezones is configured.
+	LocalTimestamp string `json:"localTimestamp,omitempty"`
+	Timezone       string `json:"timezone,omitempty"`
+	// BirthDate, Gender and Category are set only when demographics are
+	// configured.
+	BirthDate string `json:"birthDate,omitempty"`
+	Gender    string `json:"gender,omitempty"`
+	Category  string `json:"category,omitempty"`
+	// SessionID, SessionStart and Device are set for records on session
+	// channels when sessions are configured.
+	SessionID    string `json:"sessionId,omitempty"`
+	SessionStart string `json:"sessionStart,omitempty"`
+	Device       string `json:"device,omitempty"`
+	// EventType is set when events are configured; refunds name the record
+	// index of the purchase they reverse in RefundOf.
+	EventType string  `json:"eventType,omitempty"`
+	RefundOf  *uint64 `json:"refundOf,omitempty"`
+	// FraudLabel names the anomaly pattern of records in an injected
+	// incident; it is empty for normal records.
+	FraudLabel    string `json:"fraudLabel,omitempty"`
+	FraudIncident string `json:"fraudIncident,omitempty"`
+	// OrgID, INN, OGRN and KPP are set on records linked to an
+	// organization; the numbers may be corrupted, OrgID is the truth.
+	OrgID *uint64 `json:"orgId,omitempty"`
+	INN   string  `json:"inn,omitempty"`
+	OGRN  string  `json:"ogrn,omitempty"`
+	KPP   string  `json:"kpp,omitempty"`
+	// Jurisdiction and ExpiresAt are set when retention is configured;
+	// the record is due for deletion once ExpiresAt has passed.
+	Jurisdiction string `json:"jurisdiction,omitempty"`
+	ExpiresAt    string `json:"expiresAt,omitempty"`
+	// ErasedAt is set on records of a profile that requested erasure at or
+	// before their timestamp; their personal fields are empty.
+	ErasedAt string `json:"erasedAt,omitempty"`
+
+	// Custom holds the custom fields plugins declare, in config order; it
+	// is nil without plugins.
+	Custom []CustomField `json:"-"`
 }
 
 type Pools struct {
-	FirstNames []string  `json:"firstNames"`
-	LastNames  []string  `json:"lastNames"`
-	Cities     []string  `json:"cities"`
-	Channels   []string  `json:"channels"`
-	POS        []string  `json:"pos"`
+	FirstNames []string `json:"firstNames"`
+	LastNames  []string `json:"lastNames"`
+	Cities     []string `json:"cities"`
+	Channels   []string `json:"channels"`
+	POS        []string `json:"pos"`
 }
 
-// Utilities: 64-bit hashing & PRNG
+// Utilities: 64-bit hashing & PRNG, from the determ package so derived
+// fields built outside the generator stay bit-compatible with it.
 func fnv1a64(input interface{}) uint64 {
-	var data []byte
 	switch v := input.(type) {
 	case uint64:
-		data = make([]byte, 8)
-		binary.LittleEndian.PutUint64(data, v)
+		return determ.FNV1a64Uint64(v)
 	case string:
-		data = []byte(v)
-	default:
-		return 0
-	}
-
-	hash := uint64(0xcbf29ce484222325)
-	prime := uint64(0x100000001b3)
-	for _, b := range data {
-		hash ^= uint64(b)
-		hash *= prime
+		return determ.FNV1a64(v)
 	}
-	return hash
+	return 0
 }
 
-type SplitMix64 struct {
-	state uint64
-}
+type SplitMix64 = determ.SplitMix64
 
 func NewSplitMix64(seed uint64) *SplitMix64 {
-	return &SplitMix64{state: seed}
-}
-
-func (s *SplitMix64) NextUint64() uint64 {
-	z := s.state + 0x9E3779B97F4A7C15
-	s.state = z
-	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
-	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
-	return z ^ (z >> 31)
-}
-
-func (s *SplitMix64) NextFloat() float64 {
-	u := s.NextUint64()
-	return float64(u>>11) / float64(1<<53)
-}
-
-func (s *SplitMix64) NextInt(maxExclusive int) int {
-	return int(s.NextFloat() * float64(maxExclusive))
+	return determ.NewSplitMix64(seed)
 }
 
 func weightedPick(rng *SplitMix64, values []string, weights []int) string {
-	if len(weights) == 0 {
-		return values[rng.NextInt(len(values))]
-	}
-
-	total := 0
-	for _, w := range weights {
-		total += w
-	}
+	return determ.WeightedPick(rng, values, weights)
+}
 
-	r := rng.NextFloat() * float64(total)
-	for i, w := range weights {
-		r -= float64(w)
-		if r <= 0 {
-			return values[i]
-		}
-	}
-	return values[len(values)-1]
+// withSeed mixes a dataset seed into a derivation hash. Seed 0 leaves the hash
+// untouched so unseeded configs keep producing the original data.
+func withSeed(h, seed uint64) uint64 {
+	return determ.WithSeed(h, seed)
 }
 
-func classifyBucket(profileID uint64, buckets []FrequencyBucket) FrequencyBucket {
-	seed := fnv1a64(profileID)
+func classifyBucket(profileID uint64, buckets []FrequencyBucket, datasetSeed uint64) FrequencyBucket {
+	seed := withSeed(fnv1a64(profileID), datasetSeed)
 	rng := NewSplitMix64(seed)
-	
+
 	total := 0
 	for _, b := range buckets {
 		total += b.Weight
@@ -242,6 +290,7 @@ var defaultPools = Pools{
 }
 
 var defaultConfig = GeneratorConfig{
+	ConfigVersion:    currentConfigVersion,
 	ProfileSpaceSize: 1000000000000, // 10^12
 	Buckets: []FrequencyBucket{
 		{Weight: 90, RepeatMultiplier: 1},
@@ -261,21 +310,21 @@ var defaultConfig = GeneratorConfig{
 }
 
 func profileIDForIndex(idx uint64, cfg GeneratorConfig) uint64 {
-	h := fnv1a64(idx)
+	h := withSeed(fnv1a64(idx), cfg.Seed)
 	return h % cfg.ProfileSpaceSize
 }
 
-func variantForIndex(idx uint64, multiplier int) int {
+func variantForIndex(idx uint64, multiplier int, seed uint64) int {
 	if multiplier <= 1 {
 		return 0
 	}
-	h := fnv1a64(idx ^ 0xA5A5A5A5A5A5A5A5)
+	h := withSeed(fnv1a64(idx^0xA5A5A5A5A5A5A5A5), seed)
 	rng := NewSplitMix64(h)
 	return rng.NextInt(multiplier)
 }
 
 func buildProfile(profileID uint64, cfg GeneratorConfig) Profile {
-	seed := fnv1a64("profile:" + fmt.Sprintf("%d", profileID))
+	seed := withSeed(fnv1a64("profile:"+fmt.Sprintf("%d", profileID)), cfg.Seed)
 	rng := NewSplitMix64(seed)
 
 	firstName := weightedPick(rng, cfg.Pools.FirstNames, []int{8, 7, 7, 6, 6, 6, 5, 5, 4, 4})
@@ -300,7 +349,7 @@ func buildProfile(profileID uint64, cfg GeneratorConfig) Profile {
 	}
 
 	emails := make([]string, emailsCount)
-	mailSeed := fnv1a64("email:" + fmt.Sprintf("%d", profileID))
+	mailSeed := withSeed(fnv1a64("email:"+fmt.Sprintf("%d", profileID)), cfg.Seed)
 	for i := 0; i < emailsCount; i++ {
 		r := NewSplitMix64(mailSeed + uint64(i))
 		local := fmt.Sprintf("%s.%s", firstName, lastName)
@@ -312,12 +361,14 @@ func buildProfile(profileID uint64, cfg GeneratorConfig) Profile {
 			}
 		}
 		salt := fmt.Sprintf("%04d", r.NextUint64()%10000)
-		domains := []string{"gmail.com", "mail.ru", "yahoo.com", "outlook.com", "yandex.ru"}
-		emails[i] = local + salt + "@" + domains[r.NextInt(len(domains))]
+		emails[i] = local + salt + "@" + emailDomain(r, cfg.Emails, locale, lastName)
 	}
 
 	logins := make([]string, loginsCount)
-	for i := 0; i < loginsCount; i++ {
+	if cfg.Logins != nil {
+		logins = buildLogins(cfg.Logins, profileID, cfg.Seed, loginsCount, locale, firstName, lastName)
+	}
+	for i := 0; i < loginsCount && cfg.Logins == nil; i++ {
 		num := fmt.Sprintf("%04d", rng.NextUint64()%10000)
 		base := ""
 		if len(firstName) > 0 {
@@ -328,52 +379,128 @@ func buildProfile(profileID uint64, cfg GeneratorConfig) Profile {
 		logins[i] = strings.ToLower(base + num)
 	}
 
+	var home string
+	if cfg.Geography != nil {
+		home = homeCity(profileID, cfg)
+	}
+	var activeFrom, churnedAt string
+	if cfg.Lifecycle != nil {
+		from, until, churned := activeWindow(profileID, cfg)
+		activeFrom = from.Format(time.RFC3339)
+		if churned {
+			churnedAt = until.Format(time.RFC3339)
+		}
+	}
+	var erased string
+	if cfg.Erasure != nil {
+		if at, ok := erasedAt(profileID, cfg); ok {
+			erased = at.Format(time.RFC3339)
+		}
+	}
+	var born, gender string
+	if cfg.Demographics != nil {
+		born = birthDate(profileID, cfg.Seed).Format(time.DateOnly)
+		gender = genderOf(firstName)
+	}
+
 	return Profile{
-		ProfileID: profileID,
-		FirstName: firstName,
-		LastName:  lastName,
-		Phones:    phones,
-		Emails:    emails,
-		Logins:    logins,
-		Locale:    locale,
+		ProfileID:  profileID,
+		FirstName:  firstName,
+		LastName:   lastName,
+		Phones:     phones,
+		Emails:     emails,
+		Logins:     logins,
+		Locale:     locale,
+		HomeCity:   home,
+		BirthDate:  born,
+		Gender:     gender,
+		ActiveFrom: activeFrom,
+		ChurnedAt:  churnedAt,
+		ErasedAt:   erased,
 	}
 }
 
-func distortFields(profile Profile, variantIndex int, cfg GeneratorConfig, recordSeed uint64) (string, string, string, string, string) {
+// DistortionTrace records which distortions fired for a record.
+type DistortionTrace struct {
+	SwapFirstLast bool `json:"swapFirstLast"`
+	Transliterate bool `json:"transliterate"`
+	FirstNameTypo bool `json:"firstNameTypo"`
+	LastNameTypo  bool `json:"lastNameTypo"`
+	// Steps lists each fired distortion with the names before and after it.
+	Steps []DistortionStep `json:"steps,omitempty"`
+}
+
+type DistortionStep struct {
+	Name   string    `json:"name"`
+	Before [2]string `json:"before"`
+	After  [2]string `json:"after"`
+}
+
+func (t *DistortionTrace) step(name string, before [2]string, first, last string) {
+	t.Steps = append(t.Steps, DistortionStep{Name: name, Before: before, After: [2]string{first, last}})
+}
+
+// distortFields picks and distorts the profile fields for one record. trace
+// may be nil; otherwise it is filled with the distortions applied.
+func distortFields(profile Profile, variantIndex int, cfg GeneratorConfig, recordSeed uint64, trace *DistortionTrace) (string, string, string, string, string) {
 	rng := NewSplitMix64(recordSeed + uint64(variantIndex))
+	detailed := trace != nil
+	if trace == nil {
+		trace = &DistortionTrace{}
+	}
 
 	firstName := profile.FirstName
 	lastName := profile.LastName
 
 	if maybe(clamp01(cfg.Distortions.SwapFirstLast), rng) {
+		before := [2]string{firstName, lastName}
 		firstName, lastName = lastName, firstName
+		trace.SwapFirstLast = true
+		if detailed {
+			trace.step("swapFirstLast", before, firstName, lastName)
+		}
 	}
 	if maybe(clamp01(cfg.Distortions.Transliterate), rng) {
+		before := [2]string{firstName, lastName}
 		firstName = transliterateCyrillicToLatin(firstName)
 		lastName = transliterateCyrillicToLatin(lastName)
+		trace.Transliterate = true
+		if detailed {
+			trace.step("transliterate", before, firstName, lastName)
+		}
 	}
 	if maybe(clamp01(cfg.Distortions.Typo), rng) {
+		before := [2]string{firstName, lastName}
 		firstName = randomTypo(rng, firstName)
+		trace.FirstNameTypo = true
+		if detailed {
+			trace.step("firstNameTypo", before, firstName, lastName)
+		}
 	}
 	if maybe(clamp01(cfg.Distortions.Typo), rng) {
+		before := [2]string{firstName, lastName}
 		lastName = randomTypo(rng, lastName)
+		trace.LastNameTypo = true
+		if detailed {
+			trace.step("lastNameTypo", before, firstName, lastName)
+		}
 	}
 
 	// Safe array access with fallbacks
 	var email, phone, login string
-	
+
 	if len(profile.Emails) > 0 {
 		email = profile.Emails[rng.NextInt(len(profile.Emails))]
 	} else {
 		email = "default@example.com"
 	}
-	
+
 	if len(profile.Phones) > 0 {
 		phone = profile.Phones[rng.NextInt(len(profile.Phones))]
 	} else {
 		phone = "+7000000000"
 	}
-	
+
 	if len(profile.Logins) > 0 {
 		login = profile.Logins[rng.NextInt(len(profile.Logins))]
 	} else {
@@ -384,96 +511,371 @@ func distortFields(profile Profile, variantIndex int, cfg GeneratorConfig, recor
 }
 
 func timestampForIndex(idx uint64, cfg GeneratorConfig) string {
+	return timeForIndex(idx, cfg).Format(time.RFC3339)
+}
+
+func timeForIndex(idx uint64, cfg GeneratorConfig) time.Time {
 	startMs := uint64(cfg.DateSpread.Start.UnixMilli())
 	endMs := uint64(cfg.DateSpread.End.UnixMilli())
 	span := endMs - startMs
-	h := fnv1a64("time:" + fmt.Sprintf("%d", idx))
+	if span == 0 {
+		// Unvalidated configs may have an empty spread.
+		return time.UnixMilli(int64(startMs)).UTC()
+	}
+	h := withSeed(fnv1a64("time:"+fmt.Sprintf("%d", idx)), cfg.Seed)
 	offset := h % span
 	ms := startMs + offset
-	return time.UnixMilli(int64(ms)).UTC().Format(time.RFC3339)
+	return time.UnixMilli(int64(ms)).UTC()
 }
 
-func amountForIndex(idx uint64) float64 {
-	h := fnv1a64("amt:" + fmt.Sprintf("%d", idx))
+func amountForIndex(idx uint64, cfg GeneratorConfig, channel, city string) float64 {
+	h := withSeed(fnv1a64("amt:"+fmt.Sprintf("%d", idx)), cfg.Seed)
 	rng := NewSplitMix64(h)
 	sum := 0.0
 	for i := 0; i < 12; i++ {
 		sum += rng.NextFloat()
 	}
 	normal := sum - 6.0
+	if cfg.Amounts != nil {
+		return cfg.Amounts.amount(normal, channel, city)
+	}
 	base := math.Exp(normal*0.35 + 3)
 	return math.Round(base*100) / 100
 }
 
-func nonProfileFields(idx uint64, cfg GeneratorConfig) (string, string, string) {
-	h := fnv1a64("np:" + fmt.Sprintf("%d", idx))
+func nonProfileFields(idx uint64, profile Profile, ts time.Time, cfg GeneratorConfig) (string, string, string) {
+	h := withSeed(fnv1a64("np:"+fmt.Sprintf("%d", idx)), cfg.Seed)
 	rng := NewSplitMix64(h)
-	
+	if cfg.Geography != nil {
+		channel := pickChannel(rng, profile, ts, cfg)
+		return correlatedFields(rng, cfg.Geography, profile.HomeCity, channel, cfg.Pools)
+	}
+
 	city := weightedPick(rng, cfg.Pools.Cities, nil)
-	channel := weightedPick(rng, cfg.Pools.Channels, nil)
+	channel := pickChannel(rng, profile, ts, cfg)
 	pos := weightedPick(rng, cfg.Pools.POS, nil)
-	
+
 	return city, channel, pos
 }
 
+// pickChannel draws a record's channel, from the profile's demographic
+// segment when one has a channel pool.
+func pickChannel(rng *SplitMix64, profile Profile, ts time.Time, cfg GeneratorConfig) string {
+	if d := cfg.Demographics; d != nil {
+		if pool, ok := d.segmentPool(d.Channels, profile, ts); ok {
+			return pickWeighted(rng, pool)
+		}
+	}
+	return weightedPick(rng, cfg.Pools.Channels, nil)
+}
+
 // Public API: IdempotentGenerator
 type IdempotentGenerator struct {
-	cfg GeneratorConfig
+	cfg         GeneratorConfig
+	version     int
+	clock       Clock
+	parallelism int
+	profiles    *profileCache
+	schema      *Schema
+	plugins     []*loadedPlugin
+}
+
+// NewIdempotentGenerator returns a generator for cfg. It fails when an
+// option cannot be applied or a plugin of cfg cannot be loaded; configs
+// without plugins and valid options always succeed.
+func NewIdempotentGenerator(cfg GeneratorConfig, opts ...Option) (*IdempotentGenerator, error) {
+	schema, err := newSchema(cfg.OutputFields, customFields(cfg.Plugins))
+	if err != nil {
+		// validateConfig rejects bad field lists; fall back for unvalidated configs.
+		schema = defaultSchema
+	}
+	g := &IdempotentGenerator{cfg: cfg, version: generatorVersion, clock: systemClock{}, parallelism: 1, schema: schema}
+	for _, opt := range opts {
+		if err := opt(g); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Plugins != nil {
+		if g.plugins, err = loadPlugins(cfg.Plugins); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
 }
 
-func NewIdempotentGenerator(cfg GeneratorConfig) *IdempotentGenerator {
-	return &IdempotentGenerator{cfg: cfg}
+// mustNewGenerator is NewIdempotentGenerator for configs without plugins,
+// such as the defaults and presets, and options that cannot fail.
+func mustNewGenerator(cfg GeneratorConfig, opts ...Option) *IdempotentGenerator {
+	g, err := NewIdempotentGenerator(cfg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return g
 }
 
 func (g *IdempotentGenerator) ProfileByID(profileID uint64) Profile {
+	if g.profiles != nil {
+		// Cached profiles are shared; hand out a copy the caller may modify.
+		p := g.profile(profileID)
+		p.Phones = append([]string(nil), p.Phones...)
+		p.Emails = append([]string(nil), p.Emails...)
+		p.Logins = append([]string(nil), p.Logins...)
+		return p
+	}
 	return buildProfile(profileID, g.cfg)
 }
 
 func (g *IdempotentGenerator) RecordByIndex(idx uint64) RawRecord {
-	profileID := profileIDForIndex(idx, g.cfg)
-	bucket := classifyBucket(profileID, g.cfg.Buckets)
-	variantIndex := variantForIndex(idx, bucket.RepeatMultiplier)
-	profile := buildProfile(profileID, g.cfg)
-	firstName, lastName, email, phone, login := distortFields(profile, variantIndex, g.cfg, fnv1a64("rec:"+fmt.Sprintf("%d", idx)))
-	city, channel, pos := nonProfileFields(idx, g.cfg)
-
-	return RawRecord{
-		RecordIndex:   idx,
-		ProfileID:     profileID,
-		VariantIndex:  variantIndex,
-		FirstName:     firstName,
-		LastName:      lastName,
-		Email:         email,
-		Phone:         phone,
-		Login:         login,
-		PointOfSale:   pos,
-		City:          city,
-		Channel:       channel,
-		Amount:        amountForIndex(idx),
-		Timestamp:     timestampForIndex(idx, g.cfg),
+	return g.recordByIndex(idx, nil)
+}
+
+// recordOwner is what decides the profile of a record before it is built.
+type recordOwner struct {
+	profileID uint64
+	fraud     anomaly
+	isFraud   bool
+	event     string
+	// refund is set when the record reverses the purchase at origin.
+	refund bool
+	origin uint64
+}
+
+// owner resolves the profile and event of record idx without building it.
+func (g *IdempotentGenerator) owner(idx uint64) recordOwner {
+	o := recordOwner{profileID: profileIDForIndex(idx, g.cfg)}
+	if g.cfg.Fraud != nil {
+		if o.fraud, o.isFraud = g.cfg.Fraud.anomalyAt(idx, g.cfg); o.isFraud && o.fraud.pinned {
+			o.profileID = o.fraud.profileID
+		}
+	}
+	if ev := g.cfg.Events; ev != nil {
+		o.event = ev.eventType(idx, g.cfg.Seed)
+		if o.isFraud {
+			o.event = eventPurchase
+		} else if o.event == eventRefund {
+			if origin, ok := ev.refundOrigin(idx, g.cfg.Seed); ok {
+				o.refund, o.origin = true, origin
+				o.profileID = g.owner(origin).profileID
+			} else {
+				o.event = eventPurchase
+			}
+		}
+	}
+	return o
+}
+
+// placement returns when and where record idx happens, before sessions
+// adjust the time. purchase is the purchase a refund reverses.
+func (g *IdempotentGenerator) placement(idx uint64, o recordOwner, profile Profile, purchase *RawRecord) (ts time.Time, city, channel, pos string) {
+	ts = timeForIndex(idx, g.cfg)
+	if g.cfg.Lifecycle != nil {
+		ts = lifecycleTime(idx, o.profileID, g.cfg)
+	}
+	if purchase != nil {
+		bought, _ := time.Parse(time.RFC3339, purchase.Timestamp)
+		_, hi := g.activeSpan(o.profileID)
+		ts = g.cfg.Events.refundTime(idx, bought, hi, g.cfg.Seed)
+	}
+	if o.isFraud {
+		ts = o.fraud.at
+	}
+	city, channel, pos = nonProfileFields(idx, profile, ts, g.cfg)
+	if o.fraud.city != "" {
+		city = o.fraud.city
+	}
+	if o.fraud.channel != "" {
+		channel = o.fraud.channel
+	}
+	return ts, city, channel, pos
+}
+
+// activeSpan is the span [lo, hi) the records of a profile fall in: its
+// lifecycle window when lifecycles are enabled, else the date spread.
+func (g *IdempotentGenerator) activeSpan(profileID uint64) (lo, hi time.Time) {
+	if g.cfg.Lifecycle != nil {
+		from, until, _ := activeWindow(profileID, g.cfg)
+		return from, until
+	}
+	return g.cfg.DateSpread.Start.UTC(), g.cfg.DateSpread.End.UTC()
+}
+
+func (g *IdempotentGenerator) recordByIndex(idx uint64, trace *DistortionTrace) RawRecord {
+	o := g.owner(idx)
+	profileID, fraud, isFraud, event := o.profileID, o.fraud, o.isFraud, o.event
+	var purchase *RawRecord
+	if o.refund {
+		p := g.recordByIndex(o.origin, nil)
+		purchase = &p
+	}
+	bucket := classifyBucket(profileID, g.cfg.Buckets, g.cfg.Seed)
+	variantIndex := variantForIndex(idx, bucket.RepeatMultiplier, g.cfg.Seed)
+	profile := g.profile(profileID)
+	firstName, lastName, email, phone, login := distortFields(profile, variantIndex, g.cfg, withSeed(fnv1a64("rec:"+fmt.Sprintf("%d", idx)), g.cfg.Seed), trace)
+	ts, city, channel, pos := g.placement(idx, o, profile, purchase)
+	var session Session
+	if s := g.cfg.Sessions; s != nil && purchase == nil && !isFraud && s.hasChannel(channel) {
+		lo, hi := g.activeSpan(profileID)
+		session, ts = s.session(idx, profileID, ts, lo, hi, g.cfg.Seed)
+	}
+
+	var canonical string
+	if g.cfg.EmailAliases != nil {
+		canonical = email
+		rng := NewSplitMix64(withSeed(fnv1a64("alias:"+fmt.Sprintf("%d", idx)), g.cfg.Seed))
+		email = aliasEmail(rng, g.cfg.EmailAliases, email)
+	}
+
+	var localTS, zone string
+	if g.cfg.CityTimezones != nil {
+		var loc *time.Location
+		zone, loc = cityTimezone(g.cfg.CityTimezones, city)
+		localTS = ts.In(loc).Format(time.RFC3339)
+	}
+
+	rec := RawRecord{
+		RecordIndex:    idx,
+		ProfileID:      profileID,
+		VariantIndex:   variantIndex,
+		FirstName:      firstName,
+		LastName:       lastName,
+		Email:          email,
+		Phone:          phone,
+		Login:          login,
+		PointOfSale:    pos,
+		City:           city,
+		Channel:        channel,
+		Amount:         amountForIndex(idx, g.cfg, channel, city),
+		Timestamp:      ts.Format(time.RFC3339),
+		EmailCanonical: canonical,
+		LocalTimestamp: localTS,
+		Timezone:       zone,
+	}
+	if session.ID != "" {
+		rec.SessionID, rec.SessionStart, rec.Device = session.ID, session.Start.Format(time.RFC3339), session.Device
+	}
+	if d := g.cfg.Demographics; d != nil {
+		rec.BirthDate, rec.Gender = profile.BirthDate, profile.Gender
+		if pool, ok := d.segmentPool(d.Categories, profile, ts); ok {
+			rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("category:%d", idx)), g.cfg.Seed))
+			rec.Category = pickWeighted(rng, pool)
+		}
+	}
+	rec.EventType = event
+	if g.cfg.Organizations != nil {
+		if org, ok := organizationFor(idx, profileID, g.cfg.Organizations, g.cfg.Seed); ok {
+			rec.OrgID, rec.INN, rec.OGRN, rec.KPP = &org.ID, org.INN, org.OGRN, org.KPP
+		}
 	}
+	if r := g.cfg.Retention; r != nil {
+		var expires time.Time
+		rec.Jurisdiction, expires = r.expiry(ts, city, channel)
+		rec.ExpiresAt = expires.Format(time.RFC3339)
+	}
+	if purchase != nil {
+		rec.Amount, rec.Category = purchase.Amount, purchase.Category
+		rec.RefundOf = &purchase.RecordIndex
+	}
+	if isFraud {
+		rec.FraudLabel, rec.FraudIncident = fraud.pattern, fraud.incident
+		if fraud.amount != 0 {
+			rec.Amount = fraud.amount
+		}
+		if fraud.device != "" {
+			rec.Device = fraud.device
+		}
+	}
+	applyPlugins(&rec, g.plugins, g.cfg)
+	if g.cfg.Erasure != nil {
+		if at, ok := erasedAt(rec.ProfileID, g.cfg); ok && !ts.Before(at) {
+			redact(&rec, at, customFields(g.cfg.Plugins))
+		}
+	}
+	if g.cfg.FieldAvailability != nil {
+		applyAvailability(&rec, g.cfg.FieldAvailability, g.cfg.Seed)
+	}
+	if c := g.cfg.Canaries; c != nil && c.isCanary(idx) && rec.ErasedAt == "" {
+		c.apply(&rec)
+	}
+	return rec
 }
 
 func (g *IdempotentGenerator) Iterate(startInclusive, count uint64) []RawRecord {
+	records, _ := g.IterateContext(context.Background(), startInclusive, count)
+	return records
+}
+
+// IterateContext is Iterate with cancellation, checked between batches of
+// progressInterval records. On cancellation it returns the context's error
+// and no records.
+func (g *IdempotentGenerator) IterateContext(ctx context.Context, startInclusive, count uint64) ([]RawRecord, error) {
 	records := make([]RawRecord, count)
-	for i := uint64(0); i < count; i++ {
-		records[i] = g.RecordByIndex(startInclusive + i)
+	fill := func(from, to uint64) error {
+		for i := from; i < to; i++ {
+			if (i-from)%progressInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			records[i] = g.RecordByIndex(startInclusive + i)
+		}
+		return nil
 	}
-	return records
+	if g.parallelism <= 1 || count < uint64(g.parallelism) {
+		if err := fill(0, count); err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, g.parallelism)
+	chunk := (count + uint64(g.parallelism) - 1) / uint64(g.parallelism)
+	for from := uint64(0); from < count; from += chunk {
+		to := min(from+chunk, count)
+		wg.Add(1)
+		go func(from, to uint64) {
+			defer wg.Done()
+			if err := fill(from, to); err != nil {
+				errs <- err
+			}
+		}(from, to)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return records, nil
 }
 
+// platformMain, when set by a platform-specific file such as the WebAssembly
+// entry point, replaces the command line.
+var platformMain func()
+
 func main() {
-	gen := NewIdempotentGenerator(defaultConfig)
-	
+	if platformMain != nil {
+		platformMain()
+		return
+	}
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+	runBenchmark()
+}
+
+// runBenchmark is the default mode when no command is given.
+func runBenchmark() {
+	gen := mustNewGenerator(defaultConfig)
+
 	// Performance benchmark: generate 1M records WITH saving
 	fmt.Println("🚀 Performance Benchmark: Generating and Saving 1,000,000 records...")
-	
+
 	// Create output directory
 	os.MkdirAll("output", 0755)
-	
+
 	// Generate and save 1M records
 	start := time.Now()
-	
+
 	// Open file for writing
 	file, err := os.Create("output/records_1m.jsonl")
 	if err != nil {
@@ -481,44 +883,44 @@ func main() {
 		return
 	}
 	defer file.Close()
-	
+
 	// Generate 1M records and save them line by line (JSONL format for efficiency)
 	recordsGenerated := 0
 	for i := uint64(0); i < 1_000_000; i++ {
 		record := gen.RecordByIndex(i)
-		
+
 		// Convert to JSON
 		jsonData, err := json.Marshal(record)
 		if err != nil {
 			fmt.Printf("Error marshaling record %d: %v\n", i, err)
 			continue
 		}
-		
+
 		// Write to file with newline
 		_, err = file.Write(append(jsonData, '\n'))
 		if err != nil {
 			fmt.Printf("Error writing record %d: %v\n", i, err)
 			continue
 		}
-		
+
 		recordsGenerated++
-		
+
 		// Progress indicator every 100K records
 		if recordsGenerated%100_000 == 0 {
 			fmt.Printf("📝 Generated and saved %d records...\n", recordsGenerated)
 		}
 	}
-	
+
 	// Ensure all data is written to disk
 	file.Sync()
-	
+
 	totalDuration := time.Since(start)
 	recordsPerSecond := float64(recordsGenerated) / totalDuration.Seconds()
-	
+
 	fmt.Printf("✅ Generated and saved %d records in %v\n", recordsGenerated, totalDuration)
 	fmt.Printf("📊 Speed: %.0f records/second (generation + I/O)\n", recordsPerSecond)
 	fmt.Printf("⏱️  Average: %.3f microseconds per record\n", float64(totalDuration.Microseconds())/float64(recordsGenerated))
-	
+
 	// Get file size
 	fileInfo, err := file.Stat()
 	if err == nil {
@@ -526,34 +928,27 @@ func main() {
 		fmt.Printf("💾 File size: %.2f MB\n", fileSizeMB)
 		fmt.Printf("📊 Data rate: %.2f MB/s\n", fileSizeMB/totalDuration.Seconds())
 	}
-	
-	// Estimate time for 1 billion records with I/O
-	fmt.Println("\n🔮 Time Estimation for 1 Billion Records (with I/O):")
-	billionRecords := 1_000_000_000
-	estimatedSeconds := float64(billionRecords) / recordsPerSecond
-	estimatedDuration := time.Duration(estimatedSeconds * float64(time.Second))
-	
-	fmt.Printf("📈 Target: 1,000,000,000 records\n")
-	fmt.Printf("⏱️  Estimated time: %v\n", estimatedDuration)
-	fmt.Printf("🕐 Estimated time (human readable): %s\n", formatDuration(estimatedDuration))
-	
-	// Estimate storage requirements
+
+	// Project the measured run to 1 billion records
+	fmt.Println()
+	measured := sinkSample{Sink: "file", Records: uint64(recordsGenerated), Elapsed: totalDuration}
 	if fileInfo != nil {
-		estimatedSizeGB := float64(fileInfo.Size()) * float64(billionRecords) / float64(recordsGenerated) / (1024 * 1024 * 1024)
-		fmt.Printf("💾 Estimated storage: %.2f GB\n", estimatedSizeGB)
+		measured.Bytes = fileInfo.Size()
 	}
-	
+	printEstimate(os.Stdout, projectSamples("jsonl", 1_000_000_000, []sinkSample{measured}))
+	fmt.Println("   (use `generate -dry-run -count N` to estimate other sizes)")
+
 	// Now generate a small sample for display
 	fmt.Println("\n📋 Sample Output (5 records):")
 	sample := gen.Iterate(0, 5)
-	
+
 	// Convert to JSON
 	jsonData, err := json.MarshalIndent(sample, "", "  ")
 	if err != nil {
 		fmt.Printf("Error marshaling JSON: %v\n", err)
 		return
 	}
-	
+
 	fmt.Println(string(jsonData))
 }
 
@@ -578,8 +973,9 @@ func formatDuration(d time.Duration) string {
 }
 
 // # Run the Go code
-// go run main.go
+// go run *.go
 
 // # Or build and run
-// go build -o generator main.go
+// go build -o generator *.go
 // ./generator
+// ./generator serve -addr :8080
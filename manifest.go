@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Manifests describe generated output: the exact config it came from and
+// the files covering the index range, with checksums.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Worker string `json:"worker,omitempty"`
+	Start  uint64 `json:"start"`
+	Count  uint64 `json:"count"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+type Manifest struct {
+	ConfigHash string           `json:"configHash"`
+	Config     GeneratorConfig  `json:"config"`
+	Overrides  []ConfigOverride `json:"overrides,omitempty"`
+	Registered string           `json:"registered,omitempty"`
+	Format     string           `json:"format"`
+	Start      uint64           `json:"start"`
+	Count      uint64           `json:"count"`
+	// Shuffle is set when records were written in a permuted order.
+	Shuffle   *ShuffleInfo   `json:"shuffle,omitempty"`
+	Files     []ManifestFile `json:"files"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+func writeManifest(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func readManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// writeRangeFile generates [start, start+count) in format f into path and
+// returns its manifest entry.
+func writeRangeFile(ctx context.Context, gen RecordSource, f recordFormat, path string, start, count uint64, progress func(uint64, int64)) (ManifestFile, error) {
+	entry := ManifestFile{Path: path, Start: start, Count: count}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(file, hash)}
+	sink := &instrumentedWriter{w: counter, sink: "file"}
+	if _, _, err := writeRecords(ctx, gen, sink, f, start, count, progress); err != nil {
+		return entry, err
+	}
+	if err := file.Sync(); err != nil {
+		return entry, err
+	}
+
+	entry.Bytes = counter.n
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return entry, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Prometheus-style metrics, rendered in the text exposition format.
+type collector interface {
+	writeTo(w io.Writer)
+}
+
+type counterVec struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]float64)}
+}
+
+func (c *counterVec) Add(labelValue string, n float64) {
+	c.mu.Lock()
+	c.values[labelValue] += n
+	c.mu.Unlock()
+}
+
+func (c *counterVec) Inc(labelValue string) {
+	c.Add(labelValue, 1)
+}
+
+func (c *counterVec) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, lv := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", c.name, c.label, lv, formatFloat(c.values[lv]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) Observe(labelValue string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogramVec) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, lv := range sortedKeys(h.series) {
+		s := h.series[lv]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s=%q,le=%q} %d\n", h.name, h.label, lv, formatFloat(upper), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", h.name, h.label, lv, s.count)
+		fmt.Fprintf(w, "%s_sum{%s=%q} %s\n", h.name, h.label, lv, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s=%q} %d\n", h.name, h.label, lv, s.count)
+	}
+}
+
+// gaugeFunc reports values computed at scrape time.
+type gaugeFunc struct {
+	name  string
+	help  string
+	label string
+	fn    func() map[string]float64
+}
+
+func (g *gaugeFunc) writeTo(w io.Writer) {
+	values := g.fn()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, lv := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", g.name, g.label, lv, formatFloat(values[lv]))
+	}
+}
+
+type metricsRegistry struct {
+	mu         sync.Mutex
+	collectors []collector
+
+	recordsGenerated *counterVec
+	bytesWritten     *counterVec
+	sinkLatency      *histogramVec
+	errors           *counterVec
+	lookupCache      *counterVec
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	m := &metricsRegistry{
+		recordsGenerated: newCounterVec("generator_records_generated_total", "Records generated.", "source"),
+		bytesWritten:     newCounterVec("generator_bytes_written_total", "Bytes written to sinks.", "sink"),
+		sinkLatency: newHistogramVec("generator_sink_write_seconds", "Latency of individual sink writes.", "sink",
+			[]float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}),
+		errors:      newCounterVec("generator_errors_total", "Errors by subsystem.", "kind"),
+		lookupCache: newCounterVec("generator_lookup_cache_requests_total", "Lookup requests by conditional-cache result.", "result"),
+	}
+	m.collectors = []collector{m.recordsGenerated, m.bytesWritten, m.sinkLatency, m.errors, m.lookupCache}
+	return m
+}
+
+var metrics = newMetricsRegistry()
+
+func (m *metricsRegistry) register(c collector) {
+	m.mu.Lock()
+	m.collectors = append(m.collectors, c)
+	m.mu.Unlock()
+}
+
+func (m *metricsRegistry) WriteText(w io.Writer) {
+	m.mu.Lock()
+	collectors := append([]collector(nil), m.collectors...)
+	m.mu.Unlock()
+
+	for _, c := range collectors {
+		c.writeTo(w)
+	}
+}
+
+func (m *metricsRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteText(w)
+	})
+}
+
+// instrumentedWriter records bytes and per-write latency for a sink.
+type instrumentedWriter struct {
+	w    io.Writer
+	sink string
+}
+
+func (iw *instrumentedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := iw.w.Write(p)
+	metrics.sinkLatency.Observe(iw.sink, time.Since(start).Seconds())
+	metrics.bytesWritten.Add(iw.sink, float64(n))
+	if err != nil {
+		metrics.errors.Inc("sink")
+	}
+	return n, err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// currentConfigVersion is the config format this build writes. Files with a
+// lower configVersion (or none) are upgraded on load by configMigrations.
+const currentConfigVersion = 1
+
+// configMigration upgrades a decoded config from version from to from+1 and
+// returns a note for every change it made.
+type configMigration struct {
+	from  int
+	apply func(cfg map[string]interface{}) []string
+}
+
+var configMigrations = []configMigration{
+	{from: 0, apply: migrateV0},
+}
+
+// migrateV0 handles unversioned configs, which include files written for the
+// original TypeScript generator: pools there are {values, weights} objects
+// and profileSpaceSize is a bigint serialized as a string.
+func migrateV0(cfg map[string]interface{}) []string {
+	var notes []string
+	if s, ok := cfg["profileSpaceSize"].(string); ok {
+		cfg["profileSpaceSize"] = json.Number(strings.TrimSuffix(s, "n"))
+		notes = append(notes, "profileSpaceSize: string → number")
+	}
+
+	pools, _ := cfg["pools"].(map[string]interface{})
+	for _, name := range sortedKeys(pools) {
+		obj, ok := pools[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pools[name] = obj["values"]
+		note := fmt.Sprintf("pools.%s: {values, weights} → list of values", name)
+		if _, hasWeights := obj["weights"]; hasWeights {
+			note += " (weights dropped; pools are sampled with built-in weights)"
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// migrateConfig upgrades raw config JSON to currentConfigVersion. It returns
+// the upgraded JSON and a note per change.
+func migrateConfig(data []byte) ([]byte, []string, error) {
+	var cfg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("parse config: %w", err)
+	}
+	if cfg == nil {
+		return nil, nil, errors.New("parse config: not a JSON object")
+	}
+
+	version := 0
+	if v, ok := cfg["configVersion"].(json.Number); ok {
+		n, err := v.Int64()
+		if err != nil {
+			return nil, nil, fmt.Errorf("configVersion: %w", err)
+		}
+		version = int(n)
+	}
+	if version > currentConfigVersion {
+		return nil, nil, fmt.Errorf("configVersion %d is newer than this build supports (%d)", version, currentConfigVersion)
+	}
+
+	var notes []string
+	for _, m := range configMigrations {
+		if m.from < version {
+			continue
+		}
+		for _, n := range m.apply(cfg) {
+			notes = append(notes, fmt.Sprintf("v%d→v%d %s", m.from, m.from+1, n))
+		}
+	}
+	cfg["configVersion"] = currentConfigVersion
+
+	out, err := json.Marshal(cfg)
+	return out, notes, err
+}
+
+// filledDefaults lists the config keys absent from raw (a config file) that
+// therefore come from base.
+func filledDefaults(raw []byte, base GeneratorConfig) []string {
+	var tree, defaults interface{}
+	if json.Unmarshal(raw, &tree) != nil {
+		return nil
+	}
+	data, _ := json.Marshal(base)
+	json.Unmarshal(data, &defaults)
+
+	var filled []string
+	for _, key := range configKeys() {
+		def := lookupPath(defaults, key)
+		if key == "configVersion" || def == nil || lookupPath(tree, key) != nil {
+			continue
+		}
+		v, _ := json.Marshal(def)
+		if len(v) > 60 {
+			v = append(v[:57:57], "..."...)
+		}
+		filled = append(filled, fmt.Sprintf("%s = %s", key, v))
+	}
+	return filled
+}
+
+// lookupPath returns the value at a dotted path in a decoded JSON tree, or
+// nil if any part of it is missing.
+func lookupPath(node interface{}, key string) interface{} {
+	for _, part := range strings.Split(key, ".") {
+		obj, _ := node.(map[string]interface{})
+		node = obj[part]
+	}
+	return node
+}
+
+// runMigrate rewrites config files in the current format with every value
+// spelled out, reporting migrations and defaults that were filled in.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	write := fs.Bool("write", false, "rewrite the files in place instead of printing to stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: migrate [-write] <config.json>...")
+	}
+
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		migrated, notes, err := migrateConfig(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		cfg, err := parseConfig(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "🛠  %s\n", path)
+		for _, n := range notes {
+			fmt.Fprintf(os.Stderr, "   migrated: %s\n", n)
+		}
+		for _, f := range filledDefaults(migrated, cloneConfig(defaultConfig)) {
+			fmt.Fprintf(os.Stderr, "   default:  %s\n", f)
+		}
+
+		out, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = append(out, '\n')
+		if *write {
+			if err := os.WriteFile(path, out, 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		os.Stdout.Write(out)
+	}
+	return nil
+}
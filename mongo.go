@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Document-database output: canonical MongoDB Extended JSON (one document
+// per line, for mongoimport) and BSON (concatenated documents, for
+// mongorestore), and a sink that bulk-writes the same documents to a live
+// collection. The record index is the _id, so re-importing a range replaces
+// rather than duplicates it.
+
+// mongoDateFields are written as BSON dates rather than strings.
+var mongoDateFields = map[string]bool{"timestamp": true, "sessionStart": true}
+
+var (
+	formatExtendedJSON = recordFormat{name: "ejson", appendRecord: (*Schema).AppendExtendedJSON}
+	formatBSON         = recordFormat{name: "bson", appendRecord: (*Schema).AppendBSON}
+)
+
+func init() {
+	recordFormats[formatExtendedJSON.name] = formatExtendedJSON
+	recordFormats[formatBSON.name] = formatBSON
+}
+
+// AppendExtendedJSON appends rec as one canonical Extended JSON document and
+// a newline.
+func (s *Schema) AppendExtendedJSON(b []byte, rec *RawRecord) []byte {
+	b = append(b, `{"_id":{"$numberLong":"`...)
+	b = strconv.AppendUint(b, rec.RecordIndex, 10)
+	b = append(b, `"}`...)
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			continue
+		}
+		b = append(b, ',')
+		b = appendJSONString(b, f.Name)
+		b = append(b, ':')
+		switch f.Type {
+		case FieldString:
+			if t, ok := mongoDate(f.Name, v.String()); ok {
+				b = append(b, `{"$date":{"$numberLong":"`...)
+				b = strconv.AppendInt(b, t.UnixMilli(), 10)
+				b = append(b, `"}}`...)
+				continue
+			}
+			b = appendJSONString(b, v.String())
+		case FieldInt:
+			b = append(b, `{"$numberLong":"`...)
+			b = strconv.AppendInt(b, v.Int(), 10)
+			b = append(b, `"}`...)
+		case FieldUint:
+			b = append(b, `{"$numberLong":"`...)
+			b = strconv.AppendUint(b, v.Uint(), 10)
+			b = append(b, `"}`...)
+		case FieldFloat:
+			b = append(b, `{"$numberDouble":"`...)
+			b = strconv.AppendFloat(b, v.Float(), 'g', -1, 64)
+			b = append(b, `"}`...)
+		}
+	}
+	return append(b, "}\n"...)
+}
+
+// AppendBSON appends rec as one BSON document. Integers are int64, since
+// BSON has no unsigned type; invalid UTF-8 is replaced by U+FFFD.
+func (s *Schema) AppendBSON(b []byte, rec *RawRecord) []byte {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	b = appendBSONKey(b, 0x12, "_id")
+	b = binary.LittleEndian.AppendUint64(b, rec.RecordIndex)
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			if t, ok := mongoDate(f.Name, v.String()); ok {
+				b = appendBSONKey(b, 0x09, f.Name)
+				b = binary.LittleEndian.AppendUint64(b, uint64(t.UnixMilli()))
+				continue
+			}
+			str := strings.ToValidUTF8(v.String(), "\uFFFD")
+			b = appendBSONKey(b, 0x02, f.Name)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(str)+1))
+			b = append(append(b, str...), 0)
+		case FieldInt:
+			b = appendBSONKey(b, 0x12, f.Name)
+			b = binary.LittleEndian.AppendUint64(b, uint64(v.Int()))
+		case FieldUint:
+			b = appendBSONKey(b, 0x12, f.Name)
+			b = binary.LittleEndian.AppendUint64(b, v.Uint())
+		case FieldFloat:
+			b = appendBSONKey(b, 0x01, f.Name)
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float()))
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b
+}
+
+func appendBSONKey(b []byte, kind byte, name string) []byte {
+	b = append(b, kind)
+	b = append(b, name...)
+	return append(b, 0)
+}
+
+func mongoDate(field, value string) (time.Time, bool) {
+	if !mongoDateFields[field] {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
+
+// mongoUpserts returns a replace-or-insert of each record's BSON document,
+// keyed by its _id, so rerunning a range leaves one copy of each record.
+func mongoUpserts(s *Schema, recs []RawRecord) []mongo.WriteModel {
+	models := make([]mongo.WriteModel, len(recs))
+	for i := range recs {
+		doc := s.AppendBSON(nil, &recs[i])
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: int64(recs[i].RecordIndex)}}).
+			SetReplacement(bson.Raw(doc)).
+			SetUpsert(true)
+	}
+	return models
+}
+
+func runMongo(args []string) error {
+	fs := flag.NewFlagSet("mongo", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	uri := fs.String("uri", "mongodb://localhost:27017", "MongoDB connection string")
+	database := fs.String("database", "idempotent", "database to write to")
+	collection := fs.String("collection", "records", "collection to write to")
+	batchSize := fs.Int("batch", 1000, "documents per unordered bulk write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return errors.New("-batch must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	client, err := mongo.Connect(options.Client().ApplyURI(*uri))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	coll := client.Database(*database).Collection(*collection)
+
+	schema := gen.Schema()
+	batch := make([]RawRecord, 0, *batchSize)
+	var inserted, replaced int64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := coll.BulkWrite(ctx, mongoUpserts(schema, batch), options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		inserted += res.UpsertedCount
+		replaced += res.MatchedCount
+		metrics.recordsGenerated.Add("mongo", float64(len(batch)))
+		batch = batch[:0]
+		return nil
+	}
+	began := time.Now()
+	for rec := range gen.Records(*start, *count) {
+		batch = append(batch, rec)
+		if len(batch) == *batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	logFor("mongo").Info("written", "collection", *database+"."+*collection, "inserted", inserted, "replaced", replaced, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
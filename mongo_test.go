@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestMongoUpserts(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	schema := gen.Schema()
+	var recs []RawRecord
+	for rec := range gen.Records(700, 200) {
+		recs = append(recs, rec)
+	}
+	for i, m := range mongoUpserts(schema, recs) {
+		rec := &recs[i]
+		model := m.(*mongo.ReplaceOneModel)
+		if model.Upsert == nil || !*model.Upsert {
+			t.Fatalf("record %d is replaced without upsert", rec.RecordIndex)
+		}
+		if filter := model.Filter.(bson.D); len(filter) != 1 || filter[0] != (bson.E{Key: "_id", Value: int64(rec.RecordIndex)}) {
+			t.Fatalf("record %d is filtered by %v", rec.RecordIndex, model.Filter)
+		}
+
+		// The driver's decoder reads the hand-written document back.
+		doc := model.Replacement.(bson.Raw)
+		if err := doc.Validate(); err != nil {
+			t.Fatalf("record %d: %v", rec.RecordIndex, err)
+		}
+		if id := doc.Lookup("_id").Int64(); id != int64(rec.RecordIndex) {
+			t.Errorf("record %d has _id %d", rec.RecordIndex, id)
+		}
+		for j := range schema.Fields {
+			f := &schema.Fields[j]
+			want := wantFieldValue(f, rec)
+			v, err := doc.LookupErr(f.Name)
+			if want == nil {
+				if err == nil {
+					t.Errorf("record %d: null %s is written as %v", rec.RecordIndex, f.Name, v)
+				}
+				continue
+			}
+			var got any
+			switch v.Type {
+			case bson.TypeString:
+				got = v.StringValue()
+				want = strings.ToValidUTF8(want.(string), "\uFFFD")
+			case bson.TypeDateTime:
+				got = v.Time().UTC().Format(time.RFC3339Nano)
+				if d, ok := mongoDate(f.Name, want.(string)); ok {
+					want = d.Truncate(time.Millisecond).UTC().Format(time.RFC3339Nano)
+				}
+			case bson.TypeInt64:
+				got = v.Int64()
+				if u, ok := want.(uint64); ok {
+					want = int64(u)
+				}
+			case bson.TypeDouble:
+				got = v.Double()
+			}
+			if got != want {
+				t.Fatalf("record %d field %s decodes as %#v, want %#v", rec.RecordIndex, f.Name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Option configures optional behavior of an IdempotentGenerator. Options
+// never change record content except WithAlgorithmVersion, which selects it.
+// An option that cannot be applied makes NewIdempotentGenerator fail.
+type Option func(*IdempotentGenerator) error
+
+// Clock supplies the current time for metadata a generator stamps, such as
+// manifest creation times. Records never depend on it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// supportedAlgorithmVersions lists the algorithm versions this build can
+// produce.
+var supportedAlgorithmVersions = []int{generatorVersion}
+
+// WithCache keeps up to n recently built profiles in memory, which pays off
+// when records of the same profiles are generated repeatedly.
+func WithCache(n int) Option {
+	return func(g *IdempotentGenerator) error {
+		if n > 0 {
+			g.profiles = newProfileCache(n)
+		}
+		return nil
+	}
+}
+
+// WithAlgorithmVersion pins the record derivation algorithm, so a build that
+// changes the default keeps producing the pinned output. It fails if this
+// build does not support v.
+func WithAlgorithmVersion(v int) Option {
+	return func(g *IdempotentGenerator) error {
+		if !slices.Contains(supportedAlgorithmVersions, v) {
+			return fmt.Errorf("algorithm version %d is not supported (supported: %v)", v, supportedAlgorithmVersions)
+		}
+		g.version = v
+		return nil
+	}
+}
+
+// WithClock replaces the system clock.
+func WithClock(c Clock) Option {
+	return func(g *IdempotentGenerator) error {
+		g.clock = c
+		return nil
+	}
+}
+
+// WithParallelism makes Iterate generate with p goroutines.
+func WithParallelism(p int) Option {
+	return func(g *IdempotentGenerator) error {
+		if p > 0 {
+			g.parallelism = p
+		}
+		return nil
+	}
+}
+
+// AlgorithmVersion reports the record derivation algorithm in use.
+func (g *IdempotentGenerator) AlgorithmVersion() int {
+	return g.version
+}
+
+// Derive returns a generator with the same config and options over an
+// independent seed domain named by label. The same label always derives the
+// same stream, and derived streams do not correlate with g or with each
+// other, so auxiliary datasets can be generated alongside the main one.
+func (g *IdempotentGenerator) Derive(label string) *IdempotentGenerator {
+	cfg := cloneConfig(g.cfg)
+	cfg.Seed = withSeed(fnv1a64("derive:"+label), g.cfg.Seed)
+	d := *g
+	d.cfg = cfg
+	if g.profiles != nil {
+		d.profiles = newProfileCache(g.profiles.size)
+	}
+	return &d
+}
+
+func (g *IdempotentGenerator) now() time.Time {
+	return g.clock.Now()
+}
+
+// profile builds a profile, through the cache when there is one.
+func (g *IdempotentGenerator) profile(profileID uint64) Profile {
+	if g.profiles == nil {
+		return buildProfile(profileID, g.cfg)
+	}
+	if p, ok := g.profiles.get(profileID); ok {
+		return p
+	}
+	p := buildProfile(profileID, g.cfg)
+	g.profiles.put(profileID, p)
+	return p
+}
+
+// profileCache is a mutex-guarded LRU of built profiles.
+type profileCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recent; values are Profiles
+	entries map[uint64]*list.Element
+}
+
+func newProfileCache(size int) *profileCache {
+	return &profileCache{size: size, order: list.New(), entries: make(map[uint64]*list.Element, size)}
+}
+
+func (c *profileCache) get(id uint64) (Profile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		return Profile{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(Profile), true
+}
+
+func (c *profileCache) put(id uint64, p Profile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[id] = c.order.PushFront(p)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(Profile).ProfileID)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptionErrors(t *testing.T) {
+	if _, err := NewIdempotentGenerator(defaultConfig, WithAlgorithmVersion(generatorVersion+1)); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("unsupported algorithm version: %v, want an error", err)
+	}
+	gen, err := NewIdempotentGenerator(defaultConfig, WithAlgorithmVersion(generatorVersion), WithCache(10), WithParallelism(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen.AlgorithmVersion() != generatorVersion || gen.parallelism != 2 || gen.profiles == nil {
+		t.Errorf("options not applied: version %d, parallelism %d, cache %v", gen.AlgorithmVersion(), gen.parallelism, gen.profiles != nil)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scritchley/orc"
+)
+
+// ORC output: a range as one ORC file for Hive-based shops, written through
+// the columnar path Parquet uses — one stripe per group of records, capped
+// by a byte target, optionally ordered by profile within a stripe. ORC has
+// no unsigned integers; uint fields are bigint, which holds every index
+// below 2^63.
+
+var orcCodecs = map[string]orc.CompressionCodec{
+	"none": orc.CompressionNone{},
+	"zlib": orc.CompressionZlib{Level: -1},
+}
+
+// ORCOptions are the layout knobs of an ORC file.
+type ORCOptions struct {
+	ColumnarLayout
+	// StripeBytes caps a stripe's buffered size; a stripe ends early once
+	// it is reached. The writer checks it every 10000 records.
+	StripeBytes int64
+	// Compression is an orcCodecs key.
+	Compression string
+}
+
+var defaultORCOptions = ORCOptions{
+	ColumnarLayout: ColumnarLayout{GroupRows: 1_000_000},
+	StripeBytes:    64 << 20,
+	Compression:    "zlib",
+}
+
+// orcSchema is the ORC struct type of s.
+func orcSchema(s *Schema) (*orc.TypeDescription, error) {
+	fields := make([]orc.TypeDescriptionTransformFunc, 0, len(s.Fields)+1)
+	fields = append(fields, orc.SetCategory(orc.CategoryStruct))
+	for _, f := range s.Fields {
+		category := orc.CategoryString
+		switch f.Type {
+		case FieldInt, FieldUint:
+			category = orc.CategoryLong
+		case FieldFloat:
+			category = orc.CategoryDouble
+		}
+		fields = append(fields, orc.AddField(f.Name, orc.SetCategory(category)))
+	}
+	return orc.NewTypeDescription(fields...)
+}
+
+// appendORCRow appends the values of rec to row in schema order, nil for
+// the fields left out of JSON when empty.
+func appendORCRow(row []any, s *Schema, rec *RawRecord) []any {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			row = append(row, nil)
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			row = append(row, v.String())
+		case FieldInt:
+			row = append(row, v.Int())
+		case FieldUint:
+			row = append(row, int64(v.Uint()))
+		case FieldFloat:
+			row = append(row, v.Float())
+		}
+	}
+	return row
+}
+
+// writeORC writes [start, start+count) of g to w as an ORC file laid out by
+// o. The config hash and the range are kept in the file's user metadata.
+func writeORC(ctx context.Context, g *IdempotentGenerator, w io.Writer, start, count uint64, o ORCOptions) error {
+	codec, ok := orcCodecs[o.Compression]
+	if !ok {
+		return fmt.Errorf("unknown compression %q", o.Compression)
+	}
+	if o.StripeBytes <= 0 {
+		return errors.New("stripe size must be positive")
+	}
+	schema := g.Schema()
+	td, err := orcSchema(schema)
+	if err != nil {
+		return err
+	}
+	ow, err := orc.NewWriter(w,
+		orc.SetSchema(td),
+		orc.SetCompression(codec),
+		orc.SetStripeTargetSize(o.StripeBytes),
+		orc.AddUserMetadata("generator.configHash", []byte(configHash(g.cfg))),
+		orc.AddUserMetadata("generator.range", fmt.Appendf(nil, "%d+%d", start, count)),
+	)
+	if err != nil {
+		return err
+	}
+
+	var row []any
+	written := uint64(0)
+	write := func(batch []RawRecord) error {
+		for i := range batch {
+			row = appendORCRow(row[:0], schema, &batch[i])
+			if err := ow.Write(row...); err != nil {
+				return err
+			}
+		}
+		written += uint64(len(batch))
+		return nil
+	}
+	// Close writes the last stripe; flushing it first would leave an empty
+	// one behind.
+	endStripe := func() error {
+		if written == count {
+			return nil
+		}
+		return ow.Flush()
+	}
+	if err := writeColumnarGroups(ctx, g, start, count, o.ColumnarLayout, write, endStripe); err != nil {
+		return err
+	}
+	return ow.Close()
+}
+
+func runORC(args []string) error {
+	fs := flag.NewFlagSet("orc", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	out := fs.String("out", "output/records.orc", "ORC file to write")
+	d := defaultORCOptions
+	fs.Uint64Var(&d.GroupRows, "stripe-rows", d.GroupRows, "records per stripe")
+	fs.Int64Var(&d.StripeBytes, "stripe-size", d.StripeBytes, "bytes at which a stripe ends early")
+	fs.StringVar(&d.Compression, "compression", d.Compression, "compression codec: "+strings.Join(sortedKeys(orcCodecs), ", "))
+	fs.BoolVar(&d.SortByProfile, "sort-by-profile", false, "order each stripe by profileId, then recordIndex")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	began := time.Now()
+	if err := writeORC(ctx, gen, file, *start, *count, d); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	logFor("orc").Info("wrote orc", "path", *out, "records", *count, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/scritchley/orc"
+)
+
+func TestORCStripes(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	o := defaultORCOptions
+	o.GroupRows, o.SortByProfile = 1000, true
+	var buf bytes.Buffer
+	const start, count = 300, 2500
+	if err := writeORC(context.Background(), gen, &buf, start, count, o); err != nil {
+		t.Fatal(err)
+	}
+	r, err := orc.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if n, err := r.NumStripes(); err != nil || n != 3 {
+		t.Fatalf("%d stripes (%v), want 3", n, err)
+	}
+	if r.NumRows() != count {
+		t.Fatalf("%d rows, want %d", r.NumRows(), count)
+	}
+
+	// Every record of the range appears once, ordered by profile within its
+	// stripe, with the generator's values.
+	seen := make(map[uint64]bool)
+	c := r.Select("recordIndex", "profileId", "email", "city")
+	for stripe := 0; c.Stripes(); stripe++ {
+		var prevProfile, prevIndex uint64
+		for c.Next() {
+			row := c.Row()
+			idx, profile := uint64(row[0].(int64)), uint64(row[1].(int64))
+			if idx < start || (idx-start)/1000 != uint64(stripe) {
+				t.Fatalf("stripe %d holds record %d", stripe, idx)
+			}
+			if seen[idx] {
+				t.Fatalf("record %d written twice", idx)
+			}
+			seen[idx] = true
+			if profile < prevProfile || profile == prevProfile && idx < prevIndex {
+				t.Fatalf("stripe %d: record %d of profile %d follows record %d of profile %d", stripe, idx, profile, prevIndex, prevProfile)
+			}
+			prevProfile, prevIndex = profile, idx
+			want := gen.RecordByIndex(idx)
+			if profile != want.ProfileID || row[2] != want.Email || row[3] != want.City {
+				t.Fatalf("record %d differs from the generator's", idx)
+			}
+		}
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != count {
+		t.Errorf("%d of %d records written", len(seen), count)
+	}
+}
+
+func TestORCRejectsUnknownCompression(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	o := defaultORCOptions
+	o.Compression = "snappy"
+	if err := writeORC(context.Background(), gen, io.Discard, 0, 10, o); err == nil {
+		t.Error("writeORC accepted snappy, which the ORC writer cannot produce")
+	}
+}
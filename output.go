@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Records are generated, encoded and handed to the sink in batches of this
+// size; cancellation and progress are checked between batches.
+const progressInterval = 1024
+
+// recordFormat is a streaming record encoding.
+type recordFormat struct {
+	name string
+	// header, if set, is written once before the first record.
+	header func(*Schema) []byte
+	// appendRecord appends one record, including any terminator.
+	appendRecord func(s *Schema, b []byte, rec *RawRecord) []byte
+}
+
+var (
+	formatJSONL = recordFormat{
+		name: "jsonl",
+		appendRecord: func(s *Schema, b []byte, rec *RawRecord) []byte {
+			return append(s.AppendJSON(b, rec), '\n')
+		},
+	}
+	formatCSV = recordFormat{
+		name:         "csv",
+		header:       func(s *Schema) []byte { return s.AppendCSVHeader(nil) },
+		appendRecord: (*Schema).AppendCSV,
+	}
+)
+
+var recordFormats = map[string]recordFormat{
+	formatJSONL.name: formatJSONL,
+	formatCSV.name:   formatCSV,
+}
+
+// writeJSONL streams records [start, start+count) to w, one JSON object per
+// line. progress, if set, is called with the number of records and bytes
+// written so far.
+func writeJSONL(ctx context.Context, gen RecordSource, w io.Writer, start, count uint64, progress func(written uint64, bytes int64)) (uint64, error) {
+	written, _, err := writeRecords(ctx, gen, w, formatJSONL, start, count, progress)
+	return written, err
+}
+
+// writeRecords streams records [start, start+count) to w in format f and
+// returns the number of records and bytes written.
+func writeRecords(ctx context.Context, gen RecordSource, w io.Writer, f recordFormat, start, count uint64, progress func(written uint64, bytes int64)) (uint64, int64, error) {
+	schema := gen.Schema()
+	var buf []byte
+	batch := make([]RawRecord, 0, progressInterval)
+
+	var written uint64
+	var bytesOut int64
+	if f.header != nil {
+		n, err := w.Write(f.header(schema))
+		bytesOut += int64(n)
+		if err != nil {
+			return 0, bytesOut, err
+		}
+	}
+	for written < count {
+		if err := ctx.Err(); err != nil {
+			return written, bytesOut, err
+		}
+
+		n := count - written
+		if n > progressInterval {
+			n = progressInterval
+		}
+
+		_, span := StartSpan(ctx, "generate.batch")
+		span.SetAttr("batch.start", start+written)
+		span.SetAttr("batch.count", n)
+		batch = batch[:0]
+		for i := uint64(0); i < n; i++ {
+			batch = append(batch, gen.RecordByIndex(start+written+i))
+		}
+		span.End()
+
+		_, span = StartSpan(ctx, "encode.batch")
+		span.SetAttr("format", f.name)
+		buf = buf[:0]
+		for i := range batch {
+			buf = f.appendRecord(schema, buf, &batch[i])
+		}
+		span.SetAttr("bytes", len(buf))
+		span.End()
+
+		_, span = StartSpan(ctx, "sink.write")
+		m, err := w.Write(buf)
+		span.RecordError(err)
+		span.End()
+		bytesOut += int64(m)
+		if err != nil {
+			return written, bytesOut, err
+		}
+
+		written += n
+		if progress != nil {
+			progress(written, bytesOut)
+		}
+	}
+	return written, bytesOut, nil
+}
+
+// RecordRange is a range of records in one format. It implements
+// io.WriterTo, so a range can be copied into any writer:
+//
+//	io.Copy(w, RecordRange{Source: gen, Count: 1000, Format: "csv"})
+type RecordRange struct {
+	Source       RecordSource
+	Start, Count uint64
+	// Format is a recordFormats key: "jsonl" (the default), "csv", "ejson"
+	// or "bson".
+	Format string
+}
+
+// WriteTo writes the range to w and returns the number of bytes written.
+func (r RecordRange) WriteTo(w io.Writer) (int64, error) {
+	name := r.Format
+	if name == "" {
+		name = formatJSONL.name
+	}
+	f, ok := recordFormats[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown record format %q", r.Format)
+	}
+	_, n, err := writeRecords(context.Background(), r.Source, w, f, r.Start, r.Count, nil)
+	return n, err
+}
+
+// WriteJSONL writes records [start, start+count) to w as JSON lines.
+func (g *IdempotentGenerator) WriteJSONL(w io.Writer, start, count uint64) (int64, error) {
+	return RecordRange{Source: g, Start: start, Count: count, Format: formatJSONL.name}.WriteTo(w)
+}
+
+// WriteCSV writes records [start, start+count) to w as CSV with a header
+// row.
+func (g *IdempotentGenerator) WriteCSV(w io.Writer, start, count uint64) (int64, error) {
+	return RecordRange{Source: g, Start: start, Count: count, Format: formatCSV.name}.WriteTo(w)
+}
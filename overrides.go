@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ConfigOverride is a single config value set from the command line or the
+// environment rather than from a config file. Applied overrides are recorded
+// in manifests so a run can be reproduced from the manifest alone.
+type ConfigOverride struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+const overrideEnvPrefix = "GEN_"
+
+// configKeys lists the dotted JSON paths of every settable config value,
+// derived from the GeneratorConfig struct tags.
+func configKeys() []string {
+	var keys []string
+	var walk func(prefix string, t reflect.Type)
+	walk = func(prefix string, t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) {
+				walk(name, ft)
+				continue
+			}
+			keys = append(keys, name)
+		}
+	}
+	walk("", reflect.TypeOf(GeneratorConfig{}))
+	return keys
+}
+
+// envName maps a config key to its environment variable, e.g.
+// distortions.swapFirstLast → GEN_DISTORTIONS_SWAP_FIRST_LAST.
+func envName(key string) string {
+	var b strings.Builder
+	b.WriteString(overrideEnvPrefix)
+	for i, r := range key {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && i > 0:
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// envOverrides collects GEN_* variables that name a config key.
+func envOverrides() []ConfigOverride {
+	var out []ConfigOverride
+	for _, key := range configKeys() {
+		name := envName(key)
+		if v, ok := os.LookupEnv(name); ok {
+			out = append(out, ConfigOverride{Key: key, Value: v, Source: "env " + name})
+		}
+	}
+	return out
+}
+
+// parseSetFlags turns -set key=value arguments into overrides.
+func parseSetFlags(sets []string) ([]ConfigOverride, error) {
+	out := make([]ConfigOverride, 0, len(sets))
+	for _, s := range sets {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -set %q, want key=value", s)
+		}
+		out = append(out, ConfigOverride{Key: key, Value: value, Source: "flag"})
+	}
+	return out, nil
+}
+
+// applyOverrides sets each override's key on cfg in order, so later
+// overrides win. Values are taken as JSON when they parse as JSON and as
+// plain strings otherwise; keys may index arrays, as in buckets[0].weight.
+func applyOverrides(cfg GeneratorConfig, overrides []ConfigOverride) (GeneratorConfig, error) {
+	if len(overrides) == 0 {
+		return cfg, nil
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return cfg, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return cfg, err
+	}
+
+	for _, o := range overrides {
+		var value interface{}
+		if err := json.Unmarshal([]byte(o.Value), &value); err != nil {
+			value = o.Value
+		}
+		if tree, err = setPath(tree, o.Key, value); err != nil {
+			return cfg, fmt.Errorf("override %s (%s): %w", o.Key, o.Source, err)
+		}
+	}
+
+	data, err = json.Marshal(tree)
+	if err != nil {
+		return cfg, err
+	}
+	var out GeneratorConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return cfg, fmt.Errorf("apply overrides: %w", err)
+	}
+	if err := validateConfig(out); err != nil {
+		return cfg, err
+	}
+	return out, nil
+}
+
+// setPath sets a dotted path with optional [i] indices inside a decoded JSON
+// tree and returns the updated tree.
+func setPath(node interface{}, path string, value interface{}) (interface{}, error) {
+	if path == "" {
+		return value, nil
+	}
+
+	head, rest, _ := strings.Cut(path, ".")
+	name, index, hasIndex := head, "", false
+	if open := strings.IndexByte(head, '['); open >= 0 && strings.HasSuffix(head, "]") {
+		name, index, hasIndex = head[:open], head[open+1:len(head)-1], true
+	}
+
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		if node != nil {
+			return nil, fmt.Errorf("%q is not an object", name)
+		}
+		obj = make(map[string]interface{})
+	}
+
+	if !hasIndex {
+		child, err := setPath(obj[name], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = child
+		return obj, nil
+	}
+
+	arr, ok := obj[name].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%q is not an array", name)
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i > len(arr) {
+		return nil, fmt.Errorf("index %q out of range for %q (length %d)", index, name, len(arr))
+	}
+	if i == len(arr) {
+		// One past the end appends, so -set buckets[3]={...} can add a bucket.
+		arr = append(arr, nil)
+	}
+	child, err := setPath(arr[i], rest, value)
+	if err != nil {
+		return nil, err
+	}
+	arr[i] = child
+	obj[name] = arr
+	return obj, nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Paired datasets for privacy-preserving record linkage: the same index
+// range written twice, clear and masked, line for line, plus the mapping
+// between them. The masked copy drops recordIndex for an opaque maskedId
+// and masks each field by its sensitivity — direct identifiers hashed,
+// quasi-identifiers and financial fields generalized — unless -mask says
+// otherwise, so a linkage technique can be run on the masked side and
+// scored against the clear side and the mapping.
+//
+// Methods:
+//
+//	keep        the clear value
+//	hash        a salted HMAC, equal for equal values in any field
+//	partial     strings with most characters starred: the first of an
+//	            email's local part, the last four digits of a phone
+//	generalize  dates to the year, times to the day, numbers down to a
+//	            power of ten, other strings to their first character
+//	drop        the field left out
+const (
+	maskKeep       = "keep"
+	maskHash       = "hash"
+	maskPartial    = "partial"
+	maskGeneralize = "generalize"
+	maskDrop       = "drop"
+)
+
+var maskMethods = []string{maskKeep, maskHash, maskPartial, maskGeneralize, maskDrop}
+
+// defaultMasks are the methods of fields by sensitivity; untagged fields
+// are kept.
+var defaultMasks = map[Sensitivity]string{
+	SensitivityDirect:    maskHash,
+	SensitivityQuasi:     maskGeneralize,
+	SensitivityFinancial: maskGeneralize,
+}
+
+// PairedSummary describes a paired dataset; it is written next to the
+// files as paired.json.
+type PairedSummary struct {
+	ConfigHash string `json:"configHash"`
+	Start      uint64 `json:"start"`
+	Count      uint64 `json:"count"`
+	// Masks gives the method of every field of the masked file.
+	Masks map[string]string `json:"masks"`
+	Clear string            `json:"clear"`
+	// Masked lines are in the order of Clear lines.
+	Masked  string `json:"masked"`
+	Mapping string `json:"mapping"`
+}
+
+// recordMasker writes the masked form of records.
+type recordMasker struct {
+	schema  *Schema
+	methods []string
+	hasher  *Anonymizer
+}
+
+// newRecordMasker resolves the method of every schema field: the default
+// for its sensitivity, overridden by spec, "field=method,...".
+func newRecordMasker(schema *Schema, salt, spec string) (*recordMasker, error) {
+	hasher, err := NewAnonymizer(salt, anonHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	m := &recordMasker{schema: schema, methods: make([]string, len(schema.Fields)), hasher: hasher}
+	for i, f := range schema.Fields {
+		m.methods[i] = maskKeep
+		if method, ok := defaultMasks[f.Sensitivity]; ok {
+			m.methods[i] = method
+		}
+	}
+	if spec == "" {
+		return m, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, method, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("mask %q is not field=method", entry)
+		}
+		if !slices.Contains(maskMethods, method) {
+			return nil, fmt.Errorf("unknown mask method %q, want %s", method, strings.Join(maskMethods, ", "))
+		}
+		i := slices.IndexFunc(schema.Fields, func(f FieldDescriptor) bool { return f.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("mask: unknown field %q", name)
+		}
+		if method == maskPartial && schema.Fields[i].Type != FieldString {
+			return nil, fmt.Errorf("mask: %s is not a string field, so cannot be partial", name)
+		}
+		m.methods[i] = method
+	}
+	return m, nil
+}
+
+// masks lists the method of every field.
+func (m *recordMasker) masks() map[string]string {
+	out := make(map[string]string, len(m.methods))
+	for i, f := range m.schema.Fields {
+		out[f.Name] = m.methods[i]
+	}
+	return out
+}
+
+// maskedID is the opaque identifier of record idx on the masked side.
+func (m *recordMasker) maskedID(idx uint64) string {
+	return m.hasher.Value("record:" + strconv.FormatUint(idx, 10))
+}
+
+// appendMasked appends the masked JSON object of rec.
+func (m *recordMasker) appendMasked(b []byte, rec *RawRecord) []byte {
+	b = append(b, `{"maskedId":`...)
+	b = appendJSONString(b, m.maskedID(rec.RecordIndex))
+	for i := range m.schema.Fields {
+		f := &m.schema.Fields[i]
+		v, empty := f.value(rec)
+		method := m.methods[i]
+		if f.Name == "recordIndex" || method == maskDrop || (empty && (f.Optional || f.ptr)) {
+			continue
+		}
+		b = append(b, ',')
+		b = appendJSONString(b, f.Name)
+		b = append(b, ':')
+		switch {
+		case method == maskKeep:
+			b = appendFieldValue(b, f.Type, v)
+		case method == maskHash:
+			b = appendJSONString(b, m.hasher.Value(fieldText(f.Type, v)))
+		case method == maskPartial:
+			b = appendJSONString(b, partialMask(v.String()))
+		case f.Type == FieldString:
+			b = appendJSONString(b, generalizeString(f.Name, v.String()))
+		case f.Type == FieldFloat:
+			b = appendJSONFloat(b, floorPow10(v.Float()))
+		case f.Type == FieldInt:
+			b = strconv.AppendInt(b, int64(floorPow10(float64(v.Int()))), 10)
+		default:
+			b = strconv.AppendUint(b, uint64(floorPow10(float64(v.Uint()))), 10)
+		}
+	}
+	return append(b, '}')
+}
+
+func appendFieldValue(b []byte, t FieldType, v reflect.Value) []byte {
+	switch t {
+	case FieldString:
+		return appendJSONString(b, v.String())
+	case FieldInt:
+		return strconv.AppendInt(b, v.Int(), 10)
+	case FieldUint:
+		return strconv.AppendUint(b, v.Uint(), 10)
+	default:
+		return appendJSONFloat(b, v.Float())
+	}
+}
+
+// fieldText is a field value as text, the way hashing sees it.
+func fieldText(t FieldType, v reflect.Value) string {
+	return string(appendFieldValue(nil, t, v))
+}
+
+// partialMask stars most of s: an email keeps the first character of its
+// local part and its domain, a phone its last four digits, anything else
+// its first character.
+func partialMask(s string) string {
+	star := func(s string, keep int) string {
+		n := utf8.RuneCountInString(s)
+		if n <= keep {
+			return strings.Repeat("*", n)
+		}
+		return string([]rune(s)[:keep]) + strings.Repeat("*", n-keep)
+	}
+	if local, domain, ok := strings.Cut(s, "@"); ok {
+		return star(local, 1) + "@" + domain
+	}
+	if strings.HasPrefix(s, "+") && len(s) > 4 {
+		return "+" + strings.Repeat("*", len(s)-5) + s[len(s)-4:]
+	}
+	return star(s, 1)
+}
+
+// generalizeString coarsens a string field: dates to the year, times to
+// the day, anything else to its first character.
+func generalizeString(name, s string) string {
+	switch schemaStringFormats[name] {
+	case "date":
+		if len(s) >= 4 {
+			return s[:4]
+		}
+	case "date-time":
+		if len(s) >= 10 {
+			return s[:10]
+		}
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size]
+}
+
+// floorPow10 rounds x toward zero to a power of ten: 347.5 becomes 100.
+func floorPow10(x float64) float64 {
+	if x == 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return x
+	}
+	return math.Copysign(math.Pow(10, math.Floor(math.Log10(math.Abs(x)))), x)
+}
+
+func runPaired(args []string) error {
+	fs := flag.NewFlagSet("paired", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	dir := fs.String("dir", "output/paired", "directory for the clear, masked and mapping files")
+	salt := fs.String("salt", os.Getenv("ANON_SALT"), "secret salt of hashed values and masked ids (default $ANON_SALT)")
+	spec := fs.String("mask", "", "masking methods overriding the sensitivity defaults, e.g. email=partial,city=keep; methods: "+strings.Join(maskMethods, ", "))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *salt == "" {
+		return errors.New("a -salt (or $ANON_SALT) is required; without it hashes are reversible by dictionary")
+	}
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	schema := gen.Schema()
+	masker, err := newRecordMasker(schema, *salt, *spec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	summary := PairedSummary{
+		ConfigHash: configHash(cfg),
+		Start:      *start,
+		Count:      *count,
+		Masks:      masker.masks(),
+		Clear:      "clear.jsonl",
+		Masked:     "masked.jsonl",
+		Mapping:    "mapping.jsonl",
+	}
+	var writers [3]*bufio.Writer
+	for i, name := range []string{summary.Clear, summary.Masked, summary.Mapping} {
+		file, err := os.Create(filepath.Join(*dir, name))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writers[i] = bufio.NewWriterSize(file, 1<<16)
+	}
+	clearOut, maskedOut, mappingOut := writers[0], writers[1], writers[2]
+
+	ctx, stop := interruptContext()
+	defer stop()
+	var buf []byte
+	for i := uint64(0); i < *count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		rec := gen.RecordByIndex(*start + i)
+		buf = append(schema.AppendJSON(buf[:0], &rec), '\n')
+		clearOut.Write(buf)
+		buf = append(masker.appendMasked(buf[:0], &rec), '\n')
+		maskedOut.Write(buf)
+		buf = append(buf[:0], `{"recordIndex":`...)
+		buf = strconv.AppendUint(buf, rec.RecordIndex, 10)
+		buf = append(buf, `,"maskedId":`...)
+		buf = appendJSONString(buf, masker.maskedID(rec.RecordIndex))
+		buf = append(buf, `,"profileId":`...)
+		buf = strconv.AppendUint(buf, rec.ProfileID, 10)
+		buf = append(buf, "}\n"...)
+		mappingOut.Write(buf)
+	}
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	logFor("paired").Info("wrote paired datasets", "dir", *dir, "records", *count)
+	return os.WriteFile(filepath.Join(*dir, "paired.json"), append(data, '\n'), 0644)
+}
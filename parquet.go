@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/compress/gzip"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+	"github.com/parquet-go/parquet-go/compress/uncompressed"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// Parquet output: a range as one Parquet file whose physical layout is set
+// per run — row-group and page sizes, dictionary encoding and statistics
+// per column, compression, and records ordered by profile within row
+// groups — so query-performance experiments can lay the same data out
+// differently. Columns are named after the schema's fields; Parquet orders
+// them by name.
+
+var parquetCodecs = map[string]compress.Codec{
+	"none":   &uncompressed.Codec{},
+	"snappy": &snappy.Codec{},
+	"gzip":   &gzip.Codec{},
+	"zstd":   &zstd.Codec{},
+}
+
+// ParquetOptions are the layout knobs of a Parquet file.
+type ParquetOptions struct {
+	ColumnarLayout
+	// PageBytes is the size of each column's page buffer, which bounds the
+	// uncompressed size of a data page.
+	PageBytes int
+	// Compression is a parquetCodecs key.
+	Compression string
+	// Dictionary lists the columns written with dictionary encoding, and
+	// Statistics those with min/max statistics in page headers and the
+	// column index; nil means every column.
+	Dictionary, Statistics []string
+}
+
+var defaultParquetOptions = ParquetOptions{
+	ColumnarLayout: ColumnarLayout{GroupRows: 1_000_000},
+	PageBytes:      256 << 10,
+	Compression:    "snappy",
+}
+
+// parquetSchema is the Parquet schema of s under o. Fields left out of JSON
+// when empty are optional columns.
+func parquetSchema(s *Schema, o *ParquetOptions) (*parquet.Schema, error) {
+	for _, names := range [][]string{o.Dictionary, o.Statistics} {
+		for _, name := range names {
+			if !slices.ContainsFunc(s.Fields, func(f FieldDescriptor) bool { return f.Name == name }) {
+				return nil, fmt.Errorf("no column %q in the schema", name)
+			}
+		}
+	}
+	group := make(parquet.Group, len(s.Fields))
+	for _, f := range s.Fields {
+		var node parquet.Node
+		switch f.Type {
+		case FieldString:
+			node = parquet.String()
+		case FieldInt:
+			node = parquet.Int(64)
+		case FieldUint:
+			node = parquet.Uint(64)
+		case FieldFloat:
+			node = parquet.Leaf(parquet.DoubleType)
+		}
+		if o.Dictionary == nil || slices.Contains(o.Dictionary, f.Name) {
+			node = parquet.Encoded(node, &parquet.RLEDictionary)
+		}
+		if f.Optional || f.ptr {
+			node = parquet.Optional(node)
+		}
+		group[f.Name] = node
+	}
+	return parquet.NewSchema("record", group), nil
+}
+
+// appendParquetRow appends the values of rec to row, one per column of
+// fields, which lists the schema's fields in column order.
+func appendParquetRow(row parquet.Row, fields []*FieldDescriptor, rec *RawRecord) parquet.Row {
+	for col, f := range fields {
+		v, empty := f.value(rec)
+		optional := f.Optional || f.ptr
+		if empty && optional {
+			row = append(row, parquet.NullValue().Level(0, 0, col))
+			continue
+		}
+		var pv parquet.Value
+		switch f.Type {
+		case FieldString:
+			pv = parquet.ByteArrayValue([]byte(v.String()))
+		case FieldInt:
+			pv = parquet.Int64Value(v.Int())
+		case FieldUint:
+			pv = parquet.Int64Value(int64(v.Uint()))
+		case FieldFloat:
+			pv = parquet.DoubleValue(v.Float())
+		}
+		definition := 0
+		if optional {
+			definition = 1
+		}
+		row = append(row, pv.Level(0, definition, col))
+	}
+	return row
+}
+
+// writeParquet writes [start, start+count) of g to w as a Parquet file laid
+// out by o. The config hash and the range are kept in the file's key/value
+// metadata.
+func writeParquet(ctx context.Context, g *IdempotentGenerator, w io.Writer, start, count uint64, o ParquetOptions) error {
+	codec, ok := parquetCodecs[o.Compression]
+	if !ok {
+		return fmt.Errorf("unknown compression %q", o.Compression)
+	}
+	schema := g.Schema()
+	ps, err := parquetSchema(schema, &o)
+	if err != nil {
+		return err
+	}
+	fields := make([]*FieldDescriptor, 0, len(schema.Fields))
+	for _, path := range ps.Columns() {
+		i := slices.IndexFunc(schema.Fields, func(f FieldDescriptor) bool { return f.Name == path[0] })
+		fields = append(fields, &schema.Fields[i])
+	}
+
+	opts := []parquet.WriterOption{
+		ps,
+		parquet.PageBufferSize(o.PageBytes),
+		parquet.MaxRowsPerRowGroup(int64(o.GroupRows)),
+		parquet.Compression(codec),
+		parquet.KeyValueMetadata("generator.configHash", configHash(g.cfg)),
+		parquet.KeyValueMetadata("generator.range", fmt.Sprintf("%d+%d", start, count)),
+	}
+	if o.Statistics != nil {
+		for _, f := range fields {
+			if !slices.Contains(o.Statistics, f.Name) {
+				opts = append(opts, parquet.SkipPageBounds(f.Name), parquet.SkipPageStatistics(f.Name))
+			}
+		}
+	}
+	if o.SortByProfile && slices.ContainsFunc(fields, func(f *FieldDescriptor) bool { return f.Name == "profileId" }) {
+		sorting := []parquet.SortingColumn{parquet.Ascending("profileId")}
+		if slices.ContainsFunc(fields, func(f *FieldDescriptor) bool { return f.Name == "recordIndex" }) {
+			sorting = append(sorting, parquet.Ascending("recordIndex"))
+		}
+		opts = append(opts, parquet.SortingWriterConfig(parquet.SortingColumns(sorting...)))
+	}
+	config, err := parquet.NewWriterConfig(opts...)
+	if err != nil {
+		return err
+	}
+	pw := parquet.NewWriter(w, config)
+
+	rows := make([]parquet.Row, 0, progressInterval)
+	write := func(batch []RawRecord) error {
+		rows = rows[:len(batch)]
+		for i := range batch {
+			rows[i] = appendParquetRow(rows[i][:0], fields, &batch[i])
+		}
+		_, err := pw.WriteRows(rows)
+		return err
+	}
+	if err := writeColumnarGroups(ctx, g, start, count, o.ColumnarLayout, write, pw.Flush); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// parseColumnList reads a -dictionary or -stats flag: "all", "none" or
+// comma-separated column names.
+func parseColumnList(s string) []string {
+	switch s {
+	case "all":
+		return nil
+	case "none", "":
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func runParquet(args []string) error {
+	fs := flag.NewFlagSet("parquet", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	out := fs.String("out", "output/records.parquet", "Parquet file to write")
+	d := defaultParquetOptions
+	fs.Uint64Var(&d.GroupRows, "row-group-rows", d.GroupRows, "records per row group")
+	fs.IntVar(&d.PageBytes, "page-size", d.PageBytes, "bytes of each column's page buffer, bounding the uncompressed page size")
+	fs.StringVar(&d.Compression, "compression", d.Compression, "compression codec: "+strings.Join(sortedKeys(parquetCodecs), ", "))
+	dictionary := fs.String("dictionary", "all", "columns with dictionary encoding: all, none or a comma-separated list")
+	stats := fs.String("stats", "all", "columns with page statistics and column-index bounds: all, none or a comma-separated list")
+	fs.BoolVar(&d.SortByProfile, "sort-by-profile", false, "order each row group by profileId, then recordIndex")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if d.PageBytes <= 0 {
+		return errors.New("-page-size must be positive")
+	}
+	d.Dictionary, d.Statistics = parseColumnList(*dictionary), parseColumnList(*stats)
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ctx, stop := interruptContext()
+	defer stop()
+
+	began := time.Now()
+	if err := writeParquet(ctx, gen, file, *start, *count, d); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	logFor("parquet").Info("wrote parquet", "path", *out, "records", *count, "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+func TestParquetLayout(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	o := defaultParquetOptions
+	o.GroupRows, o.SortByProfile = 1000, true
+	o.Dictionary, o.Statistics = []string{"city"}, []string{"profileId"}
+	var buf bytes.Buffer
+	const start, count = 300, 2500
+	if err := writeParquet(context.Background(), gen, &buf, start, count, o); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, _ := f.Lookup("generator.configHash"); hash != configHash(gen.cfg) {
+		t.Errorf("configHash metadata is %q", hash)
+	}
+
+	columns := f.Schema().Columns()
+	column := func(name string) int {
+		i := slices.IndexFunc(columns, func(path []string) bool { return path[0] == name })
+		if i < 0 {
+			t.Fatalf("no %s column", name)
+		}
+		return i
+	}
+	profileCol, indexCol, cityCol, emailCol := column("profileId"), column("recordIndex"), column("city"), column("email")
+
+	groups := f.Metadata().RowGroups
+	if len(groups) != 3 || groups[0].NumRows != 1000 || groups[2].NumRows != 500 {
+		t.Fatalf("%d row groups, want 1000, 1000 and 500 rows", len(groups))
+	}
+	wantSorting := []format.SortingColumn{{ColumnIdx: int32(profileCol)}, {ColumnIdx: int32(indexCol)}}
+	if !slices.Equal(groups[0].SortingColumns, wantSorting) {
+		t.Errorf("sorting columns are %+v, want %+v", groups[0].SortingColumns, wantSorting)
+	}
+	chunks := groups[0].Columns
+	if !slices.Contains(chunks[cityCol].MetaData.Encoding, format.RLEDictionary) {
+		t.Errorf("city is encoded %v, want a dictionary", chunks[cityCol].MetaData.Encoding)
+	}
+	if slices.Contains(chunks[emailCol].MetaData.Encoding, format.RLEDictionary) {
+		t.Errorf("email is dictionary encoded; only city was asked for")
+	}
+	if chunks[profileCol].MetaData.Statistics.MinValue == nil {
+		t.Error("profileId has no statistics")
+	}
+	if chunks[emailCol].MetaData.Statistics.MinValue != nil {
+		t.Error("email has statistics; only profileId was asked for")
+	}
+
+	// Every record of the range appears once, ordered by profile within its
+	// row group, with the generator's values.
+	seen := make(map[uint64]bool)
+	for i, rg := range f.RowGroups() {
+		rows := rg.Rows()
+		buf := make([]parquet.Row, 100)
+		var prevProfile, prevIndex uint64
+		for {
+			k, err := rows.ReadRows(buf)
+			for _, row := range buf[:k] {
+				profile, idx := uint64(row[profileCol].Int64()), uint64(row[indexCol].Int64())
+				if idx < start || (idx-start)/1000 != uint64(i) {
+					t.Fatalf("row group %d holds record %d", i, idx)
+				}
+				if seen[idx] {
+					t.Fatalf("record %d written twice", idx)
+				}
+				seen[idx] = true
+				if profile < prevProfile || profile == prevProfile && idx < prevIndex {
+					t.Fatalf("row group %d: record %d of profile %d follows record %d of profile %d", i, idx, profile, prevIndex, prevProfile)
+				}
+				prevProfile, prevIndex = profile, idx
+				want := gen.RecordByIndex(idx)
+				if profile != want.ProfileID || row[emailCol].String() != want.Email || row[cityCol].String() != want.City {
+					t.Fatalf("record %d differs from the generator's", idx)
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		rows.Close()
+	}
+	if len(seen) != count {
+		t.Errorf("%d of %d records written", len(seen), count)
+	}
+}
+
+func TestParquetRejectsUnknownColumns(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	o := defaultParquetOptions
+	o.Dictionary = []string{"nope"}
+	if err := writeParquet(context.Background(), gen, io.Discard, 0, 10, o); err == nil {
+		t.Error("writeParquet accepted a dictionary column not in the schema")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"iter"
+	"math/bits"
+)
+
+// Shuffled emission: records of a range written in a pseudorandom but
+// deterministic order, so consumers cannot lean on recordIndex rising with
+// position. A keyed Feistel network permutes [0, n) without a table — it
+// works on the smallest even-width power of two covering n and walks the
+// cycle until it lands back inside the range — so any position maps to its
+// logical index in O(1) and a shuffled file can be resumed or sharded like
+// an ordered one. Content stays tied to the logical index: record i is the
+// same wherever it is written.
+
+// feistelRounds is enough for a well-mixed order; the permutation is not
+// meant to withstand cryptanalysis.
+const feistelRounds = 4
+
+// Permutation is a keyed bijection of [0, n).
+type Permutation struct {
+	n        uint64
+	halfBits uint
+	keys     [feistelRounds]uint64
+}
+
+func NewPermutation(n, key uint64) *Permutation {
+	p := &Permutation{n: n}
+	width := uint(bits.Len64(max(n, 2) - 1))
+	p.halfBits = (width + 1) / 2
+	rng := NewSplitMix64(fnv1a64("permutation") ^ key)
+	for i := range p.keys {
+		p.keys[i] = rng.NextUint64()
+	}
+	return p
+}
+
+// feistel permutes [0, 4^halfBits).
+func (p *Permutation) feistel(x uint64) uint64 {
+	mask := uint64(1)<<p.halfBits - 1
+	left, right := x>>p.halfBits, x&mask
+	for _, k := range p.keys {
+		f := NewSplitMix64(right ^ k).NextUint64()
+		left, right = right, (left^f)&mask
+	}
+	return left<<p.halfBits | right
+}
+
+// At returns the element at position i < n. The domain is under 4n, so the
+// cycle walk takes fewer than four steps on average.
+func (p *Permutation) At(i uint64) uint64 {
+	x := p.feistel(i)
+	for x >= p.n {
+		x = p.feistel(x)
+	}
+	return x
+}
+
+// ShuffleInfo records a shuffled emission order in manifests: the key and
+// the whole range permuted, of which a shard's file is a slice.
+type ShuffleInfo struct {
+	Key   uint64 `json:"key"`
+	Start uint64 `json:"start"`
+	Count uint64 `json:"count"`
+}
+
+// permutedSource emits the records of [start, start+count) of src in the
+// order of a permutation: position start+i holds logical index
+// start+perm.At(i). Positions outside the range pass through.
+type permutedSource struct {
+	RecordSource
+	start, count uint64
+	perm         *Permutation
+}
+
+var _ RecordSource = (*permutedSource)(nil)
+
+// shuffled returns src with [start, start+count) emitted in the order of
+// key, mixed with the config seed.
+func shuffled(src RecordSource, start, count, key, seed uint64) *permutedSource {
+	return &permutedSource{RecordSource: src, start: start, count: count, perm: NewPermutation(count, withSeed(key, seed))}
+}
+
+func (s *permutedSource) RecordByIndex(pos uint64) RawRecord {
+	if pos-s.start < s.count && pos >= s.start {
+		return s.RecordSource.RecordByIndex(s.start + s.perm.At(pos-s.start))
+	}
+	return s.RecordSource.RecordByIndex(pos)
+}
+
+func (s *permutedSource) Records(start, count uint64) iter.Seq[RawRecord] {
+	return func(yield func(RawRecord) bool) {
+		for i := uint64(0); i < count; i++ {
+			if !yield(s.RecordByIndex(start + i)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermutationIsBijection(t *testing.T) {
+	// Widths 1 to 11 bits, odd ones padded to an even-width domain, with n
+	// at, just under and just over powers of two.
+	for _, n := range []uint64{1, 2, 3, 4, 5, 7, 8, 9, 31, 32, 33, 100, 127, 128, 129, 1000, 1023, 1024, 1025, 2047} {
+		for _, key := range []uint64{0, 1, 0xdeadbeef} {
+			p := NewPermutation(n, key)
+			seen := make([]bool, n)
+			for i := uint64(0); i < n; i++ {
+				x := p.At(i)
+				if x >= n {
+					t.Fatalf("n=%d key=%d: At(%d) = %d, out of range", n, key, i, x)
+				}
+				if seen[x] {
+					t.Fatalf("n=%d key=%d: %d appears twice", n, key, x)
+				}
+				seen[x] = true
+			}
+		}
+	}
+}
+
+func TestPermutationDependsOnKey(t *testing.T) {
+	a, b := NewPermutation(1000, 1), NewPermutation(1000, 2)
+	same, fixed := 0, 0
+	for i := uint64(0); i < 1000; i++ {
+		if a.At(i) == b.At(i) {
+			same++
+		}
+		if a.At(i) == i {
+			fixed++
+		}
+	}
+	if same > 50 || fixed > 50 {
+		t.Errorf("of 1000 positions %d agree between keys and %d are fixed points", same, fixed)
+	}
+}
+
+func TestPermutedSourceRoundTrip(t *testing.T) {
+	gen := mustNewGenerator(cloneConfig(defaultConfig))
+	const start, count = 40, 333
+	src := shuffled(gen, start, count, 7, gen.cfg.Seed)
+
+	seen := make(map[uint64]bool)
+	pos := uint64(start - 5)
+	for rec := range src.Records(pos, count+10) {
+		idx := rec.RecordIndex
+		switch inRange := pos >= start && pos < start+count; {
+		case !inRange && idx != pos:
+			t.Errorf("position %d outside the range holds record %d", pos, idx)
+		case inRange && (idx < start || idx >= start+count):
+			t.Errorf("position %d holds record %d from outside the range", pos, idx)
+		case inRange && seen[idx]:
+			t.Errorf("record %d emitted twice", idx)
+		}
+		seen[idx] = true
+		if want := gen.RecordByIndex(idx); !reflect.DeepEqual(rec, want) {
+			t.Errorf("position %d: record %d differs from the ordered one", pos, idx)
+		}
+		if again := src.RecordByIndex(pos); !reflect.DeepEqual(rec, again) {
+			t.Errorf("position %d: Records and RecordByIndex disagree", pos)
+		}
+		pos++
+	}
+	for idx := uint64(start); idx < start+count; idx++ {
+		if !seen[idx] {
+			t.Errorf("record %d never emitted", idx)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+// PII classification: every field that carries personal or financial data
+// is tagged with its sensitivity, by construction rather than by scanning,
+// and the tags travel with the exported schemas and DDL — x-sensitivity in
+// JSON Schema, a sensitivity attribute in Avro, a sensitivity parameter in
+// Kafka Connect schemas, column descriptions in BigQuery, comments in SQL
+// and the table comment in CQL — so governance tooling and masking policies
+// can be tested against a dataset whose classification is known.
+
+// Sensitivity is the privacy class of a field.
+type Sensitivity string
+
+const (
+	// SensitivityDirect fields identify a person on their own.
+	SensitivityDirect Sensitivity = "direct-identifier"
+	// SensitivityQuasi fields identify a person in combination.
+	SensitivityQuasi Sensitivity = "quasi-identifier"
+	// SensitivityFinancial fields describe a person's money.
+	SensitivityFinancial Sensitivity = "financial"
+)
+
+// fieldSensitivity tags the record fields; fields not listed carry no
+// personal data. A listed name without a field fails at startup.
+var fieldSensitivity = map[string]Sensitivity{
+	"profileId":      SensitivityDirect,
+	"firstName":      SensitivityDirect,
+	"lastName":       SensitivityDirect,
+	"email":          SensitivityDirect,
+	"emailCanonical": SensitivityDirect,
+	"phone":          SensitivityDirect,
+	"login":          SensitivityDirect,
+	// A sole trader's INN and OGRNIP identify the person.
+	"inn":  SensitivityDirect,
+	"ogrn": SensitivityDirect,
+
+	"city":         SensitivityQuasi,
+	"pointOfSale":  SensitivityQuasi,
+	"timezone":     SensitivityQuasi,
+	"birthDate":    SensitivityQuasi,
+	"gender":       SensitivityQuasi,
+	"device":       SensitivityQuasi,
+	"sessionId":    SensitivityQuasi,
+	"jurisdiction": SensitivityQuasi,
+
+	"amount":   SensitivityFinancial,
+	"category": SensitivityFinancial,
+	"refundOf": SensitivityFinancial,
+}
+
+// sensitivityComment is the column comment of a tagged field, empty for
+// untagged ones.
+func sensitivityComment(f *FieldDescriptor) string {
+	if f.Sensitivity == "" {
+		return ""
+	}
+	return "sensitivity: " + string(f.Sensitivity)
+}
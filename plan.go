@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RangePlan divides a record range among parallel consumers. Plans are
+// written by the plan command and read back by generate -plan, or by any
+// external job that only needs the sub-range of its own index — such as a
+// thin Spark or Java reader that parallelizes the splits across executors,
+// fetching each from its URL or reading its file.
+type RangePlan struct {
+	ConfigHash string `json:"configHash"`
+	Start      uint64 `json:"start"`
+	Count      uint64 `json:"count"`
+	// Align is the multiple of record indices every inner boundary sits on.
+	Align uint64 `json:"align"`
+	// Format is the format of the ranges' files, when they have paths.
+	Format string         `json:"format,omitempty"`
+	Ranges []PlannedRange `json:"ranges"`
+}
+
+// PlannedRange is one split. Count is exact: every index is one record.
+type PlannedRange struct {
+	Index int    `json:"index"`
+	Start uint64 `json:"start"`
+	Count uint64 `json:"count"`
+	// URL is the first page of the range on a serve instance, pinned to
+	// the plan's config; follow nextCursor for the rest.
+	URL string `json:"url,omitempty"`
+	// Path is the file generate -plan writes the range to.
+	Path string `json:"path,omitempty"`
+}
+
+// splitRange cuts [start, start+count) into parts contiguous sub-ranges of
+// balanced size. Inner boundaries are rounded to the nearest multiple of
+// align, so with an align of a file, shard or fraud block size no unit is
+// cut in two; with align 1 the ranges are those of shardRange. Rounding may
+// leave ranges empty when count is small relative to parts*align.
+func splitRange(start, count uint64, parts int, align uint64) []PlannedRange {
+	end := start + count
+	bounds := make([]uint64, parts+1)
+	bounds[parts] = end
+	for i := 1; i < parts; i++ {
+		b, _ := shardRange(start, count, i, parts)
+		if align > 1 {
+			b = (b + align/2) / align * align
+		}
+		bounds[i] = min(max(b, bounds[i-1]), end)
+	}
+	bounds[0] = start
+	ranges := make([]PlannedRange, parts)
+	for i := range ranges {
+		ranges[i] = PlannedRange{Index: i, Start: bounds[i], Count: bounds[i+1] - bounds[i]}
+	}
+	return ranges
+}
+
+func readRangePlan(path string) (RangePlan, error) {
+	var p RangePlan
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// planRange returns range index of a plan made for cfg.
+func planRange(path string, index int, cfg GeneratorConfig) (PlannedRange, error) {
+	p, err := readRangePlan(path)
+	if err != nil {
+		return PlannedRange{}, err
+	}
+	if p.ConfigHash != configHash(cfg) {
+		return PlannedRange{}, fmt.Errorf("plan %s was made for config %s, not %s", path, p.ConfigHash, configHash(cfg))
+	}
+	if index < 0 || index >= len(p.Ranges) {
+		return PlannedRange{}, fmt.Errorf("range index %d out of range for a plan of %d ranges", index, len(p.Ranges))
+	}
+	return p.Ranges[index], nil
+}
+
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	parts := fs.Int("parts", 0, "number of sub-ranges")
+	align := fs.Uint64("align", 1, "put inner boundaries on multiples of this many indices")
+	alignFraud := fs.Bool("align-fraud-blocks", false, "align to the config's fraud block size so no incident is split")
+	output := fs.String("output", "-", "plan file, \"-\" for stdout")
+	endpoint := fs.String("endpoint", "", "base URL of a serve instance; gives each range the URL of its first record page")
+	dataset := fs.String("dataset", "", "with -endpoint, the served dataset (default the default dataset)")
+	pageSize := fs.Int("page-size", maxPageSize, "with -endpoint, records per page")
+	files := fs.String("files", "", "path template of the range files, {shard} replaced as generate -plan -output does, e.g. output/part-{shard}.jsonl")
+	formatName := fs.String("format", "jsonl", "with -files, the format of the range files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataset != "" && *endpoint == "" {
+		return errors.New("-dataset needs -endpoint")
+	}
+	if *pageSize <= 0 || *pageSize > maxPageSize {
+		return fmt.Errorf("-page-size must be in [1, %d]", maxPageSize)
+	}
+	if _, ok := recordFormats[*formatName]; !ok {
+		return fmt.Errorf("unknown format %q", *formatName)
+	}
+	if *parts <= 0 {
+		return errors.New("-parts must be positive")
+	}
+	if *align == 0 {
+		return errors.New("-align must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	if *alignFraud {
+		if cfg.Fraud == nil {
+			return errors.New("-align-fraud-blocks: the config has no fraud section")
+		}
+		*align = cfg.Fraud.BlockSize
+	}
+
+	plan := RangePlan{
+		ConfigHash: configHash(cfg),
+		Start:      *start,
+		Count:      *count,
+		Align:      *align,
+		Ranges:     splitRange(*start, *count, *parts, *align),
+	}
+	if *files != "" {
+		plan.Format = *formatName
+	}
+	for i := range plan.Ranges {
+		r := &plan.Ranges[i]
+		if *endpoint != "" {
+			r.URL = rangeURL(*endpoint, *dataset, plan.ConfigHash, *r, *pageSize)
+		}
+		if *files != "" {
+			r.Path = strings.ReplaceAll(*files, "{shard}", fmt.Sprintf("%05d", r.Index))
+		}
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0644)
+}
+
+// rangeURL is the first record page of r on the serve instance at
+// endpoint.
+func rangeURL(endpoint, dataset, hash string, r PlannedRange, pageSize int) string {
+	path := "/records"
+	if dataset != "" {
+		path = "/datasets/" + url.PathEscape(dataset) + "/records"
+	}
+	q := url.Values{}
+	q.Set("start", strconv.FormatUint(r.Start, 10))
+	q.Set("count", strconv.FormatUint(r.Count, 10))
+	q.Set("pageSize", strconv.Itoa(pageSize))
+	q.Set("version", hash)
+	return strings.TrimSuffix(endpoint, "/") + path + "?" + q.Encode()
+}
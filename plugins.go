@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Plugins: WebAssembly modules named by path in the config that add
+// proprietary field generators and distortions without a fork. A module
+// exports its linear memory and
+//
+//	alloc(size i32) i32               // a buffer for the input, valid until the next call
+//	fields(ptr i32, len i32) i64      // every record
+//	distort(ptr i32, len i32) i64     // at rate
+//
+// and either or both of fields and distort. The input is a JSON object of
+// the record's string fields and the custom fields of the config, empty
+// ones included; the result packs the address of a JSON object into the
+// high 32 bits and its length into the low ones. Its keys are the fields
+// to change; other keys are ignored. Plugins run in config order after the
+// built-in derivation, before erasure, field availability and canaries.
+//
+// The host owns determinism: the only source of randomness is the import
+//
+//	gen.rand() i64
+//
+// seeded from the config seed, the record index and the plugin's position.
+// Modules may import WASI, which the host answers without a filesystem,
+// environment or network, with clocks stopped at the Unix epoch and random
+// bytes of zero, so the same module yields the same data everywhere.
+// Instances are pooled and reused across records, so a plugin must not
+// keep state between calls. Go modules are built with
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o plugin.wasm
+//
+// and //go:wasmexport and //go:wasmimport directives; testdata/wasmplugin
+// is an example. Pin SHA256 so a rebuilt plugin cannot silently change the
+// data a config hash stands for.
+//
+// Only NewIdempotentGenerator compiles plugins; parsing and validating a
+// config never does, and serve refuses configs naming plugins on its admin
+// API unless started with -allow-plugins, since they name files on its
+// host.
+
+type PluginConfig struct {
+	Path string `json:"path"`
+	// SHA256 is the hex digest the plugin file must have, if set.
+	SHA256 string `json:"sha256,omitempty"`
+	// Rate is the share of records passed to distort.
+	Rate float64 `json:"rate,omitempty"`
+	// Fields declares the custom fields the plugin sets. They are written
+	// after the built-in fields, in config order, and are left out of a
+	// record while empty.
+	Fields []PluginField `json:"fields,omitempty"`
+}
+
+// PluginField is a custom field a plugin adds to records.
+type PluginField struct {
+	Name        string      `json:"name"`
+	Sensitivity Sensitivity `json:"sensitivity,omitempty"`
+}
+
+// CustomField is the value of a custom field on a record.
+type CustomField struct {
+	Name  string
+	Value string
+}
+
+// pluginMemoryLimitPages caps a plugin instance at 256 MiB.
+const pluginMemoryLimitPages = 4096
+
+// pluginRuntime compiles and runs every plugin; it is created on first use.
+var pluginRuntime = sync.OnceValues(func() (wazero.Runtime, error) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithMemoryLimitPages(pluginMemoryLimitPages))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		return nil, err
+	}
+	_, err := r.NewHostModuleBuilder("gen").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context) uint64 {
+			return ctx.Value(pluginRandKey{}).(func() uint64)()
+		}).
+		Export("rand").
+		Instantiate(ctx)
+	return r, err
+})
+
+// pluginRandKey carries the stream of the current plugin call to gen.rand.
+type pluginRandKey struct{}
+
+// zeroReader answers WASI random_get.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// pluginModuleConfig is the sandbox of every instance. The monotonic clock
+// reads 1 rather than 0, which the Go runtime refuses.
+var pluginModuleConfig = wazero.NewModuleConfig().
+	WithName("").
+	WithStartFunctions("_initialize").
+	WithRandSource(zeroReader{}).
+	WithWalltime(func() (int64, int32) { return 0, 0 }, 1).
+	WithNanotime(func() int64 { return 1 }, 1).
+	WithNanosleep(func(int64) {})
+
+// loadedPlugin is a compiled plugin with a pool of idle instances.
+type loadedPlugin struct {
+	path            string
+	module          wazero.CompiledModule
+	fields, distort bool
+
+	mu   sync.Mutex
+	idle []api.Module
+}
+
+// compiledPlugins caches plugins by digest, so configs naming the same
+// module share one compilation and one instance pool.
+var compiledPlugins = struct {
+	sync.Mutex
+	byDigest map[string]*loadedPlugin
+}{byDigest: map[string]*loadedPlugin{}}
+
+// load checks the plugin's digest and compiles it.
+func (p PluginConfig) load() (*loadedPlugin, error) {
+	wasm, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(wasm)
+	digest := hex.EncodeToString(sum[:])
+	if p.SHA256 != "" && digest != p.SHA256 {
+		return nil, fmt.Errorf("plugin %s has sha256 %s, want %s", p.Path, digest, p.SHA256)
+	}
+
+	compiledPlugins.Lock()
+	defer compiledPlugins.Unlock()
+	if lp := compiledPlugins.byDigest[digest]; lp != nil {
+		return lp, nil
+	}
+	r, err := pluginRuntime()
+	if err != nil {
+		return nil, err
+	}
+	module, err := r.CompileModule(context.Background(), wasm)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Path, err)
+	}
+	lp := &loadedPlugin{path: p.Path, module: module}
+	if err := lp.check(); err != nil {
+		module.Close(context.Background())
+		return nil, err
+	}
+	compiledPlugins.byDigest[digest] = lp
+	return lp, nil
+}
+
+// check inspects the exports of a compiled plugin and starts an instance,
+// so a module that cannot run fails here rather than on the first record.
+func (lp *loadedPlugin) check() error {
+	exports := lp.module.ExportedFunctions()
+	i32, i64 := api.ValueTypeI32, api.ValueTypeI64
+	// exported reports whether the module exports name with the signature
+	// params -> result.
+	exported := func(name string, result api.ValueType, params ...api.ValueType) (bool, error) {
+		def, ok := exports[name]
+		if !ok {
+			return false, nil
+		}
+		if !slices.Equal(def.ParamTypes(), params) || !slices.Equal(def.ResultTypes(), []api.ValueType{result}) {
+			return false, fmt.Errorf("plugin %s: %s has the wrong signature", lp.path, name)
+		}
+		return true, nil
+	}
+	if ok, err := exported("alloc", i32, i32); err != nil || !ok {
+		return errors.Join(err, fmt.Errorf("plugin %s does not export alloc(i32) i32", lp.path))
+	}
+	var err error
+	if lp.fields, err = exported("fields", i64, i32, i32); err != nil {
+		return err
+	}
+	if lp.distort, err = exported("distort", i64, i32, i32); err != nil {
+		return err
+	}
+	if !lp.fields && !lp.distort {
+		return fmt.Errorf("plugin %s exports neither fields nor distort", lp.path)
+	}
+	if _, ok := lp.module.ExportedMemories()["memory"]; !ok {
+		return fmt.Errorf("plugin %s does not export its memory", lp.path)
+	}
+	m, err := lp.instance()
+	if err != nil {
+		return err
+	}
+	lp.release(m)
+	return nil
+}
+
+// instance takes an idle instance or starts a new one.
+func (lp *loadedPlugin) instance() (api.Module, error) {
+	lp.mu.Lock()
+	if n := len(lp.idle); n > 0 {
+		m := lp.idle[n-1]
+		lp.idle = lp.idle[:n-1]
+		lp.mu.Unlock()
+		return m, nil
+	}
+	lp.mu.Unlock()
+	r, err := pluginRuntime()
+	if err != nil {
+		return nil, err
+	}
+	m, err := r.InstantiateModule(context.Background(), lp.module, pluginModuleConfig)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", lp.path, err)
+	}
+	return m, nil
+}
+
+func (lp *loadedPlugin) release(m api.Module) {
+	lp.mu.Lock()
+	lp.idle = append(lp.idle, m)
+	lp.mu.Unlock()
+}
+
+// call runs the exported function fn on input and returns its output.
+// Instances that fail are dropped rather than reused.
+func (lp *loadedPlugin) call(fn string, input []byte, rand func() uint64) ([]byte, error) {
+	m, err := lp.instance()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), pluginRandKey{}, rand)
+	res, err := m.ExportedFunction("alloc").Call(ctx, uint64(len(input)))
+	if err != nil {
+		m.Close(ctx)
+		return nil, err
+	}
+	ptr := uint32(res[0])
+	if !m.Memory().Write(ptr, input) {
+		m.Close(ctx)
+		return nil, fmt.Errorf("alloc(%d) returned %#x, outside memory", len(input), ptr)
+	}
+	if res, err = m.ExportedFunction(fn).Call(ctx, uint64(ptr), uint64(len(input))); err != nil {
+		m.Close(ctx)
+		return nil, err
+	}
+	out, ok := m.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		m.Close(ctx)
+		return nil, fmt.Errorf("%s returned %d bytes at %#x, outside memory", fn, uint32(res[0]), uint32(res[0]>>32))
+	}
+	out = bytes.Clone(out)
+	lp.release(m)
+	return out, nil
+}
+
+// validatePlugins checks the plugin entries of a config without opening
+// them: parsing a config must never run a plugin's code.
+func validatePlugins(plugins []PluginConfig) error {
+	seen := map[string]bool{}
+	for i, p := range plugins {
+		if p.Path == "" {
+			return fmt.Errorf("plugins[%d].path is required", i)
+		}
+		if p.Rate < 0 || p.Rate > 1 {
+			return fmt.Errorf("plugins[%d].rate must be in [0, 1]", i)
+		}
+		for j, f := range p.Fields {
+			switch _, builtin := recordFieldIndex[f.Name]; {
+			case f.Name == "":
+				return fmt.Errorf("plugins[%d].fields[%d].name is required", i, j)
+			case builtin:
+				return fmt.Errorf("plugins[%d].fields[%d]: %q is a built-in field", i, j, f.Name)
+			case seen[f.Name]:
+				return fmt.Errorf("plugins[%d].fields[%d]: %q is declared twice", i, j, f.Name)
+			}
+			seen[f.Name] = true
+			switch f.Sensitivity {
+			case "", SensitivityDirect, SensitivityQuasi, SensitivityFinancial:
+			default:
+				return fmt.Errorf("plugins[%d].fields[%d]: unknown sensitivity %q", i, j, f.Sensitivity)
+			}
+		}
+	}
+	return nil
+}
+
+// customFields lists the custom fields the plugins of a config declare,
+// in order.
+func customFields(plugins []PluginConfig) []PluginField {
+	var out []PluginField
+	for _, p := range plugins {
+		out = append(out, p.Fields...)
+	}
+	return out
+}
+
+// loadPlugins compiles the plugins of a config; NewIdempotentGenerator is
+// the only caller.
+func loadPlugins(plugins []PluginConfig) ([]*loadedPlugin, error) {
+	out := make([]*loadedPlugin, len(plugins))
+	for i, p := range plugins {
+		lp, err := p.load()
+		if err != nil {
+			return nil, fmt.Errorf("plugins[%d]: %w", i, err)
+		}
+		if p.Rate > 0 && !lp.distort {
+			return nil, fmt.Errorf("plugins[%d]: rate is set but %s exports no distort", i, p.Path)
+		}
+		out[i] = lp
+	}
+	return out, nil
+}
+
+// stringFields are the built-in record fields plugins see.
+var stringFields = func() []FieldDescriptor {
+	var out []FieldDescriptor
+	for _, f := range recordFields {
+		if f.Type == FieldString {
+			out = append(out, f)
+		}
+	}
+	return out
+}()
+
+// pluginRand returns a host-seeded stream for one plugin call.
+func pluginRand(kind string, position int, idx, seed uint64) func() uint64 {
+	rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("plugin:%d:%s:%d", position, kind, idx)), seed))
+	return rng.NextUint64
+}
+
+// applyPlugins runs the plugins of cfg on rec. A plugin that fails panics:
+// a record cannot be built without it.
+func applyPlugins(rec *RawRecord, plugins []*loadedPlugin, cfg GeneratorConfig) {
+	if len(plugins) == 0 {
+		return
+	}
+	for _, f := range customFields(cfg.Plugins) {
+		rec.Custom = append(rec.Custom, CustomField{Name: f.Name})
+	}
+	v := reflect.ValueOf(rec).Elem()
+	fields := make(map[string]string, len(stringFields)+len(rec.Custom))
+	for i, lp := range plugins {
+		call := func(fn string) {
+			for _, f := range stringFields {
+				fields[f.Name] = v.Field(f.index).String()
+			}
+			for _, c := range rec.Custom {
+				fields[c.Name] = c.Value
+			}
+			input, _ := json.Marshal(fields)
+			output, err := lp.call(fn, input, pluginRand(fn, i, rec.RecordIndex, cfg.Seed))
+			if err != nil {
+				panic(fmt.Sprintf("plugins[%d] %s: %s: %v", i, lp.path, fn, err))
+			}
+			clear(fields)
+			if err := json.Unmarshal(output, &fields); err != nil {
+				panic(fmt.Sprintf("plugins[%d] %s: %s returned %v", i, lp.path, fn, err))
+			}
+			for _, f := range stringFields {
+				if s, ok := fields[f.Name]; ok {
+					v.Field(f.index).SetString(s)
+				}
+			}
+			for j := range rec.Custom {
+				if s, ok := fields[rec.Custom[j].Name]; ok {
+					rec.Custom[j].Value = s
+				}
+			}
+			clear(fields)
+		}
+		if lp.fields {
+			call("fields")
+		}
+		if lp.distort && cfg.Plugins[i].Rate > 0 {
+			rng := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("plugin:%d:rate:%d", i, rec.RecordIndex)), cfg.Seed))
+			if rng.NextFloat() < cfg.Plugins[i].Rate {
+				call("distort")
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var examplePlugin struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// buildExamplePlugin compiles testdata/wasmplugin once per test run.
+func buildExamplePlugin(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("building the example plugin takes a while")
+	}
+	examplePlugin.once.Do(func() {
+		dir, err := os.MkdirTemp("", "wasmplugin")
+		if err != nil {
+			examplePlugin.err = err
+			return
+		}
+		examplePlugin.path = filepath.Join(dir, "wasmplugin.wasm")
+		cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", examplePlugin.path, "./testdata/wasmplugin")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			examplePlugin.err = &exec.ExitError{Stderr: out}
+		}
+	})
+	if examplePlugin.err != nil {
+		t.Fatalf("build example plugin: %v", examplePlugin.err)
+	}
+	return examplePlugin.path
+}
+
+func pluginConfig(path string) GeneratorConfig {
+	cfg := cloneConfig(defaultConfig)
+	cfg.Plugins = []PluginConfig{{
+		Path:   path,
+		Rate:   0.5,
+		Fields: []PluginField{{Name: "loyaltyId", Sensitivity: SensitivityDirect}},
+	}}
+	return cfg
+}
+
+func TestWasmPlugin(t *testing.T) {
+	cfg := pluginConfig(buildExamplePlugin(t))
+	if err := validateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := mustNewGenerator(cloneConfig(defaultConfig))
+
+	loyaltyID := regexp.MustCompile(`,"loyaltyId":"L\d{8}"}$`)
+	distorted := 0
+	for idx := uint64(0); idx < 200; idx++ {
+		rec, want := gen.RecordByIndex(idx), plain.RecordByIndex(idx)
+		line := gen.Schema().AppendJSON(nil, &rec)
+		if !loyaltyID.Match(line) {
+			t.Fatalf("record %d: no loyaltyId at the end: %s", idx, line)
+		}
+		if marshalled, _ := rec.MarshalJSON(); !bytes.Equal(marshalled, line) {
+			t.Fatalf("record %d: MarshalJSON\n%s\ndiffers from the schema encoding\n%s", idx, marshalled, line)
+		}
+		switch rec.LastName {
+		case want.LastName:
+		case strings.ToUpper(want.LastName):
+			distorted++
+		default:
+			t.Fatalf("record %d: lastName %q, want %q or upper-cased", idx, rec.LastName, want.LastName)
+		}
+	}
+	if distorted < 50 || distorted > 150 {
+		t.Errorf("%d of 200 records distorted at rate 0.5", distorted)
+	}
+
+	// Pooled instances and parallel iteration must not change the data.
+	again, err := NewIdempotentGenerator(cfg, WithParallelism(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, par := gen.Iterate(0, 200), again.Iterate(0, 200)
+	for i := range seq {
+		a, b := gen.Schema().AppendJSON(nil, &seq[i]), again.Schema().AppendJSON(nil, &par[i])
+		if !bytes.Equal(a, b) {
+			t.Fatalf("record %d differs between runs:\n%s\n%s", i, a, b)
+		}
+	}
+}
+
+func TestWasmPluginSchema(t *testing.T) {
+	cfg := pluginConfig("plugin.wasm")
+	cfg.OutputFields = []string{"recordIndex", "loyaltyId"}
+	schema, err := newSchema(cfg.OutputFields, customFields(cfg.Plugins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := RawRecord{RecordIndex: 3, Custom: []CustomField{{Name: "loyaltyId", Value: "L1"}}}
+	if got := string(schema.AppendJSON(nil, &rec)); got != `{"recordIndex":3,"loyaltyId":"L1"}` {
+		t.Errorf("AppendJSON = %s", got)
+	}
+	if f := schema.Fields[1]; f.Sensitivity != SensitivityDirect {
+		t.Errorf("loyaltyId sensitivity = %q", f.Sensitivity)
+	}
+}
+
+func TestPluginConfigErrors(t *testing.T) {
+	for _, c := range []struct {
+		plugin PluginConfig
+		want   string
+	}{
+		{PluginConfig{}, "path is required"},
+		{PluginConfig{Path: "p.wasm", Rate: 2}, "rate must be in [0, 1]"},
+		{PluginConfig{Path: "p.wasm", Fields: []PluginField{{Name: "email"}}}, "built-in field"},
+		{PluginConfig{Path: "p.wasm", Fields: []PluginField{{Name: "a"}, {Name: "a"}}}, "declared twice"},
+		{PluginConfig{Path: "p.wasm", Fields: []PluginField{{Name: "a", Sensitivity: "secret"}}}, "unknown sensitivity"},
+	} {
+		err := validatePlugins([]PluginConfig{c.plugin})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%+v: %v, want %q", c.plugin, err, c.want)
+		}
+	}
+
+	notWasm := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(notWasm, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewIdempotentGenerator(pluginConfig(notWasm)); err == nil {
+		t.Error("a file that is not WebAssembly loaded")
+	}
+	cfg := pluginConfig(notWasm)
+	cfg.Plugins[0].SHA256 = strings.Repeat("0", 64)
+	if _, err := NewIdempotentGenerator(cfg); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("digest mismatch: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// preset is a named starting config for a common scenario. Config files
+// given alongside a preset are applied on top of it.
+type preset struct {
+	summary string
+	build   func() GeneratorConfig
+}
+
+var presets = map[string]preset{
+	"smoke": {
+		summary: "tiny profile space for quick end-to-end checks; every code path is hit within a few thousand records",
+		build: func() GeneratorConfig {
+			cfg := cloneConfig(defaultConfig)
+			cfg.ProfileSpaceSize = 1_000
+			cfg.Distortions = DistortionRates{SwapFirstLast: 0.1, Transliterate: 0.2, Typo: 0.2}
+			return cfg
+		},
+	},
+	"dev-1m": {
+		summary: "sized for 1M records with a realistic share of repeat customers",
+		build: func() GeneratorConfig {
+			cfg := cloneConfig(defaultConfig)
+			cfg.ProfileSpaceSize = 600_000
+			return cfg
+		},
+	},
+	"er-benchmark-100m": {
+		summary: "100M records with heavy-tailed clusters and elevated distortions for entity-resolution benchmarks",
+		build: func() GeneratorConfig {
+			cfg := cloneConfig(defaultConfig)
+			cfg.ProfileSpaceSize = 40_000_000
+			cfg.Buckets = []FrequencyBucket{
+				{Weight: 70, RepeatMultiplier: 1},
+				{Weight: 20, RepeatMultiplier: 3},
+				{Weight: 8, RepeatMultiplier: 10},
+				{Weight: 2, RepeatMultiplier: 50},
+			}
+			cfg.Distortions = DistortionRates{SwapFirstLast: 0.05, Transliterate: 0.15, Typo: 0.1}
+			return cfg
+		},
+	},
+	"realistic": {
+		summary: "default shape with the optional realism models enabled (locale-aware email domains, email aliases, login patterns, city time zones, home cities, per-channel field availability, cohort amounts, demographics, profile lifecycles, sessions, event funnel, labeled fraud incidents, relationship edges, organizations with INN/OGRN/KPP, retention expiry, erasure requests, ...)",
+		build: func() GeneratorConfig {
+			cfg := cloneConfig(defaultConfig)
+			cfg.Emails = defaultEmailConfig.clone()
+			cfg.EmailAliases = defaultEmailAliasConfig.clone()
+			cfg.Logins = defaultLoginConfig.clone()
+			cfg.CityTimezones = cloneTimezones(defaultCityTimezones)
+			cfg.Geography = defaultGeographyConfig.clone()
+			cfg.FieldAvailability = cloneAvailability(defaultFieldAvailability)
+			cfg.Amounts = defaultAmountConfig.clone()
+			cfg.Demographics = defaultDemographicsConfig.clone()
+			cfg.Lifecycle = defaultLifecycleConfig.clone()
+			cfg.Sessions = defaultSessionConfig.clone()
+			cfg.Events = defaultEventConfig.clone()
+			cfg.Fraud = defaultFraudConfig.clone()
+			cfg.Relationships = defaultRelationshipConfig.clone()
+			cfg.Organizations = defaultOrganizationConfig.clone()
+			cfg.Retention = defaultRetentionConfig.clone()
+			cfg.Erasure = defaultErasureConfig.clone()
+			cfg.Pools.Cities = append(cfg.Pools.Cities, "Варшава", "Берлин", "Рига")
+			return cfg
+		},
+	},
+	"chaos": {
+		summary: "huge clusters, distortions on most records and a decade-wide date spread to stress matchers",
+		build: func() GeneratorConfig {
+			cfg := cloneConfig(defaultConfig)
+			cfg.ProfileSpaceSize = 10_000
+			cfg.Buckets = []FrequencyBucket{
+				{Weight: 50, RepeatMultiplier: 1},
+				{Weight: 30, RepeatMultiplier: 10},
+				{Weight: 20, RepeatMultiplier: 100},
+			}
+			cfg.Distortions = DistortionRates{SwapFirstLast: 0.5, Transliterate: 0.5, Typo: 0.5}
+			cfg.DateSpread = DateSpreadConfig{
+				Start: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+				End:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			return cfg
+		},
+	},
+}
+
+func presetNames() []string {
+	return sortedKeys(presets)
+}
+
+func presetConfig(name string) (GeneratorConfig, error) {
+	p, ok := presets[name]
+	if !ok {
+		return GeneratorConfig{}, fmt.Errorf("unknown preset %q (available: %v)", name, presetNames())
+	}
+	return p.build(), nil
+}
+
+// runPresets lists the presets, or prints one as a JSON config that can be
+// saved and edited.
+func runPresets(args []string) error {
+	if len(args) == 0 {
+		for _, name := range presetNames() {
+			fmt.Printf("%-18s %s\n", name, presets[name].summary)
+		}
+		return nil
+	}
+
+	cfg, err := presetConfig(args[0])
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfg)
+}
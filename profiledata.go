@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Data dictionary: a field-level profile of a generated range — type,
+// null rate, cardinality, top values, bounds and a histogram — written as
+// JSON or as a self-contained HTML page, so a dataset can be documented
+// without external profiling tools.
+
+// Profiling limits: distinct values tracked per field before counts become
+// approximate, top values reported, and histogram bins.
+const (
+	profileDistinctLimit = 100_000
+	profileTopValues     = 10
+	profileHistogramBins = 20
+)
+
+// DataProfile is the report for one range.
+type DataProfile struct {
+	ConfigHash string         `json:"configHash"`
+	Start      uint64         `json:"start"`
+	Count      uint64         `json:"count"`
+	Fields     []FieldProfile `json:"fields"`
+}
+
+// FieldProfile describes the values of one field. Numeric fields have a
+// value distribution and histogram, string fields a length distribution.
+type FieldProfile struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Optional bool      `json:"optional"`
+	Present  uint64    `json:"present"`
+	NullRate float64   `json:"nullRate"`
+	Distinct int       `json:"distinct"`
+	// Approximate is set when the field had more than profileDistinctLimit
+	// distinct values: Distinct is then a lower bound and TopValues only
+	// counts values seen before the limit was reached.
+	Approximate bool           `json:"approximate,omitempty"`
+	TopValues   []ValueCount   `json:"topValues"`
+	Min         string         `json:"min"`
+	Max         string         `json:"max"`
+	Values      *Distribution  `json:"values,omitempty"`
+	Lengths     *Distribution  `json:"lengths,omitempty"`
+	Histogram   []HistogramBin `json:"histogram,omitempty"`
+	lengths     []float64
+	numbers     []float64
+	counts      map[string]uint64
+	minValue    boundValue
+	maxValue    boundValue
+}
+
+type ValueCount struct {
+	Value string  `json:"value"`
+	Count uint64  `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// HistogramBin counts values in [From, To); the last bin includes To.
+type HistogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count uint64  `json:"count"`
+}
+
+// boundValue is the smallest or largest value of a field seen so far.
+type boundValue struct {
+	set bool
+	s   string
+	n   float64
+}
+
+func (p *FieldProfile) add(text string, n float64, numeric bool) {
+	p.Present++
+	if c, ok := p.counts[text]; ok || len(p.counts) < profileDistinctLimit {
+		p.counts[text] = c + 1
+	} else {
+		p.Approximate = true
+	}
+	if numeric {
+		p.numbers = append(p.numbers, n)
+		if !p.minValue.set || n < p.minValue.n {
+			p.minValue = boundValue{true, text, n}
+		}
+		if !p.maxValue.set || n > p.maxValue.n {
+			p.maxValue = boundValue{true, text, n}
+		}
+		return
+	}
+	p.lengths = append(p.lengths, float64(utf8.RuneCountInString(text)))
+	if !p.minValue.set || text < p.minValue.s {
+		p.minValue = boundValue{true, text, 0}
+	}
+	if !p.maxValue.set || text > p.maxValue.s {
+		p.maxValue = boundValue{true, text, 0}
+	}
+}
+
+// finish turns the collected values into the report fields.
+func (p *FieldProfile) finish(records uint64) {
+	if records > 0 {
+		p.NullRate = 1 - float64(p.Present)/float64(records)
+	}
+	p.Distinct = len(p.counts)
+	p.Min, p.Max = p.minValue.s, p.maxValue.s
+	values := make([]ValueCount, 0, len(p.counts))
+	for v, c := range p.counts {
+		values = append(values, ValueCount{Value: v, Count: c})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	p.TopValues = values[:min(len(values), profileTopValues)]
+	for i := range p.TopValues {
+		p.TopValues[i].Share = float64(p.TopValues[i].Count) / float64(max(p.Present, 1))
+	}
+	if len(p.numbers) > 0 {
+		p.Histogram = histogramOf(p.numbers)
+		d := distributionOf(p.numbers)
+		p.Values = &d
+	}
+	if len(p.lengths) > 0 {
+		d := distributionOf(p.lengths)
+		p.Lengths = &d
+	}
+	p.numbers, p.lengths, p.counts = nil, nil, nil
+}
+
+// histogramOf counts values in equal-width bins between their bounds.
+func histogramOf(values []float64) []HistogramBin {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	bins := profileHistogramBins
+	if hi == lo {
+		bins = 1
+	}
+	width := (hi - lo) / float64(bins)
+	out := make([]HistogramBin, bins)
+	for i := range out {
+		out[i] = HistogramBin{From: lo + float64(i)*width, To: lo + float64(i+1)*width}
+	}
+	out[bins-1].To = hi
+	for _, v := range values {
+		i := bins - 1
+		if width > 0 {
+			i = min(int((v-lo)/width), bins-1)
+		}
+		out[i].Count++
+	}
+	return out
+}
+
+func collectDataProfile(ctx context.Context, gen *IdempotentGenerator, start, count uint64) (DataProfile, error) {
+	schema := gen.Schema()
+	dp := DataProfile{Start: start, Count: count, Fields: make([]FieldProfile, len(schema.Fields))}
+	for i, f := range schema.Fields {
+		dp.Fields[i] = FieldProfile{Name: f.Name, Type: f.Type, Optional: f.Optional || f.ptr, counts: map[string]uint64{}}
+	}
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return dp, err
+			}
+		}
+		rec := gen.RecordByIndex(start + i)
+		for j := range schema.Fields {
+			f := &schema.Fields[j]
+			v, empty := f.value(&rec)
+			if empty && (f.Optional || f.ptr) {
+				continue
+			}
+			p := &dp.Fields[j]
+			switch f.Type {
+			case FieldString:
+				p.add(v.String(), 0, false)
+			case FieldInt:
+				p.add(strconv.FormatInt(v.Int(), 10), float64(v.Int()), true)
+			case FieldUint:
+				p.add(strconv.FormatUint(v.Uint(), 10), float64(v.Uint()), true)
+			case FieldFloat:
+				p.add(strconv.FormatFloat(v.Float(), 'f', -1, 64), v.Float(), true)
+			}
+		}
+	}
+	for i := range dp.Fields {
+		dp.Fields[i].finish(count)
+	}
+	return dp, nil
+}
+
+var dataProfileHTML = template.Must(template.New("profile").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return strconv.FormatFloat(f*100, 'f', 2, 64) + "%" },
+	"bar": func(n uint64, bins []HistogramBin) float64 {
+		var top uint64
+		for _, b := range bins {
+			top = max(top, b.Count)
+		}
+		return math.Round(float64(n) / float64(max(top, 1)) * 100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Data dictionary {{.ConfigHash}}</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:2em;color:#222}
+table{border-collapse:collapse;margin:.5em 0 2em}
+td,th{border:1px solid #ddd;padding:.25em .6em;text-align:left;vertical-align:top}
+.bar{background:#4a7bd0;height:.8em;display:inline-block}
+code{background:#f4f4f4}
+</style></head><body>
+<h1>Data dictionary</h1>
+<p>Config <code>{{.ConfigHash}}</code>, records [{{.Start}}, +{{.Count}})</p>
+<table><tr><th>Field</th><th>Type</th><th>Null rate</th><th>Distinct</th><th>Min</th><th>Max</th></tr>
+{{range .Fields}}<tr><td><a href="#{{.Name}}">{{.Name}}</a></td><td>{{.Type}}{{if .Optional}}, optional{{end}}</td><td>{{pct .NullRate}}</td><td>{{if .Approximate}}≥ {{end}}{{.Distinct}}</td><td>{{.Min}}</td><td>{{.Max}}</td></tr>
+{{end}}</table>
+{{range .Fields}}<h2 id="{{.Name}}">{{.Name}}</h2>
+<p>{{.Present}} present, {{pct .NullRate}} null, {{if .Approximate}}at least {{end}}{{.Distinct}} distinct</p>
+{{with .Values}}<p>Values: min {{.Min}}, p50 {{.P50}}, mean {{.Mean}}, p90 {{.P90}}, p99 {{.P99}}, max {{.Max}}</p>{{end}}
+{{with .Lengths}}<p>Length: min {{.Min}}, p50 {{.P50}}, mean {{.Mean}}, p90 {{.P90}}, max {{.Max}}</p>{{end}}
+<table><tr><th>Top value</th><th>Count</th><th>Share</th></tr>
+{{range .TopValues}}<tr><td>{{.Value}}</td><td>{{.Count}}</td><td>{{pct .Share}}</td></tr>
+{{end}}</table>
+{{if .Histogram}}{{$bins := .Histogram}}<table><tr><th>From</th><th>To</th><th>Count</th><th></th></tr>
+{{range .Histogram}}<tr><td>{{printf "%.2f" .From}}</td><td>{{printf "%.2f" .To}}</td><td>{{.Count}}</td><td><span class="bar" style="width:{{bar .Count $bins}}px"></span></td></tr>
+{{end}}</table>{{end}}
+{{end}}</body></html>
+`))
+
+func runProfileData(args []string) error {
+	fs := flag.NewFlagSet("profile-data", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records to profile")
+	format := fs.String("format", "json", "report format: json or html")
+	output := fs.String("output", "-", "report file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "html" {
+		return errors.New("-format must be json or html")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	dp, err := collectDataProfile(ctx, gen, *start, *count)
+	if err != nil {
+		return err
+	}
+	dp.ConfigHash = configHash(cfg)
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if *format == "html" {
+		err = dataProfileHTML.Execute(w, dp)
+	} else {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(dp)
+	}
+	if err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if file, ok := w.(*os.File); ok && file != os.Stdout {
+		return file.Close()
+	}
+	return nil
+}
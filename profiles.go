@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// ProfileRow is one entry of the profile dimension: the canonical profile
+// plus how it is represented in the scanned record range.
+type ProfileRow struct {
+	Profile
+	RepeatMultiplier int    `json:"repeatMultiplier"`
+	RecordsInRange   uint64 `json:"recordsInRange"`
+	FirstRecordIndex uint64 `json:"firstRecordIndex"`
+}
+
+// profilesInRange returns the distinct profiles referenced by records
+// [start, start+count) in order of first appearance. Only the index→profile
+// mapping is evaluated while scanning; profiles are built once each.
+func profilesInRange(ctx context.Context, gen *IdempotentGenerator, start, count uint64) ([]ProfileRow, error) {
+	seen := make(map[uint64]int)
+	var rows []ProfileRow
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		idx := start + i
+		id := profileIDForIndex(idx, gen.cfg)
+		if pos, ok := seen[id]; ok {
+			rows[pos].RecordsInRange++
+			continue
+		}
+		seen[id] = len(rows)
+		rows = append(rows, ProfileRow{
+			Profile:          Profile{ProfileID: id},
+			RecordsInRange:   1,
+			FirstRecordIndex: idx,
+		})
+	}
+
+	for i := range rows {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		id := rows[i].ProfileID
+		rows[i].Profile = gen.ProfileByID(id)
+		rows[i].RepeatMultiplier = classifyBucket(id, gen.cfg.Buckets, gen.cfg.Seed).RepeatMultiplier
+	}
+	return rows, nil
+}
+
+// SampleProfiles returns n distinct profiles spread uniformly over the
+// profile space, without scanning records. The space is cut into n equal
+// strata and label picks one profile in each, so the same label always
+// yields the same sample. n is capped at the profile space size.
+func (g *IdempotentGenerator) SampleProfiles(n uint64, label string) []Profile {
+	space := g.cfg.ProfileSpaceSize
+	n = min(n, space)
+	out := make([]Profile, 0, n)
+	for i := uint64(0); i < n; i++ {
+		lo, hi := stratum(space, n, i), stratum(space, n, i+1)
+		h := withSeed(fnv1a64(fmt.Sprintf("sample:%s:%d", label, i)), g.cfg.Seed)
+		out = append(out, g.ProfileByID(lo+h%(hi-lo)))
+	}
+	return out
+}
+
+// ExpectedRecordCount is the expected number of records of a profile in
+// [rangeStart, rangeEnd). Records are assigned to profiles by a uniform hash
+// of their index, so every profile expects the same share; the profile's
+// frequency bucket only decides how many spelling variants those records
+// spread over. Refunds and fraud incidents move records between profiles
+// without changing the expectation much.
+func (g *IdempotentGenerator) ExpectedRecordCount(profileID, rangeStart, rangeEnd uint64) float64 {
+	if rangeEnd <= rangeStart || profileID >= g.cfg.ProfileSpaceSize {
+		return 0
+	}
+	return float64(rangeEnd-rangeStart) / float64(g.cfg.ProfileSpaceSize)
+}
+
+// ExactRecordCount counts the records of a profile in [rangeStart,
+// rangeEnd) by scanning the index→profile mapping; records are not built.
+func (g *IdempotentGenerator) ExactRecordCount(ctx context.Context, profileID, rangeStart, rangeEnd uint64) (uint64, error) {
+	var n uint64
+	for idx := rangeStart; idx < rangeEnd; idx++ {
+		if (idx-rangeStart)%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return n, err
+			}
+		}
+		if g.owner(idx).profileID == profileID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// stratum returns the first ID of stratum i when space is cut into n.
+func stratum(space, n, i uint64) uint64 {
+	hi, lo := bits.Mul64(space, i)
+	q, _ := bits.Div64(hi, lo, n)
+	return q
+}
+
+func runProfiles(args []string) error {
+	fs := flag.NewFlagSet("profiles", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records whose profiles are exported")
+	output := fs.String("output", "-", "output file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	rows, err := profilesInRange(ctx, gen, *start, *count)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriterSize(w, 1<<16)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
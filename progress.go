@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Progress output modes. Both write to stderr so they can be combined with
+// records on stdout.
+const (
+	progressNone = ""
+	progressBar  = "bar"
+	progressJSON = "json"
+)
+
+// outputFlags are the -progress and -quiet flags of long-running commands.
+type outputFlags struct {
+	progress string
+	quiet    bool
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := &outputFlags{}
+	fs.StringVar(&o.progress, "progress", progressNone, "show progress: \"bar\" for a terminal bar, \"json\" for one JSON line per second")
+	fs.BoolVar(&o.quiet, "quiet", false, "print nothing but errors")
+	return o
+}
+
+func (o *outputFlags) validate() error {
+	switch o.progress {
+	case progressNone, progressBar, progressJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown -progress %q (want bar or json)", o.progress)
+}
+
+// logger returns the subsystem logger, limited to warnings with -quiet.
+func (o *outputFlags) logger(subsystem string) *slog.Logger {
+	l := logFor(subsystem)
+	if o.quiet {
+		return slog.New(&leveledHandler{Handler: l.Handler(), level: slog.LevelWarn})
+	}
+	return l
+}
+
+// tracker returns a progress tracker for one output file, or nil when no
+// progress should be shown. A nil tracker is safe to use.
+func (o *outputFlags) tracker(file string, total uint64) *progressTracker {
+	if o.quiet || o.progress == progressNone {
+		return nil
+	}
+	now := time.Now()
+	return &progressTracker{mode: o.progress, file: file, total: total, began: now, last: now, w: os.Stderr}
+}
+
+// ProgressUpdate is one machine-readable progress line.
+type ProgressUpdate struct {
+	File             string  `json:"file"`
+	Records          uint64  `json:"records"`
+	Total            uint64  `json:"total"`
+	Bytes            int64   `json:"bytes"`
+	RecordsPerSecond float64 `json:"recordsPerSecond"`
+	MBPerSecond      float64 `json:"mbPerSecond"`
+	ElapsedSeconds   float64 `json:"elapsedSeconds"`
+	ETASeconds       float64 `json:"etaSeconds"`
+	Done             bool    `json:"done"`
+}
+
+type progressTracker struct {
+	mode    string
+	file    string
+	total   uint64
+	began   time.Time
+	last    time.Time
+	w       io.Writer
+	records uint64
+	bytes   int64
+}
+
+// update is a writeJSONL progress callback; output is throttled.
+func (p *progressTracker) update(records uint64, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.records, p.bytes = records, bytes
+	interval := 200 * time.Millisecond
+	if p.mode == progressJSON {
+		interval = time.Second
+	}
+	if time.Since(p.last) < interval {
+		return
+	}
+	p.last = time.Now()
+	p.print(p.snapshot(records, bytes, false))
+}
+
+// finish prints the final state reported to update.
+func (p *progressTracker) finish() {
+	if p == nil {
+		return
+	}
+	p.print(p.snapshot(p.records, p.bytes, true))
+	if p.mode == progressBar {
+		fmt.Fprintln(p.w)
+	}
+}
+
+func (p *progressTracker) snapshot(records uint64, bytes int64, done bool) ProgressUpdate {
+	elapsed := time.Since(p.began).Seconds()
+	u := ProgressUpdate{File: p.file, Records: records, Total: p.total, Bytes: bytes, ElapsedSeconds: elapsed, Done: done}
+	if elapsed > 0 {
+		u.RecordsPerSecond = float64(records) / elapsed
+		u.MBPerSecond = float64(bytes) / (1 << 20) / elapsed
+	}
+	if u.RecordsPerSecond > 0 && records < p.total {
+		u.ETASeconds = float64(p.total-records) / u.RecordsPerSecond
+	}
+	return u
+}
+
+func (p *progressTracker) print(u ProgressUpdate) {
+	if p.mode == progressJSON {
+		data, _ := json.Marshal(u)
+		fmt.Fprintf(p.w, "%s\n", data)
+		return
+	}
+
+	const width = 30
+	frac := 1.0
+	if u.Total > 0 {
+		frac = float64(u.Records) / float64(u.Total)
+	}
+	filled := int(frac * width)
+	eta := time.Duration(u.ETASeconds * float64(time.Second)).Round(time.Second)
+	fmt.Fprintf(p.w, "\r[%s%s] %5.1f%%  %10.0f rec/s  %7.2f MB/s  ETA %-8v %s",
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled),
+		frac*100, u.RecordsPerSecond, u.MBPerSecond, eta, u.File)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultFrameSize fits every record produced with the default pools.
+const DefaultFrameSize = 512
+
+var ErrFrameTooSmall = errors.New("record does not fit in frame")
+
+// RecordReader presents records [start, start+count) as a virtual JSONL file
+// in which every record occupies exactly frameSize bytes (the JSON object,
+// space padding, then '\n'). Fixed framing makes byte offsets and record
+// indices interchangeable, so the dataset can be seeked and read at random
+// without materializing it. It implements io.ReadSeeker and io.ReaderAt.
+type RecordReader struct {
+	gen       RecordSource
+	start     uint64
+	count     uint64
+	frameSize int64
+
+	pos      int64
+	frame    []byte
+	frameIdx uint64
+	hasFrame bool
+}
+
+func NewRecordReader(gen RecordSource, start, count uint64, frameSize int) *RecordReader {
+	if frameSize <= 0 {
+		frameSize = DefaultFrameSize
+	}
+	return &RecordReader{gen: gen, start: start, count: count, frameSize: int64(frameSize)}
+}
+
+// Size is the total length of the virtual file in bytes.
+func (r *RecordReader) Size() int64 {
+	return int64(r.count) * r.frameSize
+}
+
+func (r *RecordReader) Read(p []byte) (int, error) {
+	n, err := r.readAt(p, r.pos, r.cachedFrame)
+	r.pos += int64(n)
+	return n, err
+}
+
+// ReadAt is safe for concurrent use; it does not touch the Read position or
+// its frame cache.
+func (r *RecordReader) ReadAt(p []byte, off int64) (int, error) {
+	buf := make([]byte, r.frameSize)
+	return r.readAt(p, off, func(i uint64) ([]byte, error) {
+		return buf, r.renderFrame(i, buf)
+	})
+}
+
+func (r *RecordReader) readAt(p []byte, off int64, frameAt func(uint64) ([]byte, error)) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		if off >= r.Size() {
+			return n, io.EOF
+		}
+		frame, err := frameAt(uint64(off / r.frameSize))
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], frame[off%r.frameSize:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+func (r *RecordReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = r.Size() + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = abs
+	return abs, nil
+}
+
+// SeekRecord positions the reader at the start of the i-th record of the
+// range (relative to start).
+func (r *RecordReader) SeekRecord(i uint64) (int64, error) {
+	return r.Seek(int64(i)*r.frameSize, io.SeekStart)
+}
+
+func (r *RecordReader) cachedFrame(i uint64) ([]byte, error) {
+	if r.hasFrame && r.frameIdx == i {
+		return r.frame, nil
+	}
+	if r.frame == nil {
+		r.frame = make([]byte, r.frameSize)
+	}
+	r.hasFrame = false
+	if err := r.renderFrame(i, r.frame); err != nil {
+		return nil, err
+	}
+	r.frameIdx, r.hasFrame = i, true
+	return r.frame, nil
+}
+
+// renderFrame writes the padded encoding of the i-th record into buf, which
+// must be frameSize bytes long.
+func (r *RecordReader) renderFrame(i uint64, buf []byte) error {
+	rec := r.gen.RecordByIndex(r.start + i)
+	data := r.gen.Schema().AppendJSON(buf[:0], &rec)
+	if int64(len(data)) > r.frameSize-1 {
+		return fmt.Errorf("%w: record %d is %d bytes, frame is %d", ErrFrameTooSmall, r.start+i, len(data), r.frameSize)
+	}
+
+	for j := int64(len(data)); j < r.frameSize-1; j++ {
+		buf[j] = ' '
+	}
+	buf[r.frameSize-1] = '\n'
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis loading: profiles become hashes and every identifier a set of the
+// profiles carrying it, written as RESP commands. Commands are pipelined in
+// batches over a connection, or written to a file for redis-cli --pipe.
+
+// redisBatch is the number of commands sent before their replies are read.
+const redisBatch = 1000
+
+func appendRESP(b []byte, args ...string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, a := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(a)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, a...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// redisProfileCommands returns the commands loading one profile under
+// prefix: the profile hash and the reverse lookup of each identifier.
+func redisProfileCommands(prefix string, p *Profile) [][]string {
+	id := strconv.FormatUint(p.ProfileID, 10)
+	hash := []string{"HSET", prefix + "profile:" + id,
+		"firstName", p.FirstName, "lastName", p.LastName, "locale", p.Locale,
+		"phones", strings.Join(p.Phones, ","), "emails", strings.Join(p.Emails, ","), "logins", strings.Join(p.Logins, ","),
+	}
+	for _, kv := range [][2]string{{"homeCity", p.HomeCity}, {"birthDate", p.BirthDate}, {"gender", p.Gender}} {
+		if kv[1] != "" {
+			hash = append(hash, kv[0], kv[1])
+		}
+	}
+	cmds := [][]string{hash}
+	for _, ids := range []struct {
+		kind   string
+		values []string
+	}{{"phone", p.Phones}, {"email", p.Emails}, {"login", p.Logins}} {
+		for _, v := range ids.values {
+			cmds = append(cmds, []string{"SADD", prefix + ids.kind + ":" + v, id})
+		}
+	}
+	return cmds
+}
+
+// redisRecordCommands indexes the identifiers as a record spells them, so
+// distorted variants resolve to their profile too.
+func redisRecordCommands(prefix string, rec *RawRecord) [][]string {
+	id := strconv.FormatUint(rec.ProfileID, 10)
+	var cmds [][]string
+	for _, kv := range [][2]string{{"phone", rec.Phone}, {"email", rec.Email}, {"login", rec.Login}} {
+		if kv[1] != "" {
+			cmds = append(cmds, []string{"SADD", prefix + kv[0] + ":" + kv[1], id})
+		}
+	}
+	return cmds
+}
+
+// redisPipe sends commands in batches and checks their replies.
+type redisPipe struct {
+	w       *bufio.Writer
+	r       *bufio.Reader // nil when writing to a file
+	pending int
+	buf     []byte
+}
+
+func (p *redisPipe) send(args ...string) error {
+	p.buf = appendRESP(p.buf[:0], args...)
+	if _, err := p.w.Write(p.buf); err != nil {
+		return err
+	}
+	p.pending++
+	if p.pending == redisBatch {
+		return p.sync()
+	}
+	return nil
+}
+
+// sync flushes the pending commands and reads their replies.
+func (p *redisPipe) sync() error {
+	if err := p.w.Flush(); err != nil {
+		return err
+	}
+	if p.r == nil {
+		p.pending = 0
+		return nil
+	}
+	for ; p.pending > 0; p.pending-- {
+		if err := readRedisReply(p.r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRedisReply reads one reply, returning server errors as errors.
+func readRedisReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return err
+		}
+		_, err = r.Discard(n + 2)
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := readRedisReply(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func loadRedis(ctx context.Context, gen *IdempotentGenerator, p *redisPipe, prefix string, start, count uint64, records bool) error {
+	rows, err := profilesInRange(ctx, gen, start, count)
+	if err != nil {
+		return err
+	}
+	for i := range rows {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		for _, cmd := range redisProfileCommands(prefix, &rows[i].Profile) {
+			if err := p.send(cmd...); err != nil {
+				return err
+			}
+		}
+	}
+	if records {
+		for i := uint64(0); i < count; i++ {
+			if i%progressInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			rec := gen.RecordByIndex(start + i)
+			for _, cmd := range redisRecordCommands(prefix, &rec) {
+				if err := p.send(cmd...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return p.sync()
+}
+
+func runRedis(args []string) error {
+	fs := flag.NewFlagSet("redis", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records whose profiles are loaded")
+	addr := fs.String("addr", "", "Redis address (host:port); without it RESP commands are written to -output")
+	password := fs.String("password", "", "AUTH password (default $REDIS_PASSWORD)")
+	db := fs.Int("db", 0, "database number to SELECT")
+	prefix := fs.String("prefix", "gen:", "key prefix")
+	records := fs.Bool("records", false, "also index identifiers as records spell them, distortions included")
+	output := fs.String("output", "-", "RESP file for redis-cli --pipe, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if *addr == "" {
+		var w io.Writer = os.Stdout
+		if *output != "-" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		return loadRedis(ctx, gen, &redisPipe{w: bufio.NewWriterSize(w, 1<<16)}, *prefix, *start, *count, *records)
+	}
+
+	var d net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	conn, err := d.DialContext(dialCtx, "tcp", *addr)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stopClose()
+
+	p := &redisPipe{w: bufio.NewWriterSize(conn, 1<<16), r: bufio.NewReader(conn)}
+	if *password == "" {
+		*password = os.Getenv("REDIS_PASSWORD")
+	}
+	if *password != "" {
+		p.send("AUTH", *password)
+	}
+	if *db != 0 {
+		p.send("SELECT", strconv.Itoa(*db))
+	}
+	if err := loadRedis(ctx, gen, p, *prefix, *start, *count, *records); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	logFor("redis").Info("loaded profiles", "start", *start, "count", *count, "addr", *addr)
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// generatorVersion identifies the record derivation algorithm. It must be
+// bumped whenever a code change alters the output of an unchanged config,
+// which invalidates every registered dataset of the previous version.
+const generatorVersion = 1
+
+// fingerprintRecords is how many leading records a registry fingerprint
+// covers.
+const fingerprintRecords = 1000
+
+// RegistryEntry freezes a named dataset: the exact config, the generator
+// version it was registered with and a fingerprint of its first records.
+// Resolving an entry checks all three, so a name always means the same bytes.
+type RegistryEntry struct {
+	Name             string          `json:"name"`
+	Description      string          `json:"description,omitempty"`
+	GeneratorVersion int             `json:"generatorVersion"`
+	ConfigHash       string          `json:"configHash"`
+	Fingerprint      string          `json:"fingerprint"`
+	Config           GeneratorConfig `json:"config"`
+	RegisteredAt     time.Time       `json:"registeredAt"`
+}
+
+// builtinRegistry holds the standard datasets shipped with the tool.
+var builtinRegistry = []RegistryEntry{
+	{
+		Name:             "er-bench-2025a",
+		Description:      "er-benchmark-100m preset with seed 2025; use records [0, 100M)",
+		GeneratorVersion: 1,
+		ConfigHash:       "3816d461ff05b61f",
+		Fingerprint:      "923db02ed7df89202f39151f89e5512708af3bb36dd8079e7107a9fde7d8c957",
+		Config:           seededPreset("er-benchmark-100m", 2025),
+		RegisteredAt:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+	},
+	{
+		Name:             "smoke-2025a",
+		Description:      "smoke preset with seed 2025 for CI fixtures",
+		GeneratorVersion: 1,
+		ConfigHash:       "8e8e156955a5915e",
+		Fingerprint:      "98ed0e2cf261092b5bc8cdfec85ff2f3367266db58a5f6b5eb215311a4ab2a33",
+		Config:           seededPreset("smoke", 2025),
+		RegisteredAt:     time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+func seededPreset(name string, seed uint64) GeneratorConfig {
+	cfg := presets[name].build()
+	cfg.Seed = seed
+	return cfg
+}
+
+// registryPath is the file of team-registered datasets, if any.
+func registryPath() string {
+	return os.Getenv("GEN_REGISTRY")
+}
+
+func readRegistryFile(path string) ([]RegistryEntry, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []RegistryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// registryEntries returns built-in and file entries sorted by name.
+func registryEntries() ([]RegistryEntry, error) {
+	file, err := readRegistryFile(registryPath())
+	if err != nil {
+		return nil, err
+	}
+	entries := append(append([]RegistryEntry(nil), builtinRegistry...), file...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func lookupRegistered(name string) (RegistryEntry, error) {
+	entries, err := registryEntries()
+	if err != nil {
+		return RegistryEntry{}, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return RegistryEntry{}, fmt.Errorf("no registered dataset %q", name)
+}
+
+// fingerprint is the sha256 of the JSONL encoding of the first
+// fingerprintRecords records of cfg.
+func fingerprint(cfg GeneratorConfig) (string, error) {
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := writeJSONL(context.Background(), gen, h, 0, fingerprintRecords, nil); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verify checks that this build reproduces the entry exactly.
+func (e RegistryEntry) verify() error {
+	if e.GeneratorVersion != generatorVersion {
+		return fmt.Errorf("%s was registered with generator version %d, this build is version %d", e.Name, e.GeneratorVersion, generatorVersion)
+	}
+	if got := configHash(e.Config); got != e.ConfigHash {
+		return fmt.Errorf("%s: config hash %s does not match registered %s", e.Name, got, e.ConfigHash)
+	}
+	got, err := fingerprint(e.Config)
+	if err != nil {
+		return err
+	}
+	if got != e.Fingerprint {
+		return fmt.Errorf("%s: output fingerprint %s does not match registered %s", e.Name, got, e.Fingerprint)
+	}
+	return nil
+}
+
+// resolveRegistered looks up and verifies a registered dataset.
+func resolveRegistered(name string) (GeneratorConfig, error) {
+	e, err := lookupRegistered(name)
+	if err != nil {
+		return GeneratorConfig{}, err
+	}
+	if err := e.verify(); err != nil {
+		return GeneratorConfig{}, err
+	}
+	return cloneConfig(e.Config), nil
+}
+
+func (s *Server) handleListRegistry(w http.ResponseWriter, r *http.Request) {
+	entries, err := registryEntries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleGetRegistered(w http.ResponseWriter, r *http.Request) {
+	e, err := lookupRegistered(r.PathValue("name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// runRegistry lists, shows, verifies and adds registered datasets.
+func runRegistry(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		entries, err := registryEntries()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			fmt.Printf("%-20s v%d  %s  %s\n", e.Name, e.GeneratorVersion, e.ConfigHash, e.Description)
+		}
+		return nil
+
+	case "show":
+		if len(args) != 2 {
+			return errors.New("usage: registry show <name>")
+		}
+		e, err := lookupRegistered(args[1])
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+
+	case "verify":
+		entries, err := registryEntries()
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, e := range entries {
+			if len(args) > 1 && e.Name != args[1] {
+				continue
+			}
+			if err := e.verify(); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("✅ %s\n", e.Name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d registered datasets do not reproduce", failed)
+		}
+		return nil
+
+	case "add":
+		return runRegistryAdd(args[1:])
+	}
+	return fmt.Errorf("unknown registry command %q (want list, show, verify or add)", args[0])
+}
+
+func runRegistryAdd(args []string) error {
+	fs := flag.NewFlagSet("registry add", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config to freeze (defaults to the built-in config)")
+	name := fs.String("name", "", "name to register")
+	description := fs.String("description", "", "what the dataset is for")
+	path := fs.String("file", registryPath(), "registry file to append to (default $GEN_REGISTRY)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || *path == "" {
+		return errors.New("-name and -file (or $GEN_REGISTRY) are required")
+	}
+	if _, err := lookupRegistered(*name); err == nil {
+		return fmt.Errorf("%q is already registered; registered datasets are immutable", *name)
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	fp, err := fingerprint(cfg)
+	if err != nil {
+		return err
+	}
+	entries, err := readRegistryFile(*path)
+	if err != nil {
+		return err
+	}
+	entry := RegistryEntry{
+		Name:             *name,
+		Description:      *description,
+		GeneratorVersion: generatorVersion,
+		ConfigHash:       configHash(cfg),
+		Fingerprint:      fp,
+		Config:           cfg,
+		RegisteredAt:     time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(append(entries, entry), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("📌 Registered %s (config %s, fingerprint %s)\n", entry.Name, entry.ConfigHash, entry.Fingerprint[:16])
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetentionConfig gives every record the jurisdiction its data falls under
+// and the time its retention period ends, so retention enforcement and TTL
+// compaction downstream can be checked against expected outcomes: a record
+// is due for deletion exactly when expiresAt has passed. The period comes
+// from the first rule matching the record's channel and jurisdiction and
+// runs from the record's timestamp.
+type RetentionConfig struct {
+	// Jurisdictions maps cities to a jurisdiction; the "*" entry applies to
+	// cities not listed.
+	Jurisdictions map[string]string `json:"jurisdictions"`
+	// Rules are tried in order; empty fields match anything.
+	Rules []RetentionRule `json:"rules"`
+	// DefaultDays is the retention of records no rule matches.
+	DefaultDays int `json:"defaultDays"`
+}
+
+type RetentionRule struct {
+	Channel      string `json:"channel,omitempty"`
+	Jurisdiction string `json:"jurisdiction,omitempty"`
+	Days         int    `json:"days"`
+}
+
+var defaultRetentionConfig = RetentionConfig{
+	Jurisdictions: map[string]string{
+		"Минск":   "BY",
+		"Алматы":  "KZ",
+		"Варшава": "EU",
+		"Берлин":  "EU",
+		"Рига":    "EU",
+		"*":       "RU",
+	},
+	Rules: []RetentionRule{
+		{Channel: "callcenter", Jurisdiction: "EU", Days: 90},
+		{Jurisdiction: "EU", Days: 730},
+		{Channel: "callcenter", Days: 365},
+		{Jurisdiction: "RU", Days: 1825},
+	},
+	DefaultDays: 1095,
+}
+
+func (c *RetentionConfig) clone() *RetentionConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	if c.Jurisdictions != nil {
+		out.Jurisdictions = make(map[string]string, len(c.Jurisdictions))
+		for city, j := range c.Jurisdictions {
+			out.Jurisdictions[city] = j
+		}
+	}
+	out.Rules = append([]RetentionRule(nil), c.Rules...)
+	return &out
+}
+
+func (c *RetentionConfig) validate(cities []string) error {
+	for city, j := range c.Jurisdictions {
+		if j == "" {
+			return fmt.Errorf("retention.jurisdictions[%s] is empty", city)
+		}
+	}
+	if _, ok := c.Jurisdictions["*"]; !ok {
+		for _, city := range cities {
+			if _, ok := c.Jurisdictions[city]; !ok {
+				return fmt.Errorf("retention.jurisdictions: no jurisdiction for city %q and no \"*\" fallback", city)
+			}
+		}
+	}
+	for i, r := range c.Rules {
+		if r.Days <= 0 {
+			return fmt.Errorf("retention.rules[%d].days must be positive", i)
+		}
+	}
+	if c.DefaultDays <= 0 {
+		return errors.New("retention.defaultDays must be positive")
+	}
+	return nil
+}
+
+// jurisdiction returns the jurisdiction of a city; validation guarantees
+// one exists.
+func (c *RetentionConfig) jurisdiction(city string) string {
+	if j, ok := c.Jurisdictions[city]; ok {
+		return j
+	}
+	return c.Jurisdictions["*"]
+}
+
+// expiry returns the jurisdiction of a record at ts in city on channel and
+// the time its retention ends.
+func (c *RetentionConfig) expiry(ts time.Time, city, channel string) (string, time.Time) {
+	j := c.jurisdiction(city)
+	days := c.DefaultDays
+	for _, r := range c.Rules {
+		if (r.Channel == "" || r.Channel == channel) && (r.Jurisdiction == "" || r.Jurisdiction == j) {
+			days = r.Days
+			break
+		}
+	}
+	return j, ts.AddDate(0, 0, days)
+}
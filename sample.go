@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+)
+
+// sampleSelector decides whether an index belongs to the sample. Both modes
+// look only at the index, so a sample costs a hash per skipped record and is
+// identical on every run.
+type sampleSelector func(idx uint64) bool
+
+func strideSelector(every, offset uint64) sampleSelector {
+	return func(idx uint64) bool {
+		return idx%every == offset%every
+	}
+}
+
+func fractionSelector(fraction float64, salt string) sampleSelector {
+	saltHash := fnv1a64("sample:" + salt)
+	return func(idx uint64) bool {
+		u := NewSplitMix64(fnv1a64(idx) ^ saltHash).NextFloat()
+		return u < fraction
+	}
+}
+
+func writeSample(ctx context.Context, gen RecordSource, w io.Writer, start, count uint64, keep sampleSelector) (uint64, error) {
+	bw := bufio.NewWriterSize(w, 1<<16)
+	schema := gen.Schema()
+	var buf []byte
+
+	var kept uint64
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return kept, err
+			}
+		}
+		idx := start + i
+		if !keep(idx) {
+			continue
+		}
+		rec := gen.RecordByIndex(idx)
+		buf = append(schema.AppendJSON(buf[:0], &rec), '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return kept, err
+		}
+		kept++
+	}
+	return kept, bw.Flush()
+}
+
+func runSample(args []string) error {
+	fs := flag.NewFlagSet("sample", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index of the range")
+	count := fs.Uint64("count", 1_000_000, "size of the range to sample from")
+	every := fs.Uint64("every", 0, "keep every N-th index (stride sampling)")
+	offset := fs.Uint64("offset", 0, "phase of the stride: keep indices with idx % N == offset")
+	fraction := fs.Float64("fraction", 0, "keep this fraction of indices, chosen by index hash")
+	salt := fs.String("salt", "", "vary the hash-based sample while keeping it reproducible")
+	output := fs.String("output", "-", "output file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var keep sampleSelector
+	switch {
+	case *every > 0 && *fraction > 0:
+		return errors.New("use either -every or -fraction, not both")
+	case *every > 0:
+		keep = strideSelector(*every, *offset)
+	case *fraction > 0 && *fraction <= 1:
+		keep = fractionSelector(*fraction, *salt)
+	default:
+		return errors.New("-every N or -fraction in (0, 1] is required")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = writeSample(ctx, gen, w, *start, *count, keep)
+	return err
+}
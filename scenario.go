@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Scenarios: a JSON file describing an end-to-end rehearsal dataset as
+// phases — 30 days of normal load, then a migration dump from another
+// source, then a burst with more typos — compiled into consecutive record
+// index ranges and time windows. Each phase is the base config with the
+// phase's overrides and its window as dateSpread, so phases share the
+// profile space and the same people reappear across them, and recompiling a
+// scenario always yields the same allocation.
+//
+//	{
+//	  "name": "migration-rehearsal",
+//	  "start": "2025-01-01T00:00:00Z",
+//	  "phases": [
+//	    {"name": "normal", "duration": "30d", "perDay": 100000},
+//	    {"name": "migration-b", "duration": "6h", "records": 500000,
+//	     "set": {"fieldAvailability": {"web": {"email": 0.2}}}},
+//	    {"name": "burst", "duration": "3d", "perDay": 110000,
+//	     "set": {"distortions.typo": 0.2}}
+//	  ]
+//	}
+
+type Scenario struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	// FirstIndex is the record index of the first phase.
+	FirstIndex uint64          `json:"firstIndex,omitempty"`
+	Phases     []ScenarioPhase `json:"phases"`
+}
+
+type ScenarioPhase struct {
+	Name string `json:"name"`
+	// Duration is a Go duration, or a whole number of days as "30d".
+	Duration string `json:"duration"`
+	// Records or PerDay, not both, size the phase.
+	Records uint64  `json:"records,omitempty"`
+	PerDay  float64 `json:"perDay,omitempty"`
+	// Set holds config overrides by key, as -set takes them.
+	Set map[string]json.RawMessage `json:"set,omitempty"`
+}
+
+// parseScenarioDuration is time.ParseDuration plus a days suffix.
+func parseScenarioDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// CompiledPhase is a phase's allocation: its index range, time window and
+// config.
+type CompiledPhase struct {
+	Name       string           `json:"name"`
+	Start      uint64           `json:"start"`
+	Count      uint64           `json:"count"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	ConfigHash string           `json:"configHash"`
+	Overrides  []ConfigOverride `json:"overrides,omitempty"`
+
+	config GeneratorConfig
+}
+
+// CompiledScenario is the allocation of a whole scenario.
+type CompiledScenario struct {
+	Name           string          `json:"name"`
+	BaseConfigHash string          `json:"baseConfigHash"`
+	Records        uint64          `json:"records"`
+	Phases         []CompiledPhase `json:"phases"`
+}
+
+func readScenario(path string) (Scenario, error) {
+	var s Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// compileScenario allocates index ranges and time windows to the phases of
+// s in order and builds each phase's config from base.
+func compileScenario(s Scenario, base GeneratorConfig) (CompiledScenario, error) {
+	if len(s.Phases) == 0 {
+		return CompiledScenario{}, errors.New("scenario has no phases")
+	}
+	if s.Start.IsZero() {
+		return CompiledScenario{}, errors.New("scenario start is required")
+	}
+	out := CompiledScenario{Name: s.Name, BaseConfigHash: configHash(base)}
+	idx, at := s.FirstIndex, s.Start.UTC()
+	seen := make(map[string]bool, len(s.Phases))
+	for i, p := range s.Phases {
+		where := fmt.Sprintf("phase %d (%s)", i, p.Name)
+		if p.Name == "" || seen[p.Name] {
+			return out, fmt.Errorf("phase %d needs a unique name", i)
+		}
+		seen[p.Name] = true
+		d, err := parseScenarioDuration(p.Duration)
+		if err != nil || d <= 0 {
+			return out, fmt.Errorf("%s: duration must be positive: %q", where, p.Duration)
+		}
+		count := p.Records
+		switch {
+		case (p.Records > 0) == (p.PerDay > 0):
+			return out, fmt.Errorf("%s: give records or perDay", where)
+		case p.PerDay > 0:
+			count = uint64(p.PerDay*d.Hours()/24 + 0.5)
+		}
+		if idx+count < idx {
+			return out, fmt.Errorf("%s: index range overflows", where)
+		}
+
+		var overrides []ConfigOverride
+		for _, key := range sortedKeys(p.Set) {
+			overrides = append(overrides, ConfigOverride{Key: key, Value: string(p.Set[key]), Source: "scenario:" + p.Name})
+		}
+		cfg, err := applyOverrides(cloneConfig(base), overrides)
+		if err != nil {
+			return out, fmt.Errorf("%s: %w", where, err)
+		}
+		cfg.DateSpread = DateSpreadConfig{Start: at, End: at.Add(d)}
+		if err := validateConfig(cfg); err != nil {
+			return out, fmt.Errorf("%s: %w", where, err)
+		}
+
+		out.Phases = append(out.Phases, CompiledPhase{
+			Name: p.Name, Start: idx, Count: count, From: at, To: at.Add(d),
+			ConfigHash: configHash(cfg), Overrides: overrides, config: cfg,
+		})
+		out.Records += count
+		idx, at = idx+count, at.Add(d)
+	}
+	return out, nil
+}
+
+func printScenario(c CompiledScenario) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "phase\tindices\trecords\tfrom\tto\tconfig\toverrides")
+	for _, p := range c.Phases {
+		var sets []string
+		for _, o := range p.Overrides {
+			sets = append(sets, o.Key+"="+o.Value)
+		}
+		fmt.Fprintf(tw, "%s\t[%d, %d)\t%d\t%s\t%s\t%s\t%s\n", p.Name, p.Start, p.Start+p.Count, p.Count,
+			p.From.Format(time.RFC3339), p.To.Format(time.RFC3339), p.ConfigHash, strings.Join(sets, " "))
+	}
+	tw.Flush()
+	fmt.Printf("%d records in %d phases\n", c.Records, len(c.Phases))
+}
+
+// runScenario prints the allocation of a scenario file, or with "run"
+// generates every phase.
+func runScenario(args []string) error {
+	mode := "plan"
+	if len(args) > 0 && (args[0] == "plan" || args[0] == "run") {
+		mode, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("scenario "+mode, flag.ContinueOnError)
+	config := addConfigFlags(fs, "base JSON config of every phase (defaults to the built-in config)")
+	asJSON := fs.Bool("json", false, "plan: print the compiled allocation as JSON")
+	dir := fs.String("dir", "output/scenario", "run: directory the phase files, their manifests and scenario.json are written to")
+	formatName := fs.String("format", "jsonl", "run: output format: "+strings.Join(sortedKeys(recordFormats), ", "))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: scenario %s [flags] <scenario.json>", mode)
+	}
+	format, ok := recordFormats[*formatName]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *formatName)
+	}
+	s, err := readScenario(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	base, err := config.load()
+	if err != nil {
+		return err
+	}
+	compiled, err := compileScenario(s, base)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(compiled, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if mode == "plan" {
+		if *asJSON {
+			_, err := os.Stdout.Write(append(data, '\n'))
+			return err
+		}
+		printScenario(compiled)
+		return nil
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	log := logFor("scenario")
+	for i, p := range compiled.Phases {
+		began := time.Now()
+		gen, err := NewIdempotentGenerator(p.config)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*dir, fmt.Sprintf("%02d-%s.%s", i, p.Name, format.name))
+		file, err := writeRangeFile(ctx, gen, format, path, p.Start, p.Count, nil)
+		if err != nil {
+			return err
+		}
+		manifest := Manifest{
+			ConfigHash: p.ConfigHash,
+			Config:     p.config,
+			Overrides:  append(append([]ConfigOverride(nil), config.overrides...), p.Overrides...),
+			Registered: config.named,
+			Format:     format.name,
+			Start:      p.Start,
+			Count:      p.Count,
+			Files:      []ManifestFile{file},
+			CreatedAt:  gen.now().UTC(),
+		}
+		if err := writeManifest(path+".manifest.json", manifest); err != nil {
+			return err
+		}
+		log.Info("generated phase", "phase", p.Name, "start", p.Start, "count", p.Count, "output", path, "duration", time.Since(began).Round(time.Millisecond))
+	}
+	return os.WriteFile(filepath.Join(*dir, "scenario.json"), append(data, '\n'), 0644)
+}
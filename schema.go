@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldType is the type of an output field.
+type FieldType string
+
+const (
+	FieldString FieldType = "string"
+	FieldInt    FieldType = "int"
+	FieldUint   FieldType = "uint"
+	FieldFloat  FieldType = "float"
+)
+
+// FieldDescriptor describes one output field. Descriptors are derived from
+// the RawRecord struct tags, so a field added to RawRecord is part of the
+// schema without touching any encoder.
+type FieldDescriptor struct {
+	Name string    `json:"name"`
+	Type FieldType `json:"type"`
+	// Optional fields are left out of a record when empty.
+	Optional bool `json:"optional"`
+	// Sensitivity is the privacy class of the field, empty when it carries
+	// no personal data.
+	Sensitivity Sensitivity `json:"sensitivity,omitempty"`
+
+	index int
+	ptr   bool
+	// custom fields are declared by plugins and kept in RawRecord.Custom.
+	custom bool
+}
+
+// Schema is the ordered list of fields encoders write. The config's
+// outputFields selects and orders them; without it every field is written
+// in canonical order.
+type Schema struct {
+	Fields []FieldDescriptor
+}
+
+// canonicalFieldOrder is the order every format writes fields in. It is
+// part of the output contract: schema-on-read consumers key columns by
+// position, so names are only ever appended, never moved or reused. A
+// RawRecord field missing from the list, or a listed name without a field,
+// fails at startup.
+var canonicalFieldOrder = []string{
+	"recordIndex", "profileId", "variantIndex", "firstName", "lastName",
+	"email", "phone", "login", "pointOfSale", "city", "channel", "amount",
+	"timestamp",
+	"emailCanonical",
+	"localTimestamp", "timezone",
+	"birthDate", "gender", "category",
+	"sessionId", "sessionStart", "device",
+	"eventType", "refundOf",
+	"fraudLabel", "fraudIncident",
+	"orgId", "inn", "ogrn", "kpp",
+	"jurisdiction", "expiresAt",
+	"erasedAt",
+}
+
+// recordFields describes every RawRecord field in canonical order.
+var recordFields = func() []FieldDescriptor {
+	t := reflect.TypeOf(RawRecord{})
+	byName := make(map[string]FieldDescriptor, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		d := FieldDescriptor{Name: name, Optional: strings.Contains(opts, "omitempty"), index: i}
+		k := f.Type.Kind()
+		if k == reflect.Pointer {
+			d.ptr, k = true, f.Type.Elem().Kind()
+		}
+		switch k {
+		case reflect.String:
+			d.Type = FieldString
+		case reflect.Int, reflect.Int64:
+			d.Type = FieldInt
+		case reflect.Uint64:
+			d.Type = FieldUint
+		case reflect.Float64:
+			d.Type = FieldFloat
+		default:
+			panic(fmt.Sprintf("RawRecord.%s: unsupported field kind %s", f.Name, k))
+		}
+		byName[name] = d
+	}
+	fields := make([]FieldDescriptor, 0, len(canonicalFieldOrder))
+	for _, name := range canonicalFieldOrder {
+		d, ok := byName[name]
+		if !ok {
+			panic(fmt.Sprintf("canonical field %q is not a RawRecord field", name))
+		}
+		delete(byName, name)
+		d.Sensitivity = fieldSensitivity[name]
+		fields = append(fields, d)
+	}
+	for name := range byName {
+		panic(fmt.Sprintf("RawRecord field %q is missing from canonicalFieldOrder", name))
+	}
+	for name := range fieldSensitivity {
+		if !slices.Contains(canonicalFieldOrder, name) {
+			panic(fmt.Sprintf("fieldSensitivity names %q, which is not a field", name))
+		}
+	}
+	return fields
+}()
+
+var defaultSchema = &Schema{Fields: recordFields}
+
+// newSchema builds the schema for an outputFields list and the custom
+// fields plugins declare; nil names select every field, the custom ones
+// last.
+func newSchema(names []string, custom []PluginField) (*Schema, error) {
+	if names == nil && custom == nil {
+		return defaultSchema, nil
+	}
+	all := slices.Clone(recordFields)
+	for _, c := range custom {
+		all = append(all, FieldDescriptor{Name: c.Name, Type: FieldString, Optional: true, Sensitivity: c.Sensitivity, custom: true})
+	}
+	if names == nil {
+		return &Schema{Fields: all}, nil
+	}
+	byName := make(map[string]FieldDescriptor, len(all))
+	for _, f := range all {
+		byName[f.Name] = f
+	}
+	s := &Schema{Fields: make([]FieldDescriptor, 0, len(names))}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		f, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("outputFields: unknown field %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("outputFields: %q listed twice", name)
+		}
+		seen[name] = true
+		s.Fields = append(s.Fields, f)
+	}
+	return s, nil
+}
+
+// value returns the field of rec, dereferenced, and whether it is empty.
+func (f *FieldDescriptor) value(rec *RawRecord) (reflect.Value, bool) {
+	if f.custom {
+		for _, c := range rec.Custom {
+			if c.Name == f.Name {
+				return reflect.ValueOf(c.Value), c.Value == ""
+			}
+		}
+		return reflect.ValueOf(""), true
+	}
+	v := reflect.ValueOf(rec).Elem().Field(f.index)
+	if f.ptr {
+		if v.IsNil() {
+			return v, true
+		}
+		return v.Elem(), false
+	}
+	return v, v.IsZero()
+}
+
+// AppendJSON appends rec as one JSON object, without a newline. The output
+// matches encoding/json with HTML escaping off.
+func (s *Schema) AppendJSON(b []byte, rec *RawRecord) []byte {
+	b = append(b, '{')
+	first := true
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			continue
+		}
+		if !first {
+			b = append(b, ',')
+		}
+		first = false
+		b = appendJSONString(b, f.Name)
+		b = append(b, ':')
+		switch f.Type {
+		case FieldString:
+			b = appendJSONString(b, v.String())
+		case FieldInt:
+			b = strconv.AppendInt(b, v.Int(), 10)
+		case FieldUint:
+			b = strconv.AppendUint(b, v.Uint(), 10)
+		case FieldFloat:
+			b = appendJSONFloat(b, v.Float())
+		}
+	}
+	return append(b, '}')
+}
+
+// MarshalJSON encodes the record with every field in canonical order and
+// then its custom fields, so records marshalled directly match the JSONL
+// writer.
+func (r RawRecord) MarshalJSON() ([]byte, error) {
+	b := defaultSchema.AppendJSON(nil, &r)
+	for _, c := range r.Custom {
+		if c.Value != "" {
+			b = append(appendJSONString(append(b[:len(b)-1], ','), c.Name), ':')
+			b = append(appendJSONString(b, c.Value), '}')
+		}
+	}
+	return b, nil
+}
+
+// AppendCSVHeader appends the CSV header row: the field names, in order.
+func (s *Schema) AppendCSVHeader(b []byte) []byte {
+	for i, f := range s.Fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendCSVField(b, f.Name)
+	}
+	return append(b, '\n')
+}
+
+// AppendCSV appends rec as one CSV row, including the newline. Unset
+// pointer fields are empty cells; numbers are formatted as in JSON.
+func (s *Schema) AppendCSV(b []byte, rec *RawRecord) []byte {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if i > 0 {
+			b = append(b, ',')
+		}
+		v, empty := f.value(rec)
+		if empty && f.ptr {
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			b = appendCSVField(b, v.String())
+		case FieldInt:
+			b = strconv.AppendInt(b, v.Int(), 10)
+		case FieldUint:
+			b = strconv.AppendUint(b, v.Uint(), 10)
+		case FieldFloat:
+			b = appendJSONFloat(b, v.Float())
+		}
+	}
+	return append(b, '\n')
+}
+
+// appendCSVField quotes s when encoding/csv would.
+func appendCSVField(b []byte, s string) []byte {
+	if s == "" || (!strings.ContainsAny(s, ",\"\r\n") && s[0] != ' ' && s[0] != '\t' && s != `\.`) {
+		return append(b, s...)
+	}
+	b = append(b, '"')
+	b = append(b, strings.ReplaceAll(s, `"`, `""`)...)
+	return append(b, '"')
+}
+
+// appendJSONFloat formats like encoding/json.
+func appendJSONFloat(b []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Shorten e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString quotes s like encoding/json with HTML escaping off.
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\uFFFD"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// FieldNames lists every record field name in canonical order.
+func FieldNames() []string {
+	names := make([]string, len(recordFields))
+	for i, f := range recordFields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// recordFieldIndex maps field names to their position in recordFields.
+var recordFieldIndex = func() map[string]int {
+	m := make(map[string]int, len(recordFields))
+	for i, f := range recordFields {
+		m[f.Name] = i
+	}
+	return m
+}()
+
+// Get returns the value of the named field as a string, int, uint64 or
+// float64; pointer fields are dereferenced, and unset ones are nil. Custom
+// fields are found by name too. ok is false for unknown names.
+func (r *RawRecord) Get(field string) (v any, ok bool) {
+	i, ok := recordFieldIndex[field]
+	if !ok {
+		for _, c := range r.Custom {
+			if c.Name == field {
+				return c.Value, true
+			}
+		}
+		return nil, false
+	}
+	f := &recordFields[i]
+	rv, empty := f.value(r)
+	if f.ptr && empty {
+		return nil, true
+	}
+	return rv.Interface(), true
+}
+
+// AsMap returns the record keyed by field name, with the same fields its JSON
+// encoding has: optional fields are present only when set.
+func (r *RawRecord) AsMap() map[string]any {
+	m := make(map[string]any, len(recordFields))
+	for i := range recordFields {
+		f := &recordFields[i]
+		rv, empty := f.value(r)
+		if empty && (f.Optional || f.ptr) {
+			continue
+		}
+		m[f.Name] = rv.Interface()
+	}
+	for _, c := range r.Custom {
+		if c.Value != "" {
+			m[c.Name] = c.Value
+		}
+	}
+	return m
+}
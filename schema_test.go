@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// wantFieldOrder is canonicalFieldOrder as shipped. Names are only ever
+// appended: a change anywhere but the end breaks consumers that key columns
+// by position.
+var wantFieldOrder = []string{
+	"recordIndex", "profileId", "variantIndex", "firstName", "lastName",
+	"email", "phone", "login", "pointOfSale", "city", "channel", "amount",
+	"timestamp",
+	"emailCanonical",
+	"localTimestamp", "timezone",
+	"birthDate", "gender", "category",
+	"sessionId", "sessionStart", "device",
+	"eventType", "refundOf",
+	"fraudLabel", "fraudIncident",
+	"orgId", "inn", "ogrn", "kpp",
+	"jurisdiction", "expiresAt",
+	"erasedAt",
+}
+
+func TestCanonicalFieldOrder(t *testing.T) {
+	if !slices.Equal(canonicalFieldOrder, wantFieldOrder) {
+		t.Fatalf("canonicalFieldOrder is\n%q\nwant\n%q", canonicalFieldOrder, wantFieldOrder)
+	}
+}
+
+// fullRecord has every field set, so no optional field is left out.
+func fullRecord() RawRecord {
+	var rec RawRecord
+	v := reflect.ValueOf(&rec).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() == reflect.Pointer {
+			f.Set(reflect.New(f.Type().Elem()))
+			f = f.Elem()
+		}
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString("2025-01-02T03:04:05Z")
+		case reflect.Int, reflect.Int64:
+			f.SetInt(7)
+		case reflect.Uint64:
+			f.SetUint(7)
+		case reflect.Float64:
+			f.SetFloat(7.5)
+		}
+	}
+	return rec
+}
+
+// jsonKeys returns the keys of a JSON object in the order they are written.
+func jsonKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		t.Fatalf("not a JSON object: %s", data)
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+// bsonKeys returns the keys of a BSON document in the order they are
+// written, for the element types AppendBSON writes.
+func bsonKeys(t *testing.T, doc []byte) []string {
+	t.Helper()
+	var keys []string
+	for p := 4; p < len(doc)-1; {
+		kind := doc[p]
+		end := p + 1 + bytes.IndexByte(doc[p+1:], 0)
+		keys = append(keys, string(doc[p+1:end]))
+		p = end + 1
+		switch kind {
+		case 0x01, 0x09, 0x12:
+			p += 8
+		case 0x02:
+			p += 4 + int(binary.LittleEndian.Uint32(doc[p:]))
+		default:
+			t.Fatalf("unexpected BSON element type %#x", kind)
+		}
+	}
+	return keys
+}
+
+// fieldOrders extracts the field order of one record in each format.
+var fieldOrders = map[string]func(t *testing.T, out []byte) []string{
+	"jsonl": func(t *testing.T, out []byte) []string { return jsonKeys(t, out) },
+	"csv": func(t *testing.T, out []byte) []string {
+		header, _, _ := strings.Cut(string(out), "\n")
+		return strings.Split(header, ",")
+	},
+	"ejson": func(t *testing.T, out []byte) []string { return jsonKeys(t, out)[1:] },
+	"bson":  func(t *testing.T, out []byte) []string { return bsonKeys(t, out)[1:] },
+	"esbulk": func(t *testing.T, out []byte) []string {
+		_, doc, _ := bytes.Cut(out, []byte("\n"))
+		return jsonKeys(t, doc)
+	},
+	"connect": func(t *testing.T, out []byte) []string {
+		var msg struct {
+			Value struct {
+				Schema struct {
+					Fields []struct {
+						Field string `json:"field"`
+					} `json:"fields"`
+				} `json:"schema"`
+				Payload json.RawMessage `json:"payload"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal(out, &msg); err != nil {
+			t.Fatal(err)
+		}
+		var schema []string
+		for _, f := range msg.Value.Schema.Fields {
+			schema = append(schema, f.Field)
+		}
+		if keys := jsonKeys(t, msg.Value.Payload); !slices.Equal(keys, schema) {
+			t.Errorf("payload order %q differs from schema order %q", keys, schema)
+		}
+		return schema
+	},
+}
+
+func TestFormatsWriteCanonicalOrder(t *testing.T) {
+	rec := fullRecord()
+	for _, name := range sortedKeys(recordFormats) {
+		t.Run(name, func(t *testing.T) {
+			order, ok := fieldOrders[name]
+			if !ok {
+				t.Fatalf("no field order extractor for format %s", name)
+			}
+			f := recordFormats[name]
+			var out []byte
+			if f.header != nil {
+				out = f.header(defaultSchema)
+			}
+			out = f.appendRecord(defaultSchema, out, &rec)
+			if got := order(t, out); !slices.Equal(got, wantFieldOrder) {
+				t.Errorf("fields written as\n%q\nwant\n%q", got, wantFieldOrder)
+			}
+		})
+	}
+}
+
+func TestExportedSchemasKeepCanonicalOrder(t *testing.T) {
+	gen := mustNewGenerator(defaultConfig)
+	for format, path := range map[string]string{"jsonschema": "properties", "avro": "fields", "protobuf": ""} {
+		doc, err := gen.ExportSchema(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		if format == "protobuf" {
+			// Fields are "[optional] type name = number;", numbered in order.
+			for line := range strings.Lines(string(doc)) {
+				decl, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+				words := strings.Fields(decl)
+				if len(words) < 4 || words[len(words)-2] != "=" {
+					continue
+				}
+				if words[len(words)-1] != strconv.Itoa(len(names)+1) {
+					t.Errorf("protobuf field %s is numbered %s", words[len(words)-3], words[len(words)-1])
+				}
+				names = append(names, words[len(words)-3])
+			}
+		} else if format == "avro" {
+			var avro struct {
+				Fields []struct {
+					Name string `json:"name"`
+				} `json:"fields"`
+			}
+			if err := json.Unmarshal(doc, &avro); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range avro.Fields {
+				names = append(names, f.Name)
+			}
+		} else {
+			var js map[string]json.RawMessage
+			if err := json.Unmarshal(doc, &js); err != nil {
+				t.Fatal(err)
+			}
+			names = jsonKeys(t, js[path])
+		}
+		if !slices.Equal(names, wantFieldOrder) {
+			t.Errorf("%s fields are\n%q\nwant\n%q", format, names, wantFieldOrder)
+		}
+	}
+}
+
+func TestSensitivityInCQLAndConnectSchemas(t *testing.T) {
+	gen := mustNewGenerator(defaultConfig)
+	schema := gen.Schema()
+	want := make(map[string]string)
+	for _, f := range schema.Fields {
+		if f.Sensitivity != "" {
+			want[f.Name] = string(f.Sensitivity)
+		}
+	}
+	if len(want) == 0 || want["email"] != string(SensitivityDirect) {
+		t.Fatalf("default schema sensitivities: %v", want)
+	}
+
+	var connect struct {
+		Fields []struct {
+			Field      string            `json:"field"`
+			Parameters map[string]string `json:"parameters"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(connectValueSchema(schema), &connect); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range connect.Fields {
+		if got := f.Parameters["sensitivity"]; got != want[f.Field] {
+			t.Errorf("Connect field %s: sensitivity parameter %q, want %q", f.Field, got, want[f.Field])
+		}
+	}
+
+	var script bytes.Buffer
+	if err := writeCQLScript(context.Background(), gen, &script, "ks", "records", 1, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	var create string
+	for _, line := range strings.Split(script.String(), "\n") {
+		if strings.HasPrefix(line, "CREATE TABLE") {
+			create = line
+		}
+	}
+	_, comment, ok := strings.Cut(create, " WITH comment = 'sensitivity: ")
+	if !ok {
+		t.Fatalf("CREATE TABLE has no sensitivity comment: %s", create)
+	}
+	comment = strings.TrimSuffix(comment, "';")
+	got := make(map[string]string)
+	for _, tag := range strings.Split(comment, ", ") {
+		name, sensitivity, _ := strings.Cut(tag, "=")
+		got[name] = sensitivity
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CQL table comment tags\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Schema export: the record layout the generator is producing — the
+// config's outputFields in their order, with each field's type,
+// optionality and sensitivity — as a JSON Schema or Avro schema document,
+// so consumers can validate records and generate code against the exact
+// shape.
+
+// schemaExportFormats are the documents ExportSchema can produce.
+var schemaExportFormats = map[string]func(s *Schema, hash string) []byte{
+	"jsonschema": jsonSchemaOf,
+	"avro":       avroSchemaOf,
+	"protobuf":   protobufSchemaOf,
+}
+
+// schemaStringFormats are the JSON Schema formats of string fields holding
+// times and dates.
+var schemaStringFormats = map[string]string{
+	"timestamp":      "date-time",
+	"localTimestamp": "date-time",
+	"sessionStart":   "date-time",
+	"birthDate":      "date",
+	"expiresAt":      "date-time",
+	"erasedAt":       "date-time",
+}
+
+// ExportSchema returns the schema of the records g produces as a JSON
+// Schema (draft 2020-12), Avro schema or Protobuf definition; format is
+// "jsonschema", "avro" or "protobuf".
+func (g *IdempotentGenerator) ExportSchema(format string) ([]byte, error) {
+	export, ok := schemaExportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema format %q (available: %v)", format, sortedKeys(schemaExportFormats))
+	}
+	return export(g.Schema(), configHash(g.cfg)), nil
+}
+
+var jsonSchemaTypes = map[FieldType]string{FieldString: "string", FieldInt: "integer", FieldUint: "integer", FieldFloat: "number"}
+
+// jsonSchemaOf is s as a JSON Schema. Fields left out of records when empty
+// are not required; every other field is. Properties keep the field order.
+func jsonSchemaOf(s *Schema, hash string) []byte {
+	b := []byte(`{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"Record","description":`)
+	b = appendJSONString(b, "Generated record, config "+hash+", schema version "+strconv.Itoa(recordSchemaVersion))
+	b = append(b, `,"type":"object","properties":{`...)
+	var required []string
+	for i, f := range s.Fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, f.Name)
+		b = append(b, `:{"type":`...)
+		b = appendJSONString(b, jsonSchemaTypes[f.Type])
+		if f.Type == FieldUint {
+			b = append(b, `,"minimum":0`...)
+		}
+		if format, ok := schemaStringFormats[f.Name]; ok {
+			b = append(b, `,"format":`...)
+			b = appendJSONString(b, format)
+		}
+		if f.Sensitivity != "" {
+			b = append(b, `,"x-sensitivity":`...)
+			b = appendJSONString(b, string(f.Sensitivity))
+		}
+		b = append(b, '}')
+		if !f.Optional && !f.ptr {
+			required = append(required, f.Name)
+		}
+	}
+	b = append(b, `},"required":[`...)
+	for i, name := range required {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, name)
+	}
+	return append(b, `],"additionalProperties":false}`...)
+}
+
+// avroTypes maps field types to Avro. Avro has no unsigned type: uint
+// fields are longs, exact below 2^63.
+var avroTypes = map[FieldType]string{FieldString: "string", FieldInt: "long", FieldUint: "long", FieldFloat: "double"}
+
+// avroSchemaOf is s as an Avro record schema. Optional fields are unions
+// with null, defaulting to null.
+func avroSchemaOf(s *Schema, hash string) []byte {
+	b := []byte(`{"type":"record","name":"Record","namespace":"generator","doc":`)
+	b = appendJSONString(b, "Generated record, config "+hash+", schema version "+strconv.Itoa(recordSchemaVersion))
+	b = append(b, `,"fields":[`...)
+	for i, f := range s.Fields {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"name":`...)
+		b = appendJSONString(b, f.Name)
+		if f.Sensitivity != "" {
+			b = append(b, `,"sensitivity":`...)
+			b = appendJSONString(b, string(f.Sensitivity))
+		}
+		if f.Optional || f.ptr {
+			b = append(b, `,"type":["null",`...)
+			b = appendJSONString(b, avroTypes[f.Type])
+			b = append(b, `],"default":null}`...)
+			continue
+		}
+		b = append(b, `,"type":`...)
+		b = appendJSONString(b, avroTypes[f.Type])
+		b = append(b, '}')
+	}
+	return append(b, "]}"...)
+}
+
+var protobufTypes = map[FieldType]string{FieldString: "string", FieldInt: "int64", FieldUint: "uint64", FieldFloat: "double"}
+
+// protobufSchemaOf is s as a proto3 message. Fields are numbered from 1 in
+// schema order; optional fields have explicit presence, so an absent value
+// is told apart from an empty one.
+func protobufSchemaOf(s *Schema, hash string) []byte {
+	b := []byte("syntax = \"proto3\";\n\npackage generator;\n\n")
+	b = append(b, "// Generated record, config "+hash+", schema version "+strconv.Itoa(recordSchemaVersion)+".\nmessage Record {\n"...)
+	for i, f := range s.Fields {
+		b = append(b, "  "...)
+		if f.Optional || f.ptr {
+			b = append(b, "optional "...)
+		}
+		b = fmt.Appendf(b, "%s %s = %d;", protobufTypes[f.Type], f.Name, i+1)
+		if f.Sensitivity != "" {
+			b = append(b, " // sensitivity: "+string(f.Sensitivity)...)
+		}
+		b = append(b, '\n')
+	}
+	return append(b, "}"...)
+}
+
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	format := fs.String("format", "jsonschema", "schema format: jsonschema, avro or protobuf")
+	output := fs.String("output", "-", "schema file, \"-\" for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	doc, err := gen.ExportSchema(*format)
+	if err != nil {
+		return err
+	}
+	doc = append(doc, '\n')
+	if *output == "-" {
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+	return os.WriteFile(*output, doc, 0644)
+}
@@ -0,0 +1,191 @@
+// Package seedtest loads a deterministic range of generated records into a
+// database, so an integration suite gets realistic, duplicate-laden data in
+// a few lines, e.g. in a database started with testcontainers:
+//
+//	db, _ := sql.Open("pgx", dsn)
+//	seedtest.Seed(t, db, seedtest.Config{
+//		Dialect: "postgres",
+//		Args:    []string{"-preset", "smoke"},
+//		Count:   10_000,
+//	})
+//
+// The schema and rows are the load script of the gen sql command, executed
+// statement by statement on one connection of the database. The package
+// imports no driver; the suite opens the database with the one it uses.
+package seedtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Config says what to load and where the load script comes from.
+type Config struct {
+	// Dialect is the gen sql dialect the script is written in: "sqlite",
+	// "duckdb" or "postgres".
+	Dialect string
+	// Start and Count select the records [Start, Start+Count).
+	Start, Count uint64
+	// Profiles also creates and fills the profiles table of the range.
+	Profiles bool
+	// Binary is the gen command that writes the script; "gen" on the PATH
+	// by default.
+	Binary string
+	// Args select the config, e.g. {"-preset", "smoke"} or
+	// {"-config", "testdata/config.json"}.
+	Args []string
+}
+
+// Seed loads cfg into db, failing the test on error.
+func Seed(t testing.TB, db *sql.DB, cfg Config) {
+	t.Helper()
+	if err := Load(context.Background(), db, cfg); err != nil {
+		t.Fatalf("seed database: %v", err)
+	}
+}
+
+// SeedDSN opens the database at dsn with the registered driver driverName,
+// loads cfg into it and closes it when the test ends.
+func SeedDSN(t testing.TB, driverName, dsn string, cfg Config) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	Seed(t, db, cfg)
+	return db
+}
+
+// Load creates the records table, and the profiles table if cfg.Profiles
+// is set, in db and fills them. The script's transactions run on a single
+// connection, which is returned to the pool afterwards.
+func Load(ctx context.Context, db *sql.DB, cfg Config) error {
+	if cfg.Dialect == "" {
+		return errors.New("seedtest: Dialect is required")
+	}
+	binary := cfg.Binary
+	if binary == "" {
+		binary = "gen"
+	}
+	args := []string{"sql", "-script", "-", "-dialect", cfg.Dialect,
+		"-start", strconv.FormatUint(cfg.Start, 10), "-count", strconv.FormatUint(cfg.Count, 10)}
+	if cfg.Profiles {
+		args = append(args, "-profiles")
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, append(args, cfg.Args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	execErr := Exec(ctx, conn, stdout)
+	if execErr != nil {
+		cancel()
+	}
+	// A gen failure truncates the script, which then fails to execute;
+	// its message says why.
+	if err := cmd.Wait(); err != nil && (execErr == nil || stderr.Len() > 0) {
+		return fmt.Errorf("%s sql: %w: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return execErr
+}
+
+// execer is what Exec runs statements on: a *sql.DB, *sql.Conn or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Exec runs the statements of script one by one. Statements end with a
+// semicolon outside string literals, quoted identifiers and comments.
+func Exec(ctx context.Context, db execer, script io.Reader) error {
+	r := bufio.NewReaderSize(script, 1<<16)
+	for n := 1; ; n++ {
+		stmt, err := nextStatement(r)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if s := strings.TrimSpace(stmt); s != "" {
+			if _, err := db.ExecContext(ctx, s); err != nil {
+				return fmt.Errorf("statement %d (%s): %w", n, abbreviate(s), err)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// nextStatement reads up to and excluding the next statement-ending
+// semicolon. It returns io.EOF with whatever follows the last one.
+func nextStatement(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	var (
+		quote        byte // ' or " inside a literal or identifier
+		lineComment  bool // inside -- ...
+		blockComment bool // inside /* ... */
+		prev         byte
+	)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return b.String(), err
+		}
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case lineComment:
+			lineComment = c != '\n'
+		case blockComment:
+			blockComment = !(prev == '*' && c == '/')
+		case c == '\'' || c == '"':
+			quote = c
+		case prev == '-' && c == '-':
+			lineComment = true
+		case prev == '/' && c == '*':
+			// The star opening a comment does not close it: /*/ is open.
+			blockComment = true
+			b.WriteByte(c)
+			prev = 0
+			continue
+		case c == ';':
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+		prev = c
+	}
+}
+
+// abbreviate shortens a statement for an error message.
+func abbreviate(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if r := []rune(s); len(r) > 80 {
+		s = string(r[:80]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+package seedtest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+)
+
+// recorder collects the statements Exec runs.
+type recorder []string
+
+func (r *recorder) ExecContext(_ context.Context, query string, _ ...any) (sql.Result, error) {
+	*r = append(*r, query)
+	return nil, nil
+}
+
+func TestExec(t *testing.T) {
+	script := `CREATE TABLE "a;b" (x TEXT /* one; two */, y TEXT);
+-- a comment; with a semicolon
+INSERT INTO "a;b" VALUES ('it''s; fine', '/*'),
+('--', ';');
+
+/*/ still a comment; */ COMMIT;
+trailing`
+	var got recorder
+	if err := Exec(context.Background(), &got, strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`CREATE TABLE "a;b" (x TEXT /* one; two */, y TEXT)`,
+		"-- a comment; with a semicolon\nINSERT INTO \"a;b\" VALUES ('it''s; fine', '/*'),\n('--', ';')",
+		`/*/ still a comment; */ COMMIT`,
+		`trailing`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("statements are\n%q\nwant\n%q", got, want)
+	}
+}
+
+var gen struct {
+	once   sync.Once
+	binary string
+	err    error
+}
+
+// buildGen compiles the gen command once per test run.
+func buildGen(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("building gen takes a while")
+	}
+	gen.once.Do(func() {
+		dir, err := os.MkdirTemp("", "seedtest")
+		if err != nil {
+			gen.err = err
+			return
+		}
+		gen.binary = filepath.Join(dir, "gen")
+		if out, err := exec.Command("go", "build", "-o", gen.binary, "..").CombinedOutput(); err != nil {
+			gen.err = fmt.Errorf("%v\n%s", err, out)
+		}
+	})
+	if gen.err != nil {
+		t.Fatalf("build gen: %v", gen.err)
+	}
+	return gen.binary
+}
+
+func TestSeedSQLite(t *testing.T) {
+	binary := buildGen(t)
+	cfg := Config{Dialect: "sqlite", Start: 100, Count: 700, Profiles: true, Binary: binary, Args: []string{"-preset", "smoke"}}
+	db := SeedDSN(t, "sqlite3", "file:"+filepath.Join(t.TempDir(), "seed.db"), cfg)
+
+	var records, profiles, distinct int
+	var first, last uint64
+	row := db.QueryRow(`SELECT count(*), count(DISTINCT "profileId"), min("recordIndex"), max("recordIndex") FROM records`)
+	if err := row.Scan(&records, &distinct, &first, &last); err != nil {
+		t.Fatal(err)
+	}
+	if records != 700 || first != 100 || last != 799 {
+		t.Errorf("records table holds %d records [%d, %d], want 700 [100, 799]", records, first, last)
+	}
+	if distinct >= records {
+		t.Errorf("%d profiles in %d records; the seed has no duplicates", distinct, records)
+	}
+	if err := db.QueryRow(`SELECT count(*) FROM profiles`).Scan(&profiles); err != nil {
+		t.Fatal(err)
+	}
+	if profiles != distinct {
+		t.Errorf("profiles table holds %d profiles, the records %d", profiles, distinct)
+	}
+
+	// A loaded row is the record gen looks up.
+	lookup := exec.Command(binary, "lookup", "-preset", "smoke")
+	lookup.Stdin = strings.NewReader("123\n")
+	out, err := lookup.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want struct {
+		Email string `json:"email"`
+		City  string `json:"city"`
+	}
+	if err := json.Unmarshal(out, &want); err != nil {
+		t.Fatal(err)
+	}
+	var email, city string
+	if err := db.QueryRow(`SELECT email, city FROM records WHERE "recordIndex" = 123`).Scan(&email, &city); err != nil {
+		t.Fatal(err)
+	}
+	if email != want.Email || city != want.City {
+		t.Errorf("record 123 loaded as %q, %q; gen looks up %q, %q", email, city, want.Email, want.City)
+	}
+}
+
+func TestLoadReportsGenErrors(t *testing.T) {
+	binary := buildGen(t)
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "seed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = Load(context.Background(), db, Config{Dialect: "oracle", Count: 10, Binary: binary})
+	if err == nil || !strings.Contains(err.Error(), `unknown dialect "oracle"`) {
+		t.Errorf("Load with an unknown dialect: %v", err)
+	}
+}
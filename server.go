@@ -0,0 +1,545 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server mode: named dataset configs plus background generation jobs.
+const defaultDataset = "default"
+
+type Server struct {
+	jobs *JobManager
+	// allowPlugins lets configs posted to the admin API name plugins,
+	// which are files on this host.
+	allowPlugins bool
+
+	mu       sync.RWMutex
+	datasets map[string]*Dataset
+}
+
+func NewServer(outputDir string) *Server {
+	s := &Server{
+		jobs:     NewJobManager(outputDir),
+		datasets: make(map[string]*Dataset),
+	}
+	metrics.register(&gaugeFunc{
+		name:  "generator_jobs",
+		help:  "Generation jobs by state.",
+		label: "state",
+		fn:    s.jobs.CountByState,
+	})
+	return s
+}
+
+// RegisterDataset creates the named dataset or, if it exists, atomically
+// switches it to cfg while keeping earlier versions addressable.
+func (s *Server) RegisterDataset(name string, cfg GeneratorConfig) (*Dataset, error) {
+	if name == "" {
+		return nil, errors.New("dataset name is required")
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	ds, ok := s.datasets[name]
+	if !ok {
+		ds = newDataset(name)
+		s.datasets[name] = ds
+	}
+	s.mu.Unlock()
+
+	v, changed, err := ds.activate(cfg)
+	if err != nil {
+		if !ok {
+			// Do not leave a dataset without a version behind.
+			s.mu.Lock()
+			if s.datasets[name] == ds {
+				delete(s.datasets, name)
+			}
+			s.mu.Unlock()
+		}
+		return nil, err
+	}
+	if changed && ok {
+		logFor("server").Info("dataset switched", "dataset", name, "configHash", v.Hash)
+	}
+	return ds, nil
+}
+
+func (s *Server) dataset(name string) (*Dataset, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ds, ok := s.datasets[name]
+	return ds, ok
+}
+
+// resolve finds a dataset version, writing a 404 and returning nil if either
+// the dataset or the requested version is unknown.
+func (s *Server) resolve(w http.ResponseWriter, name, version string) *DatasetVersion {
+	ds, ok := s.dataset(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dataset %q not found", name))
+		return nil
+	}
+	v, ok := ds.Version(version)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dataset %q has no version %q", name, version))
+		return nil
+	}
+	w.Header().Set("X-Config-Hash", v.Hash)
+	return v
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /datasets", s.handleListDatasets)
+	mux.HandleFunc("POST /datasets", s.handleRegisterDataset)
+	mux.HandleFunc("GET /datasets/{name}", s.handleGetDataset)
+	mux.HandleFunc("DELETE /datasets/{name}", s.handleDeleteDataset)
+	mux.HandleFunc("POST /datasets/{name}/config", s.handleReloadDataset)
+	mux.HandleFunc("GET /datasets/{name}/versions", s.handleListVersions)
+	mux.HandleFunc("GET /jobs", s.handleListJobs)
+	mux.HandleFunc("POST /jobs", s.handleLaunchJob)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancelJob)
+	mux.HandleFunc("GET /records", s.handleRecordPage)
+	mux.HandleFunc("GET /records/{index}", s.handleRecord)
+	mux.HandleFunc("GET /profiles/{id}", s.handleProfile)
+	mux.HandleFunc("GET /datasets/{name}/records", s.handleRecordPage)
+	mux.HandleFunc("GET /datasets/{name}/records/{index}", s.handleRecord)
+	mux.HandleFunc("GET /datasets/{name}/profiles/{id}", s.handleProfile)
+	mux.HandleFunc("GET /stream", s.handleStream)
+	mux.HandleFunc("GET /datasets/{name}/stream", s.handleStream)
+	mux.HandleFunc("GET /registry", s.handleListRegistry)
+	mux.HandleFunc("GET /registry/{name}", s.handleGetRegistered)
+	mux.Handle("GET /metrics", metrics.Handler())
+	return mux
+}
+
+func (s *Server) handleListDatasets(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	list := make([]*Dataset, 0, len(s.datasets))
+	for _, ds := range s.datasets {
+		list = append(list, ds)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleRegisterDataset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name       string          `json:"name"`
+		Config     json.RawMessage `json:"config"`
+		Registered string          `json:"registered"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Registered != "" {
+		cfg, err := resolveRegistered(body.Registered)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		ds, err := s.RegisterDataset(body.Name, cfg)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, ds)
+		return
+	}
+	s.registerFromJSON(w, body.Name, body.Config)
+}
+
+// handleReloadDataset accepts a new config document for an existing dataset.
+func (s *Server) handleReloadDataset(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := s.dataset(name); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dataset %q not found", name))
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.registerFromJSON(w, name, data)
+}
+
+func (s *Server) registerFromJSON(w http.ResponseWriter, name string, data []byte) {
+	cfg := cloneConfig(defaultConfig)
+	if len(data) > 0 {
+		var err error
+		if cfg, err = parseConfig(data); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	// Parsing never opens plugins; the generator built below does.
+	if !s.allowPlugins && len(cfg.Plugins) > 0 {
+		writeError(w, http.StatusForbidden, errors.New("configs with plugins are only accepted by serve -allow-plugins"))
+		return
+	}
+
+	ds, err := s.RegisterDataset(name, cfg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, ds)
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
+	ds, ok := s.dataset(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dataset %q not found", r.PathValue("name")))
+		return
+	}
+	writeJSON(w, http.StatusOK, ds.Versions())
+}
+
+func (s *Server) handleGetDataset(w http.ResponseWriter, r *http.Request) {
+	ds, ok := s.dataset(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dataset %q not found", r.PathValue("name")))
+		return
+	}
+	writeJSON(w, http.StatusOK, ds)
+}
+
+func (s *Server) handleDeleteDataset(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.mu.Lock()
+	_, ok := s.datasets[name]
+	delete(s.datasets, name)
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("dataset %q not found", name))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobs.List())
+}
+
+func (s *Server) handleLaunchJob(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Dataset == "" {
+		req.Dataset = defaultDataset
+	}
+	if req.Count == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("count must be positive"))
+		return
+	}
+
+	v := s.resolve(w, req.Dataset, req.Version)
+	if v == nil {
+		return
+	}
+	req.Version = v.Hash
+
+	job, err := s.jobs.Launch(r.Context(), req, v.gen)
+	switch {
+	case errors.Is(err, ErrRangeOverflow):
+		writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %q not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.jobs.Cancel(id) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %q not found", id))
+		return
+	}
+	job, _ := s.jobs.Get(id)
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
+	idx, err := strconv.ParseUint(r.PathValue("index"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid record index: %w", err))
+		return
+	}
+	v := s.resolve(w, datasetName(r), r.URL.Query().Get("version"))
+	if v == nil {
+		return
+	}
+	metrics.recordsGenerated.Inc("lookup")
+	rec := v.gen.RecordByIndex(idx)
+	writeCacheableJSON(w, r, json.RawMessage(v.gen.Schema().AppendJSON(nil, &rec)))
+}
+
+// Page sizes of GET /records.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 10_000
+)
+
+// handleRecordPage serves one page of records. The first request gives the
+// range and filters (start, count, city, channel, from, to); later ones pass
+// the returned nextCursor, which fails with 409 once the dataset's config
+// has changed.
+func (s *Server) handleRecordPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	v := s.resolve(w, datasetName(r), q.Get("version"))
+	if v == nil {
+		return
+	}
+	pageSize := defaultPageSize
+	if p := q.Get("pageSize"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("pageSize must be in [1, %d]", maxPageSize))
+			return
+		}
+		pageSize = n
+	}
+
+	cursor := q.Get("cursor")
+	if cursor == "" {
+		start, err := parseUintParam(q.Get("start"), 0)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+			return
+		}
+		count, err := parseUintParam(q.Get("count"), 1_000_000)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid count: %w", err))
+			return
+		}
+		if start+count < start {
+			writeError(w, http.StatusBadRequest, ErrRangeOverflow)
+			return
+		}
+		var f Filter
+		if c := q.Get("city"); c != "" {
+			f.Cities = strings.Split(c, ",")
+		}
+		if c := q.Get("channel"); c != "" {
+			f.Channels = strings.Split(c, ",")
+		}
+		for name, t := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+			if p := q.Get(name); p != "" {
+				if *t, err = time.Parse(time.RFC3339, p); err != nil {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s: %w", name, err))
+					return
+				}
+			}
+		}
+		if cursor, err = v.gen.NewCursor(start, start+count, f); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	records, next, err := v.gen.NextPage(cursor, pageSize)
+	switch {
+	case errors.Is(err, ErrCursorConfigChanged):
+		writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	metrics.recordsGenerated.Add("lookup", float64(len(records)))
+	page := struct {
+		Records    []json.RawMessage `json:"records"`
+		NextCursor string            `json:"nextCursor,omitempty"`
+	}{Records: make([]json.RawMessage, len(records)), NextCursor: next}
+	for i := range records {
+		page.Records[i] = v.gen.Schema().AppendJSON(nil, &records[i])
+	}
+	writeCacheableJSON(w, r, page)
+}
+
+func parseUintParam(s string, def uint64) (uint64, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid profile id: %w", err))
+		return
+	}
+	v := s.resolve(w, datasetName(r), r.URL.Query().Get("version"))
+	if v == nil {
+		return
+	}
+	writeCacheableJSON(w, r, v.gen.ProfileByID(id))
+}
+
+// datasetName returns the dataset addressed by the request path; the
+// top-level lookup routes serve the default dataset.
+func datasetName(r *http.Request) string {
+	if name := r.PathValue("name"); name != "" {
+		return name
+	}
+	return defaultDataset
+}
+
+// Generated content never changes for a given config, so lookups are served
+// with a content-hash ETag. Only a lookup pinned to a config by ?version=
+// may be cached as is; an unpinned one follows the dataset's current
+// version, which a reload changes, and is revalidated every time.
+const (
+	pinnedCacheControl   = "public, max-age=86400, immutable"
+	unpinnedCacheControl = "no-cache"
+)
+
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	etag := fmt.Sprintf("\"%016x\"", fnv1a64(string(body)))
+
+	w.Header().Set("ETag", etag)
+	if r.URL.Query().Get("version") != "" {
+		w.Header().Set("Cache-Control", pinnedCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", unpinnedCacheControl)
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		metrics.lookupCache.Inc("hit")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	metrics.lookupCache.Inc("miss")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	metrics.errors.Inc("http")
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	outputDir := fs.String("output-dir", "output", "directory for job output files")
+	config := addConfigFlags(fs, "JSON config for the \"default\" dataset")
+	var datasets stringList
+	fs.Var(&datasets, "dataset", "additional dataset as name=config.json (repeatable)")
+	watch := fs.Duration("watch", 0, "poll the -config file at this interval and reload it on change (0 disables)")
+	allowPlugins := fs.Bool("allow-plugins", false, "accept configs with plugins over the admin API; plugins are WebAssembly files read from this host")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+
+	srv := NewServer(*outputDir)
+	srv.allowPlugins = *allowPlugins
+	if _, err := srv.RegisterDataset(defaultDataset, cfg); err != nil {
+		return err
+	}
+	for _, spec := range datasets {
+		name, path, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fmt.Errorf("invalid -dataset %q, want name=config.json", spec)
+		}
+		cfg, err := loadConfigFile(path)
+		if err != nil {
+			return fmt.Errorf("dataset %s: %w", name, err)
+		}
+		if _, err := srv.RegisterDataset(name, cfg); err != nil {
+			return fmt.Errorf("dataset %s: %w", name, err)
+		}
+	}
+	if *watch > 0 && config.path != "" {
+		go watchConfigFile(config.path, *watch, config.load, func(cfg GeneratorConfig) {
+			if _, err := srv.RegisterDataset(defaultDataset, cfg); err != nil {
+				logFor("server").Warn("ignoring config change", "path", config.path, "err", err)
+			}
+		})
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	// Request contexts derive from ctx, so open streams stop on shutdown
+	// instead of holding it up.
+	hs := &http.Server{
+		Addr:        *addr,
+		Handler:     tracingMiddleware(srv.Handler()),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		hs.Shutdown(shutdownCtx)
+	}()
+
+	logFor("server").Info("serving", "addr", *addr, "outputDir", *outputDir)
+	if err := hs.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server with the default dataset registered.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer(t.TempDir())
+	if _, err := s.RegisterDataset(defaultDataset, cloneConfig(defaultConfig)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// post sends body to path on a server for s and returns the response.
+func post(t *testing.T, s *Server, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+func TestPluginGate(t *testing.T) {
+	for _, body := range []string{
+		`{"plugins":[{"path":"/nonexistent/evil.so"}]}`,
+		// encoding/json matches keys case-insensitively and keeps the last,
+		// so a check on the raw document sees no plugins here.
+		`{"plugins":[{"path":"/nonexistent/evil.so"}],"Plugins":[]}`,
+		`{"Plugins":[],"plugins":[{"path":"/nonexistent/evil.so"}]}`,
+	} {
+		w := post(t, newTestServer(t), "/datasets/default/config", body)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403: %s", body, w.Code, w.Body)
+		}
+		if strings.Contains(w.Body.String(), "evil.so") {
+			t.Errorf("%s: the plugin was opened: %s", body, w.Body)
+		}
+	}
+
+	s := newTestServer(t)
+	s.allowPlugins = true
+	w := post(t, s, "/datasets/default/config", `{"plugins":[{"path":"/nonexistent/evil.so"}]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "evil.so") {
+		t.Errorf("with -allow-plugins: status %d, want 400 naming the plugin: %s", w.Code, w.Body)
+	}
+}
+
+func TestParseConfigDoesNotOpenPlugins(t *testing.T) {
+	cfg, err := parseConfig([]byte(`{"plugins":[{"path":"/nonexistent/evil.so"}]}`))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if _, err := NewIdempotentGenerator(cfg); err == nil || !strings.Contains(err.Error(), "evil.so") {
+		t.Fatalf("NewIdempotentGenerator: %v, want the plugin load error", err)
+	}
+}
+
+// get sends a GET for path to a server for s and returns the response.
+func get(t *testing.T, s *Server, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestRangeOverflow(t *testing.T) {
+	s := newTestServer(t)
+	if w := post(t, s, "/jobs", `{"start":18446744073709551615,"count":2}`); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /jobs past the last index: status %d, want 400: %s", w.Code, w.Body)
+	}
+	if w := get(t, s, "/records?start=18446744073709551615&count=2"); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /records past the last index: status %d, want 400: %s", w.Code, w.Body)
+	}
+	if _, err := s.jobs.Launch(context.Background(), JobRequest{Start: 1 << 63, Count: 1 << 63}, nil); !errors.Is(err, ErrRangeOverflow) {
+		t.Errorf("Launch past the last index: %v, want ErrRangeOverflow", err)
+	}
+}
+
+func TestJobIDsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	// Output of a server that ran before in the same directory.
+	for _, name := range []string{"job-000001.jsonl", "job-000002.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("earlier\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer(dir)
+	if _, err := s.RegisterDataset(defaultDataset, cloneConfig(defaultConfig)); err != nil {
+		t.Fatal(err)
+	}
+	w := post(t, s, "/jobs", `{"count":5}`)
+	var status JobStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("POST /jobs: status %d: %s", w.Code, w.Body)
+	}
+	if status.ID != "job-000003" {
+		t.Errorf("job id %s, want job-000003 after the existing outputs", status.ID)
+	}
+	job, _ := s.jobs.Get(status.ID)
+	for deadline := time.Now().Add(10 * time.Second); job.Status().State == JobRunning; {
+		if time.Now().After(deadline) {
+			t.Fatal("job did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "job-000001.jsonl")); string(data) != "earlier\n" {
+		t.Errorf("earlier output overwritten: %q", data)
+	}
+}
+
+func TestLookupCacheControl(t *testing.T) {
+	s := newTestServer(t)
+	for _, path := range []string{"/records/5", "/profiles/5"} {
+		w := get(t, s, path)
+		if got := w.Header().Get("Cache-Control"); w.Code != http.StatusOK || got != unpinnedCacheControl {
+			t.Errorf("GET %s: status %d, Cache-Control %q, want %q", path, w.Code, got, unpinnedCacheControl)
+		}
+		pinned := path + "?version=" + w.Header().Get("X-Config-Hash")
+		w = get(t, s, pinned)
+		if got := w.Header().Get("Cache-Control"); w.Code != http.StatusOK || got != pinnedCacheControl {
+			t.Errorf("GET %s: status %d, Cache-Control %q, want %q", pinned, w.Code, got, pinnedCacheControl)
+		}
+	}
+}
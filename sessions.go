@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SessionConfig groups a profile's records into sessions. Time is cut into
+// fixed windows per profile; the profile's records on a session channel that
+// fall in the same window form one session, moved to a session start derived
+// from the profile and window and spread over the session's duration. Session
+// membership is therefore a pure function of the record, like everything
+// else, and needs no look at neighbouring records.
+type SessionConfig struct {
+	// WindowHours is the length of the windows; a profile has at most one
+	// session per window.
+	WindowHours int `json:"windowHours"`
+	// MaxMinutes bounds the duration of a session.
+	MaxMinutes int `json:"maxMinutes"`
+	// Channels are the channels whose records belong to sessions.
+	Channels []string `json:"channels"`
+	// Devices is the pool of the device a session runs on.
+	Devices []WeightedValue `json:"devices"`
+}
+
+var defaultSessionConfig = SessionConfig{
+	WindowHours: 24,
+	MaxMinutes:  45,
+	Channels:    []string{"web", "mobile"},
+	Devices: []WeightedValue{
+		{Value: "android", Weight: 40},
+		{Value: "ios", Weight: 30},
+		{Value: "desktop", Weight: 25},
+		{Value: "tablet", Weight: 5},
+	},
+}
+
+// Session describes the session a record belongs to.
+type Session struct {
+	ID     string
+	Start  time.Time
+	Device string
+}
+
+func (c *SessionConfig) clone() *SessionConfig {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	out.Channels = append([]string(nil), c.Channels...)
+	out.Devices = append([]WeightedValue(nil), c.Devices...)
+	return &out
+}
+
+func (c *SessionConfig) validate() error {
+	if c.WindowHours <= 0 || c.MaxMinutes <= 0 {
+		return errors.New("sessions.windowHours and sessions.maxMinutes must be positive")
+	}
+	if c.MaxMinutes > c.WindowHours*60 {
+		return errors.New("sessions.maxMinutes must fit in a window")
+	}
+	if err := validateWeighted(c.Devices); err != nil {
+		return fmt.Errorf("sessions.devices: %w", err)
+	}
+	return nil
+}
+
+func (c *SessionConfig) hasChannel(channel string) bool {
+	for _, ch := range c.Channels {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// session returns the session of a profile's record at ts and the record's
+// time within it. The session is kept inside [lo, hi), the span the
+// profile's records may fall in, which contains ts.
+func (c *SessionConfig) session(idx, profileID uint64, ts, lo, hi time.Time, seed uint64) (Session, time.Time) {
+	window := time.Duration(c.WindowHours) * time.Hour
+	duration := time.Duration(c.MaxMinutes) * time.Minute
+	slot := ts.UnixMilli() / window.Milliseconds()
+
+	h := withSeed(fnv1a64(fmt.Sprintf("session:%d:%d", profileID, slot)), seed)
+	rng := NewSplitMix64(h)
+	slack := window - duration
+	start := time.UnixMilli(slot * window.Milliseconds()).UTC().Add(time.Duration(rng.NextFloat() * float64(slack))).Truncate(time.Second)
+	length := time.Duration((0.1 + 0.9*rng.NextFloat()) * float64(duration))
+	// Shift sessions that overhang the span back inside it, shortening
+	// those longer than what is left. Every record of the session sees the
+	// same span, so they still agree on its start.
+	if first := lo.UTC().Add(time.Second - 1).Truncate(time.Second); start.Before(first) {
+		start = first
+	}
+	if end := start.Add(length); end.After(hi) {
+		start = hi.Add(-length).Truncate(time.Second)
+		if first := lo.UTC().Add(time.Second - 1).Truncate(time.Second); start.Before(first) {
+			start, length = first, hi.Sub(first)
+		}
+	}
+	s := Session{
+		ID:     fmt.Sprintf("s-%016x", h),
+		Start:  start,
+		Device: pickWeighted(rng, c.Devices),
+	}
+
+	// Each record takes its own offset, so gaps between the events of a
+	// session follow from the offsets of its records.
+	r := NewSplitMix64(withSeed(fnv1a64(fmt.Sprintf("event:%d", idx)), seed))
+	return s, start.Add(time.Duration(r.NextFloat() * float64(length))).Truncate(time.Second)
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestSessionsStayInActiveSpan(t *testing.T) {
+	withSessions := cloneConfig(defaultConfig)
+	withSessions.Sessions = defaultSessionConfig.clone()
+	withLifecycle := cloneConfig(withSessions)
+	withLifecycle.Lifecycle = defaultLifecycleConfig.clone()
+	for name, cfg := range map[string]GeneratorConfig{"date spread": withSessions, "lifecycle": withLifecycle} {
+		gen := mustNewGenerator(cfg)
+		sessions := 0
+		for idx := uint64(0); idx < 5000; idx++ {
+			if err := CheckActiveSpan(gen, idx); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if gen.RecordByIndex(idx).SessionID != "" {
+				sessions++
+			}
+		}
+		if sessions == 0 {
+			t.Fatalf("%s: no records in sessions", name)
+		}
+	}
+}
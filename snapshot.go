@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Golden snapshots: records at chosen indices checked in as JSON and
+// compared field by field against what the current build generates, so a
+// team can pin the exact data its tests expect. -update rewrites the file
+// after an intended change. Go tests do the same with
+// snapshottest.AssertRecords, which reads and writes this file format.
+
+// snapshotFile is the checked-in golden file.
+type snapshotFile struct {
+	ConfigHash string            `json:"configHash"`
+	Records    []json.RawMessage `json:"records"`
+}
+
+// parseIndexList parses comma-separated indices and inclusive ranges,
+// e.g. "0,7,100-109".
+func parseIndexList(s string) ([]uint64, error) {
+	var out []uint64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.ParseUint(lo, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("index %q: %w", part, err)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.ParseUint(hi, 10, 64); err != nil || to < from {
+				return nil, fmt.Errorf("index range %q is not ascending", part)
+			}
+		}
+		for idx := from; ; idx++ {
+			out = append(out, idx)
+			if idx == to {
+				break
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no indices given")
+	}
+	return out, nil
+}
+
+// snapshotRecords encodes the records at indices as gen writes them.
+func snapshotRecords(gen *IdempotentGenerator, indices []uint64) []json.RawMessage {
+	out := make([]json.RawMessage, len(indices))
+	for i, idx := range indices {
+		rec := gen.RecordByIndex(idx)
+		out[i] = gen.Schema().AppendJSON(nil, &rec)
+	}
+	return out
+}
+
+// decodeSnapshotRecord decodes a record for comparison, keeping numbers
+// exact.
+func decodeSnapshotRecord(data []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&m)
+	return m, err
+}
+
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	indexList := fs.String("indices", "0-9", "record indices: comma-separated indices and inclusive ranges, e.g. 0,7,100-109")
+	golden := fs.String("golden", "", "golden JSON file to compare against")
+	update := fs.Bool("update", false, "rewrite the golden file with the current records instead of comparing")
+	maxExamples := fs.Int("examples", 10, "changed records to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *golden == "" {
+		return errors.New("-golden is required")
+	}
+	indices, err := parseIndexList(*indexList)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	current := snapshotRecords(gen, indices)
+
+	if *update {
+		snap := snapshotFile{ConfigHash: configHash(cfg), Records: current}
+		data, err := json.MarshalIndent(snap, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*golden, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		logFor("snapshot").Info("updated golden file", "golden", *golden, "records", len(current))
+		return nil
+	}
+
+	data, err := os.ReadFile(*golden)
+	if err != nil {
+		return fmt.Errorf("%w (create it with -update)", err)
+	}
+	var snap snapshotFile
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("%s: %w", *golden, err)
+	}
+	want := make(map[uint64]map[string]interface{}, len(snap.Records))
+	for _, raw := range snap.Records {
+		m, err := decodeSnapshotRecord(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *golden, err)
+		}
+		n, _ := m["recordIndex"].(json.Number)
+		idx, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: record without recordIndex", *golden)
+		}
+		want[idx] = m
+	}
+
+	rd := newRecordDiff(*maxExamples)
+	for i, idx := range indices {
+		m, ok := want[idx]
+		if !ok {
+			rd.onlyB++
+			continue
+		}
+		got, _ := decodeSnapshotRecord(current[i])
+		rd.compare(idx, m, got)
+		delete(want, idx)
+	}
+	rd.onlyA = uint64(len(want))
+	if rd.changed == 0 && rd.onlyA == 0 && rd.onlyB == 0 {
+		fmt.Printf("✅ %d records match %s\n", rd.compared, *golden)
+		return nil
+	}
+	if snap.ConfigHash != configHash(cfg) {
+		fmt.Printf("⚙️  %s was recorded with config %s, this is %s\n", *golden, snap.ConfigHash, configHash(cfg))
+	}
+	rd.print(os.Stdout)
+	return fmt.Errorf("records differ from %s; rerun with -update if the change is intended", *golden)
+}
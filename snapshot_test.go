@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/damir-manapov/idempotent-entries-idea/snapshottest"
+)
+
+// snapshotConfig has snapshottest generate records with a generator for
+// cfg.
+func snapshotConfig(t testing.TB, cfg GeneratorConfig) snapshottest.Config {
+	t.Helper()
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshottest.Config{
+		Records: func(indices []uint64) ([]json.RawMessage, error) {
+			return snapshotRecords(gen, indices), nil
+		},
+		ConfigHash: configHash(cfg),
+	}
+}
+
+// TestPresetSnapshots pins records of every preset, at the start of the
+// index space and far into it.
+func TestPresetSnapshots(t *testing.T) {
+	indices := []uint64{0, 1, 2, 3, 4, 1_000_003, 1 << 40}
+	for _, name := range presetNames() {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := presetConfig(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			snapshottest.AssertRecords(t, snapshotConfig(t, cfg), indices, "testdata/snapshots/"+name+".golden.json")
+		})
+	}
+}
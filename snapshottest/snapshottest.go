@@ -0,0 +1,216 @@
+// Package snapshottest pins generated records in checked-in golden JSON
+// files, so a team's tests fail with a field-level diff when the data they
+// expect changes:
+//
+//	func TestPinnedRecords(t *testing.T) {
+//		cfg := snapshottest.Config{Args: []string{"-config", "testdata/config.json"}}
+//		snapshottest.AssertRecords(t, cfg, []uint64{0, 1, 42}, "testdata/records.golden.json")
+//	}
+//
+// Run go test -update to write the golden file, and again after an intended
+// change. The file format is the one the gen snapshot command writes, so
+// either can update a file the other checks.
+package snapshottest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the records generated now")
+
+// Config says where AssertRecords gets the current records from.
+type Config struct {
+	// Records returns the records at indices as JSON objects, in order.
+	// When nil, the records are looked up with the gen binary.
+	Records func(indices []uint64) ([]json.RawMessage, error)
+	// Binary is the gen command run when Records is nil; "gen" on the
+	// PATH by default.
+	Binary string
+	// Args select the config gen lookup runs with, e.g.
+	// {"-preset", "smoke"} or {"-config", "testdata/config.json"}.
+	Args []string
+	// ConfigHash, if set, is recorded in golden files and reported when a
+	// golden file was recorded with another config.
+	ConfigHash string
+}
+
+// Golden is a golden file.
+type Golden struct {
+	ConfigHash string            `json:"configHash"`
+	Records    []json.RawMessage `json:"records"`
+}
+
+// generate returns the current records at indices.
+func (c Config) generate(indices []uint64) ([]json.RawMessage, error) {
+	if c.Records != nil {
+		return c.Records(indices)
+	}
+	binary := c.Binary
+	if binary == "" {
+		binary = "gen"
+	}
+	var in bytes.Buffer
+	for _, idx := range indices {
+		in.WriteString(strconv.FormatUint(idx, 10) + "\n")
+	}
+	cmd := exec.Command(binary, append([]string{"lookup"}, c.Args...)...)
+	cmd.Stdin = &in
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s lookup: %w: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+	var records []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		records = append(records, json.RawMessage(bytes.Clone(scanner.Bytes())))
+	}
+	if len(records) != len(indices) {
+		return nil, fmt.Errorf("%s lookup returned %d records for %d indices", binary, len(records), len(indices))
+	}
+	return records, scanner.Err()
+}
+
+// AssertRecords generates the records at indices and compares them with
+// goldenFile, reporting every changed field of every record. With -update
+// it writes goldenFile instead.
+func AssertRecords(t testing.TB, cfg Config, indices []uint64, goldenFile string) {
+	t.Helper()
+	got, err := cfg.generate(indices)
+	if err != nil {
+		t.Fatalf("generate records: %v", err)
+	}
+	if *update {
+		data, err := json.MarshalIndent(Golden{ConfigHash: cfg.ConfigHash, Records: got}, "", "  ")
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(goldenFile), 0755)
+		}
+		if err == nil {
+			err = os.WriteFile(goldenFile, append(data, '\n'), 0644)
+		}
+		if err != nil {
+			t.Fatalf("update %s: %v", goldenFile, err)
+		}
+		t.Logf("updated %s with %d records", goldenFile, len(got))
+		return
+	}
+
+	data, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("%v (create it with go test -update)", err)
+	}
+	var golden Golden
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatalf("%s: %v", goldenFile, err)
+	}
+	diffs, err := Diff(golden.Records, got)
+	if err != nil {
+		t.Fatalf("%s: %v", goldenFile, err)
+	}
+	if len(diffs) == 0 {
+		return
+	}
+	if cfg.ConfigHash != "" && golden.ConfigHash != "" && golden.ConfigHash != cfg.ConfigHash {
+		diffs = append([]string{fmt.Sprintf("%s was recorded with config %s, this is %s", goldenFile, golden.ConfigHash, cfg.ConfigHash)}, diffs...)
+	}
+	t.Errorf("records differ from %s; rerun with go test -update if the change is intended:\n\t%s", goldenFile, strings.Join(diffs, "\n\t"))
+}
+
+// Diff matches want and got records by recordIndex and describes each
+// difference: a record only on one side, or a field changed, added or
+// removed. Numbers compare exactly.
+func Diff(want, got []json.RawMessage) ([]string, error) {
+	wantByIndex, wantOrder, err := byRecordIndex(want)
+	if err != nil {
+		return nil, fmt.Errorf("golden records: %w", err)
+	}
+	gotByIndex, gotOrder, err := byRecordIndex(got)
+	if err != nil {
+		return nil, fmt.Errorf("generated records: %w", err)
+	}
+	var diffs []string
+	for _, idx := range wantOrder {
+		if _, ok := gotByIndex[idx]; !ok {
+			diffs = append(diffs, fmt.Sprintf("record %s: in the golden file but not generated", idx))
+		}
+	}
+	for _, idx := range gotOrder {
+		w, ok := wantByIndex[idx]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("record %s: generated but not in the golden file", idx))
+			continue
+		}
+		g := gotByIndex[idx]
+		fields := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			fields = append(fields, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				fields = append(fields, k)
+			}
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			wv, inWant := w[f]
+			gv, inGot := g[f]
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("record %s: %s: %s removed", idx, f, show(wv)))
+			case !inWant:
+				diffs = append(diffs, fmt.Sprintf("record %s: %s: %s added", idx, f, show(gv)))
+			case !reflect.DeepEqual(wv, gv):
+				diffs = append(diffs, fmt.Sprintf("record %s: %s: %s → %s", idx, f, show(wv), show(gv)))
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// byRecordIndex decodes records and keys them by recordIndex, keeping
+// their order.
+func byRecordIndex(records []json.RawMessage) (map[string]map[string]any, []string, error) {
+	out := make(map[string]map[string]any, len(records))
+	order := make([]string, 0, len(records))
+	for i, raw := range records {
+		var m map[string]any
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return nil, nil, fmt.Errorf("record %d of %d: %w", i+1, len(records), err)
+		}
+		n, ok := m["recordIndex"].(json.Number)
+		if !ok {
+			return nil, nil, errors.New("record without recordIndex")
+		}
+		if _, dup := out[n.String()]; !dup {
+			order = append(order, n.String())
+		}
+		out[n.String()] = m
+	}
+	return out, order, nil
+}
+
+// show renders a decoded value as JSON.
+func show(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
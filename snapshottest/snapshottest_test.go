@@ -0,0 +1,49 @@
+package snapshottest
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	want := []json.RawMessage{
+		json.RawMessage(`{"recordIndex":1,"email":"a@x.ru","amount":10.5}`),
+		json.RawMessage(`{"recordIndex":2,"email":"b@x.ru"}`),
+		json.RawMessage(`{"recordIndex":3,"email":"c@x.ru"}`),
+	}
+	got := []json.RawMessage{
+		json.RawMessage(`{"recordIndex":1,"email":"a@y.ru","amount":10.50,"device":"ios"}`),
+		json.RawMessage(`{"recordIndex":2}`),
+		json.RawMessage(`{"recordIndex":4,"email":"d@x.ru"}`),
+	}
+	diffs, err := Diff(want, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`record 3: in the golden file but not generated`,
+		`record 1: amount: 10.5 → 10.50`,
+		`record 1: device: "ios" added`,
+		`record 1: email: "a@x.ru" → "a@y.ru"`,
+		`record 2: email: "b@x.ru" removed`,
+		`record 4: generated but not in the golden file`,
+	}
+	if !slices.Equal(diffs, expected) {
+		t.Errorf("diffs are\n%q\nwant\n%q", diffs, expected)
+	}
+	if diffs, _ := Diff(want, want); len(diffs) != 0 {
+		t.Errorf("identical records differ: %q", diffs)
+	}
+}
+
+func TestAssertRecords(t *testing.T) {
+	records := func(indices []uint64) ([]json.RawMessage, error) {
+		out := make([]json.RawMessage, len(indices))
+		for i, idx := range indices {
+			out[i], _ = json.Marshal(map[string]any{"recordIndex": idx, "square": idx * idx})
+		}
+		return out, nil
+	}
+	AssertRecords(t, Config{Records: records, ConfigHash: "squares"}, []uint64{0, 3, 7}, "testdata/squares.golden.json")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snowflake stage output: gzip-compressed CSV chunks sized for parallel
+// COPY, plus a load script that creates the table and copies the chunks in
+// from a stage. The chunks can be PUT to an internal stage by the script or
+// uploaded to an external stage's bucket.
+
+var snowflakeTypes = map[FieldType]string{FieldString: "VARCHAR", FieldInt: "NUMBER(19,0)", FieldUint: "NUMBER(20,0)", FieldFloat: "FLOAT"}
+
+// writeGzipChunk writes [start, start+count) as gzip-compressed CSV and
+// returns its manifest entry.
+func writeGzipChunk(ctx context.Context, gen RecordSource, path string, start, count uint64) (ManifestFile, error) {
+	entry := ManifestFile{Path: path, Start: start, Count: count}
+	file, err := os.Create(path)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(file, hash)}
+	zw := gzip.NewWriter(counter)
+	if _, _, err := writeRecords(ctx, gen, zw, formatCSV, start, count, nil); err != nil {
+		return entry, err
+	}
+	if err := zw.Close(); err != nil {
+		return entry, err
+	}
+	if err := file.Sync(); err != nil {
+		return entry, err
+	}
+	entry.Bytes = counter.n
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return entry, nil
+}
+
+// snowflakeLoadScript creates the table and copies the chunks in from stage.
+// put adds PUT commands uploading the chunks from dir to an internal stage.
+func snowflakeLoadScript(s *Schema, table, stage, dir string, files []ManifestFile, put bool) string {
+	var b strings.Builder
+	cols := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		t := snowflakeTypes[f.Type]
+		if f.Name == "timestamp" || f.Name == "sessionStart" {
+			t = "TIMESTAMP_TZ"
+		}
+		cols[i] = quoteSQLIdent(f.Name) + " " + t
+		if c := sensitivityComment(&f); c != "" {
+			cols[i] += " COMMENT " + string(appendSQLString(nil, c))
+		}
+	}
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (%s);\n", table, strings.Join(cols, ", "))
+	fmt.Fprintf(&b, "CREATE FILE FORMAT IF NOT EXISTS generator_csv TYPE = CSV COMPRESSION = GZIP SKIP_HEADER = 1 FIELD_OPTIONALLY_ENCLOSED_BY = '\"' EMPTY_FIELD_AS_NULL = TRUE REPLACE_INVALID_CHARACTERS = TRUE;\n")
+	if put {
+		for _, f := range files {
+			abs, _ := filepath.Abs(filepath.Join(dir, filepath.Base(f.Path)))
+			fmt.Fprintf(&b, "PUT 'file://%s' %s AUTO_COMPRESS = FALSE;\n", abs, stage)
+		}
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = "'" + filepath.Base(f.Path) + "'"
+	}
+	fmt.Fprintf(&b, "COPY INTO %s FROM %s FILES = (%s) FILE_FORMAT = (FORMAT_NAME = 'generator_csv');\n", table, stage, strings.Join(names, ", "))
+	return b.String()
+}
+
+func runSnowflake(args []string) error {
+	fs := flag.NewFlagSet("snowflake", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 1_000_000, "number of records")
+	chunk := fs.Uint64("chunk", 500_000, "records per compressed CSV chunk")
+	dir := fs.String("dir", "output/snowflake", "directory for the chunks and load script")
+	table := fs.String("table", "records", "target table")
+	stage := fs.String("stage", "@~/generator", "stage the chunks are copied from")
+	put := fs.Bool("put", true, "add PUT commands uploading the chunks to an internal stage; disable for external stages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *chunk == 0 {
+		return errors.New("-chunk must be positive")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	began := time.Now()
+	var files []ManifestFile
+	for off := uint64(0); off < *count; off += *chunk {
+		n := min(*chunk, *count-off)
+		path := filepath.Join(*dir, fmt.Sprintf("records-%05d.csv.gz", len(files)))
+		f, err := writeGzipChunk(ctx, gen, path, *start+off, n)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	manifest := Manifest{
+		ConfigHash: configHash(cfg),
+		Config:     cfg,
+		Overrides:  config.overrides,
+		Registered: config.named,
+		Format:     "csv.gz",
+		Start:      *start,
+		Count:      *count,
+		Files:      files,
+		CreatedAt:  gen.now().UTC(),
+	}
+	if err := writeManifest(filepath.Join(*dir, "manifest.json"), manifest); err != nil {
+		return err
+	}
+	script := snowflakeLoadScript(gen.Schema(), *table, *stage, *dir, files, *put)
+	if err := os.WriteFile(filepath.Join(*dir, "load.sql"), []byte(script), 0644); err != nil {
+		return err
+	}
+	logFor("snowflake").Info("wrote stage files", "dir", *dir, "chunks", len(files), "duration", time.Since(began).Round(time.Millisecond))
+	return nil
+}
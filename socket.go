@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Unix socket streaming. A client connects and sends a single JSON line:
+//
+//	{"start": 0, "count": 1000, "format": "json"}
+//
+// The server answers with one JSON line ({"ok":true,...} or {"error":...})
+// and then, on success, writes each record as a 4-byte big-endian length
+// followed by the encoded record. A zero-length frame marks the end.
+type socketHandshake struct {
+	Start  uint64 `json:"start"`
+	Count  uint64 `json:"count"`
+	Format string `json:"format"`
+}
+
+type socketReply struct {
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	ConfigHash string `json:"configHash,omitempty"`
+	Count      uint64 `json:"count,omitempty"`
+}
+
+var socketEncoders = map[string]func(*Schema, RawRecord) ([]byte, error){
+	"json": func(s *Schema, r RawRecord) ([]byte, error) { return s.AppendJSON(nil, &r), nil },
+}
+
+func runSocket(args []string) error {
+	fs := flag.NewFlagSet("socket", flag.ContinueOnError)
+	path := fs.String("path", "/tmp/idempotent-generator.sock", "unix socket path")
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	hash := configHash(cfg)
+
+	os.Remove(*path)
+	ln, err := net.Listen("unix", *path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(*path)
+
+	ctx, stop := interruptContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	logFor("sinks").Info("streaming records on unix socket", "path", *path, "configHash", hash)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveSocketConn(ctx, conn, gen, hash)
+	}
+}
+
+func serveSocketConn(ctx context.Context, conn net.Conn, gen RecordSource, hash string) {
+	defer conn.Close()
+
+	var hs socketHandshake
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &hs)
+	}
+	if hs.Format == "" {
+		hs.Format = "json"
+	}
+	encode, ok := socketEncoders[hs.Format]
+	switch {
+	case err != nil:
+		writeSocketReply(conn, socketReply{Error: "invalid handshake: " + err.Error()})
+		return
+	case !ok:
+		writeSocketReply(conn, socketReply{Error: fmt.Sprintf("unsupported format %q", hs.Format)})
+		return
+	}
+	if err := writeSocketReply(conn, socketReply{OK: true, ConfigHash: hash, Count: hs.Count}); err != nil {
+		return
+	}
+
+	w := bufio.NewWriterSize(conn, 1<<16)
+	var prefix [4]byte
+	for i := uint64(0); i < hs.Count; i++ {
+		if i%progressInterval == 0 && ctx.Err() != nil {
+			// Shutting down: end without the terminating frame so the
+			// client sees a truncated stream, not a complete one.
+			w.Flush()
+			return
+		}
+		payload, err := encode(gen.Schema(), gen.RecordByIndex(hs.Start+i))
+		if err != nil {
+			metrics.errors.Inc("socket")
+			return
+		}
+		binary.BigEndian.PutUint32(prefix[:], uint32(len(payload)))
+		w.Write(prefix[:])
+		if _, err := w.Write(payload); err != nil {
+			return
+		}
+	}
+	binary.BigEndian.PutUint32(prefix[:], 0)
+	w.Write(prefix[:])
+	w.Flush()
+}
+
+func writeSocketReply(conn net.Conn, reply socketReply) error {
+	data, _ := json.Marshal(reply)
+	_, err := conn.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import "iter"
+
+// recordSchemaVersion identifies the shape of RawRecord. It changes when
+// fields are renamed, removed or retyped; new optional fields do not change
+// it.
+const recordSchemaVersion = 1
+
+// RecordSource is anything that yields records by index. IdempotentGenerator
+// is the canonical implementation; wrappers that filter, augment or replay
+// records from files implement it too and can be passed to every sink.
+type RecordSource interface {
+	// RecordByIndex returns the record at idx.
+	RecordByIndex(idx uint64) RawRecord
+	// Records yields records [start, start+count) in order.
+	Records(start, count uint64) iter.Seq[RawRecord]
+	// SchemaVersion is the recordSchemaVersion the records follow.
+	SchemaVersion() int
+	// Schema lists the fields encoders write, in order.
+	Schema() *Schema
+}
+
+var _ RecordSource = (*IdempotentGenerator)(nil)
+
+func (g *IdempotentGenerator) Records(start, count uint64) iter.Seq[RawRecord] {
+	return func(yield func(RawRecord) bool) {
+		for i := uint64(0); i < count; i++ {
+			if !yield(g.RecordByIndex(start + i)) {
+				return
+			}
+		}
+	}
+}
+
+func (g *IdempotentGenerator) SchemaVersion() int {
+	return recordSchemaVersion
+}
+
+func (g *IdempotentGenerator) Schema() *Schema {
+	return g.schema
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Rows per INSERT statement and per transaction of a SQL load script.
+const (
+	sqlRowsPerInsert = 500
+	sqlRowsPerTx     = 100_000
+)
+
+// sqlDialect is a database a load script is written for. The script is
+// plain SQL, fed to the database's command-line shell here and executed
+// statement by statement through database/sql by the seedtest package.
+type sqlDialect struct {
+	// shell is the command that executes a script read from stdin against
+	// the database given as its last argument, after shellArgs.
+	shell     string
+	shellArgs []string
+	// server is set for databases reached by connection string; the others
+	// are files the load creates.
+	server bool
+	types  map[FieldType]string
+	// begin and commit wrap each batch of inserts.
+	begin, commit string
+	// commentOn is set for databases with COMMENT ON COLUMN, which then
+	// holds the sensitivity of columns; others get it as a comment in
+	// CREATE TABLE, which SQLite keeps in its schema.
+	commentOn bool
+	// load, when set, fills the database file in process instead of
+	// through the shell.
+	load func(ctx context.Context, gen *IdempotentGenerator, d sqlDialect, db string, start, count uint64, profiles bool) error
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"sqlite": {
+		shell:  "sqlite3",
+		types:  map[FieldType]string{FieldString: "TEXT", FieldInt: "INTEGER", FieldUint: "INTEGER", FieldFloat: "REAL"},
+		begin:  "BEGIN;",
+		commit: "COMMIT;",
+	},
+	"duckdb": {
+		shell:     "duckdb",
+		types:     map[FieldType]string{FieldString: "VARCHAR", FieldInt: "BIGINT", FieldUint: "UBIGINT", FieldFloat: "DOUBLE"},
+		begin:     "BEGIN TRANSACTION;",
+		commit:    "COMMIT;",
+		commentOn: true,
+	},
+	// PostgreSQL has no unsigned integers; NUMERIC(20) holds every uint64.
+	"postgres": {
+		shell:     "psql",
+		shellArgs: []string{"-q", "-v", "ON_ERROR_STOP=1"},
+		server:    true,
+		types:     map[FieldType]string{FieldString: "TEXT", FieldInt: "BIGINT", FieldUint: "NUMERIC(20)", FieldFloat: "DOUBLE PRECISION"},
+		begin:     "BEGIN;",
+		commit:    "COMMIT;",
+		commentOn: true,
+	},
+}
+
+// Columns indexed when present in the schema.
+var sqlIndexedColumns = []string{"profileId", "email", "phone"}
+
+// sqlProfileColumns are the columns of the profiles table.
+var sqlProfileColumns = []struct {
+	name string
+	typ  FieldType
+}{
+	{"profileId", FieldUint}, {"firstName", FieldString}, {"lastName", FieldString},
+	{"locale", FieldString}, {"homeCity", FieldString}, {"birthDate", FieldString},
+	{"gender", FieldString}, {"activeFrom", FieldString}, {"churnedAt", FieldString},
+	{"erasedAt", FieldString},
+	{"phones", FieldString}, {"emails", FieldString}, {"logins", FieldString},
+	{"repeatMultiplier", FieldInt}, {"recordsInRange", FieldUint}, {"firstRecordIndex", FieldUint},
+}
+
+func quoteSQLIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// appendSQLString quotes s as a SQL literal. Invalid UTF-8 is replaced by
+// U+FFFD, since DuckDB rejects it.
+func appendSQLString(b []byte, s string) []byte {
+	b = append(b, '\'')
+	b = append(b, strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "'", "''")...)
+	return append(b, '\'')
+}
+
+// appendSQLRow appends rec as a parenthesized value list. Fields JSON would
+// omit are NULL.
+func appendSQLRow(b []byte, s *Schema, rec *RawRecord) []byte {
+	b = append(b, '(')
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if i > 0 {
+			b = append(b, ',')
+		}
+		v, empty := f.value(rec)
+		if empty && (f.Optional || f.ptr) {
+			b = append(b, "NULL"...)
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			b = appendSQLString(b, v.String())
+		case FieldInt:
+			b = strconv.AppendInt(b, v.Int(), 10)
+		case FieldUint:
+			b = strconv.AppendUint(b, v.Uint(), 10)
+		case FieldFloat:
+			b = appendJSONFloat(b, v.Float())
+		}
+	}
+	return append(b, ')')
+}
+
+func appendSQLProfileRow(b []byte, row *ProfileRow) []byte {
+	list := func(values []string) string {
+		data, _ := json.Marshal(values)
+		return string(data)
+	}
+	nullable := func(b []byte, s string) []byte {
+		if s == "" {
+			return append(b, "NULL"...)
+		}
+		return appendSQLString(b, s)
+	}
+	p := &row.Profile
+	b = fmt.Appendf(b, "(%d,", p.ProfileID)
+	b = appendSQLString(b, p.FirstName)
+	b = append(b, ',')
+	b = appendSQLString(b, p.LastName)
+	b = append(b, ',')
+	b = appendSQLString(b, p.Locale)
+	for _, s := range []string{p.HomeCity, p.BirthDate, p.Gender, p.ActiveFrom, p.ChurnedAt, p.ErasedAt} {
+		b = nullable(append(b, ','), s)
+	}
+	for _, l := range [][]string{p.Phones, p.Emails, p.Logins} {
+		b = appendSQLString(append(b, ','), list(l))
+	}
+	return fmt.Appendf(b, ",%d,%d,%d)", row.RepeatMultiplier, row.RecordsInRange, row.FirstRecordIndex)
+}
+
+// sqlRecordsTable returns the statements creating the records table and
+// commenting its sensitive columns.
+func sqlRecordsTable(schema *Schema, d sqlDialect) []string {
+	cols := make([]string, len(schema.Fields))
+	for i, f := range schema.Fields {
+		cols[i] = quoteSQLIdent(f.Name) + " " + d.types[f.Type]
+		if c := sensitivityComment(&f); c != "" && !d.commentOn {
+			cols[i] += " /* " + c + " */"
+		}
+	}
+	stmts := []string{fmt.Sprintf("CREATE TABLE records (%s)", strings.Join(cols, ", "))}
+	for _, f := range schema.Fields {
+		if c := sensitivityComment(&f); c != "" && d.commentOn {
+			stmts = append(stmts, fmt.Sprintf("COMMENT ON COLUMN records.%s IS %s", quoteSQLIdent(f.Name), appendSQLString(nil, c)))
+		}
+	}
+	return stmts
+}
+
+func sqlProfilesTable(d sqlDialect) string {
+	cols := make([]string, len(sqlProfileColumns))
+	for i, c := range sqlProfileColumns {
+		cols[i] = quoteSQLIdent(c.name) + " " + d.types[c.typ]
+	}
+	cols[0] += " PRIMARY KEY"
+	return fmt.Sprintf("CREATE TABLE profiles (%s)", strings.Join(cols, ", "))
+}
+
+// sqlIndexes returns the statements indexing the records table, created
+// after it is filled.
+func sqlIndexes(schema *Schema) []string {
+	var stmts []string
+	for _, f := range schema.Fields {
+		for _, name := range sqlIndexedColumns {
+			if f.Name == name {
+				stmts = append(stmts, fmt.Sprintf("CREATE INDEX %s ON records (%s)", quoteSQLIdent("records_"+name), quoteSQLIdent(name)))
+			}
+		}
+	}
+	return stmts
+}
+
+// writeSQLScript writes a script creating and filling a records table, and
+// a profiles table when profiles is set, followed by the indexes.
+func writeSQLScript(ctx context.Context, gen *IdempotentGenerator, w io.Writer, d sqlDialect, start, count uint64, profiles bool) error {
+	bw := bufio.NewWriterSize(w, 1<<16)
+	schema := gen.Schema()
+	for _, stmt := range sqlRecordsTable(schema, d) {
+		fmt.Fprintf(bw, "%s;\n", stmt)
+	}
+
+	var buf []byte
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if i%sqlRowsPerTx == 0 {
+			fmt.Fprintln(bw, d.begin)
+		}
+		if i%sqlRowsPerInsert == 0 {
+			buf = append(buf[:0], "INSERT INTO records VALUES\n"...)
+		} else {
+			buf = append(buf[:0], ",\n"...)
+		}
+		rec := gen.RecordByIndex(start + i)
+		buf = appendSQLRow(buf, schema, &rec)
+		last := i+1 == count
+		if (i+1)%sqlRowsPerInsert == 0 || last {
+			buf = append(buf, ";\n"...)
+		}
+		if (i+1)%sqlRowsPerTx == 0 || last {
+			buf = append(buf, d.commit+"\n"...)
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	if profiles {
+		rows, err := profilesInRange(ctx, gen, start, count)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "%s;\n%s\n", sqlProfilesTable(d), d.begin)
+		for i := range rows {
+			if i%sqlRowsPerInsert == 0 {
+				buf = append(buf[:0], "INSERT INTO profiles VALUES\n"...)
+			} else {
+				buf = append(buf[:0], ",\n"...)
+			}
+			buf = appendSQLProfileRow(buf, &rows[i])
+			if (i+1)%sqlRowsPerInsert == 0 || i+1 == len(rows) {
+				buf = append(buf, ";\n"...)
+			}
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(bw, d.commit)
+	}
+
+	for _, stmt := range sqlIndexes(schema) {
+		fmt.Fprintf(bw, "%s;\n", stmt)
+	}
+	return bw.Flush()
+}
+
+// loadSQL runs the script through the dialect's shell into db.
+func loadSQL(ctx context.Context, d sqlDialect, db string, script func(io.Writer) error) error {
+	if _, err := exec.LookPath(d.shell); err != nil {
+		return fmt.Errorf("%s is needed to create the database (or write the script with -script): %w", d.shell, err)
+	}
+	cmd := exec.CommandContext(ctx, d.shell, append(d.shellArgs, db)...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	werr := script(stdin)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %w", d.shell, err)
+	}
+	return werr
+}
+
+func runSQL(args []string) error {
+	fs := flag.NewFlagSet("sql", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records")
+	dialect := fs.String("dialect", "sqlite", "database to load: "+strings.Join(sortedKeys(sqlDialects), ", "))
+	db := fs.String("db", "", "database file to create, or connection string for postgres")
+	scriptPath := fs.String("script", "", "write the SQL load script here instead, \"-\" for stdout")
+	profiles := fs.Bool("profiles", false, "also load the profile dimension of the range")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	d, ok := sqlDialects[*dialect]
+	if !ok {
+		return fmt.Errorf("unknown dialect %q", *dialect)
+	}
+	if (*db == "") == (*scriptPath == "") {
+		return errors.New("exactly one of -db and -script is required")
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	script := func(w io.Writer) error {
+		return writeSQLScript(ctx, gen, w, d, *start, *count, *profiles)
+	}
+
+	switch *scriptPath {
+	case "":
+		if _, err := os.Stat(*db); err == nil && !d.server {
+			return fmt.Errorf("%s already exists", *db)
+		}
+		if d.load != nil {
+			return d.load(ctx, gen, d, *db, *start, *count, *profiles)
+		}
+		return loadSQL(ctx, d, *db, script)
+	case "-":
+		return script(os.Stdout)
+	}
+	file, err := os.Create(*scriptPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := script(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// RangeStats summarizes the shape of the data in an index range.
+type RangeStats struct {
+	Start            uint64                     `json:"start"`
+	Count            uint64                     `json:"count"`
+	DistinctProfiles int                        `json:"distinctProfiles"`
+	DuplicateRate    float64                    `json:"duplicateRate"`
+	ClusterSizes     map[int]int                `json:"clusterSizes"`
+	Buckets          map[int]uint64             `json:"recordsByRepeatMultiplier"`
+	Distortions      map[string]DistortionStats `json:"distortions"`
+	Cardinalities    map[string]int             `json:"cardinalities"`
+	Amount           Distribution               `json:"amount"`
+	RecordsByMonth   map[string]uint64          `json:"recordsByMonth"`
+	FirstTimestamp   string                     `json:"firstTimestamp"`
+	LastTimestamp    string                     `json:"lastTimestamp"`
+}
+
+type DistortionStats struct {
+	Configured float64 `json:"configured"`
+	Observed   float64 `json:"observed"`
+	Count      uint64  `json:"count"`
+}
+
+type Distribution struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+}
+
+func collectStats(ctx context.Context, gen *IdempotentGenerator, start, count uint64) (RangeStats, error) {
+	cfg := gen.cfg
+	st := RangeStats{
+		Start:          start,
+		Count:          count,
+		ClusterSizes:   make(map[int]int),
+		Buckets:        make(map[int]uint64),
+		RecordsByMonth: make(map[string]uint64),
+		Cardinalities:  make(map[string]int),
+	}
+
+	perProfile := make(map[uint64]int)
+	distinct := map[string]map[string]struct{}{
+		"firstName": {}, "lastName": {}, "email": {}, "phone": {},
+		"login": {}, "city": {}, "channel": {}, "pointOfSale": {},
+	}
+	var swaps, translits, firstTypos, lastTypos uint64
+	amounts := make([]float64, 0, count)
+
+	for i := uint64(0); i < count; i++ {
+		if i%progressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return st, err
+			}
+		}
+		var trace DistortionTrace
+		rec := gen.recordByIndex(start+i, &trace)
+
+		perProfile[rec.ProfileID]++
+		st.Buckets[classifyBucket(rec.ProfileID, cfg.Buckets, cfg.Seed).RepeatMultiplier]++
+		if trace.SwapFirstLast {
+			swaps++
+		}
+		if trace.Transliterate {
+			translits++
+		}
+		if trace.FirstNameTypo {
+			firstTypos++
+		}
+		if trace.LastNameTypo {
+			lastTypos++
+		}
+
+		for field, value := range map[string]string{
+			"firstName": rec.FirstName, "lastName": rec.LastName, "email": rec.Email, "phone": rec.Phone,
+			"login": rec.Login, "city": rec.City, "channel": rec.Channel, "pointOfSale": rec.PointOfSale,
+		} {
+			distinct[field][value] = struct{}{}
+		}
+
+		amounts = append(amounts, rec.Amount)
+		if st.FirstTimestamp == "" || rec.Timestamp < st.FirstTimestamp {
+			st.FirstTimestamp = rec.Timestamp
+		}
+		if rec.Timestamp > st.LastTimestamp {
+			st.LastTimestamp = rec.Timestamp
+		}
+		if ts, err := time.Parse(time.RFC3339, rec.Timestamp); err == nil {
+			st.RecordsByMonth[ts.Format("2006-01")]++
+		}
+	}
+
+	st.DistinctProfiles = len(perProfile)
+	for _, n := range perProfile {
+		st.ClusterSizes[n]++
+	}
+	if count > 0 {
+		st.DuplicateRate = float64(count-uint64(len(perProfile))) / float64(count)
+	}
+	for field, values := range distinct {
+		st.Cardinalities[field] = len(values)
+	}
+
+	rate := func(n uint64) float64 {
+		if count == 0 {
+			return 0
+		}
+		return float64(n) / float64(count)
+	}
+	st.Distortions = map[string]DistortionStats{
+		"swapFirstLast": {Configured: clamp01(cfg.Distortions.SwapFirstLast), Observed: rate(swaps), Count: swaps},
+		"transliterate": {Configured: clamp01(cfg.Distortions.Transliterate), Observed: rate(translits), Count: translits},
+		"firstNameTypo": {Configured: clamp01(cfg.Distortions.Typo), Observed: rate(firstTypos), Count: firstTypos},
+		"lastNameTypo":  {Configured: clamp01(cfg.Distortions.Typo), Observed: rate(lastTypos), Count: lastTypos},
+	}
+	st.Amount = distributionOf(amounts)
+	return st, nil
+}
+
+// distributionOf sorts values in place and summarizes them.
+func distributionOf(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	q := func(p float64) float64 {
+		return values[int(math.Min(float64(len(values)-1), p*float64(len(values))))]
+	}
+	return Distribution{
+		Min:  values[0],
+		Max:  values[len(values)-1],
+		Mean: math.Round(sum/float64(len(values))*100) / 100,
+		P50:  q(0.5),
+		P90:  q(0.9),
+		P99:  q(0.99),
+	}
+}
+
+func printStats(w io.Writer, st RangeStats) {
+	fmt.Fprintf(w, "📊 Records [%d, +%d)\n", st.Start, st.Count)
+	fmt.Fprintf(w, "   Distinct profiles: %d\n", st.DistinctProfiles)
+	fmt.Fprintf(w, "   Duplicate rate:    %.2f%%\n", st.DuplicateRate*100)
+
+	fmt.Fprintln(w, "\n🧮 Cluster sizes (records per profile → profiles):")
+	sizes := make([]int, 0, len(st.ClusterSizes))
+	for size := range st.ClusterSizes {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		fmt.Fprintf(w, "   %4d → %d\n", size, st.ClusterSizes[size])
+	}
+
+	fmt.Fprintln(w, "\n🪣 Records by bucket repeat multiplier:")
+	mults := make([]int, 0, len(st.Buckets))
+	for m := range st.Buckets {
+		mults = append(mults, m)
+	}
+	sort.Ints(mults)
+	for _, m := range mults {
+		fmt.Fprintf(w, "   x%-3d %d (%.2f%%)\n", m, st.Buckets[m], float64(st.Buckets[m])/float64(max(st.Count, 1))*100)
+	}
+
+	fmt.Fprintln(w, "\n🌀 Distortions (observed vs configured):")
+	for _, name := range sortedKeys(st.Distortions) {
+		d := st.Distortions[name]
+		fmt.Fprintf(w, "   %-14s %6.2f%% vs %6.2f%% (%d)\n", name, d.Observed*100, d.Configured*100, d.Count)
+	}
+
+	fmt.Fprintln(w, "\n🔢 Field cardinalities:")
+	for _, field := range sortedKeys(st.Cardinalities) {
+		fmt.Fprintf(w, "   %-12s %d\n", field, st.Cardinalities[field])
+	}
+
+	a := st.Amount
+	fmt.Fprintf(w, "\n💰 Amount: min %.2f  p50 %.2f  mean %.2f  p90 %.2f  p99 %.2f  max %.2f\n", a.Min, a.P50, a.Mean, a.P90, a.P99, a.Max)
+
+	fmt.Fprintf(w, "\n🗓️  Timestamps: %s … %s\n", st.FirstTimestamp, st.LastTimestamp)
+	for _, month := range sortedKeys(st.RecordsByMonth) {
+		fmt.Fprintf(w, "   %s %d\n", month, st.RecordsByMonth[month])
+	}
+}
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	config := addConfigFlags(fs, "JSON config (defaults to the built-in config)")
+	start := fs.Uint64("start", 0, "first record index")
+	count := fs.Uint64("count", 100_000, "number of records to scan")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	calibrateMode := fs.Bool("calibrate", false, "compare observed distortion, bucket and variant rates with the config and fail on significant deviations")
+	duplicates := fs.Bool("duplicates", false, "only report the share of records repeating a profile, overall and per bucket")
+	alpha := fs.Float64("alpha", 0.001, "significance level of -calibrate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.load()
+	if err != nil {
+		return err
+	}
+	gen, err := NewIdempotentGenerator(cfg)
+	if err != nil {
+		return err
+	}
+	if *duplicates {
+		st := gen.DuplicateStats(*start, *count)
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(st)
+		}
+		printDuplicateStats(os.Stdout, st)
+		return nil
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	if *calibrateMode {
+		c, err := calibrate(ctx, gen, *start, *count, *alpha)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		} else {
+			printCalibration(os.Stdout, c)
+		}
+		if dev := c.Deviations(); len(dev) > 0 {
+			return fmt.Errorf("%d of %d rates deviate significantly from the config", len(dev), len(c.Checks))
+		}
+		return nil
+	}
+	st, err := collectStats(ctx, gen, *start, *count)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	}
+	printStats(os.Stdout, st)
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleStream pushes records as Server-Sent Events. Query parameters:
+// start (first index), count (0 = unbounded) and rate (records per second,
+// default 10, 0 = unthrottled). Event IDs are record indices, so a
+// reconnecting client's Last-Event-ID resumes exactly where it left off.
+// With watermarkEvery=N, a watermark event follows every N records, with
+// the allowed out-of-orderness given by lateness (a Go duration, default
+// 1h), and a final one ends a bounded stream; a resumed stream starts its
+// watermark afresh.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, err := uintParam(q.Get("start"), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("start: %w", err))
+		return
+	}
+	count, err := uintParam(q.Get("count"), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("count: %w", err))
+		return
+	}
+	rate, err := strconv.ParseFloat(q.Get("rate"), 64)
+	if q.Get("rate") == "" {
+		rate, err = 10, nil
+	}
+	if err != nil || rate < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("rate must be a non-negative number"))
+		return
+	}
+
+	watermarkEvery, err := uintParam(q.Get("watermarkEvery"), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("watermarkEvery: %w", err))
+		return
+	}
+	lateness := time.Hour
+	if l := q.Get("lateness"); l != "" {
+		if lateness, err = time.ParseDuration(l); err != nil || lateness < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("lateness must be a non-negative duration"))
+			return
+		}
+	}
+
+	end := uint64(0)
+	if count > 0 {
+		end = start + count
+	}
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		idx, err := strconv.ParseUint(last, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid Last-Event-ID"))
+			return
+		}
+		start = idx + 1
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	v := s.resolve(w, datasetName(r), q.Get("version"))
+	if v == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	watermarks := newWatermarker(watermarkEvery, lateness)
+	for idx := start; end == 0 || idx < end; idx++ {
+		if tick != nil {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-tick:
+			}
+		} else if r.Context().Err() != nil {
+			return
+		}
+
+		rec := v.gen.RecordByIndex(idx)
+		data := v.gen.Schema().AppendJSON(nil, &rec)
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: record\ndata: %s\n\n", idx, data); err != nil {
+			return
+		}
+		if mark, ok := watermarks.observe(&rec); ok {
+			writeWatermarkEvent(w, mark)
+		}
+		flusher.Flush()
+		metrics.recordsGenerated.Inc("stream")
+	}
+	if mark, ok := watermarks.final(end - 1); ok && end > start {
+		writeWatermarkEvent(w, mark)
+	}
+	fmt.Fprint(w, "event: end\ndata: {}\n\n")
+	flusher.Flush()
+}
+
+func uintParam(value string, def uint64) (uint64, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// writeWatermarkEvent writes a watermark as an event without an ID, so it
+// does not move a client's resume position.
+func writeWatermarkEvent(w io.Writer, mark Watermark) {
+	data, _ := json.Marshal(mark)
+	fmt.Fprintf(w, "event: watermark\ndata: %s\n\n", data)
+}
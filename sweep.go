@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Config sweeps: a base config and a grid of overrides — say typo rates
+// crossed with bucket distributions — generated once per combination into
+// its own directory with its own manifest, plus a summary table of what
+// each combination produced, for matcher-sensitivity experiments.
+
+// sweepAxis is one swept config key and the values it takes.
+type sweepAxis struct {
+	Key    string
+	Values []string
+}
+
+// parseSweepAxis parses key=v1|v2|..., each value JSON or a plain string as
+// in -set.
+func parseSweepAxis(s string) (sweepAxis, error) {
+	key, values, ok := strings.Cut(s, "=")
+	if !ok || key == "" || values == "" {
+		return sweepAxis{}, fmt.Errorf("invalid -axis %q, want key=value|value|...", s)
+	}
+	return sweepAxis{key, strings.Split(values, "|")}, nil
+}
+
+// readSweepGrid reads a JSON object mapping config keys to arrays of values.
+func readSweepGrid(path string) ([]sweepAxis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var grid map[string][]json.RawMessage
+	if err := json.Unmarshal(data, &grid); err != nil {
+		return nil, fmt.Errorf("%s: want an object of key → array of values: %w", path, err)
+	}
+	var axes []sweepAxis
+	for _, key := range sortedKeys(grid) {
+		if len(grid[key]) == 0 {
+			return nil, fmt.Errorf("%s: %s has no values", path, key)
+		}
+		axis := sweepAxis{Key: key}
+		for _, v := range grid[key] {
+			axis.Values = append(axis.Values, string(v))
+		}
+		axes = append(axes, axis)
+	}
+	return axes, nil
+}
+
+// sweepCombinations is the cartesian product of axes as override lists,
+// the last axis varying fastest.
+func sweepCombinations(axes []sweepAxis) [][]ConfigOverride {
+	combos := [][]ConfigOverride{nil}
+	for _, axis := range axes {
+		var next [][]ConfigOverride
+		for _, combo := range combos {
+			for _, v := range axis.Values {
+				c := append(append([]ConfigOverride(nil), combo...), ConfigOverride{Key: axis.Key, Value: v, Source: "sweep"})
+				next = append(next, c)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// SweepResult is one row of the summary.
+type SweepResult struct {
+	Dir              string             `json:"dir"`
+	Overrides        []ConfigOverride   `json:"overrides"`
+	ConfigHash       string             `json:"configHash"`
+	Records          uint64             `json:"records"`
+	Bytes            int64              `json:"bytes"`
+	DistinctProfiles int                `json:"distinctProfiles"`
+	DuplicateRate    float64            `json:"duplicateRate"`
+	Distortions      map[string]float64 `json:"distortions"`
+}
+
+func printSweep(w io.Writer, axes []sweepAxis, results []SweepResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "dir\t")
+	for _, axis := range axes {
+		fmt.Fprintf(tw, "%s\t", axis.Key)
+	}
+	fmt.Fprintln(tw, "config\trecords\tprofiles\tduplicates\tswap\ttranslit\ttypo first\ttypo last\tbytes")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t", filepath.Base(r.Dir))
+		for _, o := range r.Overrides {
+			v := o.Value
+			if len(v) > 32 {
+				v = v[:29] + "..."
+			}
+			fmt.Fprintf(tw, "%s\t", v)
+		}
+		d := r.Distortions
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%.2f%%\t%.2f%%\t%.2f%%\t%.2f%%\t%d\n", r.ConfigHash, r.Records, r.DistinctProfiles, r.DuplicateRate*100,
+			d["swapFirstLast"]*100, d["transliterate"]*100, d["firstNameTypo"]*100, d["lastNameTypo"]*100, r.Bytes)
+	}
+	tw.Flush()
+}
+
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	config := addConfigFlags(fs, "base JSON config (defaults to the built-in config)")
+	var axisFlags stringList
+	fs.Var(&axisFlags, "axis", "swept key and values as key=v1|v2|..., e.g. distortions.typo=0.01|0.05|0.2 (repeatable)")
+	gridPath := fs.String("grid", "", "JSON file mapping config keys to arrays of values, swept after the -axis flags")
+	start := fs.Uint64("start", 0, "first record index of every dataset")
+	count := fs.Uint64("count", 100_000, "records per combination; a small count makes each dataset a sample")
+	dir := fs.String("dir", "output/sweep", "directory the per-combination datasets and summary.json are written to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var axes []sweepAxis
+	for _, s := range axisFlags {
+		axis, err := parseSweepAxis(s)
+		if err != nil {
+			return err
+		}
+		axes = append(axes, axis)
+	}
+	if *gridPath != "" {
+		grid, err := readSweepGrid(*gridPath)
+		if err != nil {
+			return err
+		}
+		axes = append(axes, grid...)
+	}
+	if len(axes) == 0 {
+		return errors.New("nothing to sweep: give -axis or -grid")
+	}
+
+	base, err := config.load()
+	if err != nil {
+		return err
+	}
+	combos := sweepCombinations(axes)
+	// Every combination must make a valid config before any is generated.
+	configs := make([]GeneratorConfig, len(combos))
+	for i, combo := range combos {
+		if configs[i], err = applyOverrides(cloneConfig(base), combo); err != nil {
+			return fmt.Errorf("combination %d: %w", i, err)
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	log := logFor("sweep")
+	results := make([]SweepResult, 0, len(combos))
+	for i, cfg := range configs {
+		began := time.Now()
+		out := filepath.Join(*dir, fmt.Sprintf("%03d", i))
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+		gen, err := NewIdempotentGenerator(cfg)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(out, "records.jsonl")
+		file, err := writeRangeFile(ctx, gen, formatJSONL, path, *start, *count, nil)
+		if err != nil {
+			return err
+		}
+		manifest := Manifest{
+			ConfigHash: configHash(cfg),
+			Config:     cfg,
+			Overrides:  append(append([]ConfigOverride(nil), config.overrides...), combos[i]...),
+			Format:     formatJSONL.name,
+			Start:      *start,
+			Count:      *count,
+			Files:      []ManifestFile{file},
+			CreatedAt:  gen.now().UTC(),
+		}
+		if err := writeManifest(path+".manifest.json", manifest); err != nil {
+			return err
+		}
+		st, err := collectStats(ctx, gen, *start, *count)
+		if err != nil {
+			return err
+		}
+		r := SweepResult{
+			Dir: out, Overrides: combos[i], ConfigHash: manifest.ConfigHash, Records: *count, Bytes: file.Bytes,
+			DistinctProfiles: st.DistinctProfiles, DuplicateRate: st.DuplicateRate, Distortions: map[string]float64{},
+		}
+		for name, d := range st.Distortions {
+			r.Distortions[name] = d.Observed
+		}
+		results = append(results, r)
+		log.Info("generated combination", "dir", out, "of", len(combos), "configHash", r.ConfigHash, "duration", time.Since(began).Round(time.Millisecond))
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "summary.json"), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	printSweep(os.Stdout, axes, results)
+	return nil
+}
@@ -0,0 +1,60 @@
+// Command wasmplugin is an example generator plugin. It adds a loyaltyId
+// field to every record and distorts records by upper-casing the last
+// name. Build it with
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o wasmplugin.wasm ./testdata/wasmplugin
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+func main() {}
+
+//go:wasmimport gen rand
+func rand() uint64
+
+// buf holds the input of the current call; result holds its output.
+var buf, result []byte
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	buf = make([]byte, size)
+	if size == 0 {
+		return 0
+	}
+	return uint32(uintptr(unsafe.Pointer(&buf[0])))
+}
+
+// run decodes the record at buf, lets change set fields and returns the
+// changed fields packed as the host expects.
+func run(size uint32, change func(rec, out map[string]string)) uint64 {
+	var rec map[string]string
+	if err := json.Unmarshal(buf[:size], &rec); err != nil {
+		panic(err)
+	}
+	out := map[string]string{}
+	change(rec, out)
+	result, _ = json.Marshal(out)
+	if len(result) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&result[0])))<<32 | uint64(len(result))
+}
+
+//go:wasmexport fields
+func fields(ptr, size uint32) uint64 {
+	return run(size, func(rec, out map[string]string) {
+		out["loyaltyId"] = fmt.Sprintf("L%08d", rand()%100_000_000)
+	})
+}
+
+//go:wasmexport distort
+func distort(ptr, size uint32) uint64 {
+	return run(size, func(rec, out map[string]string) {
+		out["lastName"] = strings.ToUpper(rec["lastName"])
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	// Embed the zone database so local times work on hosts without one.
+	// Hosts that have their own database use it, so local offsets follow
+	// that database's version; the UTC timestamp never depends on it.
+	_ "time/tzdata"
+)
+
+// defaultCityTimezones covers the default city pool plus a few cities with
+// daylight saving time. The "*" entry applies to cities not listed.
+var defaultCityTimezones = map[string]string{
+	"Москва":          "Europe/Moscow",
+	"Санкт-Петербург": "Europe/Moscow",
+	"Казань":          "Europe/Moscow",
+	"Новосибирск":     "Asia/Novosibirsk",
+	"Екатеринбург":    "Asia/Yekaterinburg",
+	"Минск":           "Europe/Minsk",
+	"Алматы":          "Asia/Almaty",
+	"Варшава":         "Europe/Warsaw",
+	"Берлин":          "Europe/Berlin",
+	"Рига":            "Europe/Riga",
+	"*":               "UTC",
+}
+
+var locationCache sync.Map // zone name → *time.Location
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+func cloneTimezones(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// validateTimezones checks that every zone exists and every pool city has
+// one, directly or through "*".
+func validateTimezones(m map[string]string, cities []string) error {
+	for city, zone := range m {
+		if _, err := loadLocation(zone); err != nil {
+			return fmt.Errorf("cityTimezones[%s]: %w", city, err)
+		}
+	}
+	if _, ok := m["*"]; ok {
+		return nil
+	}
+	for _, city := range cities {
+		if _, ok := m[city]; !ok {
+			return fmt.Errorf("cityTimezones: no zone for city %q and no \"*\" fallback", city)
+		}
+	}
+	return nil
+}
+
+// cityTimezone returns the zone of a city; validation guarantees one exists.
+func cityTimezone(m map[string]string, city string) (string, *time.Location) {
+	zone, ok := m[city]
+	if !ok {
+		zone = m["*"]
+	}
+	loc, err := loadLocation(zone)
+	if err != nil {
+		return "UTC", time.UTC
+	}
+	return zone, loc
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimal OpenTelemetry-compatible tracing: spans are exported as OTLP/HTTP
+// JSON to OTEL_EXPORTER_OTLP_ENDPOINT and trace context travels in W3C
+// traceparent headers. Without an endpoint every span is a no-op.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]))
+}
+
+func parseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	return sc, sc.IsValid()
+}
+
+type spanKind int
+
+const (
+	spanKindInternal spanKind = 1
+	spanKindServer   spanKind = 2
+)
+
+type Span struct {
+	sc     SpanContext
+	parent [8]byte
+	name   string
+	kind   spanKind
+	start  time.Time
+	end    time.Time
+	attrs  map[string]string
+	err    string
+}
+
+// SetAttr attaches a string attribute; it is safe to call on a nil span.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs[key] = fmt.Sprint(value)
+}
+
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err.Error()
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	tracer.export(s)
+}
+
+type spanContextKey struct{}
+
+func contextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+func spanContextFrom(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// StartSpan begins a child of the span in ctx (or a new trace). It returns a
+// nil span when tracing is disabled.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	return startSpan(ctx, name, spanKindInternal)
+}
+
+func startSpan(ctx context.Context, name string, kind spanKind) (context.Context, *Span) {
+	if !tracer.enabled() {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, kind: kind, start: time.Now(), attrs: make(map[string]string)}
+	if parent, ok := spanContextFrom(ctx); ok {
+		span.sc.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+	} else {
+		putRandom(span.sc.TraceID[:])
+	}
+	putRandom(span.sc.SpanID[:])
+	return contextWithSpanContext(ctx, span.sc), span
+}
+
+func putRandom(b []byte) {
+	for i := range b {
+		b[i] = byte(rand.Uint32())
+	}
+}
+
+// tracingMiddleware starts a server span per request, continuing any trace
+// announced in the traceparent header.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = contextWithSpanContext(ctx, sc)
+		}
+		ctx, span := startSpan(ctx, r.Method+" "+r.URL.Path, spanKindServer)
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.target", r.URL.Path)
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Exporter
+const (
+	traceBatchSize     = 512
+	traceFlushInterval = 2 * time.Second
+)
+
+type otlpTracer struct {
+	endpoint    string
+	serviceName string
+	pending     []*Span
+	spans       chan *Span
+	done        chan struct{}
+}
+
+var tracer = &otlpTracer{}
+
+func (t *otlpTracer) enabled() bool {
+	return t.spans != nil
+}
+
+func (t *otlpTracer) export(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+		// Never block generation on a slow collector.
+		metrics.errors.Inc("trace_dropped")
+	}
+}
+
+// setupTracing enables the exporter from the standard OTEL_* environment
+// variables and returns a function that flushes outstanding spans.
+func setupTracing() func() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return func() {}
+	}
+
+	tracer.endpoint = endpoint
+	tracer.serviceName = os.Getenv("OTEL_SERVICE_NAME")
+	if tracer.serviceName == "" {
+		tracer.serviceName = "idempotent-generator"
+	}
+	tracer.spans = make(chan *Span, 4*traceBatchSize)
+	tracer.done = make(chan struct{})
+	go tracer.loop()
+
+	return func() {
+		close(tracer.spans)
+		<-tracer.done
+	}
+}
+
+func (t *otlpTracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s, ok := <-t.spans:
+			if !ok {
+				t.flush()
+				return
+			}
+			t.pending = append(t.pending, s)
+			if len(t.pending) >= traceBatchSize {
+				t.flush()
+			}
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              spanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+func keyValue(key, value string) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	kv.Value.StringValue = value
+	return kv
+}
+
+func (t *otlpTracer) flush() {
+	if len(t.pending) == 0 {
+		return
+	}
+	spans := make([]otlpSpan, len(t.pending))
+	for i, s := range t.pending {
+		out := otlpSpan{
+			TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			out.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, k := range sortedKeys(s.attrs) {
+			out.Attributes = append(out.Attributes, keyValue(k, s.attrs[k]))
+		}
+		if s.err != "" {
+			out.Status.Code = 2
+			out.Status.Message = s.err
+		}
+		spans[i] = out
+	}
+	t.pending = t.pending[:0]
+
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpKeyValue{keyValue("service.name", t.serviceName)},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "idempotent-generator"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		metrics.errors.Inc("trace_export")
+		return
+	}
+
+	resp, err := http.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		metrics.errors.Inc("trace_export")
+		logFor("tracing").Warn("span export failed", "endpoint", t.endpoint, "spans", len(spans), "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		metrics.errors.Inc("trace_export")
+		logFor("tracing").Warn("span export rejected", "endpoint", t.endpoint, "spans", len(spans), "status", resp.StatusCode)
+	}
+}
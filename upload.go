@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Remote delivery of generated files over SFTP or WebDAV. Each file is
+// uploaded under a .part name and renamed once complete, so a consumer
+// polling the directory never sees a partial file; the manifest goes last
+// and marks the delivery complete. Files already delivered with the right
+// size are skipped, so an interrupted delivery resumes where it stopped —
+// over SFTP within a partly uploaded file too.
+
+const partSuffix = ".part"
+
+// remoteUpload is one file to deliver.
+type remoteUpload struct {
+	local   string
+	name    string
+	size    int64
+	partial int64 // bytes of name.part already uploaded, -1 if none
+	exists  bool  // a file called name exists but is stale
+}
+
+// fileSink is a remote directory files are delivered into.
+type fileSink interface {
+	// list returns the directory's regular files and their sizes,
+	// creating the directory if it is missing.
+	list(ctx context.Context) (map[string]int64, error)
+	// deliver uploads each file as name.part and renames it to name.
+	deliver(ctx context.Context, uploads []remoteUpload) error
+}
+
+// sftpSink drives the OpenSSH sftp client in batch mode, so keys, agents
+// and known_hosts work as they do for ssh.
+type sftpSink struct {
+	target   string // [user@]host
+	port     string
+	dir      string
+	identity string
+}
+
+// sftpQuote quotes a path for an sftp batch file.
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (s *sftpSink) run(ctx context.Context, script string) (string, error) {
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if s.port != "" {
+		args = append(args, "-P", s.port)
+	}
+	if s.identity != "" {
+		args = append(args, "-i", s.identity)
+	}
+	cmd := exec.CommandContext(ctx, "sftp", append(args, s.target)...)
+	cmd.Stdin = strings.NewReader(script)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("sftp: %w", err)
+	}
+	return string(out), nil
+}
+
+func (s *sftpSink) list(ctx context.Context) (map[string]int64, error) {
+	out, err := s.run(ctx, fmt.Sprintf("-mkdir %s\ncd %s\nls -ln\n", sftpQuote(s.dir), sftpQuote(s.dir)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]int64{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		// -rw-r--r--    1 1000     1000       123 Jan  1 00:00 name
+		f := strings.Fields(sc.Text())
+		if len(f) < 9 || !strings.HasPrefix(f[0], "-") {
+			continue
+		}
+		if size, err := strconv.ParseInt(f[4], 10, 64); err == nil {
+			files[strings.Join(f[8:], " ")] = size
+		}
+	}
+	return files, nil
+}
+
+func (s *sftpSink) deliver(ctx context.Context, uploads []remoteUpload) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cd %s\n", sftpQuote(s.dir))
+	for _, u := range uploads {
+		part := sftpQuote(u.name + partSuffix)
+		if u.partial > 0 {
+			fmt.Fprintf(&b, "reput %s %s\n", sftpQuote(u.local), part)
+		} else {
+			fmt.Fprintf(&b, "put %s %s\n", sftpQuote(u.local), part)
+		}
+		if u.exists {
+			fmt.Fprintf(&b, "rm %s\n", sftpQuote(u.name))
+		}
+		fmt.Fprintf(&b, "rename %s %s\n", part, sftpQuote(u.name))
+	}
+	_, err := s.run(ctx, b.String())
+	return err
+}
+
+// webDAVSink uploads with PUT and renames with MOVE. WebDAV has no standard
+// way to append to a file, so partial uploads restart.
+type webDAVSink struct {
+	dir    *url.URL // ends in a slash
+	client *http.Client
+}
+
+func (s *webDAVSink) do(ctx context.Context, method, name string, body io.Reader, size int64, header map[string]string) (*http.Response, error) {
+	target := s.dir
+	if name != "" {
+		target = s.dir.JoinPath(name)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if u := s.dir.User; u != nil {
+		pass, ok := u.Password()
+		if !ok {
+			pass = os.Getenv("WEBDAV_PASSWORD")
+		}
+		req.SetBasicAuth(u.Username(), pass)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return s.client.Do(req)
+}
+
+// call sends a request whose response has no body of interest and turns
+// a status other than ok into an error.
+func (s *webDAVSink) call(ctx context.Context, method, name string, header map[string]string, body io.Reader, size int64, ok ...int) error {
+	resp, err := s.do(ctx, method, name, body, size, header)
+	if err != nil {
+		return err
+	}
+	return s.check(resp, ok...)
+}
+
+// check closes resp and turns a status other than ok into an error.
+func (s *webDAVSink) check(resp *http.Response, ok ...int) error {
+	defer resp.Body.Close()
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			io.Copy(io.Discard, resp.Body)
+			return nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("webdav: %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+}
+
+// davMultistatus is the part of a PROPFIND response listing file sizes.
+type davMultistatus struct {
+	Responses []struct {
+		Href  string `xml:"href"`
+		Props []struct {
+			Length     string    `xml:"prop>getcontentlength"`
+			Collection *struct{} `xml:"prop>resourcetype>collection"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (s *webDAVSink) list(ctx context.Context) (map[string]int64, error) {
+	// MKCOL answers 405 Method Not Allowed when the collection exists.
+	if err := s.call(ctx, "MKCOL", "", nil, nil, 0, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+		return nil, err
+	}
+	const propfind = `<?xml version="1.0"?><propfind xmlns="DAV:"><prop><getcontentlength/><resourcetype/></prop></propfind>`
+	resp, err := s.do(ctx, "PROPFIND", "", strings.NewReader(propfind), int64(len(propfind)), map[string]string{"Depth": "1", "Content-Type": "application/xml"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, s.check(resp)
+	}
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("webdav: PROPFIND response: %w", err)
+	}
+	files := map[string]int64{}
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil || strings.HasSuffix(href, "/") {
+			continue
+		}
+		for _, p := range r.Props {
+			if p.Collection != nil || p.Length == "" {
+				continue
+			}
+			if size, err := strconv.ParseInt(p.Length, 10, 64); err == nil {
+				files[path.Base(href)] = size
+			}
+		}
+	}
+	return files, nil
+}
+
+func (s *webDAVSink) deliver(ctx context.Context, uploads []remoteUpload) error {
+	for _, u := range uploads {
+		file, err := os.Open(u.local)
+		if err != nil {
+			return err
+		}
+		err = s.call(ctx, http.MethodPut, u.name+partSuffix, nil, file, u.size, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		dest := *s.dir.JoinPath(u.name)
+		dest.User = nil
+		move := map[string]string{"Destination": dest.String(), "Overwrite": "T"}
+		if err := s.call(ctx, "MOVE", u.name+partSuffix, move, nil, 0, http.StatusCreated, http.StatusNoContent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newFileSink returns the sink for an sftp:// or http(s):// WebDAV URL.
+func newFileSink(rawURL, identity string) (fileSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "sftp":
+		if _, err := exec.LookPath("sftp"); err != nil {
+			return nil, fmt.Errorf("sftp is needed to deliver to %s: %w", u.Redacted(), err)
+		}
+		if _, ok := u.User.Password(); ok {
+			return nil, errors.New("sftp URLs cannot carry a password; use a key (-identity) or an agent")
+		}
+		target := u.Hostname()
+		if u.User != nil {
+			target = u.User.Username() + "@" + target
+		}
+		dir := strings.TrimPrefix(u.Path, "/")
+		if dir == "" {
+			dir = "."
+		}
+		return &sftpSink{target: target, port: u.Port(), dir: dir, identity: identity}, nil
+	case "http", "https":
+		dir := *u
+		if !strings.HasSuffix(dir.Path, "/") {
+			dir.Path += "/"
+		}
+		return &webDAVSink{dir: &dir, client: &http.Client{Timeout: 30 * time.Minute}}, nil
+	}
+	return nil, fmt.Errorf("unsupported destination scheme %q (want sftp, http or https)", u.Scheme)
+}
+
+// localManifestFile finds a manifest entry's file: at its recorded path,
+// or next to the manifest when the directory was moved.
+func localManifestFile(manifestPath string, f ManifestFile) (string, error) {
+	for _, p := range []string{f.Path, filepath.Join(filepath.Dir(manifestPath), filepath.Base(f.Path))} {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if info.Size() != f.Bytes {
+			return "", fmt.Errorf("%s is %d bytes, the manifest says %d", p, info.Size(), f.Bytes)
+		}
+		return p, nil
+	}
+	return "", fmt.Errorf("%s: not found", f.Path)
+}
+
+func runUpload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	manifestPath := fs.String("manifest", "", "manifest of the files to deliver")
+	to := fs.String("to", "", "destination directory: sftp://[user@]host[:port]/path, relative to the login directory, or a WebDAV http(s):// URL (password default $WEBDAV_PASSWORD)")
+	identity := fs.String("identity", "", "SSH private key for sftp (default: ssh's own configuration)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *manifestPath == "" || *to == "" {
+		return errors.New("-manifest and -to are required")
+	}
+	dest := *to
+	if u, err := url.Parse(*to); err == nil {
+		dest = u.Redacted()
+	}
+
+	m, err := readManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	sink, err := newFileSink(*to, *identity)
+	if err != nil {
+		return err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// The delivered manifest names files as they are in the remote directory.
+	var uploads []remoteUpload
+	remote := m
+	remote.Files = make([]ManifestFile, len(m.Files))
+	for i, f := range m.Files {
+		local, err := localManifestFile(*manifestPath, f)
+		if err != nil {
+			return err
+		}
+		remote.Files[i] = f
+		remote.Files[i].Path = filepath.Base(f.Path)
+		uploads = append(uploads, remoteUpload{local: local, name: remote.Files[i].Path, size: f.Bytes})
+	}
+	data, err := json.MarshalIndent(remote, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp("", "manifest-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(data, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	uploads = append(uploads, remoteUpload{local: tmp.Name(), name: filepath.Base(*manifestPath), size: int64(len(data) + 1)})
+
+	existing, err := sink.list(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []remoteUpload
+	for _, u := range uploads {
+		size, ok := existing[u.name]
+		if ok && size == u.size && u.local != tmp.Name() {
+			continue
+		}
+		u.exists = ok
+		u.partial = -1
+		if size, ok := existing[u.name+partSuffix]; ok && size <= u.size {
+			u.partial = size
+		}
+		pending = append(pending, u)
+	}
+	log := logFor("upload")
+	log.Info("delivering files", "to", dest, "files", len(pending), "skipped", len(uploads)-len(pending))
+	if err := sink.deliver(ctx, pending); err != nil {
+		return err
+	}
+	log.Info("delivered", "to", dest, "manifest", filepath.Base(*manifestPath))
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Record validation: JSONL records, generated here or elsewhere, checked
+// against field rules — schema types and required fields, email syntax,
+// E.164 phones, RFC 3339 timestamps, amount bounds — with violation counts
+// per rule and an example of each.
+
+// ValidationRule checks one property of a decoded record and returns a
+// description of the problem, or "" when the record passes.
+type ValidationRule struct {
+	Name  string
+	Check func(rec map[string]any) string
+}
+
+var (
+	// emailPattern accepts internationalized local parts and domains, as
+	// SMTPUTF8 allows, but no spaces, no empty labels and a letter TLD.
+	emailPattern = regexp.MustCompile(`^[\p{L}\p{N}!#$%&'*+/=?^_` + "`" + `{|}~-]+(?:\.[\p{L}\p{N}!#$%&'*+/=?^_` + "`" + `{|}~-]+)*@(?:[\p{L}\p{N}](?:[\p{L}\p{N}-]*[\p{L}\p{N}])?\.)+\p{L}{2,}$`)
+	e164Pattern  = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+)
+
+// stringFieldRule checks every non-empty string value of fields with ok.
+func stringFieldRule(name string, fields []string, ok func(string) bool) ValidationRule {
+	return ValidationRule{name, func(rec map[string]any) string {
+		for _, field := range fields {
+			if s, _ := rec[field].(string); s != "" && !ok(s) {
+				return fmt.Sprintf("%s %q", field, s)
+			}
+		}
+		return ""
+	}}
+}
+
+// DefaultValidationRules are the rules of NewValidator, amount bounds
+// aside.
+var DefaultValidationRules = []ValidationRule{
+	{"schema", checkSchemaTypes},
+	stringFieldRule("email-syntax", []string{"email", "emailCanonical"}, emailPattern.MatchString),
+	stringFieldRule("e164-phone", []string{"phone"}, e164Pattern.MatchString),
+	stringFieldRule("rfc3339-timestamp", []string{"timestamp", "localTimestamp", "sessionStart"}, func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	}),
+	stringFieldRule("iso-date", []string{"birthDate"}, func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	}),
+	// Decoding replaces invalid UTF-8 with U+FFFD, as the encoders do, so
+	// broken text shows up as replacement characters.
+	{"utf8", func(rec map[string]any) string {
+		for _, field := range sortedKeys(rec) {
+			if s, ok := rec[field].(string); ok && strings.ContainsRune(s, utf8.RuneError) {
+				return fmt.Sprintf("%s %q", field, s)
+			}
+		}
+		return ""
+	}},
+}
+
+// checkSchemaTypes checks that every field of the record schema that is
+// present has its JSON type, that fields never left out are present, and
+// that there are no unknown fields.
+func checkSchemaTypes(rec map[string]any) string {
+	for _, f := range recordFields {
+		v, present := rec[f.Name]
+		if !present {
+			if !f.Optional && !f.ptr {
+				return "missing " + f.Name
+			}
+			continue
+		}
+		switch f.Type {
+		case FieldString:
+			if _, ok := v.(string); !ok {
+				return fmt.Sprintf("%s is %v, not a string", f.Name, v)
+			}
+		case FieldInt, FieldUint, FieldFloat:
+			n, ok := v.(json.Number)
+			if !ok {
+				return fmt.Sprintf("%s is %v, not a number", f.Name, v)
+			}
+			if _, err := n.Int64(); f.Type == FieldInt && err != nil {
+				return fmt.Sprintf("%s is %s, not an integer", f.Name, n)
+			}
+			if _, err := strconv.ParseUint(n.String(), 10, 64); f.Type == FieldUint && err != nil {
+				return fmt.Sprintf("%s is %s, not an unsigned integer", f.Name, n)
+			}
+		}
+	}
+	for name := range rec {
+		if _, ok := recordFieldIndex[name]; !ok {
+			return "unknown field " + name
+		}
+	}
+	return ""
+}
+
+// amountRule checks that amount lies in [min, max].
+func amountRule(min, max float64) ValidationRule {
+	return ValidationRule{"amount-bounds", func(rec map[string]any) string {
+		n, ok := rec["amount"].(json.Number)
+		if !ok {
+			return ""
+		}
+		if f, err := n.Float64(); err != nil || f < min || f > max || math.IsNaN(f) {
+			return fmt.Sprintf("amount %s outside [%g, %g]", n, min, max)
+		}
+		return ""
+	}}
+}
+
+// Validator checks records against a set of rules.
+type Validator struct {
+	Rules []ValidationRule
+}
+
+// NewValidator returns a validator with the default rules and amounts
+// bounded by [amountMin, amountMax].
+func NewValidator(amountMin, amountMax float64) *Validator {
+	return &Validator{Rules: append(append([]ValidationRule(nil), DefaultValidationRules...), amountRule(amountMin, amountMax))}
+}
+
+// ValidationReport counts the violations of each rule in a stream.
+type ValidationReport struct {
+	Records        uint64            `json:"records"`
+	InvalidRecords uint64            `json:"invalidRecords"`
+	Violations     map[string]uint64 `json:"violations"`
+	// Examples hold the first violation of each rule, with its line.
+	Examples map[string]string `json:"examples"`
+}
+
+// Stream validates every JSONL record read from r into report. Lines that
+// are not JSON objects count against the "json" rule.
+func (v *Validator) Stream(r io.Reader, name string, report *ValidationReport) error {
+	if report.Violations == nil {
+		report.Violations, report.Examples = map[string]uint64{}, map[string]string{}
+	}
+	violate := func(rule, where, detail string) {
+		if report.Violations[rule]++; report.Examples[rule] == "" {
+			report.Examples[rule] = where + ": " + detail
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		report.Records++
+		where := fmt.Sprintf("%s:%d", name, line)
+		var rec map[string]any
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&rec); err != nil || rec == nil {
+			report.InvalidRecords++
+			violate("json", where, fmt.Sprint("not a JSON object: ", err))
+			continue
+		}
+		invalid := false
+		for _, rule := range v.Rules {
+			if detail := rule.Check(rec); detail != "" {
+				invalid = true
+				violate(rule.Name, where, detail)
+			}
+		}
+		if invalid {
+			report.InvalidRecords++
+		}
+	}
+	return scanner.Err()
+}
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	amountMin := fs.Float64("amount-min", 0, "smallest valid amount")
+	amountMax := fs.Float64("amount-max", 1_000_000, "largest valid amount")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	v := NewValidator(*amountMin, *amountMax)
+	var report ValidationReport
+	if fs.NArg() == 0 {
+		if err := v.Stream(os.Stdin, "stdin", &report); err != nil {
+			return err
+		}
+	}
+	for _, path := range fs.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = v.Stream(file, path, &report)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("🔎 %d records, %d invalid\n", report.Records, report.InvalidRecords)
+		names := []string{"json"}
+		for _, rule := range v.Rules {
+			names = append(names, rule.Name)
+		}
+		for _, name := range names {
+			n := report.Violations[name]
+			if n == 0 && name == "json" {
+				continue
+			}
+			mark := "✅"
+			if n > 0 {
+				mark = "❌"
+			}
+			fmt.Printf("   %s %-18s %d", mark, name, n)
+			if ex := report.Examples[name]; ex != "" {
+				fmt.Printf("  e.g. %s", ex)
+			}
+			fmt.Println()
+		}
+	}
+	if report.InvalidRecords > 0 {
+		return fmt.Errorf("%d of %d records violate a rule", report.InvalidRecords, report.Records)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"syscall/js"
+)
+
+// WebAssembly build: instead of the CLI, main exposes a small JS API on
+// globalThis.idempotentEntries so browser demos and documentation sandboxes
+// can show deterministic records without a backend:
+//
+//	GOOS=js GOARCH=wasm go build -o generator.wasm .
+//
+// and, after loading it with Go's wasm_exec.js:
+//
+//	idempotentEntries.recordByIndex(42)          // record object
+//	idempotentEntries.profileById("123456789")   // profile object
+//	idempotentEntries.configure('{"seed": 7}')   // or a preset name
+//
+// Indices and IDs may be numbers, strings or BigInts; pass strings or
+// BigInts above 2^53. Functions return an Error object on bad input.
+
+func init() {
+	platformMain = serveJSAPI
+}
+
+// jsAPI holds the generator the API serves; configure replaces it.
+var jsAPI = struct {
+	sync.Mutex
+	gen *IdempotentGenerator
+}{gen: mustNewGenerator(defaultConfig, WithCache(10_000))}
+
+func jsGenerator() *IdempotentGenerator {
+	jsAPI.Lock()
+	defer jsAPI.Unlock()
+	return jsAPI.gen
+}
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
+
+// jsUint reads a uint64 from a JS number, string or BigInt. It formats v
+// with String() rather than switching on its type: syscall/js cannot type
+// BigInts.
+func jsUint(v js.Value) (uint64, error) {
+	s := js.Global().Call("String", v).String()
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an unsigned integer", s)
+	}
+	return n, nil
+}
+
+// jsJSON turns encoded JSON into a JS object.
+func jsJSON(data []byte) js.Value {
+	return js.Global().Get("JSON").Call("parse", string(data))
+}
+
+func jsFunc(fn func(args []js.Value) (js.Value, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		v, err := fn(args)
+		if err != nil {
+			return jsError(err)
+		}
+		return v
+	})
+}
+
+func serveJSAPI() {
+	api := map[string]any{
+		"version": strconv.Itoa(generatorVersion),
+		"recordByIndex": jsFunc(func(args []js.Value) (js.Value, error) {
+			if len(args) != 1 {
+				return js.Null(), fmt.Errorf("recordByIndex(index) takes 1 argument")
+			}
+			idx, err := jsUint(args[0])
+			if err != nil {
+				return js.Null(), err
+			}
+			gen := jsGenerator()
+			rec := gen.RecordByIndex(idx)
+			return jsJSON(gen.Schema().AppendJSON(nil, &rec)), nil
+		}),
+		"profileById": jsFunc(func(args []js.Value) (js.Value, error) {
+			if len(args) != 1 {
+				return js.Null(), fmt.Errorf("profileById(id) takes 1 argument")
+			}
+			id, err := jsUint(args[0])
+			if err != nil {
+				return js.Null(), err
+			}
+			data, err := json.Marshal(jsGenerator().ProfileByID(id))
+			if err != nil {
+				return js.Null(), err
+			}
+			return jsJSON(data), nil
+		}),
+		"configure": jsFunc(func(args []js.Value) (js.Value, error) {
+			if len(args) != 1 || args[0].Type() != js.TypeString {
+				return js.Null(), fmt.Errorf("configure(configJSON or preset name) takes 1 string")
+			}
+			s := args[0].String()
+			cfg, err := presetConfig(s)
+			if err != nil {
+				if cfg, err = parseConfig([]byte(s)); err != nil {
+					return js.Null(), err
+				}
+			}
+			gen, err := NewIdempotentGenerator(cfg, WithCache(10_000))
+			if err != nil {
+				return js.Null(), err
+			}
+			jsAPI.Lock()
+			jsAPI.gen = gen
+			jsAPI.Unlock()
+			return js.ValueOf(configHash(cfg)), nil
+		}),
+	}
+	js.Global().Set("idempotentEntries", js.ValueOf(api))
+	select {}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"time"
+)
+
+// Event-time watermarks for streaming fixtures: a control message every N
+// records carrying the watermark a bounded-out-of-orderness strategy would
+// emit — the largest event time seen minus the allowed lateness minus one
+// millisecond, as Flink's BoundedOutOfOrdernessWatermarks computes it — and
+// a final watermark at end of input, like Flink's Long.MAX_VALUE watermark
+// or Beam TestStream's advanceWatermarkToInfinity. Since record timestamps
+// are not ordered by index, records behind the current watermark are late
+// data, and the same range always yields the same watermarks, so window and
+// trigger logic can be tested deterministically.
+
+// Watermark is a watermark control message.
+type Watermark struct {
+	Type string `json:"type"`
+	// TimestampMillis is the watermark in epoch milliseconds, the unit of
+	// Flink and Beam; Final watermarks carry the largest int64.
+	TimestampMillis int64  `json:"timestampMillis"`
+	Timestamp       string `json:"timestamp,omitempty"`
+	// AfterRecord is the index of the last record the watermark follows.
+	AfterRecord uint64 `json:"afterRecord"`
+	Final       bool   `json:"final,omitempty"`
+}
+
+// watermarkFlags are the -watermark-every and -lateness flags of streaming
+// sinks.
+type watermarkFlags struct {
+	every    uint64
+	lateness time.Duration
+}
+
+func addWatermarkFlags(fs *flag.FlagSet) *watermarkFlags {
+	w := &watermarkFlags{}
+	fs.Uint64Var(&w.every, "watermark-every", 0, "emit a watermark control message after every N records, and a final one at the end; 0 emits none")
+	fs.DurationVar(&w.lateness, "lateness", time.Hour, "allowed out-of-orderness the watermark trails the largest event time by")
+	return w
+}
+
+func (w *watermarkFlags) validate() error {
+	if w.lateness < 0 {
+		return errors.New("-lateness must not be negative")
+	}
+	return nil
+}
+
+// watermarker tracks the watermark of a stream of records.
+type watermarker struct {
+	every    uint64
+	lateness time.Duration
+	seen     uint64
+	maxTs    time.Time
+}
+
+// newWatermarker returns nil, which emits nothing, when every is 0.
+func newWatermarker(every uint64, lateness time.Duration) *watermarker {
+	if every == 0 {
+		return nil
+	}
+	return &watermarker{every: every, lateness: lateness}
+}
+
+// observe takes the next record and returns the watermark to emit after
+// it, if one is due.
+func (w *watermarker) observe(rec *RawRecord) (Watermark, bool) {
+	if w == nil {
+		return Watermark{}, false
+	}
+	if ts := eventTime(rec); ts.After(w.maxTs) {
+		w.maxTs = ts
+	}
+	w.seen++
+	if w.seen%w.every != 0 {
+		return Watermark{}, false
+	}
+	at := w.maxTs.Add(-w.lateness - time.Millisecond).UTC()
+	return Watermark{Type: "watermark", TimestampMillis: at.UnixMilli(), Timestamp: at.Format(time.RFC3339Nano), AfterRecord: rec.RecordIndex}, true
+}
+
+// final returns the end-of-input watermark.
+func (w *watermarker) final(last uint64) (Watermark, bool) {
+	if w == nil {
+		return Watermark{}, false
+	}
+	return Watermark{Type: "watermark", TimestampMillis: 1<<63 - 1, AfterRecord: last, Final: true}, true
+}
+
+// eventTime is the event time of rec.
+func eventTime(rec *RawRecord) time.Time {
+	ts, _ := time.Parse(time.RFC3339, rec.Timestamp)
+	return ts
+}
+
+// eventTimeHeaders are the per-record message headers naming its event
+// time, in epoch milliseconds and as text.
+func eventTimeHeaders(rec *RawRecord) []string {
+	ts := eventTime(rec)
+	return []string{"event-time", strconv.FormatInt(ts.UnixMilli(), 10), "event-time-iso", rec.Timestamp, "record-index", strconv.FormatUint(rec.RecordIndex, 10)}
+}
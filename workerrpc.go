@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// WorkerProtocol is the coordinator's gRPC service for generation agents,
+// defined in worker.proto. The tree has no protoc step: the service is
+// registered from a hand-written descriptor and its messages encode
+// themselves with protowire, matching the field numbers in worker.proto, so
+// clients generated from that file talk to it unchanged.
+type WorkerProtocol struct {
+	c *Coordinator
+}
+
+const workerService = "idempotententries.worker.v1.WorkerProtocol"
+
+type AssignRangeRequest struct {
+	Worker string
+}
+
+// AssignRangeResponse carries either an assignment or one of the Wait/Done
+// signals; a worker told to wait should ask again shortly.
+type AssignRangeResponse struct {
+	Assignment *Assignment
+	Wait       bool
+	Done       bool
+}
+
+type ReportProgressRequest struct {
+	Worker  string
+	RangeID int
+	Written uint64
+	Failure string
+}
+
+type UploadManifestRequest struct {
+	Worker  string
+	RangeID int
+	File    ManifestFile
+}
+
+type Empty struct{}
+
+func (p *WorkerProtocol) AssignRange(_ context.Context, req *AssignRangeRequest) (*AssignRangeResponse, error) {
+	if req.Worker == "" {
+		return nil, status.Error(codes.InvalidArgument, "worker id is required")
+	}
+	a, err := p.c.Lease(req.Worker)
+	switch {
+	case errors.Is(err, errNoWork):
+		return &AssignRangeResponse{Wait: true}, nil
+	case errors.Is(err, errAllDone):
+		return &AssignRangeResponse{Done: true}, nil
+	case err != nil:
+		return nil, err
+	}
+	return &AssignRangeResponse{Assignment: &a}, nil
+}
+
+func (p *WorkerProtocol) ReportProgress(_ context.Context, req *ReportProgressRequest) (*Empty, error) {
+	if req.Failure != "" {
+		return &Empty{}, reportStatus(p.c.Fail(req.Worker, req.RangeID, req.Failure))
+	}
+	return &Empty{}, reportStatus(p.c.Progress(req.Worker, req.RangeID, req.Written))
+}
+
+func (p *WorkerProtocol) UploadManifest(_ context.Context, req *UploadManifestRequest) (*Empty, error) {
+	return &Empty{}, reportStatus(p.c.Complete(req.Worker, req.RangeID, req.File))
+}
+
+// reportStatus maps a coordinator error to the status the HTTP API's
+// status code stands for.
+func reportStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errLeaseLost):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// workerProtocolServer is the handler type grpc checks WorkerProtocol
+// against at registration.
+type workerProtocolServer interface {
+	AssignRange(context.Context, *AssignRangeRequest) (*AssignRangeResponse, error)
+	ReportProgress(context.Context, *ReportProgressRequest) (*Empty, error)
+	UploadManifest(context.Context, *UploadManifestRequest) (*Empty, error)
+}
+
+var workerServiceDesc = grpc.ServiceDesc{
+	ServiceName: workerService,
+	HandlerType: (*workerProtocolServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("AssignRange", (*WorkerProtocol).AssignRange),
+		unaryMethod("ReportProgress", (*WorkerProtocol).ReportProgress),
+		unaryMethod("UploadManifest", (*WorkerProtocol).UploadManifest),
+	},
+	Metadata: "worker.proto",
+}
+
+// unaryMethod adapts a WorkerProtocol method to grpc's untyped handler.
+func unaryMethod[Req, Resp any](name string, call func(*WorkerProtocol, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			p := srv.(*WorkerProtocol)
+			if interceptor == nil {
+				return call(p, ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + workerService + "/" + name}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return call(p, ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+// serveWorkerGRPC serves the worker protocol on ln until the returned
+// server is stopped.
+func serveWorkerGRPC(c *Coordinator, ln net.Listener) *grpc.Server {
+	srv := grpc.NewServer(grpc.ForceServerCodec(wireCodec{}))
+	srv.RegisterService(&workerServiceDesc, &WorkerProtocol{c: c})
+	go srv.Serve(ln)
+	return srv
+}
+
+type grpcCoordinatorClient struct {
+	conn   *grpc.ClientConn
+	worker string
+}
+
+func dialGRPCCoordinator(addr, worker string) (*grpcCoordinatorClient, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcCoordinatorClient{conn: conn, worker: worker}, nil
+}
+
+func (c *grpcCoordinatorClient) invoke(method string, req, resp any) error {
+	return c.conn.Invoke(context.Background(), "/"+workerService+"/"+method, req, resp)
+}
+
+func (c *grpcCoordinatorClient) AssignRange() (Assignment, error) {
+	var resp AssignRangeResponse
+	if err := c.invoke("AssignRange", &AssignRangeRequest{Worker: c.worker}, &resp); err != nil {
+		return Assignment{}, err
+	}
+	switch {
+	case resp.Done:
+		return Assignment{}, errAllDone
+	case resp.Wait || resp.Assignment == nil:
+		return Assignment{}, errNoWork
+	}
+	return *resp.Assignment, nil
+}
+
+func (c *grpcCoordinatorClient) ReportProgress(rangeID int, written uint64, failure string) error {
+	req := &ReportProgressRequest{Worker: c.worker, RangeID: rangeID, Written: written, Failure: failure}
+	return c.invoke("ReportProgress", req, &Empty{})
+}
+
+func (c *grpcCoordinatorClient) UploadManifest(rangeID int, file ManifestFile) error {
+	req := &UploadManifestRequest{Worker: c.worker, RangeID: rangeID, File: file}
+	return c.invoke("UploadManifest", req, &Empty{})
+}
+
+// wireMessage is a worker.proto message in Go.
+type wireMessage interface {
+	appendWire(b []byte) []byte
+	readWire(b []byte) error
+}
+
+// wireCodec is the "proto" codec for wireMessages.
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return "proto" }
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a worker protocol message", v)
+	}
+	return m.appendWire(nil), nil
+}
+
+func (wireCodec) Unmarshal(b []byte, v any) error {
+	m, ok := v.(wireMessage)
+	if !ok {
+		return fmt.Errorf("%T is not a worker protocol message", v)
+	}
+	return m.readWire(b)
+}
+
+// Appenders leave out proto3 default values, as generated code does.
+
+func appendVarintField(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), v)
+}
+
+func appendBoolField(b []byte, num protowire.Number, v bool) []byte {
+	return appendVarintField(b, num, protowire.EncodeBool(v))
+}
+
+func appendStringField(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return protowire.AppendString(protowire.AppendTag(b, num, protowire.BytesType), s)
+}
+
+func appendMessageField(b []byte, num protowire.Number, m []byte) []byte {
+	return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), m)
+}
+
+// wireReader walks the fields of an encoded message. Fields of an
+// unexpected wire type are skipped like unknown ones.
+type wireReader struct {
+	b   []byte
+	num protowire.Number
+	typ protowire.Type
+	err error
+}
+
+func (r *wireReader) next() bool {
+	if r.err != nil || len(r.b) == 0 {
+		return false
+	}
+	num, typ, n := protowire.ConsumeTag(r.b)
+	r.num, r.typ = num, typ
+	return r.advance(n)
+}
+
+func (r *wireReader) advance(n int) bool {
+	if n < 0 {
+		r.err, r.b = protowire.ParseError(n), nil
+		return false
+	}
+	r.b = r.b[n:]
+	return true
+}
+
+func (r *wireReader) skip() {
+	r.advance(protowire.ConsumeFieldValue(r.num, r.typ, r.b))
+}
+
+func (r *wireReader) varint() uint64 {
+	if r.typ != protowire.VarintType {
+		r.skip()
+		return 0
+	}
+	v, n := protowire.ConsumeVarint(r.b)
+	r.advance(n)
+	return v
+}
+
+func (r *wireReader) bytes() []byte {
+	if r.typ != protowire.BytesType {
+		r.skip()
+		return nil
+	}
+	v, n := protowire.ConsumeBytes(r.b)
+	r.advance(n)
+	return v
+}
+
+func (m *AssignRangeRequest) appendWire(b []byte) []byte {
+	return appendStringField(b, 1, m.Worker)
+}
+
+func (m *AssignRangeRequest) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			m.Worker = string(r.bytes())
+		default:
+			r.skip()
+		}
+	}
+	return r.err
+}
+
+func (a *Assignment) appendWire(b []byte) []byte {
+	config, _ := json.Marshal(a.Config)
+	b = appendVarintField(b, 1, uint64(a.RangeID))
+	b = appendVarintField(b, 2, a.Start)
+	b = appendVarintField(b, 3, a.Count)
+	b = appendStringField(b, 4, a.ConfigHash)
+	b = appendStringField(b, 5, string(config))
+	if !a.LeaseUntil.IsZero() {
+		var ts []byte
+		ts = appendVarintField(ts, 1, uint64(a.LeaseUntil.Unix()))
+		ts = appendVarintField(ts, 2, uint64(a.LeaseUntil.Nanosecond()))
+		b = appendMessageField(b, 6, ts)
+	}
+	return b
+}
+
+func (a *Assignment) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			a.RangeID = int(int64(r.varint()))
+		case 2:
+			a.Start = r.varint()
+		case 3:
+			a.Count = r.varint()
+		case 4:
+			a.ConfigHash = string(r.bytes())
+		case 5:
+			if err := json.Unmarshal(r.bytes(), &a.Config); err != nil {
+				return fmt.Errorf("assignment config: %w", err)
+			}
+		case 6:
+			var sec, nsec uint64
+			ts := wireReader{b: r.bytes()}
+			for ts.next() {
+				switch ts.num {
+				case 1:
+					sec = ts.varint()
+				case 2:
+					nsec = ts.varint()
+				default:
+					ts.skip()
+				}
+			}
+			if ts.err != nil {
+				return ts.err
+			}
+			a.LeaseUntil = time.Unix(int64(sec), int64(int32(nsec)))
+		default:
+			r.skip()
+		}
+	}
+	return r.err
+}
+
+func (m *AssignRangeResponse) appendWire(b []byte) []byte {
+	if m.Assignment != nil {
+		b = appendMessageField(b, 1, m.Assignment.appendWire(nil))
+	}
+	b = appendBoolField(b, 2, m.Wait)
+	return appendBoolField(b, 3, m.Done)
+}
+
+func (m *AssignRangeResponse) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			m.Assignment = new(Assignment)
+			if err := m.Assignment.readWire(r.bytes()); err != nil {
+				return err
+			}
+		case 2:
+			m.Wait = r.varint() != 0
+		case 3:
+			m.Done = r.varint() != 0
+		default:
+			r.skip()
+		}
+	}
+	return r.err
+}
+
+func (m *ReportProgressRequest) appendWire(b []byte) []byte {
+	b = appendStringField(b, 1, m.Worker)
+	b = appendVarintField(b, 2, uint64(m.RangeID))
+	b = appendVarintField(b, 3, m.Written)
+	return appendStringField(b, 4, m.Failure)
+}
+
+func (m *ReportProgressRequest) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			m.Worker = string(r.bytes())
+		case 2:
+			m.RangeID = int(int64(r.varint()))
+		case 3:
+			m.Written = r.varint()
+		case 4:
+			m.Failure = string(r.bytes())
+		default:
+			r.skip()
+		}
+	}
+	return r.err
+}
+
+func (f *ManifestFile) appendWire(b []byte) []byte {
+	b = appendStringField(b, 1, f.Path)
+	b = appendStringField(b, 2, f.Worker)
+	b = appendVarintField(b, 3, f.Start)
+	b = appendVarintField(b, 4, f.Count)
+	b = appendVarintField(b, 5, uint64(f.Bytes))
+	return appendStringField(b, 6, f.SHA256)
+}
+
+func (f *ManifestFile) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			f.Path = string(r.bytes())
+		case 2:
+			f.Worker = string(r.bytes())
+		case 3:
+			f.Start = r.varint()
+		case 4:
+			f.Count = r.varint()
+		case 5:
+			f.Bytes = int64(r.varint())
+		case 6:
+			f.SHA256 = string(r.bytes())
+		default:
+			r.skip()
+		}
+	}
+	return r.err
+}
+
+func (m *UploadManifestRequest) appendWire(b []byte) []byte {
+	b = appendStringField(b, 1, m.Worker)
+	b = appendVarintField(b, 2, uint64(m.RangeID))
+	return appendMessageField(b, 3, m.File.appendWire(nil))
+}
+
+func (m *UploadManifestRequest) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			m.Worker = string(r.bytes())
+		case 2:
+			m.RangeID = int(int64(r.varint()))
+		case 3:
+			if err := m.File.readWire(r.bytes()); err != nil {
+				return err
+			}
+		default:
+			r.skip()
+		}
+	}
+	return r.err
+}
+
+func (*Empty) appendWire(b []byte) []byte { return b }
+
+func (*Empty) readWire(b []byte) error {
+	r := wireReader{b: b}
+	for r.next() {
+		r.skip()
+	}
+	return r.err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWorkerGRPC(t *testing.T) {
+	cfg := cloneConfig(defaultConfig)
+	coord := NewCoordinator(cfg, 100, 250, 200, time.Minute)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serveWorkerGRPC(coord, ln)
+	defer srv.Stop()
+
+	a1, _ := dialGRPCCoordinator(ln.Addr().String(), "w1")
+	a2, _ := dialGRPCCoordinator(ln.Addr().String(), "w2")
+	first, err := a1.AssignRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Start != 100 || first.Count != 200 || first.ConfigHash != configHash(cfg) || configHash(first.Config) != first.ConfigHash {
+		t.Errorf("first assignment is [%d,+%d) with hash %s, config hashing to %s", first.Start, first.Count, first.ConfigHash, configHash(first.Config))
+	}
+	if time.Until(first.LeaseUntil) < 50*time.Second {
+		t.Errorf("lease runs until %v", first.LeaseUntil)
+	}
+	second, err := a2.AssignRange()
+	if err != nil || second.Start != 300 || second.Count != 50 {
+		t.Fatalf("second assignment is [%d,+%d), %v", second.Start, second.Count, err)
+	}
+	if _, err := a2.AssignRange(); !errors.Is(err, errNoWork) {
+		t.Errorf("with every range leased AssignRange = %v, want errNoWork", err)
+	}
+
+	if err := a1.ReportProgress(first.RangeID, 120, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := a2.ReportProgress(first.RangeID, 10, ""); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("progress on another worker's range: %v, want FailedPrecondition", err)
+	}
+	for _, step := range []struct {
+		client *grpcCoordinatorClient
+		a      Assignment
+	}{{a1, first}, {a2, second}} {
+		file := ManifestFile{Path: "part", Start: step.a.Start, Count: step.a.Count, Bytes: 1234, SHA256: "abc"}
+		if err := step.client.UploadManifest(step.a.RangeID, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-coord.Done():
+	default:
+		t.Fatal("coordinator is not done after every manifest was uploaded")
+	}
+	if _, err := a1.AssignRange(); !errors.Is(err, errAllDone) {
+		t.Errorf("after completion AssignRange = %v, want errAllDone", err)
+	}
+	if got := coord.Manifest().Files; len(got) != 2 || got[1].Worker != "w2" || got[1].Bytes != 1234 {
+		t.Errorf("manifest files are %+v", got)
+	}
+}
+
+// TestWorkerWireFormat pins the encoding to worker.proto's field numbers,
+// which clients generated from it rely on.
+func TestWorkerWireFormat(t *testing.T) {
+	req := &ReportProgressRequest{Worker: "w", RangeID: 2, Written: 300}
+	want := []byte{0x0a, 0x01, 'w', 0x10, 0x02, 0x18, 0xac, 0x02}
+	if got := req.appendWire(nil); !bytes.Equal(got, want) {
+		t.Errorf("ReportProgressRequest encodes as % x, want % x", got, want)
+	}
+	// An unknown field from a newer client is skipped.
+	var back ReportProgressRequest
+	if err := back.readWire(append(want, 0x28, 0x07)); err != nil || back != *req {
+		t.Errorf("decoded %+v (%v), want %+v", back, err, *req)
+	}
+}